@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"simple-service/internal/cli"
+	"simple-service/internal/config"
+)
+
+// runConfigPrint - итоговая конфигурация со скрытыми секретами и источником каждого значения
+func runConfigPrint(c *cli.Command, args []string) error {
+	fs := c.FlagSet()
+	loader := config.NewLoader(fs)
+	if err := cli.ParseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return cli.UsageErrorf(c, "unexpected arguments: %v", fs.Args())
+	}
+
+	loaded, err := loader.Load()
+	if err != nil {
+		return errors.Wrap(err, "failed to load configuration")
+	}
+
+	return config.Print(os.Stdout, loaded)
+}
+
+// runConfigValidate - проверка конфигурации без запуска сервера, для CI и деплоя
+func runConfigValidate(c *cli.Command, args []string) error {
+	fs := c.FlagSet()
+	loader := config.NewLoader(fs)
+	if err := cli.ParseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return cli.UsageErrorf(c, "unexpected arguments: %v", fs.Args())
+	}
+
+	loaded, err := loader.Load()
+	if err != nil {
+		var invalid *config.ValidationError
+		if errors.As(err, &invalid) {
+			return cli.CheckFailedf("%s", invalid.Error())
+		}
+		return errors.Wrap(err, "failed to load configuration")
+	}
+
+	for _, key := range loaded.UnknownKeys {
+		fmt.Printf("warning: unknown key in config file %s: %s\n", loaded.File, key)
+	}
+	fmt.Println("Configuration is valid")
+
+	return nil
+}
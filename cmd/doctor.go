@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"simple-service/internal/cli"
+	"simple-service/internal/config"
+	"simple-service/internal/migrations"
+	"simple-service/internal/repo"
+)
+
+// Результат отдельной проверки doctor
+const (
+	checkOK   = "ok"
+	checkWarn = "warn"
+	checkFail = "FAIL"
+)
+
+type checkResult struct {
+	name   string
+	status string
+	detail string
+}
+
+// runDoctor - диагностика окружения: конфигурация, БД, миграции, расхождение часов с БД
+func runDoctor(c *cli.Command, args []string) error {
+	fs := c.FlagSet()
+	maxSkew := fs.Duration("max-clock-skew", 5*time.Second, "maximum allowed clock difference with the database")
+	timeout := fs.Duration("timeout", 10*time.Second, "timeout for database checks")
+	loader := config.NewLoader(fs)
+	if err := cli.ParseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return cli.UsageErrorf(c, "unexpected arguments: %v", fs.Args())
+	}
+
+	results := doctorChecks(loader, *maxSkew, *timeout)
+	if err := printChecks(os.Stdout, results); err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.status == checkFail {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return cli.CheckFailedf("%d of %d checks failed", failed, len(results))
+	}
+
+	return nil
+}
+
+// doctorChecks - проверки по порядку, следующие пропускаются, если без предыдущей они бессмысленны
+func doctorChecks(loader *config.Loader, maxSkew, timeout time.Duration) []checkResult {
+	var results []checkResult
+	add := func(name, status, format string, args ...any) {
+		results = append(results, checkResult{name: name, status: status, detail: fmt.Sprintf(format, args...)})
+	}
+
+	loaded, err := loader.Load()
+	if err != nil {
+		add("config", checkFail, "%v", err)
+		return results
+	}
+	switch {
+	case len(loaded.UnknownKeys) > 0:
+		add("config", checkWarn, "unknown keys in %s: %v", loaded.File, loaded.UnknownKeys)
+	case loaded.File != "":
+		add("config", checkOK, "loaded from %s", loaded.File)
+	default:
+		add("config", checkOK, "loaded from environment")
+	}
+	cfg := loaded.Config
+
+	if !cfg.UsesPostgres() {
+		add("storage", checkWarn, "%s storage, tasks are lost on restart; database checks skipped", cfg.Storage.Backend)
+		return results
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	repository, err := repo.NewRepository(ctx, cfg.PostgreSQL, nil)
+	if err != nil {
+		add("database", checkFail, "%v", err)
+		return results
+	}
+	defer repository.Close()
+	pool := repository.Pool()
+
+	var version string
+	if err := pool.QueryRow(ctx, "SHOW server_version").Scan(&version); err != nil {
+		add("database", checkFail, "cannot connect to %s:%d/%s: %v", cfg.PostgreSQL.Host, cfg.PostgreSQL.Port, cfg.PostgreSQL.Name, err)
+		return results
+	}
+	add("database", checkOK, "connected to %s:%d/%s, PostgreSQL %s", cfg.PostgreSQL.Host, cfg.PostgreSQL.Port, cfg.PostgreSQL.Name, version)
+
+	results = append(results, migrationsCheck(ctx, pool), clockCheck(ctx, pool, maxSkew))
+
+	diffs, err := checkSchema(ctx, pool)
+	switch {
+	case err != nil:
+		add("schema", checkFail, "%v", err)
+	case len(diffs) > 0:
+		add("schema", checkWarn, "%d differences with the snapshot, run `schema check` for a report", len(diffs))
+	default:
+		add("schema", checkOK, "matches the snapshot")
+	}
+
+	return results
+}
+
+func migrationsCheck(ctx context.Context, pool *pgxpool.Pool) checkResult {
+	result := checkResult{name: "migrations"}
+
+	migrator, err := migrations.NewMigrator(pool, migrations.FS, zap.NewNop().Sugar())
+	if err != nil {
+		result.status, result.detail = checkFail, err.Error()
+		return result
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		result.status, result.detail = checkFail, err.Error()
+		return result
+	}
+
+	var applied, pending, modified int
+	for _, s := range statuses {
+		switch {
+		case !s.Applied:
+			pending++
+		case s.Checksum == migrations.ChecksumModified:
+			modified++
+			applied++
+		default:
+			applied++
+		}
+	}
+
+	switch {
+	case modified > 0:
+		result.status, result.detail = checkFail, fmt.Sprintf("%d applied migrations were modified, run `migrate status`", modified)
+	case pending > 0:
+		result.status, result.detail = checkFail, fmt.Sprintf("%d applied, %d pending, run `migrate up`", applied, pending)
+	default:
+		result.status, result.detail = checkOK, fmt.Sprintf("%d applied, schema is up to date", applied)
+	}
+
+	return result
+}
+
+// clockCheck - разница часов хоста и БД, важна для exp/iat токенов и временных меток задач
+func clockCheck(ctx context.Context, pool *pgxpool.Pool, maxSkew time.Duration) checkResult {
+	result := checkResult{name: "clock"}
+
+	before := time.Now()
+	var dbNow time.Time
+	if err := pool.QueryRow(ctx, "SELECT now()").Scan(&dbNow); err != nil {
+		result.status, result.detail = checkFail, err.Error()
+		return result
+	}
+	after := time.Now()
+
+	// Время БД сравниваем с серединой запроса, чтобы не учитывать сетевую задержку
+	local := before.Add(after.Sub(before) / 2)
+	skew := dbNow.Sub(local)
+	if skew < 0 {
+		skew = -skew
+	}
+
+	if skew > maxSkew {
+		result.status, result.detail = checkFail, fmt.Sprintf("skew with database is %s, allowed %s", skew.Round(time.Millisecond), maxSkew)
+	} else {
+		result.status, result.detail = checkOK, fmt.Sprintf("skew with database is %s", skew.Round(time.Millisecond))
+	}
+
+	return result
+}
+
+func printChecks(w io.Writer, results []checkResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.status, r.name, r.detail)
+	}
+	return tw.Flush()
+}
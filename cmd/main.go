@@ -1,25 +1,11 @@
 package main
 
 import (
-	"context"
-	"log"
 	"os"
-	"os/signal"
-	"syscall"
-	"time"
-
-	"github.com/joho/godotenv"
-	"github.com/kelseyhightower/envconfig"
-	"github.com/pkg/errors"
-
-	"simple-service/internal/api"
-	"simple-service/internal/config"
-	customLogger "simple-service/internal/logger"
-	"simple-service/internal/migrations"
-	"simple-service/internal/repo"
-	"simple-service/internal/service"
 
 	_ "simple-service/docs" // docs is generated by Swag CLI, you have to import it.
+
+	"simple-service/internal/cli"
 )
 
 // @title Simple Service API
@@ -28,65 +14,76 @@ import (
 // @host localhost:8080
 // @BasePath /
 
-func main() {
-	// Загружаем env файл, если он существует (опционально)
-	if err := godotenv.Load(config.EnvPath); err != nil {
-		log.Printf("Env файл не найден или не может быть загружен: %v (используем переменные окружения)", err)
-	}
-
-	// Загружаем конфигурацию из переменных окружения
-	var cfg config.AppConfig
-	if err := envconfig.Process("", &cfg); err != nil {
-		log.Fatal(errors.Wrap(err, "failed to load configuration"))
-	}
-
-	// Инициализация логгера
-	logger, err := customLogger.NewLogger(cfg.LogLevel)
-	if err != nil {
-		log.Fatal(errors.Wrap(err, "error initializing logger"))
-	}
-
-	// Подключение к PostgreSQL
-	repository, err := repo.NewRepository(context.Background(), cfg.PostgreSQL)
-	if err != nil {
-		log.Fatal(errors.Wrap(err, "failed to initialize repository"))
-	}
-
-	// Применяем миграции
-	if err := migrations.RunMigrations(context.Background(), repository.Pool(), logger); err != nil {
-		log.Fatal(errors.Wrap(err, "failed to run migrations"))
-	}
-
-	// Создание сервиса с бизнес-логикой
-	serviceInstance := service.NewService(repository, logger)
-
-	// Инициализация API
-	app := api.NewRouters(&api.Routers{Service: serviceInstance, Logger: logger}, cfg.Rest.Token)
+const binaryName = "simple-service"
 
-	// Запуск HTTP-сервера в отдельной горутине
-	go func() {
-		logger.Infof("Starting server on %s", cfg.Rest.ListenAddress)
-		if err := app.Listen(cfg.Rest.ListenAddress); err != nil {
-			log.Fatal(errors.Wrap(err, "failed to start server"))
-		}
-	}()
-
-	// Ожидание системных сигналов для корректного завершения работы
-	signalChan := make(chan os.Signal, 1)
-	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
-	<-signalChan
-
-	logger.Info("Shutting down gracefully...")
-
-	// Graceful shutdown сервера
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+func main() {
+	os.Exit(cli.Execute(newRootCommand(), os.Args[1:], os.Stderr))
+}
 
-	if err := app.ShutdownWithContext(ctx); err != nil {
-		logger.Errorf("Server shutdown error: %v", err)
+// newRootCommand - дерево команд бинарника. Справка по командам собирается из этих описаний
+func newRootCommand() *cli.Command {
+	root := &cli.Command{
+		Name:    binaryName,
+		Summary: "Simple Service - REST API for tasks. Without a command the server is started.",
+		Default: "serve",
+		Subcommands: []*cli.Command{
+			{
+				Name:    "serve",
+				Args:    "",
+				Summary: "Start the HTTP server",
+				Run:     runServe,
+			},
+			{
+				Name:    "migrate",
+				Summary: "Manage database schema with embedded migrations",
+				Subcommands: []*cli.Command{
+					{Name: "up", Args: "[N]", Summary: "Apply N pending migrations (all by default)", Run: runMigrate},
+					{Name: "down", Args: "[N]", Summary: "Roll back N last applied migrations (1 by default)", Run: runMigrate},
+					{Name: "status", Summary: "Show applied and pending migrations", Run: runMigrate},
+					{Name: "redo", Summary: "Roll back and re-apply the last migration", Run: runMigrate},
+					{Name: "goto", Args: "VERSION", Summary: "Migrate up or down to VERSION (0 rolls back everything)", Run: runMigrate},
+					{Name: "create", Args: "NAME", Summary: "Create numbered up/down SQL files", Run: runMigrateCreate},
+				},
+			},
+			{
+				Name:    "schema",
+				Summary: "Detect drift between the database and the expected schema",
+				Subcommands: []*cli.Command{
+					{Name: "check", Summary: "Compare database schema with the snapshot", Run: runSchemaCheck,
+						Description: "Compare database schema with the embedded snapshot. Exits with code 3 on drift."},
+					{Name: "snapshot", Summary: "Write the current database schema as the new snapshot", Run: runSchemaSnapshot},
+				},
+			},
+			{
+				Name:    "config",
+				Summary: "Inspect configuration",
+				Subcommands: []*cli.Command{
+					{Name: "print", Summary: "Print effective configuration with sources, secrets are masked", Run: runConfigPrint},
+					{Name: "validate", Summary: "Validate configuration, exits with code 3 if it is invalid", Run: runConfigValidate},
+				},
+			},
+			{
+				Name:    "token",
+				Summary: "Work with API tokens",
+				Subcommands: []*cli.Command{
+					{Name: "mint", Summary: "Sign a development JWT with the configured secret", Run: runTokenMint},
+				},
+			},
+			{
+				Name:    "seed",
+				Summary: "Insert sample tasks for local development",
+				Run:     runSeed,
+			},
+			{
+				Name:    "doctor",
+				Summary: "Check environment, database connectivity, migrations and clock skew",
+				Run:     runDoctor,
+				Description: "Check configuration, database connectivity, migration state and clock skew\n" +
+					"between this host and the database. Exits with code 3 if any check fails.",
+			},
+			cli.HelpCommand(),
+		},
 	}
 
-	// Закрытие пула соединений с БД
-	repository.Close()
-	logger.Info("Server stopped gracefully")
+	return root.Link()
 }
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"simple-service/internal/cli"
+)
+
+func TestCommandTree(t *testing.T) {
+	root := newRootCommand()
+
+	// У каждой команды есть описание, у каждого листа - обработчик
+	var walk func(c *cli.Command)
+	walk = func(c *cli.Command) {
+		assert.NotEmpty(t, c.Summary, c.Path())
+		if len(c.Subcommands) == 0 {
+			assert.NotNil(t, c.Run, c.Path())
+		}
+		for _, sub := range c.Subcommands {
+			walk(sub)
+		}
+	}
+	walk(root)
+
+	c, rest := cli.Resolve(root, []string{"migrate", "goto", "3"})
+	assert.Equal(t, "simple-service migrate goto", c.Path())
+	assert.Equal(t, []string{"3"}, rest)
+}
+
+func TestMintToken(t *testing.T) {
+	now := time.Now()
+
+	token, err := mintToken("secret", "alice", splitScopes("tasks:read, tasks:write"), time.Hour, now)
+	require.NoError(t, err)
+
+	parsed, err := jwt.Parse(token, func(*jwt.Token) (interface{}, error) { return []byte("secret"), nil })
+	require.NoError(t, err)
+
+	claims := parsed.Claims.(jwt.MapClaims)
+	assert.Equal(t, "alice", claims["sub"])
+	assert.Equal(t, "tasks:read tasks:write", claims["scope"])
+	assert.Equal(t, float64(now.Add(time.Hour).Unix()), claims["exp"])
+
+	_, err = jwt.Parse(token, func(*jwt.Token) (interface{}, error) { return []byte("other"), nil })
+	assert.Error(t, err)
+}
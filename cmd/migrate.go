@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+
+	"simple-service/internal/cli"
+	"simple-service/internal/config"
+	"simple-service/internal/migrations"
+	"simple-service/internal/repo"
+)
+
+// defaultMigrationsDir - каталог с файлами миграций в репозитории
+const defaultMigrationsDir = "internal/migrations/postgres"
+
+// runMigrate - команды migrate, работающие с БД: имя команды определяет действие
+func runMigrate(c *cli.Command, args []string) error {
+	fs := c.FlagSet()
+	loader := config.NewLoader(fs)
+	if err := cli.ParseFlags(fs, args); err != nil {
+		return err
+	}
+
+	run, err := migrateCommand(c, fs.Args())
+	if err != nil {
+		return err
+	}
+
+	cfg, logger, err := setup(loader)
+	if err != nil {
+		return err
+	}
+	if err := requirePostgres(cfg); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	repository, err := repo.NewRepository(ctx, cfg.PostgreSQL, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to initialize repository")
+	}
+	defer repository.Close()
+
+	migrator, err := migrations.NewMigrator(repository.Pool(), migrations.FS, logger)
+	if err != nil {
+		return err
+	}
+	migrator.SetLockTimeout(cfg.Migrations.LockTimeout)
+
+	return run(ctx, migrator)
+}
+
+// migrateCommand - разбор аргументов команды до подключения к БД
+func migrateCommand(c *cli.Command, args []string) (func(context.Context, *migrations.Migrator) error, error) {
+	switch c.Name {
+	case "up":
+		n, err := countArg(c, args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context, m *migrations.Migrator) error { return m.Up(ctx, n) }, nil
+
+	case "down":
+		n, err := countArg(c, args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context, m *migrations.Migrator) error { return m.Down(ctx, n) }, nil
+
+	case "redo":
+		if len(args) > 0 {
+			return nil, cli.UsageErrorf(c, "unexpected arguments: %v", args)
+		}
+		return func(ctx context.Context, m *migrations.Migrator) error { return m.Redo(ctx) }, nil
+
+	case "goto":
+		if len(args) != 1 {
+			return nil, cli.UsageErrorf(c, "exactly one VERSION is required")
+		}
+		version, err := strconv.Atoi(args[0])
+		if err != nil || version < 0 {
+			return nil, cli.UsageErrorf(c, "invalid version %q", args[0])
+		}
+		return func(ctx context.Context, m *migrations.Migrator) error { return m.Goto(ctx, version) }, nil
+
+	case "status":
+		if len(args) > 0 {
+			return nil, cli.UsageErrorf(c, "unexpected arguments: %v", args)
+		}
+		return printStatus, nil
+	}
+
+	return nil, errors.Errorf("unknown migrate command %q", c.Name)
+}
+
+// countArg - необязательное положительное число миграций
+func countArg(c *cli.Command, args []string, def int) (int, error) {
+	switch len(args) {
+	case 0:
+		return def, nil
+	case 1:
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			return 0, cli.UsageErrorf(c, "invalid number of migrations %q", args[0])
+		}
+		return n, nil
+	}
+
+	return 0, cli.UsageErrorf(c, "unexpected arguments: %v", args[1:])
+}
+
+func printStatus(ctx context.Context, m *migrations.Migrator) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT\tCHECKSUM")
+	for _, s := range statuses {
+		state, appliedAt := "pending", "-"
+		if s.Applied {
+			state, appliedAt = "applied", s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		checksum := s.Checksum
+		if checksum == "" {
+			checksum = "-"
+		}
+		fmt.Fprintf(w, "%06d\t%s\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt, checksum)
+	}
+
+	return w.Flush()
+}
+
+// runMigrateCreate - заготовка новой миграции, подключение к БД не нужно
+func runMigrateCreate(c *cli.Command, args []string) error {
+	fs := c.FlagSet()
+	dir := fs.String("dir", defaultMigrationsDir, "directory with migration files")
+	if err := cli.ParseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return cli.UsageErrorf(c, "exactly one NAME is required")
+	}
+
+	up, down, err := migrations.Create(*dir, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Created", up)
+	fmt.Println("Created", down)
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"simple-service/internal/api"
+	"simple-service/internal/config"
+	"simple-service/internal/metrics"
+)
+
+// reloader - повторное чтение конфигурации и применение параметров, не требующих перезапуска
+type reloader struct {
+	mu       sync.Mutex
+	loader   *config.Loader
+	current  *config.AppConfig
+	level    zap.AtomicLevel
+	settings *api.Settings
+	logger   *zap.SugaredLogger
+}
+
+// Current - конфигурация, с которой сейчас работает сервис
+func (r *reloader) Current() *config.AppConfig {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Reload - перечитывает конфигурацию; при ошибке продолжает работать со старой
+func (r *reloader) Reload(trigger string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	loaded, err := r.loader.Load()
+	if err != nil {
+		metrics.ConfigReloads.WithLabelValues("failure").Inc()
+		r.logger.Errorw("Config reload failed, keeping current configuration", "trigger", trigger, "error", err)
+		return
+	}
+
+	for _, key := range loaded.UnknownKeys {
+		r.logger.Warnw("Unknown key in config file", "file", loaded.File, "key", key)
+	}
+
+	var applied, restartRequired []string
+	for _, change := range config.Diff(r.current, loaded.Config) {
+		if change.Reloadable {
+			applied = append(applied, change.Key)
+		} else {
+			restartRequired = append(restartRequired, change.Key)
+		}
+	}
+
+	if len(restartRequired) > 0 {
+		r.logger.Warnw("Changed config keys require restart and were not applied", "keys", restartRequired)
+	}
+
+	// Текущая конфигурация отражает то, что реально работает: меняем только изменяемую часть
+	next := *r.current
+	config.ApplyReloadable(&next, loaded.Config)
+
+	// Уровень уже проверен в Validate
+	level, _ := zap.ParseAtomicLevel(next.LogLevel)
+	r.level.SetLevel(level.Level())
+	r.settings.Set(api.NewRuntimeConfig(next.Rest))
+	r.current = &next
+
+	metrics.ConfigReloads.WithLabelValues("success").Inc()
+	if len(applied) == 0 {
+		r.logger.Debugw("Config reloaded without changes", "trigger", trigger)
+		return
+	}
+	r.logger.Infow("Config reloaded", "trigger", trigger, "applied", applied)
+}
+
+// every - периодическая перезагрузка, например для ротации секретов из файлов
+func (r *reloader) every(ctx context.Context, interval time.Duration, trigger string) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Reload(trigger)
+		}
+	}
+}
+
+// watchFile - перезагрузка конфигурации при изменении файла (проверка времени модификации)
+func (r *reloader) watchFile(ctx context.Context, path string, interval time.Duration) {
+	modTime := fileModTime(path)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if mt := fileModTime(path); !mt.Equal(modTime) {
+				modTime = mt
+				r.Reload("file")
+			}
+		}
+	}
+}
+
+func fileModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"simple-service/internal/api"
+	"simple-service/internal/config"
+	"simple-service/internal/repo/memory"
+	"simple-service/internal/service"
+)
+
+const reloadConfig = `
+log_level: %s
+storage:
+  backend: memory
+rest:
+  listen_address: "%s"
+  write_timeout: 5s
+  server_name: reload-test
+  token: %s
+  cors_allow_origins:
+    - %s
+  rate_limit: %d
+`
+
+func writeReloadConfig(t *testing.T, path, level, listen, token, origin string, rateLimit int) {
+	t.Helper()
+	content := []byte(fmt.Sprintf(reloadConfig, level, listen, token, origin, rateLimit))
+	require.NoError(t, os.WriteFile(path, content, 0o600))
+}
+
+func TestReload(t *testing.T) {
+	const oldSecret, newSecret = "old-secret", "new-secret"
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeReloadConfig(t, path, "info", ":8080", oldSecret, "https://old.example", 0)
+
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	loader := config.NewLoader(fs)
+	require.NoError(t, fs.Parse([]string{"-env-file", "", "-config", path}))
+	loaded, err := loader.Load()
+	require.NoError(t, err)
+
+	logger := zap.NewNop().Sugar()
+	settings := api.NewSettings(api.NewRuntimeConfig(loaded.Config.Rest))
+	r := &reloader{
+		loader:   loader,
+		current:  loaded.Config,
+		level:    zap.NewAtomicLevelAt(zapcore.InfoLevel),
+		settings: settings,
+		logger:   logger,
+	}
+	app := api.NewRouters(&api.Routers{
+		Service: service.NewService(memory.NewRepository(), logger),
+		Logger:  logger,
+	}, loaded.Config.Rest, settings)
+
+	// request - статус ответа и разрешённый CORS origin для запроса с токеном, подписанным secret
+	request := func(secret, origin string) (int, string) {
+		token, err := mintToken(secret, "alice", nil, time.Hour, time.Now())
+		require.NoError(t, err)
+		req, _ := http.NewRequest("GET", "/v1/tasks", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Origin", origin)
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err)
+		return resp.StatusCode, resp.Header.Get("Access-Control-Allow-Origin")
+	}
+
+	status, allowed := request(oldSecret, "https://old.example")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "https://old.example", allowed)
+
+	t.Run("Ошибка загрузки сохраняет текущую конфигурацию", func(t *testing.T) {
+		current := r.Current()
+		writeReloadConfig(t, path, "loud", ":8080", newSecret, "https://new.example", 0)
+		r.Reload("test")
+
+		assert.Same(t, current, r.Current())
+		assert.Equal(t, zapcore.InfoLevel, r.level.Level())
+		status, _ := request(oldSecret, "https://old.example")
+		assert.Equal(t, http.StatusOK, status, "прежний секрет действует")
+
+		require.NoError(t, os.WriteFile(path, []byte("rest: ["), 0o600))
+		r.Reload("test")
+		assert.Same(t, current, r.Current())
+	})
+
+	t.Run("Ключи, требующие перезапуска, не применяются", func(t *testing.T) {
+		writeReloadConfig(t, path, "debug", ":9090", newSecret, "https://new.example", 2)
+		r.Reload("test")
+
+		current := r.Current()
+		assert.Equal(t, ":8080", current.Rest.ListenAddress)
+		assert.Equal(t, newSecret, current.Rest.Token)
+		assert.Equal(t, []string{"https://new.example"}, current.Rest.CORSAllowOrigins)
+		assert.Equal(t, 2, current.Rest.RateLimit)
+		assert.Equal(t, zapcore.DebugLevel, r.level.Level())
+	})
+
+	t.Run("Параметры API заменяются для следующего запроса", func(t *testing.T) {
+		status, _ := request(oldSecret, "https://new.example")
+		assert.Equal(t, http.StatusUnauthorized, status, "старый секрет отклоняется")
+
+		status, allowed := request(newSecret, "https://new.example")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "https://new.example", allowed)
+
+		// Лимит в 2 запроса за окно исчерпан двумя запросами выше
+		status, _ = request(newSecret, "https://new.example")
+		assert.Equal(t, http.StatusTooManyRequests, status, "новый лимит частоты")
+	})
+}
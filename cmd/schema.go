@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"simple-service/internal/cli"
+	"simple-service/internal/config"
+	"simple-service/internal/migrations"
+	"simple-service/internal/repo"
+	"simple-service/internal/schema"
+)
+
+// defaultSnapshotFile - снимок схемы в репозитории, встраивается в бинарник
+const defaultSnapshotFile = "internal/schema/snapshot.json"
+
+// runSchemaCheck - сравнение схемы БД со снимком, при расхождении код завершения 3
+func runSchemaCheck(c *cli.Command, args []string) error {
+	fs := c.FlagSet()
+	loader := config.NewLoader(fs)
+	if err := cli.ParseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return cli.UsageErrorf(c, "unexpected arguments: %v", fs.Args())
+	}
+
+	cfg, _, err := setup(loader)
+	if err != nil {
+		return err
+	}
+	if err := requirePostgres(cfg); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	repository, err := repo.NewRepository(ctx, cfg.PostgreSQL, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to initialize repository")
+	}
+	defer repository.Close()
+
+	diffs, err := checkSchema(ctx, repository.Pool())
+	if err != nil {
+		return err
+	}
+	if err := schema.Report(os.Stdout, diffs); err != nil {
+		return err
+	}
+	if len(diffs) > 0 {
+		return cli.CheckFailedf("schema drift detected: %d differences", len(diffs))
+	}
+
+	return nil
+}
+
+// runSchemaSnapshot - запись текущей схемы БД в файл снимка
+func runSchemaSnapshot(c *cli.Command, args []string) error {
+	fs := c.FlagSet()
+	output := fs.String("o", defaultSnapshotFile, "snapshot file to write")
+	loader := config.NewLoader(fs)
+	if err := cli.ParseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return cli.UsageErrorf(c, "unexpected arguments: %v", fs.Args())
+	}
+
+	cfg, logger, err := setup(loader)
+	if err != nil {
+		return err
+	}
+	if err := requirePostgres(cfg); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	repository, err := repo.NewRepository(ctx, cfg.PostgreSQL, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to initialize repository")
+	}
+	defer repository.Close()
+
+	return writeSnapshot(ctx, repository.Pool(), *output, logger)
+}
+
+// checkSchema - расхождения схемы базы со встроенным снимком
+func checkSchema(ctx context.Context, pool *pgxpool.Pool) ([]schema.Difference, error) {
+	expected, err := schema.Expected()
+	if err != nil {
+		return nil, err
+	}
+
+	actual, err := schema.Inspect(ctx, pool)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to inspect database schema")
+	}
+
+	return schema.Diff(expected, actual), nil
+}
+
+// startupSchemaCheck - проверка схемы при запуске сервера: в режиме warn расхождения
+// только пишутся в лог, в режиме fail сервер не запускается
+func startupSchemaCheck(ctx context.Context, pool *pgxpool.Pool, mode string, logger *zap.SugaredLogger) error {
+	if mode == config.SchemaCheckOff {
+		return nil
+	}
+
+	diffs, err := checkSchema(ctx, pool)
+	if err != nil {
+		return err
+	}
+	if len(diffs) == 0 {
+		logger.Info("Database schema matches the expected snapshot")
+		return nil
+	}
+
+	for _, d := range diffs {
+		logger.Warnw("Schema drift", "table", d.Table, "object", d.Object, "problem", d.Problem,
+			"expected", d.Expected, "actual", d.Actual)
+	}
+
+	if mode == config.SchemaCheckFail {
+		return errors.Errorf("schema drift detected: %d differences, run `schema check` for a report", len(diffs))
+	}
+
+	return nil
+}
+
+// writeSnapshot - текущая схема базы как новый снимок. Снимать нужно с базы,
+// к которой применены все миграции и ничего больше
+func writeSnapshot(ctx context.Context, pool *pgxpool.Pool, path string, logger *zap.SugaredLogger) error {
+	migrator, err := migrations.NewMigrator(pool, migrations.FS, logger)
+	if err != nil {
+		return err
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	version := 0
+	for _, s := range statuses {
+		if !s.Applied {
+			return errors.Errorf("migration %d is not applied, apply all migrations before taking a snapshot", s.Version)
+		}
+		version = s.Version
+	}
+
+	snapshot, err := schema.Inspect(ctx, pool)
+	if err != nil {
+		return errors.Wrap(err, "failed to inspect database schema")
+	}
+	snapshot.Version = version
+
+	data, err := snapshot.Marshal()
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return errors.Wrap(err, "failed to write snapshot")
+	}
+
+	fmt.Printf("Snapshot of schema version %d written to %s\n", version, path)
+
+	return nil
+}
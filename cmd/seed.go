@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"simple-service/internal/cli"
+	"simple-service/internal/config"
+	"simple-service/internal/repo"
+	"simple-service/internal/service"
+)
+
+// runSeed - тестовые задачи для локальной разработки
+func runSeed(c *cli.Command, args []string) error {
+	fs := c.FlagSet()
+	count := fs.Int("count", 10, "number of tasks to create")
+	loader := config.NewLoader(fs)
+	if err := cli.ParseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return cli.UsageErrorf(c, "unexpected arguments: %v", fs.Args())
+	}
+	if *count < 1 {
+		return cli.UsageErrorf(c, "-count must be at least 1, got %d", *count)
+	}
+
+	cfg, logger, err := setup(loader)
+	if err != nil {
+		return err
+	}
+	if err := requirePostgres(cfg); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	repository, err := repo.NewRepository(ctx, cfg.PostgreSQL, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to initialize repository")
+	}
+	defer repository.Close()
+
+	svc := service.NewService(repository, logger, service.WithEvents(repository))
+	for i := 1; i <= *count; i++ {
+		id, err := svc.CreateTask(ctx, service.TaskRequest{
+			Title:       fmt.Sprintf("Sample task %d", i),
+			Description: "Created by the seed command",
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to create task %d of %d", i, *count)
+		}
+		fmt.Printf("Created task %d\n", id)
+	}
+
+	return nil
+}
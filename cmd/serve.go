@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"simple-service/internal/api"
+	"simple-service/internal/cli"
+	"simple-service/internal/config"
+	customLogger "simple-service/internal/logger"
+	"simple-service/internal/outbox"
+	"simple-service/internal/service"
+	"simple-service/internal/webhook"
+)
+
+// runServe - запуск HTTP сервера до сигнала завершения
+func runServe(c *cli.Command, args []string) error {
+	// Загружаем конфигурацию: файл, переменные окружения, флаги
+	fs := c.FlagSet()
+	loader := config.NewLoader(fs)
+	if err := cli.ParseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return cli.UsageErrorf(c, "unexpected arguments: %v", fs.Args())
+	}
+
+	loaded, err := loader.Load()
+	if err != nil {
+		return errors.Wrap(err, "failed to load configuration")
+	}
+	cfg := loaded.Config
+
+	// Инициализация логгера, уровень можно менять без перезапуска
+	logLevel, err := zap.ParseAtomicLevel(cfg.LogLevel)
+	if err != nil {
+		return errors.Wrap(err, "invalid log level")
+	}
+	logger, err := customLogger.NewLoggerWithLevel(logLevel)
+	if err != nil {
+		return errors.Wrap(err, "error initializing logger")
+	}
+
+	for _, key := range loaded.UnknownKeys {
+		logger.Warnw("Unknown key in config file", "file", loaded.File, "key", key)
+	}
+
+	// Параметры API и перезагрузка конфигурации по SIGHUP
+	settings := api.NewSettings(api.NewRuntimeConfig(cfg.Rest))
+	reload := &reloader{
+		loader:   loader,
+		current:  cfg,
+		level:    logLevel,
+		settings: settings,
+		logger:   logger,
+	}
+
+	// Хранилище задач: PostgreSQL (новые соединения используют актуальный пароль) или память
+	repository, err := openStorage(context.Background(), cfg, func(context.Context) (string, error) {
+		return reload.Current().PostgreSQL.Password, nil
+	}, logger)
+	if err != nil {
+		return err
+	}
+
+	// Кеш чтения задач, для PostgreSQL - с инвалидацией по изменениям других реплик
+	cacheCtx, stopCache := context.WithCancel(context.Background())
+	defer stopCache()
+	tasks := withCache(cacheCtx, repository, cfg.Cache, logger)
+
+	// Создание сервиса с бизнес-логикой, изменения задач записываются в outbox
+	serviceInstance := service.NewService(tasks, logger, service.WithEvents(repository))
+
+	// Получатели событий из outbox: OUTBOX_SINK и доставки подписчикам webhooks
+	sink, err := outbox.NewPublisher(cfg.Outbox, logger)
+	if err != nil {
+		return errors.Wrap(err, "failed to initialize outbox sink")
+	}
+	publisher := outbox.Multi{sink, webhook.NewPublisher(repository)}
+
+	// Инициализация API
+	app := api.NewRouters(&api.Routers{
+		Service:     serviceInstance,
+		Readiness:   repository,
+		Idempotency: repository,
+		Webhooks:    service.NewWebhookService(repository, logger),
+		Logger:      logger,
+	}, cfg.Rest, settings)
+
+	// TLS: сертификат перечитывается с диска при изменении файлов
+	var certs *api.CertReloader
+	if cfg.Rest.TLSEnabled() {
+		certs, err = api.NewCertReloader(cfg.Rest.TLSCertFile, cfg.Rest.TLSKeyFile, logger)
+		if err != nil {
+			return errors.Wrap(err, "failed to load TLS certificate")
+		}
+	}
+
+	// Периодическая перезагрузка: изменения файла конфигурации и ротация секретов
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	if loaded.File != "" && cfg.ConfigWatchInterval > 0 {
+		go reload.watchFile(watchCtx, loaded.File, cfg.ConfigWatchInterval)
+	}
+	if cfg.Secrets.RefreshInterval > 0 {
+		go reload.every(watchCtx, cfg.Secrets.RefreshInterval, "secrets")
+	}
+	if certs != nil {
+		go certs.Watch(watchCtx, cfg.Rest.TLSReloadInterval)
+	}
+
+	// Удаление истёкших ключей идемпотентности из PostgreSQL
+	if purger, ok := repository.(idempotencyPurger); ok {
+		go purgeIdempotencyKeys(watchCtx, purger, logger)
+	}
+
+	// Доставка событий задач и отправка подписчикам, останавливаются до закрытия хранилища
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	defer stopRelay()
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		outbox.NewRelay(repository, publisher, cfg.Outbox, logger).Run(relayCtx)
+	}()
+	dispatchDone := make(chan struct{})
+	go func() {
+		defer close(dispatchDone)
+		webhook.NewDispatcher(repository, cfg.Webhooks, logger).Run(relayCtx)
+	}()
+
+	// Запуск HTTP-сервера в отдельной горутине
+	go func() {
+		logger.Infow("Starting server", "address", cfg.Rest.ListenAddress, "tls", certs != nil)
+		if err := listen(app, cfg.Rest.ListenAddress, certs); err != nil {
+			log.Fatal(errors.Wrap(err, "failed to start server"))
+		}
+	}()
+
+	// Ожидание системных сигналов: SIGHUP - перезагрузка конфигурации, остальные - завершение работы
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range signalChan {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		reload.Reload("sighup")
+	}
+
+	logger.Info("Shutting down gracefully...")
+
+	// Graceful shutdown сервера
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := app.ShutdownWithContext(ctx); err != nil {
+		logger.Errorf("Server shutdown error: %v", err)
+	}
+
+	// Остановка доставки событий: недоставленные останутся в outbox и журнале доставок до следующего запуска
+	stopRelay()
+	<-relayDone
+	<-dispatchDone
+	if err := publisher.Close(); err != nil {
+		logger.Errorf("Outbox sink close error: %v", err)
+	}
+
+	// Закрытие хранилища (пула соединений с БД)
+	stopCache()
+	repository.Close()
+	logger.Info("Server stopped gracefully")
+
+	return nil
+}
+
+// listen - запуск сервера по HTTP или, если есть сертификат, по HTTPS
+func listen(app *fiber.App, address string, certs *api.CertReloader) error {
+	if certs == nil {
+		return app.Listen(address)
+	}
+
+	ln, err := tls.Listen("tcp", address, certs.TLSConfig())
+	if err != nil {
+		return err
+	}
+
+	return app.Listener(ln)
+}
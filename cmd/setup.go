@@ -0,0 +1,29 @@
+package main
+
+import (
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"simple-service/internal/config"
+	customLogger "simple-service/internal/logger"
+)
+
+// setup - конфигурация и логгер для подкоманд, флаги уже разобраны
+func setup(loader *config.Loader) (*config.AppConfig, *zap.SugaredLogger, error) {
+	loaded, err := loader.Load()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to load configuration")
+	}
+	cfg := loaded.Config
+
+	logLevel, err := zap.ParseAtomicLevel(cfg.LogLevel)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid log level")
+	}
+	logger, err := customLogger.NewLoggerWithLevel(logLevel)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error initializing logger")
+	}
+
+	return cfg, logger, nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"simple-service/internal/config"
+	"simple-service/internal/migrations"
+	"simple-service/internal/outbox"
+	"simple-service/internal/repo"
+	"simple-service/internal/repo/cache"
+	"simple-service/internal/repo/memory"
+	"simple-service/internal/service"
+	"simple-service/internal/webhook"
+)
+
+// storage - хранилище задач, выбранное STORAGE_BACKEND
+type storage interface {
+	service.Repository
+	service.IdempotencyStore
+	service.EventStore
+	outbox.Store
+	service.WebhookStore
+	webhook.Store
+	Ready(ctx context.Context) error
+	Close()
+}
+
+// openStorage - подключение к хранилищу. Для PostgreSQL применяются миграции
+// и проверяется схема, новые соединения получают пароль из password
+func openStorage(ctx context.Context, cfg *config.AppConfig, password repo.PasswordFunc, logger *zap.SugaredLogger) (storage, error) {
+	switch cfg.Storage.Backend {
+	case config.StorageMemory:
+		logger.Warnw("Using in-memory storage, tasks are lost on restart", "storage", cfg.Storage.Backend)
+		return memory.NewRepository(), nil
+
+	case config.StoragePostgres:
+		repository, err := repo.NewRepository(ctx, cfg.PostgreSQL, password)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to initialize repository")
+		}
+
+		// Применяем миграции
+		if err := migrations.RunMigrations(ctx, repository.Pool(), cfg.Migrations, logger); err != nil {
+			repository.Close()
+			return nil, errors.Wrap(err, "failed to run migrations")
+		}
+
+		// Сравнение схемы со снимком, если включено
+		if err := startupSchemaCheck(ctx, repository.Pool(), cfg.Migrations.SchemaCheck, logger); err != nil {
+			repository.Close()
+			return nil, err
+		}
+
+		return repository, nil
+	}
+
+	return nil, errors.Errorf("unknown storage backend %q", cfg.Storage.Backend)
+}
+
+// requirePostgres - команды, работающие напрямую с БД, не имеют смысла без PostgreSQL
+func requirePostgres(cfg *config.AppConfig) error {
+	if !cfg.UsesPostgres() {
+		return errors.Errorf("this command requires STORAGE_BACKEND=%s, got %s", config.StoragePostgres, cfg.Storage.Backend)
+	}
+	return nil
+}
+
+// taskChangeListener - хранилище, которое сообщает об изменениях задач другими репликами
+type taskChangeListener interface {
+	ListenTaskChanges(ctx context.Context, changed func(id int), reset func()) error
+}
+
+// Пауза перед повторной подпиской на изменения задач после потери соединения
+const (
+	listenRetryMin = time.Second
+	listenRetryMax = 30 * time.Second
+)
+
+// withCache - кеш чтения задач поверх хранилища, если он включён. Для PostgreSQL кеш
+// инвалидируется по изменениям других реплик, подписка живёт до отмены ctx
+func withCache(ctx context.Context, repository storage, cfg config.Cache, logger *zap.SugaredLogger) service.Repository {
+	if cfg.Size == 0 {
+		return repository
+	}
+
+	cached := cache.New(repository, cfg)
+	if listener, ok := repository.(taskChangeListener); ok {
+		go listenTaskChanges(ctx, listener, cached, logger)
+	}
+	logger.Infow("Task cache enabled", "size", cfg.Size, "ttl", cfg.TTL, "negative_ttl", cfg.NegativeTTL)
+
+	return cached
+}
+
+// listenTaskChanges - подписка на изменения с переподключением. Пока подписки нет,
+// кеш может отставать не больше чем на CACHE_TTL
+func listenTaskChanges(ctx context.Context, listener taskChangeListener, cached *cache.Repository, logger *zap.SugaredLogger) {
+	delay := listenRetryMin
+	for {
+		started := time.Now()
+		err := listener.ListenTaskChanges(ctx, cached.Invalidate, cached.Purge)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Подписка проработала долго - соединение было, начинаем паузы заново
+		if time.Since(started) > listenRetryMax {
+			delay = listenRetryMin
+		}
+		logger.Warnw("Task change subscription lost, cache may be stale until it is restored",
+			"error", err, "retry_in", delay)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, listenRetryMax)
+	}
+}
+
+// idempotencyPurger - хранилище, в котором истёкшие ключи идемпотентности нужно удалять отдельно
+type idempotencyPurger interface {
+	PurgeIdempotencyKeys(ctx context.Context) (int, error)
+}
+
+// idempotencyPurgeInterval - как часто удаляются истёкшие ключи идемпотентности
+const idempotencyPurgeInterval = time.Hour
+
+// purgeIdempotencyKeys - периодическое удаление истёкших ключей до отмены ctx.
+// Истёкший ключ и так считается свободным, удаление только ограничивает размер таблицы
+func purgeIdempotencyKeys(ctx context.Context, purger idempotencyPurger, logger *zap.SugaredLogger) {
+	ticker := time.NewTicker(idempotencyPurgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		n, err := purger.PurgeIdempotencyKeys(ctx)
+		if err != nil {
+			logger.Warnw("Failed to purge expired idempotency keys", "error", err)
+			continue
+		}
+		logger.Debugw("Purged expired idempotency keys", "count", n)
+	}
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"simple-service/internal/cli"
+	"simple-service/pkg/client"
+)
+
+// globalFlags - флаги подключения и вывода, общие для всех команд работы с задачами
+type globalFlags struct {
+	profile string
+	baseURL string
+	token   string
+	output  string
+	timeout time.Duration
+}
+
+func (g *globalFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&g.profile, "profile", "", "profile from the profiles file (env "+envProfile+")")
+	fs.StringVar(&g.baseURL, "base-url", "", "service URL, overrides the profile (env "+envBaseURL+")")
+	fs.StringVar(&g.token, "token", "", "JWT, overrides the profile (env "+envToken+")")
+	fs.StringVar(&g.output, "o", formatTable, "output format: table, json or yaml")
+	fs.DurationVar(&g.timeout, "timeout", 30*time.Second, "request timeout, 0 disables it")
+}
+
+// session - клиент и вывод, настроенные по флагам, окружению и профилю
+type session struct {
+	client *client.Client
+	out    *printer
+}
+
+func (g *globalFlags) session() (*session, error) {
+	out, err := newPrinter(os.Stdout, g.output)
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := profilesPath()
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := loadProfiles(path)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := profiles.resolve(g.profile, g.baseURL, g.token, os.Getenv)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []client.Option{client.WithUserAgent("simple-service-tasks")}
+	if profile.Token != "" {
+		opts = append(opts, client.WithToken(profile.Token))
+	}
+	c, err := client.New(profile.BaseURL, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &session{client: c, out: out}, nil
+}
+
+// context - контекст команды, отменяется по Ctrl+C и по истечении timeout
+func (g *globalFlags) context(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	if timeout <= 0 {
+		return ctx, stop
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// parse - разбор флагов команды вместе с общими флагами
+func parse(c *cli.Command, args []string, register func(fs *flag.FlagSet)) (*globalFlags, *flag.FlagSet, error) {
+	g := &globalFlags{}
+	fs := c.FlagSet()
+	g.register(fs)
+	if register != nil {
+		register(fs)
+	}
+	if err := cli.ParseFlags(fs, args); err != nil {
+		return nil, nil, err
+	}
+	return g, fs, nil
+}
+
+// taskIDs - положительные ID задач из аргументов
+func taskIDs(c *cli.Command, args []string) ([]int, error) {
+	if len(args) == 0 {
+		return nil, cli.UsageErrorf(c, "task ID is required")
+	}
+	ids := make([]int, 0, len(args))
+	for _, arg := range args {
+		id, err := strconv.Atoi(arg)
+		if err != nil || id < 1 {
+			return nil, cli.UsageErrorf(c, "invalid task ID %q", arg)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// taskID - ровно один ID задачи
+func taskID(c *cli.Command, args []string) (int, error) {
+	if len(args) > 1 {
+		return 0, cli.UsageErrorf(c, "unexpected arguments: %v", args[1:])
+	}
+	ids, err := taskIDs(c, args)
+	if err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+// runCreate - tasks create [flags] TITLE...
+func runCreate(c *cli.Command, args []string) error {
+	var description string
+	g, fs, err := parse(c, args, func(fs *flag.FlagSet) {
+		fs.StringVar(&description, "description", "", "task description")
+	})
+	if err != nil {
+		return err
+	}
+	title := strings.Join(fs.Args(), " ")
+	if title == "" {
+		return cli.UsageErrorf(c, "TITLE is required")
+	}
+
+	s, err := g.session()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := g.context(g.timeout)
+	defer cancel()
+
+	id, err := s.client.CreateTask(ctx, client.TaskRequest{Title: title, Description: description})
+	if err != nil {
+		return err
+	}
+	task, err := s.client.GetTask(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return s.out.task(task)
+}
+
+// runGet - tasks get [flags] ID
+func runGet(c *cli.Command, args []string) error {
+	g, fs, err := parse(c, args, nil)
+	if err != nil {
+		return err
+	}
+	id, err := taskID(c, fs.Args())
+	if err != nil {
+		return err
+	}
+
+	s, err := g.session()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := g.context(g.timeout)
+	defer cancel()
+
+	task, err := s.client.GetTask(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return s.out.task(task)
+}
+
+// runList - tasks list [flags]
+func runList(c *cli.Command, args []string) error {
+	var opts client.ListOptions
+	g, fs, err := parse(c, args, func(fs *flag.FlagSet) {
+		fs.StringVar(&opts.Status, "status", "", "only tasks with this status: new, in_progress or done")
+		fs.IntVar(&opts.Limit, "limit", 50, "page size, at most 1000")
+		fs.IntVar(&opts.Offset, "offset", 0, "number of tasks to skip")
+	})
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return cli.UsageErrorf(c, "unexpected arguments: %v", fs.Args())
+	}
+
+	s, err := g.session()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := g.context(g.timeout)
+	defer cancel()
+
+	list, err := s.client.ListTasks(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	return s.out.list(list)
+}
+
+// runUpdate - tasks update [flags] ID, меняются только переданные флаги
+func runUpdate(c *cli.Command, args []string) error {
+	var title, description, status string
+	g, fs, err := parse(c, args, func(fs *flag.FlagSet) {
+		fs.StringVar(&title, "title", "", "new title")
+		fs.StringVar(&description, "description", "", "new description, empty string clears it")
+		fs.StringVar(&status, "status", "", "new status: new, in_progress or done")
+	})
+	if err != nil {
+		return err
+	}
+	id, err := taskID(c, fs.Args())
+	if err != nil {
+		return err
+	}
+
+	var req client.UpdateTaskRequest
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "title":
+			req.Title = &title
+		case "description":
+			req.Description = &description
+		case "status":
+			req.Status = &status
+		}
+	})
+	if req.Title == nil && req.Description == nil && req.Status == nil {
+		return cli.UsageErrorf(c, "at least one of -title, -description or -status is required")
+	}
+
+	return updateTask(g, id, req)
+}
+
+// runDone - tasks done [flags] ID, сокращение для update -status done
+func runDone(c *cli.Command, args []string) error {
+	g, fs, err := parse(c, args, nil)
+	if err != nil {
+		return err
+	}
+	id, err := taskID(c, fs.Args())
+	if err != nil {
+		return err
+	}
+
+	status := client.StatusDone
+	return updateTask(g, id, client.UpdateTaskRequest{Status: &status})
+}
+
+func updateTask(g *globalFlags, id int, req client.UpdateTaskRequest) error {
+	s, err := g.session()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := g.context(g.timeout)
+	defer cancel()
+
+	task, err := s.client.UpdateTask(ctx, id, req)
+	if err != nil {
+		return err
+	}
+
+	return s.out.task(task)
+}
+
+// runDelete - tasks delete [flags] ID...
+func runDelete(c *cli.Command, args []string) error {
+	g, fs, err := parse(c, args, nil)
+	if err != nil {
+		return err
+	}
+	ids, err := taskIDs(c, fs.Args())
+	if err != nil {
+		return err
+	}
+
+	s, err := g.session()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := g.context(g.timeout)
+	defer cancel()
+
+	for _, id := range ids {
+		if err := s.client.DeleteTask(ctx, id); err != nil {
+			return errors.Wrapf(err, "failed to delete task %d", id)
+		}
+		fmt.Fprintln(os.Stderr, "Deleted task", id)
+	}
+
+	return nil
+}
+
+// taskStatuses - допустимые значения -until
+var taskStatuses = []string{client.StatusNew, client.StatusInProgress, client.StatusDone}
+
+// runWatch - tasks watch [flags] ID, ожидание смены статуса задачи
+func runWatch(c *cli.Command, args []string) error {
+	var (
+		interval time.Duration
+		until    string
+		wait     time.Duration
+	)
+	g, fs, err := parse(c, args, func(fs *flag.FlagSet) {
+		fs.DurationVar(&interval, "interval", 2*time.Second, "polling interval")
+		fs.StringVar(&until, "until", "", "wait for this status (new, in_progress or done) instead of any change")
+		fs.DurationVar(&wait, "wait", 0, "give up after this duration, 0 waits until interrupted")
+	})
+	if err != nil {
+		return err
+	}
+	id, err := taskID(c, fs.Args())
+	if err != nil {
+		return err
+	}
+	if interval <= 0 {
+		return cli.UsageErrorf(c, "-interval must be positive, got %s", interval)
+	}
+	if until != "" && !slices.Contains(taskStatuses, until) {
+		return cli.UsageErrorf(c, "-until must be one of %s, got %q", strings.Join(taskStatuses, ", "), until)
+	}
+
+	s, err := g.session()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := g.context(wait)
+	defer cancel()
+
+	// Таймаут отдельного запроса, ожидание в целом ограничивает -wait
+	get := func(ctx context.Context) (*client.Task, error) {
+		if g.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, g.timeout)
+			defer cancel()
+		}
+		return s.client.GetTask(ctx, id)
+	}
+
+	task, err := watch(ctx, get, until, interval, func(task *client.Task) {
+		fmt.Fprintf(os.Stderr, "Task %d is %s, waiting...\n", task.ID, task.Status)
+	})
+	if err != nil {
+		// Истёк -wait, а не таймаут отдельного запроса: ошибки запросов возвращаются как есть
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return errors.Errorf("task %d status did not change within %s", id, wait)
+		}
+		return err
+	}
+
+	return s.out.task(task)
+}
+
+// watch - опрос задачи каждые interval, пока статус не изменится относительно первого ответа
+// (или не станет равен until). progress вызывается один раз после первого ответа
+func watch(ctx context.Context, get func(context.Context) (*client.Task, error), until string,
+	interval time.Duration, progress func(*client.Task)) (*client.Task, error) {
+	task, err := get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	initial := task.Status
+	done := func(task *client.Task) bool {
+		if until != "" {
+			return task.Status == until
+		}
+		return task.Status != initial
+	}
+	if until != "" && done(task) {
+		return task, nil
+	}
+	if progress != nil {
+		progress(task)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+
+		task, err := get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if done(task) {
+			return task, nil
+		}
+	}
+}
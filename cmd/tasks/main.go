@@ -0,0 +1,48 @@
+// Команда tasks - клиент командной строки для работы с задачами через HTTP API Simple Service
+package main
+
+import (
+	"os"
+
+	"simple-service/internal/cli"
+)
+
+const binaryName = "tasks"
+
+func main() {
+	os.Exit(cli.Execute(newRootCommand(), os.Args[1:], os.Stderr))
+}
+
+// newRootCommand - дерево команд. Адрес сервиса и токен берутся из флагов, переменных
+// окружения TASKS_* или профиля в файле профилей
+func newRootCommand() *cli.Command {
+	root := &cli.Command{
+		Name:    binaryName,
+		Summary: "Command-line client for the Simple Service tasks API",
+		Description: "Command-line client for the Simple Service tasks API.\n\n" +
+			"Base URL and token come from flags, TASKS_BASE_URL/TASKS_TOKEN or a profile\n" +
+			"in the profiles file (TASKS_CONFIG, by default tasks.yaml in the user config directory).",
+		Subcommands: []*cli.Command{
+			{Name: "create", Args: "TITLE...", Summary: "Create a task", Run: runCreate},
+			{Name: "get", Args: "ID", Summary: "Show a task", Run: runGet},
+			{Name: "list", Summary: "List tasks, optionally filtered by status", Run: runList},
+			{Name: "update", Args: "ID", Summary: "Change title, description or status of a task", Run: runUpdate},
+			{Name: "done", Args: "ID", Summary: "Mark a task as done", Run: runDone},
+			{Name: "delete", Args: "ID...", Summary: "Delete tasks", Run: runDelete},
+			{Name: "watch", Args: "ID", Summary: "Poll a task until its status changes", Run: runWatch,
+				Description: "Poll a task until its status changes (or becomes -until) and print it."},
+			{
+				Name:    "profile",
+				Summary: "Manage profiles with base URL and token",
+				Subcommands: []*cli.Command{
+					{Name: "set", Args: "NAME", Summary: "Create or change a profile", Run: runProfileSet},
+					{Name: "use", Args: "NAME", Summary: "Make a profile current", Run: runProfileUse},
+					{Name: "list", Summary: "List profiles", Run: runProfileList},
+				},
+			},
+			cli.HelpCommand(),
+		},
+	}
+
+	return root.Link()
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"simple-service/pkg/client"
+)
+
+// Форматы вывода
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatYAML  = "yaml"
+)
+
+const timeLayout = "2006-01-02 15:04:05"
+
+// printer - вывод задач в выбранном формате. JSON и YAML используют поля DTO API
+type printer struct {
+	w      io.Writer
+	format string
+}
+
+func newPrinter(w io.Writer, format string) (*printer, error) {
+	switch format {
+	case formatTable, formatJSON, formatYAML:
+		return &printer{w: w, format: format}, nil
+	}
+	return nil, errors.Errorf("unknown output format %q, expected table, json or yaml", format)
+}
+
+// task - одна задача, в табличном формате - карточка поле: значение
+func (p *printer) task(task *client.Task) error {
+	if p.format != formatTable {
+		return p.encode(task)
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "ID:\t%d\n", task.ID)
+	fmt.Fprintf(tw, "Title:\t%s\n", task.Title)
+	fmt.Fprintf(tw, "Description:\t%s\n", task.Description)
+	fmt.Fprintf(tw, "Status:\t%s\n", task.Status)
+	fmt.Fprintf(tw, "Created:\t%s\n", task.CreatedAt.Local().Format(timeLayout))
+	fmt.Fprintf(tw, "Updated:\t%s\n", task.UpdatedAt.Local().Format(timeLayout))
+	return tw.Flush()
+}
+
+// list - страница задач, в табличном формате - строка на задачу
+func (p *printer) list(list *client.TaskList) error {
+	if p.format != formatTable {
+		return p.encode(list)
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATUS\tTITLE\tUPDATED")
+	for _, task := range list.Tasks {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", task.ID, task.Status, task.Title, task.UpdatedAt.Local().Format(timeLayout))
+	}
+	return tw.Flush()
+}
+
+func (p *printer) encode(v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode output")
+	}
+
+	if p.format == formatYAML {
+		if data, err = jsonToYAML(data); err != nil {
+			return err
+		}
+	} else {
+		data = append(data, '\n')
+	}
+
+	_, err = p.w.Write(data)
+	return err
+}
+
+// jsonToYAML - YAML с теми же именами и порядком полей, что и JSON ответ API.
+// JSON является YAML в flow стиле, поэтому достаточно сбросить стиль узлов
+func jsonToYAML(data []byte) ([]byte, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, errors.Wrap(err, "failed to convert output to YAML")
+	}
+
+	var reset func(n *yaml.Node)
+	reset = func(n *yaml.Node) {
+		n.Style = 0
+		for _, child := range n.Content {
+			reset(child)
+		}
+	}
+	reset(&node)
+
+	out, err := yaml.Marshal(&node)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to convert output to YAML")
+	}
+	return out, nil
+}
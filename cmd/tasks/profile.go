@@ -0,0 +1,139 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Переменные окружения, переопределяющие файл профилей
+const (
+	envConfig  = "TASKS_CONFIG"
+	envProfile = "TASKS_PROFILE"
+	envBaseURL = "TASKS_BASE_URL"
+	envToken   = "TASKS_TOKEN"
+)
+
+const (
+	defaultProfile = "default"
+	defaultBaseURL = "http://localhost:8080"
+)
+
+// Profile - адрес сервиса и токен для одного окружения
+type Profile struct {
+	BaseURL string `yaml:"base_url"`
+	Token   string `yaml:"token,omitempty"`
+}
+
+// Profiles - файл профилей, например:
+//
+//	current: local
+//	profiles:
+//	  local:
+//	    base_url: http://localhost:8080
+//	    token: eyJhbGciOi...
+type Profiles struct {
+	Current  string             `yaml:"current,omitempty"`
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// profilesPath - TASKS_CONFIG или tasks.yaml в пользовательском каталоге конфигурации
+func profilesPath() (string, error) {
+	if path := os.Getenv(envConfig); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to find user config directory, set "+envConfig)
+	}
+	return filepath.Join(dir, "simple-service", "tasks.yaml"), nil
+}
+
+// loadProfiles - чтение файла профилей, отсутствующий файл - пустой набор профилей
+func loadProfiles(path string) (*Profiles, error) {
+	profiles := &Profiles{Profiles: map[string]Profile{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return profiles, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read profiles")
+	}
+
+	if err := yaml.Unmarshal(data, profiles); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse profiles %s", path)
+	}
+	if profiles.Profiles == nil {
+		profiles.Profiles = map[string]Profile{}
+	}
+
+	return profiles, nil
+}
+
+// save - запись файла профилей. Файл содержит токены, поэтому доступен только владельцу
+func (p *Profiles) save(path string) error {
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode profiles")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return errors.Wrap(err, "failed to create profiles directory")
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return errors.Wrap(err, "failed to write profiles")
+	}
+	return nil
+}
+
+// names - имена профилей по алфавиту
+func (p *Profiles) names() []string {
+	names := make([]string, 0, len(p.Profiles))
+	for name := range p.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolve - итоговые адрес и токен. Приоритет: флаги, переменные окружения, профиль.
+// Явно запрошенный профиль должен существовать
+func (p *Profiles) resolve(name, baseURL, token string, getenv func(string) string) (Profile, error) {
+	explicit := name != ""
+	if name == "" {
+		name = getenv(envProfile)
+		explicit = name != ""
+	}
+	if name == "" {
+		name = p.Current
+	}
+	if name == "" {
+		name = defaultProfile
+	}
+
+	profile, ok := p.Profiles[name]
+	if !ok && explicit {
+		return Profile{}, errors.Errorf("profile %q not found", name)
+	}
+
+	if v := getenv(envBaseURL); v != "" {
+		profile.BaseURL = v
+	}
+	if v := getenv(envToken); v != "" {
+		profile.Token = v
+	}
+	if baseURL != "" {
+		profile.BaseURL = baseURL
+	}
+	if token != "" {
+		profile.Token = token
+	}
+	if profile.BaseURL == "" {
+		profile.BaseURL = defaultBaseURL
+	}
+
+	return profile, nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+
+	"simple-service/internal/cli"
+)
+
+// runProfileSet - tasks profile set [flags] NAME, создание или изменение профиля
+func runProfileSet(c *cli.Command, args []string) error {
+	fs := c.FlagSet()
+	baseURL := fs.String("base-url", "", "service URL")
+	token := fs.String("token", "", "JWT, e.g. from 'simple-service token mint'")
+	use := fs.Bool("use", false, "make the profile current")
+	if err := cli.ParseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return cli.UsageErrorf(c, "exactly one NAME is required")
+	}
+	name := fs.Arg(0)
+
+	path, profiles, err := openProfiles()
+	if err != nil {
+		return err
+	}
+
+	profile := profiles.Profiles[name]
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "base-url":
+			profile.BaseURL = *baseURL
+		case "token":
+			profile.Token = *token
+		}
+	})
+	profiles.Profiles[name] = profile
+	if *use || profiles.Current == "" {
+		profiles.Current = name
+	}
+
+	if err := profiles.save(path); err != nil {
+		return err
+	}
+	fmt.Printf("Saved profile %q to %s\n", name, path)
+
+	return nil
+}
+
+// runProfileUse - tasks profile use NAME, выбор текущего профиля
+func runProfileUse(c *cli.Command, args []string) error {
+	fs := c.FlagSet()
+	if err := cli.ParseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return cli.UsageErrorf(c, "exactly one NAME is required")
+	}
+	name := fs.Arg(0)
+
+	path, profiles, err := openProfiles()
+	if err != nil {
+		return err
+	}
+	if _, ok := profiles.Profiles[name]; !ok {
+		return errors.Errorf("profile %q not found", name)
+	}
+
+	profiles.Current = name
+
+	return profiles.save(path)
+}
+
+// runProfileList - tasks profile list, токены не выводятся
+func runProfileList(c *cli.Command, args []string) error {
+	fs := c.FlagSet()
+	if err := cli.ParseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return cli.UsageErrorf(c, "unexpected arguments: %v", fs.Args())
+	}
+
+	_, profiles, err := openProfiles()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CURRENT\tNAME\tBASE URL\tTOKEN")
+	for _, name := range profiles.names() {
+		profile := profiles.Profiles[name]
+		current, token := "", "-"
+		if name == profiles.Current {
+			current = "*"
+		}
+		if profile.Token != "" {
+			token = "set"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", current, name, profile.BaseURL, token)
+	}
+
+	return w.Flush()
+}
+
+func openProfiles() (string, *Profiles, error) {
+	path, err := profilesPath()
+	if err != nil {
+		return "", nil, err
+	}
+	profiles, err := loadProfiles(path)
+	if err != nil {
+		return "", nil, err
+	}
+	return path, profiles, nil
+}
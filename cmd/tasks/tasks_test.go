@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"simple-service/internal/cli"
+	"simple-service/pkg/client"
+)
+
+func TestProfilesResolve(t *testing.T) {
+	profiles := &Profiles{
+		Current: "local",
+		Profiles: map[string]Profile{
+			"local":   {BaseURL: "http://localhost:8080", Token: "local-token"},
+			"staging": {BaseURL: "https://staging.example.com", Token: "staging-token"},
+		},
+	}
+	env := func(values map[string]string) func(string) string {
+		return func(key string) string { return values[key] }
+	}
+
+	tests := []struct {
+		name     string
+		profile  string
+		baseURL  string
+		token    string
+		env      map[string]string
+		want     Profile
+		wantErr  string
+		profiles *Profiles
+	}{
+		{
+			name: "Текущий профиль",
+			want: Profile{BaseURL: "http://localhost:8080", Token: "local-token"},
+		},
+		{
+			name:    "Профиль из флага",
+			profile: "staging",
+			want:    Profile{BaseURL: "https://staging.example.com", Token: "staging-token"},
+		},
+		{
+			name: "Профиль из окружения",
+			env:  map[string]string{envProfile: "staging"},
+			want: Profile{BaseURL: "https://staging.example.com", Token: "staging-token"},
+		},
+		{
+			name:  "Флаги важнее окружения, окружение важнее профиля",
+			token: "flag-token",
+			env:   map[string]string{envBaseURL: "http://env:8080", envToken: "env-token"},
+			want:  Profile{BaseURL: "http://env:8080", Token: "flag-token"},
+		},
+		{
+			name:    "Неизвестный профиль",
+			profile: "prod",
+			wantErr: `profile "prod" not found`,
+		},
+		{
+			name:     "Без файла профилей - локальный сервис",
+			profiles: &Profiles{Profiles: map[string]Profile{}},
+			want:     Profile{BaseURL: defaultBaseURL},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := profiles
+			if tt.profiles != nil {
+				p = tt.profiles
+			}
+
+			got, err := p.resolve(tt.profile, tt.baseURL, tt.token, env(tt.env))
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestProfilesSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "tasks.yaml")
+
+	empty, err := loadProfiles(path)
+	require.NoError(t, err)
+	assert.Empty(t, empty.Profiles)
+
+	profiles := &Profiles{Current: "local", Profiles: map[string]Profile{"local": {BaseURL: "http://localhost:8080", Token: "t"}}}
+	require.NoError(t, profiles.save(path))
+
+	loaded, err := loadProfiles(path)
+	require.NoError(t, err)
+	assert.Equal(t, profiles, loaded)
+}
+
+func TestPrinter(t *testing.T) {
+	at := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	list := &client.TaskList{
+		Tasks: []client.Task{{ID: 1, Title: "Write CLI", Description: "42", Status: "new", CreatedAt: at, UpdatedAt: at}},
+		Limit: 50,
+	}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: formatJSON,
+			want: `{
+  "tasks": [
+    {
+      "id": 1,
+      "title": "Write CLI",
+      "description": "42",
+      "status": "new",
+      "created_at": "2024-01-15T10:30:00Z",
+      "updated_at": "2024-01-15T10:30:00Z"
+    }
+  ],
+  "limit": 50,
+  "offset": 0
+}
+`,
+		},
+		{
+			format: formatYAML,
+			want: `tasks:
+    - id: 1
+      title: Write CLI
+      description: "42"
+      status: new
+      created_at: "2024-01-15T10:30:00Z"
+      updated_at: "2024-01-15T10:30:00Z"
+limit: 50
+offset: 0
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			p, err := newPrinter(&buf, tt.format)
+			require.NoError(t, err)
+			require.NoError(t, p.list(list))
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+
+	var buf bytes.Buffer
+	p, err := newPrinter(&buf, formatTable)
+	require.NoError(t, err)
+	require.NoError(t, p.list(list))
+	assert.Contains(t, buf.String(), "ID  STATUS  TITLE      UPDATED\n1   new     Write CLI  ")
+
+	_, err = newPrinter(&buf, "xml")
+	assert.EqualError(t, err, `unknown output format "xml", expected table, json or yaml`)
+}
+
+func TestWatch(t *testing.T) {
+	// poll - сервер, возвращающий статусы по очереди, последний повторяется
+	poll := func(statuses ...string) (func(context.Context) (*client.Task, error), *int) {
+		calls := 0
+		return func(context.Context) (*client.Task, error) {
+			status := statuses[min(calls, len(statuses)-1)]
+			calls++
+			return &client.Task{ID: 1, Status: status}, nil
+		}, &calls
+	}
+
+	t.Run("Любая смена статуса", func(t *testing.T) {
+		get, calls := poll("new", "new", "in_progress", "done")
+		task, err := watch(context.Background(), get, "", time.Millisecond, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "in_progress", task.Status)
+		assert.Equal(t, 3, *calls)
+	})
+
+	t.Run("Ожидание конкретного статуса", func(t *testing.T) {
+		get, calls := poll("new", "in_progress", "done")
+		task, err := watch(context.Background(), get, "done", time.Millisecond, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "done", task.Status)
+		assert.Equal(t, 3, *calls)
+	})
+
+	t.Run("Статус уже достигнут", func(t *testing.T) {
+		get, calls := poll("done")
+		_, err := watch(context.Background(), get, "done", time.Hour, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 1, *calls)
+	})
+
+	t.Run("Статус не меняется до дедлайна", func(t *testing.T) {
+		get, _ := poll("new")
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		_, err := watch(ctx, get, "", time.Millisecond, nil)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestRunWatch(t *testing.T) {
+	// Сервер отвечает медленнее таймаута запроса
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("TASKS_CONFIG", filepath.Join(t.TempDir(), "tasks.yaml"))
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantErr  string
+	}{
+		{name: "Неизвестный статус -until", args: []string{"-until", "finished"}, wantCode: cli.ExitUsage,
+			wantErr: `-until must be one of new, in_progress, done, got "finished"`},
+		// Ошибка запроса не выдаётся за истечение -wait
+		{name: "Таймаут запроса без -wait", args: []string{"-timeout", "20ms"}, wantCode: cli.ExitError,
+			wantErr: "context deadline exceeded"},
+		{name: "Таймаут запроса раньше -wait", args: []string{"-timeout", "20ms", "-wait", "5s"}, wantCode: cli.ExitError,
+			wantErr: "context deadline exceeded"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stderr bytes.Buffer
+			args := append([]string{"watch", "-base-url", server.URL, "-token", "t"}, tt.args...)
+			code := cli.Execute(newRootCommand(), append(args, "1"), &stderr)
+
+			assert.Equal(t, tt.wantCode, code, stderr.String())
+			assert.Contains(t, stderr.String(), tt.wantErr)
+			assert.NotContains(t, stderr.String(), "did not change")
+		})
+	}
+}
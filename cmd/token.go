@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+
+	"simple-service/internal/cli"
+	"simple-service/internal/config"
+)
+
+// runTokenMint - JWT для локальной разработки, подписанный секретом TOKEN из конфигурации
+func runTokenMint(c *cli.Command, args []string) error {
+	fs := c.FlagSet()
+	subject := fs.String("subject", "developer", "token subject (sub claim)")
+	scopes := fs.String("scopes", "", "comma-separated scopes (scope claim), e.g. tasks:read,tasks:write")
+	ttl := fs.Duration("ttl", time.Hour, "token lifetime")
+	loader := config.NewLoader(fs)
+	if err := cli.ParseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return cli.UsageErrorf(c, "unexpected arguments: %v", fs.Args())
+	}
+	if *ttl <= 0 {
+		return cli.UsageErrorf(c, "-ttl must be positive, got %s", *ttl)
+	}
+
+	loaded, err := loader.Load()
+	if err != nil {
+		return errors.Wrap(err, "failed to load configuration")
+	}
+
+	token, err := mintToken(loaded.Config.Rest.Token, *subject, splitScopes(*scopes), *ttl, time.Now())
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(token)
+
+	return nil
+}
+
+// mintToken - HS256 токен с claims sub, scope (через пробел, как в OAuth 2.0), iat и exp
+func mintToken(secret, subject string, scopes []string, ttl time.Duration, now time.Time) (string, error) {
+	claims := jwt.MapClaims{
+		"sub": subject,
+		"iat": now.Unix(),
+		"exp": now.Add(ttl).Unix(),
+	}
+	if len(scopes) > 0 {
+		claims["scope"] = strings.Join(scopes, " ")
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to sign token")
+	}
+
+	return token, nil
+}
+
+// splitScopes - список scope через запятую или пробел
+func splitScopes(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' })
+}
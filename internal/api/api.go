@@ -1,6 +1,10 @@
 package api
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/swagger"
@@ -8,39 +12,152 @@ import (
 
 	"simple-service/internal/api/handlers"
 	"simple-service/internal/api/middleware"
+	"simple-service/internal/config"
+	"simple-service/internal/dto"
+	"simple-service/internal/metrics"
 	"simple-service/internal/service"
 )
 
+// Лимиты размера тела запроса для маршрутов, обновление задачи ограничено так же, как создание
+const createTaskBodyLimit = 16 * 1024
+
 // Routers - структура для хранения зависимостей роутов
 type Routers struct {
 	Service service.Service
-	Logger  *zap.SugaredLogger
+	// Readiness - проверка хранилища для /ready, nil - маршрут не регистрируется
+	Readiness handlers.ReadinessChecker
+	// Idempotency - хранилище ключей идемпотентности, nil - заголовок Idempotency-Key не учитывается
+	Idempotency service.IdempotencyStore
+	// Webhooks - управление подписками на события задач, nil - маршруты /v1/webhooks не регистрируются
+	Webhooks service.WebhookService
+	Logger   *zap.SugaredLogger
 }
 
-// NewRouters - конструктор для настройки API
-func NewRouters(r *Routers, token string) *fiber.App {
-	app := fiber.New()
+// NewRouters - конструктор для настройки API.
+// cfg задаёт параметры сервера, settings читаются на каждый запрос и могут заменяться без перезапуска
+func NewRouters(r *Routers, cfg config.Rest, settings *Settings) *fiber.App {
+	app := fiber.New(fiber.Config{
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+		BodyLimit:    cfg.BodyLimit,
+		Concurrency:  cfg.Concurrency,
+		ServerHeader: cfg.ServerName,
+		// Реальный IP клиента из заголовка прокси, только если запрос пришёл от доверенного прокси
+		ProxyHeader:             cfg.ProxyHeader,
+		EnableTrustedProxyCheck: len(cfg.TrustedProxies) > 0,
+		TrustedProxies:          cfg.TrustedProxies,
+		ErrorHandler:            errorHandler,
+	})
 
-	// Настройка CORS (разрешенные методы, заголовки, авторизация)
+	// Настройка CORS (разрешенные источники, методы, заголовки, авторизация)
 	app.Use(cors.New(cors.Config{
-		AllowMethods:  "GET, POST, PUT, DELETE",
-		AllowHeaders:  "Accept, Authorization, Content-Type, X-CSRF-Token, X-REQUEST-ID",
-		ExposeHeaders: "Link",
-		MaxAge:        300,
+		AllowOriginsFunc: settings.allowOrigin,
+		AllowMethods:     "GET, POST, PUT, PATCH, DELETE",
+		AllowHeaders:     "Accept, Authorization, Content-Type, X-CSRF-Token, X-REQUEST-ID, Idempotency-Key",
+		ExposeHeaders:    "Link, Idempotent-Replayed",
+		MaxAge:           300,
 	}))
 
 	// Swagger UI (без авторизации)
 	app.Get("/swagger/*", swagger.HandlerDefault)
 
-	// Группа маршрутов с авторизацией
-	apiGroup := app.Group("/v1", middleware.JWTAuthorization(token))
+	// Метрики Prometheus (без авторизации)
+	app.Get("/metrics", metrics.Handler())
+
+	// Готовность к приёму запросов (без авторизации)
+	if r.Readiness != nil {
+		app.Get("/ready", handlers.NewHealthHandler(r.Readiness, r.Logger).Ready)
+	}
+
+	// Группа маршрутов с ограничением частоты запросов и авторизацией
+	apiGroup := app.Group("/v1",
+		middleware.RateLimit(settings.rateLimit),
+		middleware.JWTAuthorizationFunc(settings.jwtSecret),
+	)
+
+	// Бюджет времени маршрута: ROUTE_TIMEOUTS или fallback. Ошибка формата отсекается при проверке конфигурации
+	routeTimeouts, _ := cfg.ParseRouteTimeouts()
+	routeDeadline := func(method, path string, fallback time.Duration) fiber.Handler {
+		if timeout, ok := routeTimeouts[method+" /v1"+path]; ok {
+			return middleware.Deadline(timeout)
+		}
+		return middleware.Deadline(fallback)
+	}
+	deadline := func(method, path string) fiber.Handler {
+		return routeDeadline(method, path, cfg.RequestTimeout)
+	}
 
 	// Инициализация обработчиков
-	taskHandler := handlers.NewTaskHandler(r.Service, r.Logger)
+	taskHandler := handlers.NewTaskHandler(r.Service, r.Logger, handlers.Limits{
+		BatchMaxSize:    cfg.BatchMaxSize,
+		BulkMaxAffected: cfg.BulkMaxAffected,
+		ImportMaxRows:   cfg.ImportMaxRows,
+	})
+
+	// Повтор создания с тем же Idempotency-Key не создаёт задачи второй раз. Маршруты с ключом перечислены
+	// в config.IdempotentRoutes: их бюджет проверяется против IDEMPOTENCY_LOCK_TIMEOUT
+	idempotency := func(c *fiber.Ctx) error { return c.Next() }
+	if r.Idempotency != nil {
+		idempotency = middleware.Idempotency(r.Idempotency, cfg.IdempotencyTTL, cfg.IdempotencyLockTimeout, r.Logger)
+	}
 
 	// Роуты для задач
-	apiGroup.Post("/create_task", taskHandler.CreateTask)
-	apiGroup.Get("/tasks/:id", taskHandler.GetTask)
+	apiGroup.Post("/create_task", deadline(fiber.MethodPost, "/create_task"),
+		middleware.BodyLimit(createTaskBodyLimit), idempotency, taskHandler.CreateTask)
+	// Двоеточие в пути экранировано, иначе Fiber считает :batchCreate параметром.
+	// Тело ограничено лимитом на задачу, умноженным на размер пакета, но не больше общего BODY_LIMIT:
+	// больший запрос сервер отклоняет раньше middleware
+	batchBodyLimit := min(createTaskBodyLimit*cfg.BatchMaxSize, app.Config().BodyLimit)
+	apiGroup.Post("/tasks\\:batchCreate", deadline(fiber.MethodPost, "/tasks:batchCreate"),
+		middleware.BodyLimit(batchBodyLimit), idempotency, taskHandler.CreateTasks)
+	apiGroup.Post("/tasks\\:batchUpdate", deadline(fiber.MethodPost, "/tasks:batchUpdate"),
+		middleware.BodyLimit(batchBodyLimit), taskHandler.UpdateTasks)
+	apiGroup.Post("/tasks\\:batchDelete", deadline(fiber.MethodPost, "/tasks:batchDelete"),
+		middleware.BodyLimit(batchBodyLimit), taskHandler.DeleteTasks)
+	apiGroup.Get("/tasks", deadline(fiber.MethodGet, "/tasks"), taskHandler.ListTasks)
+	// Регистрируется до /tasks/:id, иначе export разбирается как ID
+	// Выгрузка длится дольше обычного запроса, её бюджет - EXPORT_TIMEOUT
+	apiGroup.Get("/tasks/export", routeDeadline(fiber.MethodGet, "/tasks/export", cfg.ExportTimeout), taskHandler.ExportTasks)
+	// Загрузка ограничена лимитом на задачу, умноженным на IMPORT_MAX_ROWS, и так же не больше BODY_LIMIT
+	importBodyLimit := min(createTaskBodyLimit*cfg.ImportMaxRows, app.Config().BodyLimit)
+	apiGroup.Post("/tasks/import", deadline(fiber.MethodPost, "/tasks/import"),
+		middleware.BodyLimit(importBodyLimit), taskHandler.ImportTasks)
+	apiGroup.Get("/tasks/:id", deadline(fiber.MethodGet, "/tasks/:id"), taskHandler.GetTask)
+	apiGroup.Patch("/tasks/:id", deadline(fiber.MethodPatch, "/tasks/:id"),
+		middleware.BodyLimit(createTaskBodyLimit), taskHandler.UpdateTask)
+	apiGroup.Delete("/tasks/:id", deadline(fiber.MethodDelete, "/tasks/:id"), taskHandler.DeleteTask)
+
+	// Роуты для подписок на события, только со scope tasks:admin
+	if r.Webhooks != nil {
+		webhookHandler := handlers.NewWebhookHandler(r.Webhooks, r.Logger)
+		webhooks := apiGroup.Group("/webhooks", middleware.RequireScope(service.ScopeAdmin))
+		webhooks.Post("/", deadline(fiber.MethodPost, "/webhooks"),
+			middleware.BodyLimit(createTaskBodyLimit), webhookHandler.CreateWebhook)
+		webhooks.Get("/", deadline(fiber.MethodGet, "/webhooks"), webhookHandler.ListWebhooks)
+		webhooks.Get("/:id", deadline(fiber.MethodGet, "/webhooks/:id"), webhookHandler.GetWebhook)
+		webhooks.Patch("/:id", deadline(fiber.MethodPatch, "/webhooks/:id"),
+			middleware.BodyLimit(createTaskBodyLimit), webhookHandler.UpdateWebhook)
+		webhooks.Delete("/:id", deadline(fiber.MethodDelete, "/webhooks/:id"), webhookHandler.DeleteWebhook)
+		webhooks.Get("/:id/deliveries", deadline(fiber.MethodGet, "/webhooks/:id/deliveries"), webhookHandler.ListDeliveries)
+		webhooks.Post("/:id/deliveries/:delivery_id/replay", deadline(fiber.MethodPost, "/webhooks/:id/deliveries/:delivery_id/replay"),
+			webhookHandler.ReplayDelivery)
+	}
 
 	return app
 }
+
+// errorHandler - ошибки уровня Fiber (неизвестный маршрут, превышение BodyLimit) в формате API
+func errorHandler(ctx *fiber.Ctx, err error) error {
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		switch fiberErr.Code {
+		case fiber.StatusNotFound:
+			return dto.NotFoundError(ctx, fiberErr.Message)
+		case fiber.StatusRequestEntityTooLarge:
+			return dto.PayloadTooLargeError(ctx, fmt.Sprintf("Request body exceeds %d bytes", ctx.App().Config().BodyLimit))
+		}
+	}
+
+	return dto.InternalServerError(ctx)
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"simple-service/internal/config"
+	"simple-service/internal/dto"
+	"simple-service/internal/repo/memory"
+	"simple-service/internal/service"
+)
+
+const testSecret = "test-secret"
+
+// serveTestApp - сервер на локальном порту: общий BODY_LIMIT проверяет сервер до маршрутов,
+// поэтому app.Test такой запрос не выполняет. Возвращает базовый URL
+func serveTestApp(t *testing.T, cfg config.Rest) string {
+	t.Helper()
+
+	logger := zap.NewNop().Sugar()
+	app := NewRouters(&Routers{Service: service.NewService(memory.NewRepository(), logger), Logger: logger},
+		cfg, NewSettings(NewRuntimeConfig(cfg)))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = app.Listener(ln) }()
+	t.Cleanup(func() { _ = app.Shutdown() })
+
+	return "http://" + ln.Addr().String()
+}
+
+// batchBody - тело batchCreate из задачи с заголовком такой длины, чтобы тело заняло size байт
+func batchBody(size int) string {
+	const prefix, suffix = `{"tasks":[{"title":"`, `"}]}`
+	return prefix + strings.Repeat("a", size-len(prefix)-len(suffix)) + suffix
+}
+
+// importBody - NDJSON из строк по 1 КБ общим размером size байт
+func importBody(size int) string {
+	line := `{"title":"` + strings.Repeat("a", 1024-len(`{"title":""}`+"\n")) + "\"}\n"
+	body := strings.Repeat(line, size/len(line))
+	return body + strings.Repeat(" ", size-len(body))
+}
+
+func TestBodyLimits(t *testing.T) {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "test"}).SignedString([]byte(testSecret))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		path         string
+		contentType  string
+		body         string
+		maxRows      int
+		expectedDesc string
+	}{
+		{
+			name:         "Лимит пакета больше BODY_LIMIT - действует BODY_LIMIT",
+			path:         "/v1/tasks:batchCreate",
+			contentType:  "application/json",
+			body:         batchBody(64*1024 + 1),
+			maxRows:      1000,
+			expectedDesc: "Request body exceeds 65536 bytes",
+		},
+		{
+			name:         "Лимит пакета меньше BODY_LIMIT",
+			path:         "/v1/tasks:batchCreate",
+			contentType:  "application/json",
+			body:         batchBody(2*createTaskBodyLimit + 1),
+			maxRows:      2,
+			expectedDesc: "Request body exceeds 32768 bytes",
+		},
+		{
+			name:         "Лимит загрузки больше BODY_LIMIT - действует BODY_LIMIT",
+			path:         "/v1/tasks/import",
+			contentType:  "application/x-ndjson",
+			body:         importBody(64*1024 + 1),
+			maxRows:      1000,
+			expectedDesc: "Request body exceeds 65536 bytes",
+		},
+		{
+			name:         "Лимит загрузки меньше BODY_LIMIT",
+			path:         "/v1/tasks/import",
+			contentType:  "application/x-ndjson",
+			body:         importBody(2*createTaskBodyLimit + 1),
+			maxRows:      2,
+			expectedDesc: "Request body exceeds 32768 bytes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url := serveTestApp(t, config.Rest{
+				Token:          testSecret,
+				RequestTimeout: time.Minute,
+				BodyLimit:      64 * 1024,
+				BatchMaxSize:   tt.maxRows,
+				ImportMaxRows:  tt.maxRows,
+			})
+
+			req, err := http.NewRequest(http.MethodPost, url+tt.path, strings.NewReader(tt.body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", tt.contentType)
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			var body dto.Response
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+			require.NotNil(t, body.Error)
+			assert.Equal(t, dto.PayloadTooLarge, body.Error.Code)
+			assert.Equal(t, tt.expectedDesc, body.Error.Desc)
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+
+	"simple-service/internal/dto"
+)
+
+// Общий строгий разбор JSON тела запроса для всех обработчиков
+
+const jsonContentType = "application/json"
+
+// DecodeError - ошибка разбора тела запроса, содержит HTTP статус и код ошибки для ответа
+type DecodeError struct {
+	Status int
+	Code   string
+	Desc   string
+}
+
+func (e *DecodeError) Error() string {
+	return e.Desc
+}
+
+// DecodeJSON - разбор JSON тела запроса в dst.
+// Требует Content-Type application/json, запрещает неизвестные поля,
+// дублирующиеся ключи и данные после JSON значения
+func DecodeJSON(ctx *fiber.Ctx, dst any) error {
+	if err := checkContentType(ctx.Get(fiber.HeaderContentType), jsonContentType); err != nil {
+		return err
+	}
+
+	body := ctx.Body()
+	if len(bytes.TrimSpace(body)) == 0 {
+		return badFormat("Request body is empty")
+	}
+
+	// Первый проход - синтаксис, дубликаты ключей и хвостовые данные
+	if err := checkStructure(body); err != nil {
+		return err
+	}
+
+	// Второй проход - декодирование в структуру с запретом неизвестных полей
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return decodeFailure(body, err)
+	}
+
+	return nil
+}
+
+// RespondDecodeError - формирование ответа для ошибки, полученной из DecodeJSON
+func RespondDecodeError(ctx *fiber.Ctx, err error) error {
+	dErr, ok := err.(*DecodeError)
+	if !ok {
+		return dto.BadResponseError(ctx, dto.FieldBadFormat, "Invalid request body")
+	}
+
+	switch dErr.Status {
+	case fiber.StatusUnsupportedMediaType:
+		return dto.UnsupportedMediaTypeError(ctx, dErr.Desc)
+	case fiber.StatusRequestEntityTooLarge:
+		return dto.PayloadTooLargeError(ctx, dErr.Desc)
+	default:
+		return dto.BadResponseError(ctx, dErr.Code, dErr.Desc)
+	}
+}
+
+// checkContentType - Content-Type с media type expected в UTF-8
+func checkContentType(header, expected string) error {
+	if header == "" {
+		return unsupportedMediaType("Content-Type header is required, expected " + expected)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(header)
+	if err != nil || mediaType != expected {
+		return unsupportedMediaType("Unsupported Content-Type " + header + ", expected " + expected)
+	}
+
+	if charset, ok := params["charset"]; ok && !strings.EqualFold(charset, "utf-8") {
+		return unsupportedMediaType("Unsupported charset " + charset + ", expected utf-8")
+	}
+
+	return nil
+}
+
+// checkStructure - проверка синтаксиса, дубликатов ключей и данных после JSON значения
+func checkStructure(body []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(body))
+
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		return decodeFailure(body, err)
+	}
+
+	// Всё, кроме пробельных символов после первого значения, считается лишними данными
+	end := dec.InputOffset()
+	if rest := bytes.TrimLeft(body[end:], " \t\r\n"); len(rest) > 0 {
+		line, col := position(body, int64(len(body)-len(rest)))
+		return badFormat(fmt.Sprintf("Unexpected data after JSON value at line %d, column %d", line, col))
+	}
+
+	// Значение уже синтаксически корректно, остаётся проверить уникальность ключей
+	tokens := json.NewDecoder(bytes.NewReader(raw))
+	tokens.UseNumber()
+
+	return walkValue(tokens, raw, "")
+}
+
+func walkValue(dec *json.Decoder, body []byte, path string) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return decodeFailure(body, err)
+	}
+
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return nil
+	}
+
+	switch delim {
+	case '{':
+		seen := make(map[string]struct{})
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return decodeFailure(body, err)
+			}
+			key, _ := keyTok.(string)
+			if _, dup := seen[key]; dup {
+				line, col := position(body, dec.InputOffset())
+				return badFormat(fmt.Sprintf("Duplicate key %q at line %d, column %d", path+key, line, col))
+			}
+			seen[key] = struct{}{}
+
+			if err := walkValue(dec, body, path+key+"."); err != nil {
+				return err
+			}
+		}
+	case '[':
+		for dec.More() {
+			if err := walkValue(dec, body, path); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Закрывающая скобка объекта или массива
+	if _, err := dec.Token(); err != nil {
+		return decodeFailure(body, err)
+	}
+
+	return nil
+}
+
+// decodeFailure - перевод ошибок encoding/json в DecodeError с позицией в теле запроса
+func decodeFailure(body []byte, err error) error {
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		// Offset указывает на байт после ошибочного символа
+		line, col := position(body, e.Offset-1)
+		return badFormat(fmt.Sprintf("Invalid JSON at line %d, column %d: %s", line, col, e.Error()))
+	case *json.UnmarshalTypeError:
+		line, col := position(body, e.Offset)
+		return badFormat(fmt.Sprintf("Invalid value for field %q at line %d, column %d: expected %s, got %s",
+			e.Field, line, col, e.Type.String(), e.Value))
+	}
+
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		line, col := position(body, int64(len(body)))
+		return badFormat(fmt.Sprintf("Invalid JSON at line %d, column %d: unexpected end of input", line, col))
+	}
+
+	// encoding/json не экспортирует тип ошибки для неизвестного поля
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return badFormat("Unknown field " + field)
+	}
+
+	return badFormat("Invalid request body: " + err.Error())
+}
+
+// position - номер строки и колонки (с единицы) для смещения в байтах
+func position(body []byte, offset int64) (int, int) {
+	if offset > int64(len(body)) {
+		offset = int64(len(body))
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	prefix := body[:offset]
+	line := bytes.Count(prefix, []byte("\n")) + 1
+	lastLine := prefix[bytes.LastIndexByte(prefix, '\n')+1:]
+
+	return line, utf8.RuneCount(lastLine) + 1
+}
+
+func badFormat(desc string) error {
+	return &DecodeError{Status: fiber.StatusBadRequest, Code: dto.FieldBadFormat, Desc: desc}
+}
+
+func unsupportedMediaType(desc string) error {
+	return &DecodeError{Status: fiber.StatusUnsupportedMediaType, Code: dto.UnsupportedMediaType, Desc: desc}
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+type decodeTestRequest struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Priority    int    `json:"priority"`
+}
+
+func TestDecodeJSON(t *testing.T) {
+	app := fiber.New()
+	app.Post("/test", func(c *fiber.Ctx) error {
+		var req decodeTestRequest
+		if err := DecodeJSON(c, &req); err != nil {
+			return RespondDecodeError(c, err)
+		}
+		return c.JSON(req)
+	})
+
+	tests := []struct {
+		name           string
+		contentType    string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Валидный запрос",
+			contentType:    "application/json",
+			body:           `{"title":"Задача","description":"Описание","priority":1}`,
+			expectedStatus: 200,
+			expectedBody:   `{"title":"Задача","description":"Описание","priority":1}`,
+		},
+		{
+			name:           "Content-Type с charset utf-8",
+			contentType:    "application/json; charset=UTF-8",
+			body:           `{"title":"Задача"}`,
+			expectedStatus: 200,
+			expectedBody:   `{"title":"Задача","description":"","priority":0}`,
+		},
+		{
+			name:           "Отсутствует Content-Type",
+			contentType:    "",
+			body:           `{"title":"Задача"}`,
+			expectedStatus: 415,
+			expectedBody:   `{"status":"error","error":{"code":"UNSUPPORTED_MEDIA_TYPE","desc":"Content-Type header is required, expected application/json"}}`,
+		},
+		{
+			name:           "Неподдерживаемый Content-Type",
+			contentType:    "text/plain",
+			body:           `{"title":"Задача"}`,
+			expectedStatus: 415,
+			expectedBody:   `{"status":"error","error":{"code":"UNSUPPORTED_MEDIA_TYPE","desc":"Unsupported Content-Type text/plain, expected application/json"}}`,
+		},
+		{
+			name:           "Неподдерживаемая кодировка",
+			contentType:    "application/json; charset=latin1",
+			body:           `{"title":"Задача"}`,
+			expectedStatus: 415,
+			expectedBody:   `{"status":"error","error":{"code":"UNSUPPORTED_MEDIA_TYPE","desc":"Unsupported charset latin1, expected utf-8"}}`,
+		},
+		{
+			name:           "Пустое тело",
+			contentType:    "application/json",
+			body:           "  ",
+			expectedStatus: 400,
+			expectedBody:   `{"status":"error","error":{"code":"FIELD_BADFORMAT","desc":"Request body is empty"}}`,
+		},
+		{
+			name:           "Синтаксическая ошибка с позицией",
+			contentType:    "application/json",
+			body:           "{\n  \"title\": \"Задача\",\n}",
+			expectedStatus: 400,
+			expectedBody:   `{"status":"error","error":{"code":"FIELD_BADFORMAT","desc":"Invalid JSON at line 3, column 1: invalid character '}' looking for beginning of object key string"}}`,
+		},
+		{
+			name:           "Обрезанный JSON",
+			contentType:    "application/json",
+			body:           `{"title":"Задача"`,
+			expectedStatus: 400,
+			expectedBody:   `{"status":"error","error":{"code":"FIELD_BADFORMAT","desc":"Invalid JSON at line 1, column 18: unexpected end of input"}}`,
+		},
+		{
+			name:           "Неизвестное поле",
+			contentType:    "application/json",
+			body:           `{"title":"Задача","owner":"admin"}`,
+			expectedStatus: 400,
+			expectedBody:   `{"status":"error","error":{"code":"FIELD_BADFORMAT","desc":"Unknown field \"owner\""}}`,
+		},
+		{
+			name:           "Дублирующийся ключ",
+			contentType:    "application/json",
+			body:           `{"title":"Первая","title":"Вторая"}`,
+			expectedStatus: 400,
+			expectedBody:   `{"status":"error","error":{"code":"FIELD_BADFORMAT","desc":"Duplicate key \"title\" at line 1, column 26"}}`,
+		},
+		{
+			name:           "Данные после JSON значения",
+			contentType:    "application/json",
+			body:           `{"title":"Задача"} {"title":"Ещё одна"}`,
+			expectedStatus: 400,
+			expectedBody:   `{"status":"error","error":{"code":"FIELD_BADFORMAT","desc":"Unexpected data after JSON value at line 1, column 20"}}`,
+		},
+		{
+			name:           "Неверный тип поля",
+			contentType:    "application/json",
+			body:           `{"title":"Задача","priority":"high"}`,
+			expectedStatus: 400,
+			expectedBody:   `{"status":"error","error":{"code":"FIELD_BADFORMAT","desc":"Invalid value for field \"priority\" at line 1, column 36: expected int, got string"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/test", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			resp, err := app.Test(req)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+
+			body, err := io.ReadAll(resp.Body)
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.expectedBody, string(body))
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package handlers
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"fmt"
 	"strconv"
 
 	"simple-service/internal/dto"
@@ -12,15 +14,30 @@ import (
 	"go.uber.org/zap"
 )
 
+// defaultListLimit - размер страницы списка задач, если limit не передан
+const defaultListLimit = 50
+
+// Limits - ограничения пакетных и массовых операций
+type Limits struct {
+	// BatchMaxSize - максимум задач в запросе пакетного создания
+	BatchMaxSize int
+	// BulkMaxAffected - максимум задач, затрагиваемых массовой операцией без scope tasks:admin
+	BulkMaxAffected int
+	// ImportMaxRows - максимум задач в файле загрузки
+	ImportMaxRows int
+}
+
 type TaskHandler struct {
 	service service.Service
 	log     *zap.SugaredLogger
+	limits  Limits
 }
 
-func NewTaskHandler(svc service.Service, logger *zap.SugaredLogger) *TaskHandler {
+func NewTaskHandler(svc service.Service, logger *zap.SugaredLogger, limits Limits) *TaskHandler {
 	return &TaskHandler{
 		service: svc,
 		log:     logger,
+		limits:  limits,
 	}
 }
 
@@ -31,26 +48,33 @@ func NewTaskHandler(svc service.Service, logger *zap.SugaredLogger) *TaskHandler
 // @Accept json
 // @Produce json
 // @Param request body dto.TaskRequest true "Task data"
+// @Param Idempotency-Key header string false "Key for safe retries: a repeated request with the same key and body returns the saved response; requires a token with sub claim"
 // @Success 200 {object} dto.SuccessResponse{data=dto.CreateTaskResponse}
 // @Failure 400 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 413 {object} dto.ErrorResponse
+// @Failure 415 {object} dto.ErrorResponse
+// @Failure 422 {object} dto.ErrorResponse
 // @Failure 500 {object} dto.ErrorResponse
+// @Failure 503 {object} dto.ErrorResponse
+// @Failure 504 {object} dto.ErrorResponse
 // @Router /v1/create_task [post]
 func (h *TaskHandler) CreateTask(ctx *fiber.Ctx) error {
 	var req service.TaskRequest
 
-	if err := json.Unmarshal(ctx.Body(), &req); err != nil {
+	if err := DecodeJSON(ctx, &req); err != nil {
 		h.log.Errorw("Invalid request body", "error", err)
-		return dto.BadResponseError(ctx, dto.FieldBadFormat, "Invalid request body")
+		return RespondDecodeError(ctx, err)
 	}
 
-	if vErr := validator.Validate(ctx.Context(), req); vErr != nil {
+	if vErr := validator.Validate(ctx.UserContext(), req); vErr != nil {
 		return dto.BadResponseError(ctx, dto.FieldIncorrect, vErr.Error())
 	}
 
-	taskID, err := h.service.CreateTask(ctx.Context(), req)
+	taskID, err := h.service.CreateTask(ctx.UserContext(), req)
 	if err != nil {
 		h.log.Errorw("Failed to create task", "error", err)
-		return dto.InternalServerError(ctx)
+		return respondServiceError(ctx, err)
 	}
 
 	response := dto.SuccessResponse{
@@ -61,6 +85,88 @@ func (h *TaskHandler) CreateTask(ctx *fiber.Ctx) error {
 	return ctx.Status(fiber.StatusOK).JSON(response)
 }
 
+// CreateTasks creates several tasks in one request
+// @Summary Create tasks in batch
+// @Description Validates each task separately and creates the valid ones in one transaction.
+// @Description Results are listed in request order. In all_or_nothing mode (default) any invalid task
+// @Description rejects the batch with 400 and nothing is created; in best_effort mode invalid tasks
+// @Description are reported in results and the rest are created. Batch size is limited by BATCH_MAX_SIZE
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param request body dto.BatchCreateRequest true "Tasks and batch mode"
+// @Param Idempotency-Key header string false "Key for safe retries: a repeated request with the same key and body returns the saved response; requires a token with sub claim"
+// @Success 200 {object} dto.SuccessResponse{data=dto.BatchCreateResponse}
+// @Failure 400 {object} dto.Response{data=dto.BatchCreateResponse}
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 413 {object} dto.ErrorResponse
+// @Failure 415 {object} dto.ErrorResponse
+// @Failure 422 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Failure 503 {object} dto.ErrorResponse
+// @Failure 504 {object} dto.ErrorResponse
+// @Router /v1/tasks:batchCreate [post]
+func (h *TaskHandler) CreateTasks(ctx *fiber.Ctx) error {
+	var req service.BatchCreateRequest
+
+	if err := DecodeJSON(ctx, &req); err != nil {
+		h.log.Errorw("Invalid request body", "error", err)
+		return RespondDecodeError(ctx, err)
+	}
+
+	if vErr := validator.Validate(ctx.UserContext(), req); vErr != nil {
+		return dto.BadResponseError(ctx, dto.FieldIncorrect, vErr.Error())
+	}
+	if len(req.Tasks) == 0 {
+		return dto.BadResponseError(ctx, dto.FieldIncorrect, "At least one task is required")
+	}
+	if len(req.Tasks) > h.limits.BatchMaxSize {
+		return dto.BadResponseError(ctx, dto.FieldIncorrect,
+			fmt.Sprintf("Batch has %d tasks, at most %d allowed", len(req.Tasks), h.limits.BatchMaxSize))
+	}
+
+	// Каждая задача проверяется отдельно, positions - индексы прошедших проверку задач в запросе
+	results := make([]dto.BatchItemResult, len(req.Tasks))
+	valid := make([]service.TaskRequest, 0, len(req.Tasks))
+	positions := make([]int, 0, len(req.Tasks))
+	for i, task := range req.Tasks {
+		results[i].Index = i
+		if vErr := validator.Validate(ctx.UserContext(), task); vErr != nil {
+			results[i].Error = &dto.Error{Code: dto.FieldIncorrect, Desc: vErr.Error()}
+			continue
+		}
+		valid = append(valid, task)
+		positions = append(positions, i)
+	}
+
+	failed := len(req.Tasks) - len(valid)
+	if failed > 0 && req.Mode != service.BatchBestEffort {
+		for _, i := range positions {
+			results[i].Error = &dto.Error{Code: dto.BatchAborted, Desc: "Task was not created because the batch has invalid tasks"}
+		}
+		return dto.BatchRejectedError(ctx, fmt.Sprintf("%d of %d tasks are invalid", failed, len(req.Tasks)),
+			dto.BatchCreateResponse{Failed: len(req.Tasks), Results: results})
+	}
+
+	if len(valid) > 0 {
+		ids, err := h.service.CreateTasks(ctx.UserContext(), valid)
+		if err != nil {
+			h.log.Errorw("Failed to create tasks", "error", err, "count", len(valid))
+			return respondServiceError(ctx, err)
+		}
+		for j, i := range positions {
+			results[i].TaskID = ids[j]
+		}
+	}
+
+	response := dto.SuccessResponse{
+		Status: "success",
+		Data:   dto.BatchCreateResponse{Created: len(valid), Failed: failed, Results: results},
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(response)
+}
+
 // GetTask retrieves a task by ID
 // @Summary Get task by ID
 // @Description Retrieves a task by its ID
@@ -72,24 +178,127 @@ func (h *TaskHandler) CreateTask(ctx *fiber.Ctx) error {
 // @Failure 400 {object} dto.ErrorResponse
 // @Failure 404 {object} dto.ErrorResponse
 // @Failure 500 {object} dto.ErrorResponse
+// @Failure 503 {object} dto.ErrorResponse
+// @Failure 504 {object} dto.ErrorResponse
 // @Router /v1/tasks/{id} [get]
 func (h *TaskHandler) GetTask(ctx *fiber.Ctx) error {
 	// Получаем ID из параметров URL
-	idStr := ctx.Params("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		h.log.Errorw("Invalid task ID", "error", err, "id", idStr)
+	id, ok := h.taskID(ctx)
+	if !ok {
 		return dto.BadResponseError(ctx, dto.FieldIncorrect, "Invalid task ID")
 	}
 
 	// Получаем задачу из сервиса
-	task, err := h.service.GetTask(ctx.Context(), id)
+	task, err := h.service.GetTask(ctx.UserContext(), id)
 	if err != nil {
 		h.log.Errorw("Failed to get task", "error", err, "task_id", id)
-		if err.Error() == "task not found" {
-			return dto.NotFoundError(ctx, "Task not found")
-		}
-		return dto.InternalServerError(ctx)
+		return respondServiceError(ctx, err)
+	}
+
+	response := dto.SuccessResponse{
+		Status: "success",
+		Data:   task,
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(response)
+}
+
+// ListTasks returns a page of tasks
+// @Summary List tasks
+// @Description Returns tasks ordered by ID, optionally filtered by status
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param status query string false "Task status" Enums(new, in_progress, done)
+// @Param limit query int false "Page size (1-1000)" default(50)
+// @Param offset query int false "Number of tasks to skip" default(0)
+// @Success 200 {object} dto.SuccessResponse{data=dto.TaskListResponse}
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Failure 503 {object} dto.ErrorResponse
+// @Failure 504 {object} dto.ErrorResponse
+// @Router /v1/tasks [get]
+func (h *TaskHandler) ListTasks(ctx *fiber.Ctx) error {
+	filter := service.ListFilter{
+		Status: ctx.Query("status"),
+		Limit:  defaultListLimit,
+	}
+
+	var err error
+	if filter.Limit, err = queryInt(ctx, "limit", defaultListLimit); err != nil {
+		return dto.BadResponseError(ctx, dto.FieldBadFormat, "Invalid limit")
+	}
+	if filter.Offset, err = queryInt(ctx, "offset", 0); err != nil {
+		return dto.BadResponseError(ctx, dto.FieldBadFormat, "Invalid offset")
+	}
+
+	if vErr := validator.Validate(ctx.UserContext(), filter); vErr != nil {
+		return dto.BadResponseError(ctx, dto.FieldIncorrect, vErr.Error())
+	}
+
+	tasks, err := h.service.ListTasks(ctx.UserContext(), filter)
+	if err != nil {
+		h.log.Errorw("Failed to list tasks", "error", err)
+		return respondServiceError(ctx, err)
+	}
+
+	page := dto.TaskListResponse{
+		Tasks:  make([]dto.TaskResponse, 0, len(tasks)),
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+	}
+	for _, task := range tasks {
+		page.Tasks = append(page.Tasks, dto.TaskResponse(task))
+	}
+
+	response := dto.SuccessResponse{
+		Status: "success",
+		Data:   page,
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(response)
+}
+
+// UpdateTask partially updates a task
+// @Summary Update task
+// @Description Updates the fields present in the request body, other fields are left unchanged
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param request body dto.UpdateTaskRequest true "Fields to update"
+// @Success 200 {object} dto.SuccessResponse{data=dto.TaskResponse}
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 413 {object} dto.ErrorResponse
+// @Failure 415 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Failure 503 {object} dto.ErrorResponse
+// @Failure 504 {object} dto.ErrorResponse
+// @Router /v1/tasks/{id} [patch]
+func (h *TaskHandler) UpdateTask(ctx *fiber.Ctx) error {
+	id, ok := h.taskID(ctx)
+	if !ok {
+		return dto.BadResponseError(ctx, dto.FieldIncorrect, "Invalid task ID")
+	}
+
+	var req service.UpdateTaskRequest
+	if err := DecodeJSON(ctx, &req); err != nil {
+		h.log.Errorw("Invalid request body", "error", err)
+		return RespondDecodeError(ctx, err)
+	}
+
+	if req.Empty() {
+		return dto.BadResponseError(ctx, dto.FieldIncorrect, "At least one field is required")
+	}
+	if vErr := validator.Validate(ctx.UserContext(), req); vErr != nil {
+		return dto.BadResponseError(ctx, dto.FieldIncorrect, vErr.Error())
+	}
+
+	task, err := h.service.UpdateTask(ctx.UserContext(), id, req)
+	if err != nil {
+		h.log.Errorw("Failed to update task", "error", err, "task_id", id)
+		return respondServiceError(ctx, err)
 	}
 
 	response := dto.SuccessResponse{
@@ -99,3 +308,204 @@ func (h *TaskHandler) GetTask(ctx *fiber.Ctx) error {
 
 	return ctx.Status(fiber.StatusOK).JSON(response)
 }
+
+// DeleteTask deletes a task
+// @Summary Delete task
+// @Description Deletes a task by its ID
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Success 204
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Failure 503 {object} dto.ErrorResponse
+// @Failure 504 {object} dto.ErrorResponse
+// @Router /v1/tasks/{id} [delete]
+func (h *TaskHandler) DeleteTask(ctx *fiber.Ctx) error {
+	id, ok := h.taskID(ctx)
+	if !ok {
+		return dto.BadResponseError(ctx, dto.FieldIncorrect, "Invalid task ID")
+	}
+
+	if err := h.service.DeleteTask(ctx.UserContext(), id); err != nil {
+		h.log.Errorw("Failed to delete task", "error", err, "task_id", id)
+		return respondServiceError(ctx, err)
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// UpdateTasks updates tasks selected by IDs or filter
+// @Summary Update tasks in bulk
+// @Description Applies the patch to all selected tasks in one transaction. With dry_run nothing is changed,
+// @Description the response lists the tasks that would be updated. If more than BULK_MAX_AFFECTED tasks match,
+// @Description the request fails with 422 unless override_limit is set by a token with tasks:admin scope
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param request body dto.BulkUpdateRequest true "Selection and patch"
+// @Success 200 {object} dto.SuccessResponse{data=dto.BulkResponse}
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 413 {object} dto.ErrorResponse
+// @Failure 415 {object} dto.ErrorResponse
+// @Failure 422 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Failure 503 {object} dto.ErrorResponse
+// @Failure 504 {object} dto.ErrorResponse
+// @Router /v1/tasks:batchUpdate [post]
+func (h *TaskHandler) UpdateTasks(ctx *fiber.Ctx) error {
+	var req service.BulkUpdateRequest
+	if err := DecodeJSON(ctx, &req); err != nil {
+		h.log.Errorw("Invalid request body", "error", err)
+		return RespondDecodeError(ctx, err)
+	}
+
+	if vErr := validator.Validate(ctx.UserContext(), req); vErr != nil {
+		return dto.BadResponseError(ctx, dto.FieldIncorrect, vErr.Error())
+	}
+	if req.Patch.Empty() {
+		return dto.BadResponseError(ctx, dto.FieldIncorrect, "At least one field is required in patch")
+	}
+	sel, desc := selector(req.IDs, req.Filter)
+	if desc != "" {
+		return dto.BadResponseError(ctx, dto.FieldIncorrect, desc)
+	}
+	opts, ok := h.bulkOptions(ctx, req.DryRun, req.OverrideLimit)
+	if !ok {
+		return dto.ForbiddenError(ctx, "override_limit requires "+service.ScopeAdmin+" scope")
+	}
+
+	result, err := h.service.UpdateTasks(ctx.UserContext(), sel, req.Patch, opts)
+	if err != nil {
+		h.log.Errorw("Failed to update tasks", "error", err)
+		return respondServiceError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(dto.SuccessResponse{
+		Status: "success",
+		Data:   dto.BulkResponse(*result),
+	})
+}
+
+// DeleteTasks deletes tasks selected by IDs or filter
+// @Summary Delete tasks in bulk
+// @Description Deletes all selected tasks in one transaction. With dry_run nothing is deleted,
+// @Description the response lists the tasks that would be deleted. If more than BULK_MAX_AFFECTED tasks match,
+// @Description the request fails with 422 unless override_limit is set by a token with tasks:admin scope
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param request body dto.BulkDeleteRequest true "Selection"
+// @Success 200 {object} dto.SuccessResponse{data=dto.BulkResponse}
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 413 {object} dto.ErrorResponse
+// @Failure 415 {object} dto.ErrorResponse
+// @Failure 422 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Failure 503 {object} dto.ErrorResponse
+// @Failure 504 {object} dto.ErrorResponse
+// @Router /v1/tasks:batchDelete [post]
+func (h *TaskHandler) DeleteTasks(ctx *fiber.Ctx) error {
+	var req service.BulkDeleteRequest
+	if err := DecodeJSON(ctx, &req); err != nil {
+		h.log.Errorw("Invalid request body", "error", err)
+		return RespondDecodeError(ctx, err)
+	}
+
+	if vErr := validator.Validate(ctx.UserContext(), req); vErr != nil {
+		return dto.BadResponseError(ctx, dto.FieldIncorrect, vErr.Error())
+	}
+	sel, desc := selector(req.IDs, req.Filter)
+	if desc != "" {
+		return dto.BadResponseError(ctx, dto.FieldIncorrect, desc)
+	}
+	opts, ok := h.bulkOptions(ctx, req.DryRun, req.OverrideLimit)
+	if !ok {
+		return dto.ForbiddenError(ctx, "override_limit requires "+service.ScopeAdmin+" scope")
+	}
+
+	result, err := h.service.DeleteTasks(ctx.UserContext(), sel, opts)
+	if err != nil {
+		h.log.Errorw("Failed to delete tasks", "error", err)
+		return respondServiceError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(dto.SuccessResponse{
+		Status: "success",
+		Data:   dto.BulkResponse(*result),
+	})
+}
+
+// selector - отбор задач массовой операции: ровно одно из ids и filter, у фильтра хотя бы одно условие.
+// Непустое описание - ошибка запроса
+func selector(ids []int, filter *service.TaskFilter) (service.TaskSelector, string) {
+	switch {
+	case len(ids) > 0 && filter != nil:
+		return service.TaskSelector{}, "Either ids or filter is allowed, not both"
+	case len(ids) > 0:
+		return service.TaskSelector{IDs: ids}, ""
+	case filter == nil:
+		return service.TaskSelector{}, "Either ids or filter is required"
+	case filter.Empty():
+		return service.TaskSelector{}, "Filter must have at least one condition"
+	}
+	return service.TaskSelector{Filter: *filter}, ""
+}
+
+// bulkOptions - параметры массовой операции; снять ограничение можно только со scope tasks:admin
+func (h *TaskHandler) bulkOptions(ctx *fiber.Ctx, dryRun, overrideLimit bool) (service.BulkOptions, bool) {
+	opts := service.BulkOptions{DryRun: dryRun, MaxAffected: h.limits.BulkMaxAffected}
+	if overrideLimit {
+		if !service.HasScope(ctx.UserContext(), service.ScopeAdmin) {
+			return opts, false
+		}
+		opts.MaxAffected = 0
+	}
+	return opts, true
+}
+
+// respondServiceError - ответ на ошибку сервиса: 404 для отсутствующей задачи, 422 при превышении
+// ограничения массовой операции, 504 при исчерпании бюджета времени, 503 с Retry-After при недоступном хранилище, иначе 500.
+// Обрыв соединения после записи (service.ErrOutcomeUnknown) - 500 с кодом OUTCOME_UNKNOWN: повтор мог бы выполнить запись дважды
+func respondServiceError(ctx *fiber.Ctx, err error) error {
+	var unavailable *service.UnavailableError
+	var limit *service.BulkLimitError
+	switch {
+	case errors.Is(err, service.ErrTaskNotFound):
+		return dto.NotFoundError(ctx, "Task not found")
+	case errors.As(err, &limit):
+		return dto.LimitExceededError(ctx, fmt.Sprintf("Operation affects %d tasks, limit is %d; set override_limit with %s scope",
+			limit.Affected, limit.Limit, service.ScopeAdmin))
+	case errors.Is(err, service.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return dto.GatewayTimeoutError(ctx, "Request deadline exceeded")
+	case errors.As(err, &unavailable):
+		return dto.ServiceUnavailableError(ctx, unavailable.RetryAfter, "Storage is temporarily unavailable")
+	case errors.Is(err, service.ErrOutcomeUnknown):
+		return dto.OutcomeUnknownError(ctx, "Storage connection was lost, the request may have been applied")
+	}
+	return dto.InternalServerError(ctx)
+}
+
+// taskID - ID задачи из параметров URL
+func (h *TaskHandler) taskID(ctx *fiber.Ctx) (int, bool) {
+	idStr := ctx.Params("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		h.log.Errorw("Invalid task ID", "error", err, "id", idStr)
+		return 0, false
+	}
+	return id, true
+}
+
+// queryInt - целочисленный query параметр, def если параметр не передан
+func queryInt(ctx *fiber.Ctx, key string, def int) (int, error) {
+	value := ctx.Query(key)
+	if value == "" {
+		return def, nil
+	}
+	return strconv.Atoi(value)
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"simple-service/internal/dto"
+	"simple-service/internal/service"
+)
+
+// ReadinessChecker - проверка готовности хранилища принимать запросы
+type ReadinessChecker interface {
+	Ready(ctx context.Context) error
+}
+
+// breakerReporter - хранилище с circuit breaker
+type breakerReporter interface {
+	BreakerState() string
+}
+
+type HealthHandler struct {
+	storage ReadinessChecker
+	log     *zap.SugaredLogger
+}
+
+func NewHealthHandler(storage ReadinessChecker, logger *zap.SugaredLogger) *HealthHandler {
+	return &HealthHandler{
+		storage: storage,
+		log:     logger,
+	}
+}
+
+// Ready reports whether the service can serve requests
+// @Summary Readiness probe
+// @Description Checks storage availability and reports the database circuit breaker state
+// @Tags health
+// @Produce json
+// @Success 200 {object} dto.SuccessResponse{data=dto.ReadinessResponse}
+// @Failure 503 {object} dto.Response{data=dto.ReadinessResponse}
+// @Router /ready [get]
+func (h *HealthHandler) Ready(ctx *fiber.Ctx) error {
+	readiness := dto.ReadinessResponse{Storage: "ok"}
+	if reporter, ok := h.storage.(breakerReporter); ok {
+		readiness.Breaker = reporter.BreakerState()
+	}
+
+	if err := h.storage.Ready(ctx.UserContext()); err != nil {
+		h.log.Warnw("Storage is not ready", "error", err, "breaker", readiness.Breaker)
+		readiness.Storage = "unavailable"
+
+		var unavailable *service.UnavailableError
+		if errors.As(err, &unavailable) {
+			return dto.NotReadyError(ctx, unavailable.RetryAfter, readiness)
+		}
+		return dto.NotReadyError(ctx, 0, readiness)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(dto.SuccessResponse{
+		Status: "success",
+		Data:   readiness,
+	})
+}
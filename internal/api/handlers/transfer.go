@@ -0,0 +1,507 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"simple-service/internal/dto"
+	"simple-service/internal/service"
+	"simple-service/pkg/validator"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Выгрузка и загрузка задач в NDJSON и CSV. Формат файла один для обоих направлений:
+// выгруженный файл можно загрузить без изменений
+
+// formatContentTypes - media type файла для каждого формата
+var formatContentTypes = map[string]string{
+	service.FormatNDJSON: "application/x-ndjson",
+	service.FormatCSV:    "text/csv",
+}
+
+// csvColumns - колонки CSV в порядке выгрузки
+var csvColumns = []string{"id", "title", "description", "status", "created_at", "updated_at"}
+
+// utf8BOM - метка порядка байт, с которой CSV сохраняют табличные редакторы
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// transferQuery - query параметры выгрузки и загрузки
+type transferQuery struct {
+	Format     string `validate:"oneof=ndjson csv"`
+	Mode       string `validate:"oneof=all_or_nothing best_effort"`
+	IDs        string `validate:"oneof=reassign preserve"`
+	Timestamps string `validate:"oneof=reassign preserve"`
+}
+
+// ExportTasks streams tasks as NDJSON or CSV
+// @Summary Export tasks
+// @Description Streams all tasks matching the filter, ordered by ID, from one database snapshot.
+// @Description The response is sent in chunks as tasks are read, memory use does not depend on the number of tasks.
+// @Description If the export fails midway, the connection is closed without the final chunk, so a truncated file is reported as a read error
+// @Tags tasks
+// @Produce application/x-ndjson
+// @Produce text/csv
+// @Param format query string false "File format" Enums(ndjson, csv) default(ndjson)
+// @Param status query string false "Task status" Enums(new, in_progress, done)
+// @Param created_after query string false "Created at or after, RFC 3339" example(2024-01-01T00:00:00Z)
+// @Param created_before query string false "Created before, RFC 3339" example(2024-02-01T00:00:00Z)
+// @Success 200 {file} file "One task per line (NDJSON) or per row after the header (CSV)"
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Failure 503 {object} dto.ErrorResponse
+// @Failure 504 {object} dto.ErrorResponse
+// @Router /v1/tasks/export [get]
+func (h *TaskHandler) ExportTasks(ctx *fiber.Ctx) error {
+	query := transferQuery{Format: ctx.Query("format", service.FormatNDJSON), Mode: service.BatchAllOrNothing,
+		IDs: "reassign", Timestamps: "reassign"}
+	if vErr := validator.Validate(ctx.UserContext(), query); vErr != nil {
+		return dto.BadResponseError(ctx, dto.FieldIncorrect, vErr.Error())
+	}
+
+	filter := service.TaskFilter{Status: ctx.Query("status")}
+	var err error
+	if filter.CreatedAfter, err = queryTime(ctx, "created_after"); err != nil {
+		return dto.BadResponseError(ctx, dto.FieldBadFormat, "Invalid created_after, expected RFC 3339 time")
+	}
+	if filter.CreatedBefore, err = queryTime(ctx, "created_before"); err != nil {
+		return dto.BadResponseError(ctx, dto.FieldBadFormat, "Invalid created_before, expected RFC 3339 time")
+	}
+	if vErr := validator.Validate(ctx.UserContext(), filter); vErr != nil {
+		return dto.BadResponseError(ctx, dto.FieldIncorrect, vErr.Error())
+	}
+
+	cursor, err := h.service.ExportTasks(ctx.UserContext(), filter)
+	if err != nil {
+		h.log.Errorw("Failed to export tasks", "error", err)
+		return respondServiceError(ctx, err)
+	}
+
+	// Тело пишется в отдельной горутине и после возврата из обработчика, когда контекст запроса
+	// уже отменён. Бюджет времени запроса продолжает действовать
+	streamCtx, cancel := detach(ctx.UserContext())
+	conn := ctx.Context().Conn()
+	deadline, _ := streamCtx.Deadline()
+	cursor = &writeDeadlineCursor{TaskCursor: cursor, conn: conn, deadline: deadline}
+	format := query.Format
+
+	ctx.Set(fiber.HeaderContentType, formatContentTypes[format]+"; charset=utf-8")
+	ctx.Set(fiber.HeaderContentDisposition, `attachment; filename="tasks.`+format+`"`)
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+
+		n, err := writeTasks(streamCtx, w, cursor, format)
+		if closeErr := cursor.Close(context.WithoutCancel(streamCtx)); closeErr != nil {
+			h.log.Errorw("Failed to close export cursor", "error", closeErr)
+		}
+		if err != nil {
+			// Статус 200 уже отправлен: обрыв соединения без завершающего чанка - единственный
+			// способ сообщить клиенту, что файл неполный
+			h.log.Errorw("Task export interrupted", "error", err, "format", format, "exported", n)
+			_ = conn.Close()
+			return
+		}
+		h.log.Infow("Tasks exported", "format", format, "count", n)
+	})
+
+	return nil
+}
+
+// ImportTasks imports tasks from an NDJSON or CSV file
+// @Summary Import tasks
+// @Description Imports tasks from a file in the export format. Each row is validated like a created task,
+// @Description rows that fail are listed in the report with their line numbers. In all_or_nothing mode (default)
+// @Description any failed row rejects the whole file: 400 for invalid rows, 409 if preserved IDs are taken.
+// @Description ids=preserve keeps task IDs from the file (a row with a taken ID fails), timestamps=preserve keeps
+// @Description created_at and updated_at. Otherwise the storage assigns them. The file is limited by IMPORT_MAX_ROWS
+// @Tags tasks
+// @Accept application/x-ndjson
+// @Accept text/csv
+// @Produce json
+// @Param format query string false "File format, Content-Type must match" Enums(ndjson, csv) default(ndjson)
+// @Param mode query string false "Import mode" Enums(all_or_nothing, best_effort) default(all_or_nothing)
+// @Param ids query string false "Keep task IDs from the file" Enums(reassign, preserve) default(reassign)
+// @Param timestamps query string false "Keep created_at and updated_at from the file" Enums(reassign, preserve) default(reassign)
+// @Param file body string true "NDJSON lines or CSV with a header row: id, title, description, status, created_at, updated_at"
+// @Success 200 {object} dto.SuccessResponse{data=dto.ImportResponse}
+// @Failure 400 {object} dto.Response{data=dto.ImportResponse}
+// @Failure 409 {object} dto.Response{data=dto.ImportResponse}
+// @Failure 413 {object} dto.ErrorResponse
+// @Failure 415 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Failure 503 {object} dto.ErrorResponse
+// @Failure 504 {object} dto.ErrorResponse
+// @Router /v1/tasks/import [post]
+func (h *TaskHandler) ImportTasks(ctx *fiber.Ctx) error {
+	query := transferQuery{
+		Format:     ctx.Query("format", service.FormatNDJSON),
+		Mode:       ctx.Query("mode", service.BatchAllOrNothing),
+		IDs:        ctx.Query("ids", "reassign"),
+		Timestamps: ctx.Query("timestamps", "reassign"),
+	}
+	if vErr := validator.Validate(ctx.UserContext(), query); vErr != nil {
+		return dto.BadResponseError(ctx, dto.FieldIncorrect, vErr.Error())
+	}
+	if err := checkContentType(ctx.Get(fiber.HeaderContentType), formatContentTypes[query.Format]); err != nil {
+		return RespondDecodeError(ctx, err)
+	}
+	opts := service.ImportOptions{
+		Mode:               query.Mode,
+		PreserveIDs:        query.IDs == "preserve",
+		PreserveTimestamps: query.Timestamps == "preserve",
+	}
+
+	rows, err := parseImport(query.Format, ctx.Body(), h.limits.ImportMaxRows)
+	if err != nil {
+		h.log.Errorw("Invalid import file", "error", err)
+		return RespondDecodeError(ctx, err)
+	}
+	if len(rows) == 0 {
+		return dto.BadResponseError(ctx, dto.FieldIncorrect, "File has no tasks")
+	}
+
+	// lines - номера строк файла для задач, прошедших проверку
+	report := dto.ImportResponse{Errors: []dto.ImportRowError{}}
+	valid := make([]service.ImportTaskRequest, 0, len(rows))
+	lines := make([]int, 0, len(rows))
+	for _, row := range rows {
+		if row.err == nil {
+			if vErr := validator.Validate(ctx.UserContext(), row.task); vErr != nil {
+				row.err = &dto.Error{Code: dto.FieldIncorrect, Desc: vErr.Error()}
+			} else if opts.PreserveIDs && row.task.ID == 0 {
+				row.err = &dto.Error{Code: dto.FieldIncorrect, Desc: "Field is required for field: ID (ids=preserve)"}
+			}
+		}
+		if row.err != nil {
+			report.Errors = append(report.Errors, dto.ImportRowError{Line: row.line, Error: *row.err})
+			continue
+		}
+		valid = append(valid, row.task)
+		lines = append(lines, row.line)
+	}
+
+	if len(report.Errors) > 0 && opts.Mode != service.BatchBestEffort {
+		report.Failed = len(report.Errors)
+		return dto.ImportRejectedError(ctx, fiber.StatusBadRequest, dto.FieldIncorrect,
+			fmt.Sprintf("%d of %d rows are invalid", report.Failed, len(rows)), report)
+	}
+
+	if len(valid) > 0 {
+		ids, err := h.service.ImportTasks(ctx.UserContext(), valid, opts)
+		var conflict *service.ImportConflictError
+		if errors.As(err, &conflict) {
+			for _, i := range conflict.Indexes {
+				report.Errors = append(report.Errors, taskExists(lines[i], valid[i].ID))
+			}
+			report.Failed = len(report.Errors)
+			return dto.ImportRejectedError(ctx, fiber.StatusConflict, dto.TaskExists,
+				fmt.Sprintf("%d of %d tasks already exist", report.Failed, len(rows)), report)
+		}
+		if err != nil {
+			h.log.Errorw("Failed to import tasks", "error", err, "count", len(valid))
+			return respondServiceError(ctx, err)
+		}
+
+		for i, id := range ids {
+			if id == 0 {
+				report.Errors = append(report.Errors, taskExists(lines[i], valid[i].ID))
+				continue
+			}
+			report.Imported++
+		}
+		sort.SliceStable(report.Errors, func(i, j int) bool { return report.Errors[i].Line < report.Errors[j].Line })
+	}
+	report.Failed = len(report.Errors)
+
+	return ctx.Status(fiber.StatusOK).JSON(dto.SuccessResponse{
+		Status: "success",
+		Data:   report,
+	})
+}
+
+func taskExists(line, id int) dto.ImportRowError {
+	return dto.ImportRowError{Line: line, Error: dto.Error{Code: dto.TaskExists, Desc: fmt.Sprintf("Task %d already exists", id)}}
+}
+
+// detach - контекст с дедлайном ctx, который не отменяется вместе с ним
+func detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	if deadline, ok := ctx.Deadline(); ok {
+		return context.WithDeadline(context.WithoutCancel(ctx), deadline)
+	}
+	return context.WithCancel(context.WithoutCancel(ctx))
+}
+
+// writeDeadlineCursor - курсор, перед каждой порцией продлевающий дедлайн записи в соединение до бюджета
+// выгрузки: WRITE_TIMEOUT сервера отсчитывается от начала ответа и оборвал бы долгую выгрузку.
+// Нулевой deadline снимает ограничение
+type writeDeadlineCursor struct {
+	service.TaskCursor
+	conn     net.Conn
+	deadline time.Time
+}
+
+func (c *writeDeadlineCursor) Next(ctx context.Context) ([]service.TaskResponse, error) {
+	if err := c.conn.SetWriteDeadline(c.deadline); err != nil {
+		return nil, err
+	}
+	return c.TaskCursor.Next(ctx)
+}
+
+// queryTime - query параметр во времени RFC 3339, nil если параметр не передан
+func queryTime(ctx *fiber.Ctx, key string) (*time.Time, error) {
+	value := ctx.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// taskEncoder - запись задач в формате выгрузки
+type taskEncoder interface {
+	Encode(task service.TaskResponse) error
+	// Flush - отправка записанного клиенту
+	Flush() error
+}
+
+// writeTasks - задачи курсора в w порциями, каждая порция сразу отправляется. Возвращает число записанных задач
+func writeTasks(ctx context.Context, w *bufio.Writer, cursor service.TaskCursor, format string) (int, error) {
+	enc, err := newTaskEncoder(w, format)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for {
+		tasks, err := cursor.Next(ctx)
+		if err != nil {
+			return n, err
+		}
+		if len(tasks) == 0 {
+			return n, enc.Flush()
+		}
+		for _, task := range tasks {
+			if err := enc.Encode(task); err != nil {
+				return n, err
+			}
+			n++
+		}
+		if err := enc.Flush(); err != nil {
+			return n, err
+		}
+	}
+}
+
+func newTaskEncoder(w *bufio.Writer, format string) (taskEncoder, error) {
+	if format == service.FormatCSV {
+		enc := &csvEncoder{w: w, csv: csv.NewWriter(w)}
+		return enc, enc.csv.Write(csvColumns)
+	}
+	return &ndjsonEncoder{w: w, json: json.NewEncoder(w)}, nil
+}
+
+// ndjsonEncoder - задача в формате ответа API на строку
+type ndjsonEncoder struct {
+	w    *bufio.Writer
+	json *json.Encoder
+}
+
+func (e *ndjsonEncoder) Encode(task service.TaskResponse) error {
+	return e.json.Encode(dto.TaskResponse(task))
+}
+
+func (e *ndjsonEncoder) Flush() error {
+	return e.w.Flush()
+}
+
+// csvEncoder - строка на задачу в порядке csvColumns, время в RFC 3339 UTC
+type csvEncoder struct {
+	w   *bufio.Writer
+	csv *csv.Writer
+}
+
+func (e *csvEncoder) Encode(task service.TaskResponse) error {
+	return e.csv.Write([]string{
+		strconv.Itoa(task.ID),
+		task.Title,
+		task.Description,
+		task.Status,
+		task.CreatedAt.UTC().Format(time.RFC3339Nano),
+		task.UpdatedAt.UTC().Format(time.RFC3339Nano),
+	})
+}
+
+func (e *csvEncoder) Flush() error {
+	e.csv.Flush()
+	if err := e.csv.Error(); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+// importRow - строка файла загрузки, err - строка не разобрана или не прошла проверку
+type importRow struct {
+	line int
+	task service.ImportTaskRequest
+	err  *dto.Error
+}
+
+// parseImport - задачи файла в формате format. Ошибки отдельных строк попадают в importRow.err,
+// ошибки файла целиком (заголовок CSV, число строк больше maxRows) возвращаются как *DecodeError
+func parseImport(format string, body []byte, maxRows int) ([]importRow, error) {
+	body = bytes.TrimPrefix(body, utf8BOM)
+	if format == service.FormatCSV {
+		return parseCSV(body, maxRows)
+	}
+	return parseNDJSON(body, maxRows)
+}
+
+// parseNDJSON - объект задачи на строку, пустые строки пропускаются
+func parseNDJSON(body []byte, maxRows int) ([]importRow, error) {
+	rows := make([]importRow, 0)
+	for i, line := range bytes.Split(body, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if len(rows) == maxRows {
+			return nil, badFormat(fmt.Sprintf("File has more than %d rows", maxRows))
+		}
+
+		row := importRow{line: i + 1}
+		if err := decodeLine(line, &row.task); err != nil {
+			// Строка разбирается отдельно от файла, в сообщении подставляется её номер в файле
+			desc := strings.Replace(err.Error(), " at line 1, ", fmt.Sprintf(" at line %d, ", row.line), 1)
+			row.err = &dto.Error{Code: dto.FieldBadFormat, Desc: desc}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// decodeLine - строгий разбор строки NDJSON по тем же правилам, что и тело запроса в DecodeJSON
+func decodeLine(line []byte, dst any) error {
+	if err := checkStructure(line); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return decodeFailure(line, err)
+	}
+	return nil
+}
+
+// parseCSV - первая строка - заголовок с именами колонок из csvColumns в любом порядке, обязательна title
+func parseCSV(body []byte, maxRows int) ([]importRow, error) {
+	reader := csv.NewReader(bytes.NewReader(body))
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return []importRow{}, nil
+	}
+	if err != nil {
+		return nil, badFormat("Invalid CSV header: " + err.Error())
+	}
+	columns, err := csvHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]importRow, 0)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		// Лишние или недостающие поля - ошибка строки, остальные ошибки разбора (кавычки) сбивают чтение файла
+		var parseErr *csv.ParseError
+		if err != nil && !(errors.As(err, &parseErr) && errors.Is(err, csv.ErrFieldCount)) {
+			return nil, badFormat("Invalid CSV: " + err.Error())
+		}
+		if len(rows) == maxRows {
+			return nil, badFormat(fmt.Sprintf("File has more than %d rows", maxRows))
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := importRow{line: line}
+		if err != nil {
+			row.err = &dto.Error{Code: dto.FieldBadFormat, Desc: fmt.Sprintf("Row has %d fields, header has %d", len(record), len(header))}
+		} else if desc := csvTask(record, columns, &row.task); desc != "" {
+			row.err = &dto.Error{Code: dto.FieldBadFormat, Desc: desc}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// csvHeader - позиции колонок по именам; неизвестная или повторённая колонка - ошибка файла
+func csvHeader(header []string) (map[string]int, error) {
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		known := false
+		for _, column := range csvColumns {
+			known = known || column == name
+		}
+		if !known {
+			return nil, badFormat(fmt.Sprintf("Unknown CSV column %q, expected %s", name, strings.Join(csvColumns, ", ")))
+		}
+		if _, dup := columns[name]; dup {
+			return nil, badFormat(fmt.Sprintf("Duplicate CSV column %q", name))
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, badFormat("CSV header must have a title column")
+	}
+	return columns, nil
+}
+
+// csvTask - задача из полей строки CSV, пустые id и время - не заданы. Непустой результат - ошибка формата
+func csvTask(record []string, columns map[string]int, task *service.ImportTaskRequest) string {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok {
+			return record[i]
+		}
+		return ""
+	}
+
+	task.Title = field("title")
+	task.Description = field("description")
+	task.Status = field("status")
+
+	if value := field("id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Sprintf("Invalid id %q, expected integer", value)
+		}
+		task.ID = id
+	}
+	for name, dst := range map[string]**time.Time{"created_at": &task.CreatedAt, "updated_at": &task.UpdatedAt} {
+		value := field(name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return fmt.Sprintf("Invalid %s %q, expected RFC 3339 time", name, value)
+		}
+		*dst = &t
+	}
+	return ""
+}
@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"simple-service/internal/dto"
+	"simple-service/internal/repo/memory"
+	"simple-service/internal/service"
+)
+
+func newTransferApp(t *testing.T) *fiber.App {
+	t.Helper()
+	logger := zap.NewNop().Sugar()
+	h := NewTaskHandler(service.NewService(memory.NewRepository(), logger), logger, Limits{ImportMaxRows: 3})
+
+	app := fiber.New()
+	app.Get("/tasks/export", h.ExportTasks)
+	app.Post("/tasks/import", h.ImportTasks)
+	return app
+}
+
+func doTransfer(t *testing.T, app *fiber.App, method, target, contentType, body string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, target, strings.NewReader(body))
+	require.NoError(t, err)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(data)
+}
+
+// importReport - отчёт загрузки из ответа
+func importReport(t *testing.T, body string) dto.ImportResponse {
+	t.Helper()
+	var resp struct {
+		Data dto.ImportResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(body), &resp), body)
+	return resp.Data
+}
+
+func TestImportTasks(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		contentType    string
+		body           string
+		expectedStatus int
+		expectedReport dto.ImportResponse
+		expectedCode   string
+	}{
+		{
+			name:           "NDJSON с пустыми строками",
+			contentType:    "application/x-ndjson",
+			body:           "{\"title\":\"First\"}\n\n{\"title\":\"Second\",\"status\":\"done\"}\n",
+			expectedStatus: 200,
+			expectedReport: dto.ImportResponse{Imported: 2, Errors: []dto.ImportRowError{}},
+		},
+		{
+			name:           "CSV с BOM и колонками в другом порядке",
+			query:          "?format=csv",
+			contentType:    "text/csv; charset=utf-8",
+			body:           "\xef\xbb\xbfstatus,title\r\ndone,First\r\n,\"Second, with comma\"\r\n",
+			expectedStatus: 200,
+			expectedReport: dto.ImportResponse{Imported: 2, Errors: []dto.ImportRowError{}},
+		},
+		{
+			name:           "Ошибки строк отклоняют весь файл",
+			contentType:    "application/x-ndjson",
+			body:           "{\"title\":\"First\"}\n{\"title\":\"\"}\n\n{\"title\":\"Third\",\"priority\":1}\n",
+			expectedStatus: 400,
+			expectedReport: dto.ImportResponse{Failed: 2, Errors: []dto.ImportRowError{
+				{Line: 2, Error: dto.Error{Code: dto.FieldIncorrect, Desc: "Field is required for field: Title"}},
+				{Line: 4, Error: dto.Error{Code: dto.FieldBadFormat, Desc: `Unknown field "priority"`}},
+			}},
+			expectedCode: dto.FieldIncorrect,
+		},
+		{
+			name:           "best_effort загружает корректные строки",
+			query:          "?mode=best_effort",
+			contentType:    "application/x-ndjson",
+			body:           "{\"title\":\"First\"}\n{\"title\":\"Second\",\n{\"title\":\"Third\",\"status\":\"archived\"}\n",
+			expectedStatus: 200,
+			expectedReport: dto.ImportResponse{Imported: 1, Failed: 2, Errors: []dto.ImportRowError{
+				{Line: 2, Error: dto.Error{Code: dto.FieldBadFormat, Desc: "Invalid JSON at line 2, column 19: unexpected end of input"}},
+				{Line: 3, Error: dto.Error{Code: dto.FieldIncorrect, Desc: "Field must be one of (new, in_progress, done) for field: Status"}},
+			}},
+		},
+		{
+			name:           "Строка CSV с другим числом полей",
+			query:          "?format=csv&mode=best_effort",
+			contentType:    "text/csv",
+			body:           "title,description\nFirst,One\nSecond\nThird,Three,extra\n",
+			expectedStatus: 200,
+			expectedReport: dto.ImportResponse{Imported: 1, Failed: 2, Errors: []dto.ImportRowError{
+				{Line: 3, Error: dto.Error{Code: dto.FieldBadFormat, Desc: "Row has 1 fields, header has 2"}},
+				{Line: 4, Error: dto.Error{Code: dto.FieldBadFormat, Desc: "Row has 3 fields, header has 2"}},
+			}},
+		},
+		{
+			name:           "ids=preserve требует ID",
+			query:          "?ids=preserve&format=csv",
+			contentType:    "text/csv",
+			body:           "id,title\n7,First\n,Second\n",
+			expectedStatus: 400,
+			expectedReport: dto.ImportResponse{Failed: 1, Errors: []dto.ImportRowError{
+				{Line: 3, Error: dto.Error{Code: dto.FieldIncorrect, Desc: "Field is required for field: ID (ids=preserve)"}},
+			}},
+			expectedCode: dto.FieldIncorrect,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTransferApp(t)
+			status, body := doTransfer(t, app, http.MethodPost, "/tasks/import"+tt.query, tt.contentType, tt.body)
+			require.Equal(t, tt.expectedStatus, status, body)
+
+			report := importReport(t, body)
+			assert.Equal(t, tt.expectedReport.Imported, report.Imported)
+			assert.Equal(t, tt.expectedReport.Failed, report.Failed)
+			require.Len(t, report.Errors, len(tt.expectedReport.Errors))
+			for i, expected := range tt.expectedReport.Errors {
+				assert.Equal(t, expected.Line, report.Errors[i].Line)
+				assert.Equal(t, expected.Error.Code, report.Errors[i].Error.Code)
+				assert.Contains(t, report.Errors[i].Error.Desc, expected.Error.Desc)
+			}
+			if tt.expectedCode != "" {
+				assert.Contains(t, body, `"code":"`+tt.expectedCode+`"`)
+			}
+		})
+	}
+}
+
+func TestImportTasksRejected(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		contentType    string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Content-Type не совпадает с форматом",
+			query:          "?format=csv",
+			contentType:    "application/x-ndjson",
+			body:           "title\nFirst\n",
+			expectedStatus: 415,
+			expectedBody:   "expected text/csv",
+		},
+		{
+			name:           "Неизвестный формат",
+			query:          "?format=xml",
+			contentType:    "application/xml",
+			body:           "<tasks/>",
+			expectedStatus: 400,
+			expectedBody:   "Field must be one of (ndjson, csv) for field: Format",
+		},
+		{
+			name:           "Пустой файл",
+			contentType:    "application/x-ndjson",
+			body:           "\n\n",
+			expectedStatus: 400,
+			expectedBody:   "File has no tasks",
+		},
+		{
+			name:           "Строк больше IMPORT_MAX_ROWS",
+			contentType:    "application/x-ndjson",
+			body:           strings.Repeat("{\"title\":\"Task\"}\n", 4),
+			expectedStatus: 400,
+			expectedBody:   "File has more than 3 rows",
+		},
+		{
+			name:           "Неизвестная колонка CSV",
+			query:          "?format=csv",
+			contentType:    "text/csv",
+			body:           "title,priority\nFirst,1\n",
+			expectedStatus: 400,
+			expectedBody:   `Unknown CSV column \"priority\"`,
+		},
+		{
+			name:           "CSV без колонки title",
+			query:          "?format=csv",
+			contentType:    "text/csv",
+			body:           "description\nOne\n",
+			expectedStatus: 400,
+			expectedBody:   "CSV header must have a title column",
+		},
+		{
+			name:           "Незакрытая кавычка CSV",
+			query:          "?format=csv",
+			contentType:    "text/csv",
+			body:           "title\n\"First\n",
+			expectedStatus: 400,
+			expectedBody:   "Invalid CSV",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := doTransfer(t, newTransferApp(t), http.MethodPost, "/tasks/import"+tt.query, tt.contentType, tt.body)
+			assert.Equal(t, tt.expectedStatus, status)
+			assert.Contains(t, body, tt.expectedBody)
+		})
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	for _, format := range []string{service.FormatNDJSON, service.FormatCSV} {
+		t.Run(format, func(t *testing.T) {
+			source := newTransferApp(t)
+			status, body := doTransfer(t, source, http.MethodPost, "/tasks/import?format=csv&ids=preserve&timestamps=preserve", "text/csv",
+				"id,title,description,status,created_at,updated_at\n"+
+					"5,First,\"Line one\nline two\",done,2024-01-02T03:04:05.123456Z,2024-01-03T00:00:00Z\n"+
+					"9,Second,,,2024-02-01T00:00:00Z,\n")
+			require.Equal(t, 200, status, body)
+
+			status, exported := doTransfer(t, source, http.MethodGet, "/tasks/export?format="+format, "", "")
+			require.Equal(t, 200, status, exported)
+
+			// Повторная загрузка в то же хранилище с сохранением ID - все задачи уже существуют
+			status, body = doTransfer(t, source, http.MethodPost, "/tasks/import?ids=preserve&format="+format, formatContentTypes[format], exported)
+			assert.Equal(t, 409, status)
+			assert.Contains(t, body, `"code":"TASK_EXISTS"`)
+			assert.Contains(t, body, "Task 9 already exists")
+
+			target := newTransferApp(t)
+			status, body = doTransfer(t, target, http.MethodPost, "/tasks/import?ids=preserve&timestamps=preserve&format="+format,
+				formatContentTypes[format], exported)
+			require.Equal(t, 200, status, body)
+			assert.Equal(t, 2, importReport(t, body).Imported)
+
+			_, again := doTransfer(t, target, http.MethodGet, "/tasks/export?format="+format, "", "")
+			assert.Equal(t, exported, again)
+
+			_, filtered := doTransfer(t, target, http.MethodGet, "/tasks/export?status=done&created_after=2024-01-01T00:00:00Z", "", "")
+			assert.Equal(t, 1, strings.Count(filtered, "\n"))
+			assert.Contains(t, filtered, `"id":5`)
+		})
+	}
+}
+
+// deadlineConn - соединение, запоминающее дедлайны записи
+type deadlineConn struct {
+	net.Conn
+	deadlines []time.Time
+}
+
+func (c *deadlineConn) SetWriteDeadline(t time.Time) error {
+	c.deadlines = append(c.deadlines, t)
+	return nil
+}
+
+// pages - курсор из готовых порций
+type pages struct {
+	service.TaskCursor
+	left int
+}
+
+func (p *pages) Next(context.Context) ([]service.TaskResponse, error) {
+	if p.left == 0 {
+		return nil, nil
+	}
+	p.left--
+	return []service.TaskResponse{{ID: p.left}}, nil
+}
+
+func TestWriteDeadlineCursor(t *testing.T) {
+	deadline := time.Now().Add(10 * time.Minute)
+	conn := &deadlineConn{}
+	cursor := &writeDeadlineCursor{TaskCursor: &pages{left: 2}, conn: conn, deadline: deadline}
+
+	// WRITE_TIMEOUT сервера выставлен при начале ответа, каждая порция продлевает его до бюджета выгрузки
+	for {
+		tasks, err := cursor.Next(context.Background())
+		require.NoError(t, err)
+		if len(tasks) == 0 {
+			break
+		}
+	}
+	assert.Equal(t, []time.Time{deadline, deadline, deadline}, conn.deadlines)
+}
@@ -0,0 +1,344 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"simple-service/internal/dto"
+	"simple-service/internal/service"
+	"simple-service/pkg/validator"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// Управление подписками на события задач. Маршруты доступны только токенам со scope tasks:admin
+
+type WebhookHandler struct {
+	service service.WebhookService
+	log     *zap.SugaredLogger
+}
+
+func NewWebhookHandler(svc service.WebhookService, logger *zap.SugaredLogger) *WebhookHandler {
+	return &WebhookHandler{
+		service: svc,
+		log:     logger,
+	}
+}
+
+// CreateWebhook creates a webhook subscription
+// @Summary Create webhook
+// @Description Creates a subscription to task events. Each event is sent as POST with the event as JSON body
+// @Description and headers X-Webhook-Delivery, X-Webhook-Event, X-Webhook-Timestamp and
+// @Description X-Webhook-Signature: "sha256=" + hex HMAC-SHA256 of "<timestamp>.<body>" with the secret.
+// @Description The secret is returned only in this response. Requires tasks:admin scope
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param request body dto.WebhookRequest true "Subscription"
+// @Success 201 {object} dto.SuccessResponse{data=dto.CreateWebhookResponse}
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 413 {object} dto.ErrorResponse
+// @Failure 415 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Failure 503 {object} dto.ErrorResponse
+// @Failure 504 {object} dto.ErrorResponse
+// @Router /v1/webhooks [post]
+func (h *WebhookHandler) CreateWebhook(ctx *fiber.Ctx) error {
+	var req service.WebhookRequest
+	if err := DecodeJSON(ctx, &req); err != nil {
+		h.log.Errorw("Invalid request body", "error", err)
+		return RespondDecodeError(ctx, err)
+	}
+
+	if vErr := validator.Validate(ctx.UserContext(), req); vErr != nil {
+		return dto.BadResponseError(ctx, dto.FieldIncorrect, vErr.Error())
+	}
+	if err := service.CheckWebhookURL(req.URL); err != nil {
+		return dto.BadResponseError(ctx, dto.FieldIncorrect, "Invalid url, expected absolute http or https URL")
+	}
+
+	hook, secret, err := h.service.CreateWebhook(ctx.UserContext(), req)
+	if err != nil {
+		h.log.Errorw("Failed to create webhook", "error", err)
+		return respondWebhookError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(dto.SuccessResponse{
+		Status: "success",
+		Data:   dto.CreateWebhookResponse{WebhookResponse: dto.WebhookResponse(*hook), Secret: secret},
+	})
+}
+
+// ListWebhooks returns all webhook subscriptions
+// @Summary List webhooks
+// @Description Returns all subscriptions ordered by ID, without secrets. Requires tasks:admin scope
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Success 200 {object} dto.SuccessResponse{data=dto.WebhookListResponse}
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Failure 503 {object} dto.ErrorResponse
+// @Failure 504 {object} dto.ErrorResponse
+// @Router /v1/webhooks [get]
+func (h *WebhookHandler) ListWebhooks(ctx *fiber.Ctx) error {
+	hooks, err := h.service.ListWebhooks(ctx.UserContext())
+	if err != nil {
+		h.log.Errorw("Failed to list webhooks", "error", err)
+		return respondWebhookError(ctx, err)
+	}
+
+	list := dto.WebhookListResponse{Webhooks: make([]dto.WebhookResponse, 0, len(hooks))}
+	for _, hook := range hooks {
+		list.Webhooks = append(list.Webhooks, dto.WebhookResponse(hook))
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(dto.SuccessResponse{
+		Status: "success",
+		Data:   list,
+	})
+}
+
+// GetWebhook retrieves a webhook subscription by ID
+// @Summary Get webhook by ID
+// @Description Retrieves a subscription by its ID, without the secret. Requires tasks:admin scope
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} dto.SuccessResponse{data=dto.WebhookResponse}
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Failure 503 {object} dto.ErrorResponse
+// @Failure 504 {object} dto.ErrorResponse
+// @Router /v1/webhooks/{id} [get]
+func (h *WebhookHandler) GetWebhook(ctx *fiber.Ctx) error {
+	id, ok := h.webhookID(ctx)
+	if !ok {
+		return dto.BadResponseError(ctx, dto.FieldIncorrect, "Invalid webhook ID")
+	}
+
+	hook, err := h.service.GetWebhook(ctx.UserContext(), id)
+	if err != nil {
+		h.log.Errorw("Failed to get webhook", "error", err, "webhook_id", id)
+		return respondWebhookError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(dto.SuccessResponse{
+		Status: "success",
+		Data:   dto.WebhookResponse(*hook),
+	})
+}
+
+// UpdateWebhook partially updates a webhook subscription
+// @Summary Update webhook
+// @Description Updates the fields present in the request body, other fields are left unchanged.
+// @Description Deactivated subscriptions receive no new deliveries, pending ones wait for reactivation. Requires tasks:admin scope
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param request body dto.UpdateWebhookRequest true "Fields to update"
+// @Success 200 {object} dto.SuccessResponse{data=dto.WebhookResponse}
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 413 {object} dto.ErrorResponse
+// @Failure 415 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Failure 503 {object} dto.ErrorResponse
+// @Failure 504 {object} dto.ErrorResponse
+// @Router /v1/webhooks/{id} [patch]
+func (h *WebhookHandler) UpdateWebhook(ctx *fiber.Ctx) error {
+	id, ok := h.webhookID(ctx)
+	if !ok {
+		return dto.BadResponseError(ctx, dto.FieldIncorrect, "Invalid webhook ID")
+	}
+
+	var req service.UpdateWebhookRequest
+	if err := DecodeJSON(ctx, &req); err != nil {
+		h.log.Errorw("Invalid request body", "error", err)
+		return RespondDecodeError(ctx, err)
+	}
+
+	if req.Empty() {
+		return dto.BadResponseError(ctx, dto.FieldIncorrect, "At least one field is required")
+	}
+	if vErr := validator.Validate(ctx.UserContext(), req); vErr != nil {
+		return dto.BadResponseError(ctx, dto.FieldIncorrect, vErr.Error())
+	}
+	if req.URL != nil {
+		if err := service.CheckWebhookURL(*req.URL); err != nil {
+			return dto.BadResponseError(ctx, dto.FieldIncorrect, "Invalid url, expected absolute http or https URL")
+		}
+	}
+
+	hook, err := h.service.UpdateWebhook(ctx.UserContext(), id, req)
+	if err != nil {
+		h.log.Errorw("Failed to update webhook", "error", err, "webhook_id", id)
+		return respondWebhookError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(dto.SuccessResponse{
+		Status: "success",
+		Data:   dto.WebhookResponse(*hook),
+	})
+}
+
+// DeleteWebhook deletes a webhook subscription
+// @Summary Delete webhook
+// @Description Deletes a subscription together with its delivery log. Requires tasks:admin scope
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 204
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Failure 503 {object} dto.ErrorResponse
+// @Failure 504 {object} dto.ErrorResponse
+// @Router /v1/webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteWebhook(ctx *fiber.Ctx) error {
+	id, ok := h.webhookID(ctx)
+	if !ok {
+		return dto.BadResponseError(ctx, dto.FieldIncorrect, "Invalid webhook ID")
+	}
+
+	if err := h.service.DeleteWebhook(ctx.UserContext(), id); err != nil {
+		h.log.Errorw("Failed to delete webhook", "error", err, "webhook_id", id)
+		return respondWebhookError(ctx, err)
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// ListDeliveries returns a page of the webhook delivery log
+// @Summary List webhook deliveries
+// @Description Returns deliveries of the subscription, newest first, optionally filtered by status.
+// @Description Finished deliveries are kept for WEBHOOK_DELIVERY_RETENTION. Requires tasks:admin scope
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param status query string false "Delivery status" Enums(pending, delivered, dead)
+// @Param limit query int false "Page size (1-1000)" default(50)
+// @Param offset query int false "Number of deliveries to skip" default(0)
+// @Success 200 {object} dto.SuccessResponse{data=dto.DeliveryListResponse}
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Failure 503 {object} dto.ErrorResponse
+// @Failure 504 {object} dto.ErrorResponse
+// @Router /v1/webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) ListDeliveries(ctx *fiber.Ctx) error {
+	id, ok := h.webhookID(ctx)
+	if !ok {
+		return dto.BadResponseError(ctx, dto.FieldIncorrect, "Invalid webhook ID")
+	}
+
+	filter := service.DeliveryFilter{Status: ctx.Query("status")}
+	var err error
+	if filter.Limit, err = queryInt(ctx, "limit", defaultListLimit); err != nil {
+		return dto.BadResponseError(ctx, dto.FieldBadFormat, "Invalid limit")
+	}
+	if filter.Offset, err = queryInt(ctx, "offset", 0); err != nil {
+		return dto.BadResponseError(ctx, dto.FieldBadFormat, "Invalid offset")
+	}
+
+	if vErr := validator.Validate(ctx.UserContext(), filter); vErr != nil {
+		return dto.BadResponseError(ctx, dto.FieldIncorrect, vErr.Error())
+	}
+
+	deliveries, err := h.service.ListDeliveries(ctx.UserContext(), id, filter)
+	if err != nil {
+		h.log.Errorw("Failed to list webhook deliveries", "error", err, "webhook_id", id)
+		return respondWebhookError(ctx, err)
+	}
+
+	page := dto.DeliveryListResponse{
+		Deliveries: make([]dto.DeliveryResponse, 0, len(deliveries)),
+		Limit:      filter.Limit,
+		Offset:     filter.Offset,
+	}
+	for _, delivery := range deliveries {
+		page.Deliveries = append(page.Deliveries, dto.DeliveryResponse(delivery))
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(dto.SuccessResponse{
+		Status: "success",
+		Data:   page,
+	})
+}
+
+// ReplayDelivery sends a delivery again
+// @Summary Replay webhook delivery
+// @Description Resets the delivery to pending with zero attempts, it is sent again with the same
+// @Description X-Webhook-Delivery and body. Used for dead deliveries after the subscriber is fixed. A pending delivery
+// @Description that is being sent or waits for its next attempt cannot be replayed (409). Requires tasks:admin scope
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param delivery_id path int true "Delivery ID"
+// @Success 200 {object} dto.SuccessResponse{data=dto.DeliveryResponse}
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Failure 503 {object} dto.ErrorResponse
+// @Failure 504 {object} dto.ErrorResponse
+// @Router /v1/webhooks/{id}/deliveries/{delivery_id}/replay [post]
+func (h *WebhookHandler) ReplayDelivery(ctx *fiber.Ctx) error {
+	id, ok := h.webhookID(ctx)
+	if !ok {
+		return dto.BadResponseError(ctx, dto.FieldIncorrect, "Invalid webhook ID")
+	}
+	deliveryID, err := strconv.ParseInt(ctx.Params("delivery_id"), 10, 64)
+	if err != nil {
+		return dto.BadResponseError(ctx, dto.FieldIncorrect, "Invalid delivery ID")
+	}
+
+	delivery, err := h.service.ReplayDelivery(ctx.UserContext(), id, deliveryID)
+	if err != nil {
+		h.log.Errorw("Failed to replay webhook delivery", "error", err, "webhook_id", id, "delivery_id", deliveryID)
+		return respondWebhookError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(dto.SuccessResponse{
+		Status: "success",
+		Data:   dto.DeliveryResponse(*delivery),
+	})
+}
+
+// respondWebhookError - 404 для отсутствующей подписки или доставки, 409 для доставки в отправке,
+// остальное - как respondServiceError
+func respondWebhookError(ctx *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrWebhookNotFound):
+		return dto.NotFoundError(ctx, "Webhook not found")
+	case errors.Is(err, service.ErrDeliveryNotFound):
+		return dto.NotFoundError(ctx, "Delivery not found")
+	case errors.Is(err, service.ErrDeliveryInFlight):
+		return dto.DeliveryInFlightError(ctx, "Delivery is being sent or waits for its next attempt")
+	}
+	return respondServiceError(ctx, err)
+}
+
+// webhookID - ID подписки из параметров URL
+func (h *WebhookHandler) webhookID(ctx *fiber.Ctx) (int, bool) {
+	idStr := ctx.Params("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		h.log.Errorw("Invalid webhook ID", "error", err, "id", idStr)
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"simple-service/internal/dto"
+	"simple-service/internal/repo/memory"
+	"simple-service/internal/service"
+	"simple-service/internal/webhook"
+)
+
+// webhookStore - подписки и очередь доставок одного хранилища
+type webhookStore interface {
+	service.WebhookStore
+	webhook.Store
+}
+
+func newWebhookApp(t *testing.T) (*fiber.App, webhookStore) {
+	t.Helper()
+	logger := zap.NewNop().Sugar()
+	store := memory.NewRepository()
+	h := NewWebhookHandler(service.NewWebhookService(store, logger), logger)
+
+	app := fiber.New()
+	app.Post("/webhooks", h.CreateWebhook)
+	app.Get("/webhooks", h.ListWebhooks)
+	app.Get("/webhooks/:id", h.GetWebhook)
+	app.Patch("/webhooks/:id", h.UpdateWebhook)
+	app.Delete("/webhooks/:id", h.DeleteWebhook)
+	app.Get("/webhooks/:id/deliveries", h.ListDeliveries)
+	app.Post("/webhooks/:id/deliveries/:delivery_id/replay", h.ReplayDelivery)
+	return app, store
+}
+
+// responseData - поле data ответа
+func responseData[T any](t *testing.T, body string) T {
+	t.Helper()
+	var resp struct {
+		Data T `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(body), &resp), body)
+	return resp.Data
+}
+
+// responseError - поле error ответа
+func responseError(t *testing.T, body string) dto.Error {
+	t.Helper()
+	var resp dto.ErrorResponse
+	require.NoError(t, json.Unmarshal([]byte(body), &resp), body)
+	require.NotNil(t, resp.Error, body)
+	return *resp.Error
+}
+
+func TestCreateWebhook(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedTypes  []string
+		expectedSecret string
+		expectedActive bool
+		expectedDesc   string
+	}{
+		{
+			name:           "Секрет создаётся сервисом",
+			body:           `{"url":"https://example.com/hooks"}`,
+			expectedStatus: 201,
+			expectedTypes:  []string{},
+			expectedActive: true,
+		},
+		{
+			name:           "Свой секрет и фильтр событий",
+			body:           `{"url":"http://127.0.0.1:9000/","event_types":["task.deleted"],"secret":"0123456789abcdef","active":false}`,
+			expectedStatus: 201,
+			expectedTypes:  []string{"task.deleted"},
+			expectedSecret: "0123456789abcdef",
+		},
+		{
+			name:           "Адрес без схемы",
+			body:           `{"url":"example.com/hooks"}`,
+			expectedStatus: 400,
+			expectedDesc:   "Invalid url, expected absolute http or https URL",
+		},
+		{
+			name:           "Неизвестный тип события",
+			body:           `{"url":"https://example.com/hooks","event_types":["task.created","task.archived"]}`,
+			expectedStatus: 400,
+			expectedDesc:   "Field must be one of (task.created, task.updated, task.status_changed, task.deleted) for field: EventTypes[1]",
+		},
+		{
+			name:           "Короткий секрет",
+			body:           `{"url":"https://example.com/hooks","secret":"short"}`,
+			expectedStatus: 400,
+			expectedDesc:   "Field is below minimum length (min: 16 characters) for field: Secret",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := newWebhookApp(t)
+			status, body := doTransfer(t, app, "POST", "/webhooks", "application/json", tt.body)
+			require.Equal(t, tt.expectedStatus, status, body)
+
+			if tt.expectedDesc != "" {
+				assert.Equal(t, tt.expectedDesc, responseError(t, body).Desc)
+				return
+			}
+			created := responseData[dto.CreateWebhookResponse](t, body)
+			assert.Equal(t, 1, created.ID)
+			assert.Equal(t, tt.expectedTypes, created.EventTypes)
+			assert.Equal(t, tt.expectedActive, created.Active)
+			if tt.expectedSecret != "" {
+				assert.Equal(t, tt.expectedSecret, created.Secret)
+			} else {
+				assert.Len(t, created.Secret, 64)
+			}
+
+			// Секрет не возвращается после создания
+			_, body = doTransfer(t, app, "GET", "/webhooks/1", "", "")
+			assert.NotContains(t, body, "secret")
+		})
+	}
+}
+
+func TestUpdateWebhook(t *testing.T) {
+	tests := []struct {
+		name           string
+		target         string
+		body           string
+		expectedStatus int
+		expectedURL    string
+		expectedTypes  []string
+		expectedActive bool
+	}{
+		{
+			name:           "Изменение адреса и активности",
+			target:         "/webhooks/1",
+			body:           `{"url":"https://example.org/new","active":false}`,
+			expectedStatus: 200,
+			expectedURL:    "https://example.org/new",
+			expectedTypes:  []string{"task.created"},
+		},
+		{
+			name:           "Пустой список - все события",
+			target:         "/webhooks/1",
+			body:           `{"event_types":[]}`,
+			expectedStatus: 200,
+			expectedURL:    "https://example.com/hooks",
+			expectedTypes:  []string{},
+			expectedActive: true,
+		},
+		{
+			name:           "Нет полей для изменения",
+			target:         "/webhooks/1",
+			body:           `{}`,
+			expectedStatus: 400,
+		},
+		{
+			name:           "Некорректный адрес",
+			target:         "/webhooks/1",
+			body:           `{"url":"ftp://example.com"}`,
+			expectedStatus: 400,
+		},
+		{
+			name:           "Подписки нет",
+			target:         "/webhooks/2",
+			body:           `{"active":false}`,
+			expectedStatus: 404,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := newWebhookApp(t)
+			status, body := doTransfer(t, app, "POST", "/webhooks", "application/json",
+				`{"url":"https://example.com/hooks","event_types":["task.created"]}`)
+			require.Equal(t, 201, status, body)
+
+			status, body = doTransfer(t, app, "PATCH", tt.target, "application/json", tt.body)
+			require.Equal(t, tt.expectedStatus, status, body)
+			if status != 200 {
+				return
+			}
+			hook := responseData[dto.WebhookResponse](t, body)
+			assert.Equal(t, tt.expectedURL, hook.URL)
+			assert.Equal(t, tt.expectedTypes, hook.EventTypes)
+			assert.Equal(t, tt.expectedActive, hook.Active)
+		})
+	}
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	ctx := context.Background()
+	app, store := newWebhookApp(t)
+
+	status, body := doTransfer(t, app, "POST", "/webhooks", "application/json", `{"url":"https://example.com/hooks"}`)
+	require.Equal(t, 201, status, body)
+	for id := range int64(3) {
+		_, err := store.EnqueueDeliveries(ctx, id+1, service.EventTaskCreated, 1, []byte(`{"id":1}`))
+		require.NoError(t, err)
+	}
+	// Первая доставка исчерпала попытки
+	claimed, err := store.ClaimDeliveries(ctx, 1, 0)
+	require.NoError(t, err)
+	require.NoError(t, store.RecordAttempt(ctx, claimed[0].ID, service.DeliveryAttempt{
+		Status: service.DeliveryDead, StatusCode: 500, Error: "unexpected status 500",
+	}))
+	// Вторая отправляется
+	_, err = store.ClaimDeliveries(ctx, 1, time.Minute)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		method         string
+		target         string
+		expectedStatus int
+		expectedIDs    []int64
+		expectedDesc   string
+	}{
+		{name: "Журнал, новые первыми", method: "GET", target: "/webhooks/1/deliveries", expectedStatus: 200, expectedIDs: []int64{3, 2, 1}},
+		{name: "Страница журнала", method: "GET", target: "/webhooks/1/deliveries?limit=1&offset=1", expectedStatus: 200, expectedIDs: []int64{2}},
+		{name: "Только dead letter", method: "GET", target: "/webhooks/1/deliveries?status=dead", expectedStatus: 200, expectedIDs: []int64{1}},
+		{name: "Неизвестный статус", method: "GET", target: "/webhooks/1/deliveries?status=failed", expectedStatus: 400,
+			expectedDesc: "Field must be one of (pending, delivered, dead) for field: Status"},
+		{name: "Журнал отсутствующей подписки", method: "GET", target: "/webhooks/2/deliveries", expectedStatus: 404, expectedDesc: "Webhook not found"},
+		{name: "Повтор отсутствующей доставки", method: "POST", target: "/webhooks/1/deliveries/9/replay", expectedStatus: 404, expectedDesc: "Delivery not found"},
+		{name: "Некорректный ID доставки", method: "POST", target: "/webhooks/1/deliveries/x/replay", expectedStatus: 400, expectedDesc: "Invalid delivery ID"},
+		{name: "Повтор доставки в отправке", method: "POST", target: "/webhooks/1/deliveries/2/replay", expectedStatus: 409,
+			expectedDesc: "Delivery is being sent or waits for its next attempt"},
+		{name: "Повтор dead letter", method: "POST", target: "/webhooks/1/deliveries/1/replay", expectedStatus: 200, expectedIDs: []int64{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := doTransfer(t, app, tt.method, tt.target, "", "")
+			require.Equal(t, tt.expectedStatus, status, body)
+
+			switch {
+			case tt.expectedDesc != "":
+				assert.Equal(t, tt.expectedDesc, responseError(t, body).Desc)
+			case tt.method == "POST":
+				delivery := responseData[dto.DeliveryResponse](t, body)
+				assert.Equal(t, tt.expectedIDs, []int64{delivery.ID})
+				assert.Equal(t, service.DeliveryPending, delivery.Status)
+				assert.Zero(t, delivery.Attempts)
+				assert.JSONEq(t, `{"id":1}`, string(delivery.Payload))
+			default:
+				var ids []int64
+				for _, d := range responseData[dto.DeliveryListResponse](t, body).Deliveries {
+					ids = append(ids, d.ID)
+				}
+				assert.Equal(t, tt.expectedIDs, ids)
+			}
+		})
+	}
+
+	// Удаление подписки удаляет и журнал
+	status, _ = doTransfer(t, app, "DELETE", "/webhooks/1", "", "")
+	assert.Equal(t, 204, status)
+	status, _ = doTransfer(t, app, "GET", "/webhooks/1/deliveries", "", "")
+	assert.Equal(t, 404, status)
+	status, body = doTransfer(t, app, "GET", "/webhooks", "", "")
+	require.Equal(t, 200, status)
+	assert.Empty(t, responseData[dto.WebhookListResponse](t, body).Webhooks)
+}
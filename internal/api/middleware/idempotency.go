@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"simple-service/internal/dto"
+	"simple-service/internal/metrics"
+	"simple-service/internal/service"
+)
+
+const (
+	// IdempotencyKeyHeader - заголовок с ключом идемпотентности запроса
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader - отметка ответа, повторённого по ключу идемпотентности
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLen = 255
+	// idempotencyStoreTimeout - ограничение сохранения ответа, бюджет самого запроса к этому моменту может быть исчерпан
+	idempotencyStoreTimeout = 5 * time.Second
+)
+
+// Idempotency - middleware ключей идемпотентности. Запрос с заголовком Idempotency-Key выполняется
+// один раз для субъекта токена: повтор с тем же телом получает сохранённый ответ, с другим телом - 422,
+// пока исходный запрос выполняется - 409. Ответ хранится ttl, незавершённый запрос занимает ключ не дольше lease.
+// Ключ освобождается только после 503: хранилище недоступно, и запрос точно не выполнен, его можно
+// повторить с тем же ключом. Остальные ответы 5xx сохраняются: после 500 OUTCOME_UNKNOWN или 504 запись
+// могла выполниться, и повтор с тем же ключом не должен создать задачу второй раз.
+// Токен без claim sub не может использовать ключ: иначе все такие токены делили бы одно пространство ключей
+func Idempotency(store service.IdempotencyStore, ttl, lease time.Duration, logger *zap.SugaredLogger) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(IdempotencyKeyHeader)
+		if key == "" {
+			return c.Next()
+		}
+		if !validIdempotencyKey(key) {
+			return dto.BadResponseError(c, dto.FieldBadFormat, "Idempotency-Key must be 1-255 printable ASCII characters")
+		}
+
+		ctx := c.UserContext()
+		principal := service.SubjectFromContext(ctx)
+		if principal == "" {
+			return dto.BadResponseError(c, dto.FieldIncorrect, "Idempotency-Key requires a token with sub claim")
+		}
+		hash := requestHash(c)
+
+		record, err := store.ReserveIdempotencyKey(ctx, principal, key, hash, lease)
+		if err != nil {
+			logger.Errorw("Failed to reserve idempotency key", "error", err)
+			return respondStoreError(c, err)
+		}
+		if record != nil {
+			return replay(c, record, hash)
+		}
+		metrics.IdempotencyRequests.WithLabelValues("new").Inc()
+
+		// Ключ захвачен: результат сохраняется и после исчерпания бюджета запроса
+		storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), idempotencyStoreTimeout)
+		defer cancel()
+
+		// Ошибку обработчика отдаст ErrorHandler, ответ на этом этапе неизвестен. Выполнен ли запрос,
+		// тоже неизвестно: ключ остаётся занятым до истечения lease
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		resp := c.Response()
+		if resp.StatusCode() == fiber.StatusServiceUnavailable {
+			release(storeCtx, store, principal, key, logger)
+			return nil
+		}
+
+		saved := service.IdempotentResponse{
+			Status:      resp.StatusCode(),
+			ContentType: string(resp.Header.ContentType()),
+			Body:        bytes.Clone(resp.Body()),
+		}
+		if err := store.CompleteIdempotencyKey(storeCtx, principal, key, saved, ttl); err != nil {
+			// Ответ уже готов; ключ останется занятым до истечения lease
+			logger.Errorw("Failed to save idempotent response", "error", err)
+		}
+		return nil
+	}
+}
+
+// replay - ответ на запрос с уже занятым ключом
+func replay(c *fiber.Ctx, record *service.IdempotencyRecord, hash []byte) error {
+	switch {
+	case !bytes.Equal(record.RequestHash, hash):
+		metrics.IdempotencyRequests.WithLabelValues("mismatch").Inc()
+		return dto.IdempotencyReusedError(c, "Idempotency-Key was already used with a different request")
+	case record.Response == nil:
+		metrics.IdempotencyRequests.WithLabelValues("in_flight").Inc()
+		return dto.IdempotencyInUseError(c, "A request with this Idempotency-Key is still in progress")
+	}
+
+	metrics.IdempotencyRequests.WithLabelValues("replayed").Inc()
+	c.Set(IdempotentReplayedHeader, "true")
+	if record.Response.ContentType != "" {
+		c.Set(fiber.HeaderContentType, record.Response.ContentType)
+	}
+	return c.Status(record.Response.Status).Send(record.Response.Body)
+}
+
+func release(ctx context.Context, store service.IdempotencyStore, principal, key string, logger *zap.SugaredLogger) {
+	if err := store.ReleaseIdempotencyKey(ctx, principal, key); err != nil {
+		logger.Errorw("Failed to release idempotency key", "error", err)
+	}
+}
+
+// requestHash - SHA-256 метода, пути и тела: ключ, повторённый с другим маршрутом или телом, отклоняется
+func requestHash(c *fiber.Ctx) []byte {
+	h := sha256.New()
+	h.Write([]byte(c.Method()))
+	h.Write([]byte{0})
+	h.Write([]byte(c.Path()))
+	h.Write([]byte{0})
+	h.Write(c.Body())
+	return h.Sum(nil)
+}
+
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLen {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// respondStoreError - хранилище ключей недоступно: 503 с Retry-After, 504 при исчерпании бюджета, иначе 500
+func respondStoreError(c *fiber.Ctx, err error) error {
+	var unavailable *service.UnavailableError
+	switch {
+	case errors.Is(err, service.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return dto.GatewayTimeoutError(c, "Request deadline exceeded")
+	case errors.As(err, &unavailable):
+		return dto.ServiceUnavailableError(c, unavailable.RetryAfter, "Storage is temporarily unavailable")
+	}
+	return dto.InternalServerError(c)
+}
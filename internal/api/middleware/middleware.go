@@ -1,14 +1,24 @@
 package middleware
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
+
+	"simple-service/internal/dto"
+	"simple-service/internal/service"
 )
 
 // JWTAuthorization - middleware для проверки JWT токена
 func JWTAuthorization(secretKey string) fiber.Handler {
+	return JWTAuthorizationFunc(func() string { return secretKey })
+}
+
+// JWTAuthorizationFunc - middleware для проверки JWT токена,
+// секрет запрашивается на каждый запрос, что позволяет менять его без перезапуска
+func JWTAuthorizationFunc(secretKey func() string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
 
@@ -33,7 +43,7 @@ func JWTAuthorization(secretKey string) fiber.Handler {
 			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
 				return nil, jwt.ErrSignatureInvalid
 			}
-			return []byte(secretKey), nil
+			return []byte(secretKey()), nil
 		})
 		if err != nil {
 			return unauthorizedResponse(c, "Invalid authorization token")
@@ -43,9 +53,26 @@ func JWTAuthorization(secretKey string) fiber.Handler {
 			return unauthorizedResponse(c, "Invalid authorization token")
 		}
 
-		// Сохраняем claims в контекст
+		// Сохраняем claims в контекст, субъект и scope - ещё и в контекст для сервиса
 		if claims, ok := parsedToken.Claims.(jwt.MapClaims); ok {
 			c.Locals("user", claims)
+			if subject, err := claims.GetSubject(); err == nil && subject != "" {
+				c.SetUserContext(service.WithSubject(c.UserContext(), subject))
+			}
+			if scope, ok := claims["scope"].(string); ok {
+				c.SetUserContext(service.WithScopes(c.UserContext(), strings.Fields(scope)))
+			}
+		}
+
+		return c.Next()
+	}
+}
+
+// BodyLimit - middleware ограничения размера тела запроса для отдельного маршрута
+func BodyLimit(limit int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Request().Header.ContentLength() > limit || len(c.Body()) > limit {
+			return dto.PayloadTooLargeError(c, fmt.Sprintf("Request body exceeds %d bytes", limit))
 		}
 
 		return c.Next()
@@ -1,13 +1,24 @@
 package middleware
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+
+	"simple-service/internal/api/handlers"
+	"simple-service/internal/repo/memory"
+	"simple-service/internal/repo/mocks"
+	"simple-service/internal/service"
 )
 
 func TestJWTAuthorization(t *testing.T) {
@@ -119,3 +130,326 @@ func createTestJWT(t *testing.T, secretKey string, claims map[string]interface{}
 	assert.NoError(t, err)
 	return tokenString
 }
+
+func TestJWTSubject(t *testing.T) {
+	secretKey := "test-secret-key"
+	app := fiber.New()
+	app.Get("/test", JWTAuthorization(secretKey), func(c *fiber.Ctx) error {
+		return c.SendString(fmt.Sprintf("%s admin=%t",
+			service.SubjectFromContext(c.UserContext()), service.HasScope(c.UserContext(), service.ScopeAdmin)))
+	})
+
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		want   string
+	}{
+		{name: "Субъект из claim sub", claims: map[string]interface{}{"sub": "alice"}, want: "alice admin=false"},
+		{name: "Токен без sub", claims: map[string]interface{}{"user_id": "123"}, want: " admin=false"},
+		{name: "Scope через пробел", claims: map[string]interface{}{"sub": "bob", "scope": "tasks:read tasks:admin"}, want: "bob admin=true"},
+		{name: "Scope не строкой игнорируется", claims: map[string]interface{}{"sub": "eve", "scope": []string{"tasks:admin"}}, want: "eve admin=false"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.claims["exp"] = time.Now().Add(time.Hour).Unix()
+			req, _ := http.NewRequest("GET", "/test", nil)
+			req.Header.Set("Authorization", "Bearer "+createTestJWT(t, secretKey, tt.claims))
+
+			resp, err := app.Test(req)
+			assert.NoError(t, err)
+			body, _ := io.ReadAll(resp.Body)
+			assert.Equal(t, tt.want, string(body))
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	secretKey := "test-secret-key"
+	app := fiber.New()
+	app.Get("/test", JWTAuthorization(secretKey), RequireScope(service.ScopeAdmin), func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{"message": "success"})
+	})
+
+	tests := []struct {
+		name           string
+		scope          string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Есть нужный scope",
+			scope:          "tasks:read tasks:admin",
+			expectedStatus: 200,
+			expectedBody:   `{"message":"success"}`,
+		},
+		{
+			name:           "Нет нужного scope",
+			scope:          "tasks:read",
+			expectedStatus: 403,
+			expectedBody:   `{"status":"error","error":{"code":"FORBIDDEN","desc":"Token requires tasks:admin scope"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := map[string]interface{}{"sub": "alice", "scope": tt.scope, "exp": time.Now().Add(time.Hour).Unix()}
+			req, _ := http.NewRequest("GET", "/test", nil)
+			req.Header.Set("Authorization", "Bearer "+createTestJWT(t, secretKey, claims))
+
+			resp, err := app.Test(req)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+
+			body, err := io.ReadAll(resp.Body)
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.expectedBody, string(body))
+		})
+	}
+}
+
+func TestBodyLimit(t *testing.T) {
+	app := fiber.New()
+	app.Post("/test", BodyLimit(16), func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{"message": "success"})
+	})
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Тело в пределах лимита",
+			body:           `{"title":"a"}`,
+			expectedStatus: 200,
+			expectedBody:   `{"message":"success"}`,
+		},
+		{
+			name:           "Тело превышает лимит",
+			body:           `{"title":"слишком длинный заголовок"}`,
+			expectedStatus: 413,
+			expectedBody:   `{"status":"error","error":{"code":"PAYLOAD_TOO_LARGE","desc":"Request body exceeds 16 bytes"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/test", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := app.Test(req)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+
+			body, err := io.ReadAll(resp.Body)
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.expectedBody, string(body))
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	limit := 2
+	app := fiber.New()
+	app.Use(RateLimit(func() (int, time.Duration) { return limit, time.Minute }))
+	app.Get("/test", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{"message": "success"})
+	})
+
+	request := func() *http.Response {
+		req, _ := http.NewRequest("GET", "/test", nil)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp
+	}
+
+	assert.Equal(t, 200, request().StatusCode)
+	assert.Equal(t, 200, request().StatusCode)
+
+	resp := request()
+	assert.Equal(t, 429, resp.StatusCode)
+	assert.Equal(t, "60", resp.Header.Get("Retry-After"))
+	body, _ := io.ReadAll(resp.Body)
+	assert.JSONEq(t, `{"status":"error","error":{"code":"TOO_MANY_REQUESTS","desc":"Rate limit exceeded, retry after 60 seconds"}}`, string(body))
+
+	// Новый лимит применяется без пересоздания middleware
+	limit = 5
+	assert.Equal(t, 200, request().StatusCode)
+
+	// Нулевой лимит отключает ограничение
+	limit = 0
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, 200, request().StatusCode)
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	tests := []struct {
+		name         string
+		timeout      time.Duration
+		wantDeadline bool
+	}{
+		{name: "Бюджет задан", timeout: time.Minute, wantDeadline: true},
+		{name: "Без ограничения", timeout: 0, wantDeadline: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/test", Deadline(tt.timeout), func(c *fiber.Ctx) error {
+				deadline, ok := c.UserContext().Deadline()
+				assert.Equal(t, tt.wantDeadline, ok)
+				if ok {
+					assert.WithinDuration(t, time.Now().Add(tt.timeout), deadline, time.Second)
+				}
+				return c.SendStatus(fiber.StatusNoContent)
+			})
+
+			req, _ := http.NewRequest("GET", "/test", nil)
+			resp, err := app.Test(req)
+			assert.NoError(t, err)
+			assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+		})
+	}
+}
+
+func TestIdempotency(t *testing.T) {
+	var calls int
+	status := fiber.StatusCreated
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.SetUserContext(service.WithSubject(c.UserContext(), c.Get("X-Subject")))
+		return c.Next()
+	})
+	app.Post("/test", Idempotency(memory.NewRepository(), time.Hour, time.Minute, zap.NewNop().Sugar()), func(c *fiber.Ctx) error {
+		calls++
+		return c.Status(status).JSON(fiber.Map{"call": calls})
+	})
+
+	type result struct {
+		status   int
+		body     string
+		replayed string
+	}
+	request := func(subject, key, body string) result {
+		req, _ := http.NewRequest("POST", "/test", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Subject", subject)
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		data, _ := io.ReadAll(resp.Body)
+		return result{status: resp.StatusCode, body: string(data), replayed: resp.Header.Get(IdempotentReplayedHeader)}
+	}
+
+	tests := []struct {
+		name    string
+		subject string
+		key     string
+		body    string
+		status  int // статус ответа обработчика
+		want    result
+	}{
+		{name: "Без ключа запрос выполняется", subject: "alice", key: "", body: `{}`, want: result{status: 201, body: `{"call":1}`}},
+		{name: "Первый запрос с ключом", subject: "alice", key: "k1", body: `{}`, want: result{status: 201, body: `{"call":2}`}},
+		{name: "Повтор получает сохранённый ответ", subject: "alice", key: "k1", body: `{}`, want: result{status: 201, body: `{"call":2}`, replayed: "true"}},
+		{name: "Ключ с другим телом", subject: "alice", key: "k1", body: `{"a":1}`, want: result{status: 422,
+			body: `{"status":"error","error":{"code":"IDEMPOTENCY_KEY_REUSED","desc":"Idempotency-Key was already used with a different request"}}`}},
+		{name: "Ключи субъектов независимы", subject: "bob", key: "k1", body: `{}`, want: result{status: 201, body: `{"call":3}`}},
+		{name: "Ответ 503 не сохраняется", subject: "alice", key: "k2", body: `{}`, status: 503, want: result{status: 503, body: `{"call":4}`}},
+		{name: "Повтор после 503 выполняется", subject: "alice", key: "k2", body: `{}`, want: result{status: 201, body: `{"call":5}`}},
+		{name: "Ошибка клиента сохраняется", subject: "alice", key: "k3", body: `{}`, status: 400, want: result{status: 400, body: `{"call":6}`}},
+		{name: "Повтор ошибки клиента", subject: "alice", key: "k3", body: `{}`, want: result{status: 400, body: `{"call":6}`, replayed: "true"}},
+		{name: "Ответ 504 сохраняется", subject: "alice", key: "k5", body: `{}`, status: 504, want: result{status: 504, body: `{"call":7}`}},
+		{name: "Повтор после 504 не выполняется", subject: "alice", key: "k5", body: `{}`, want: result{status: 504, body: `{"call":7}`, replayed: "true"}},
+		{name: "Недопустимый ключ", subject: "alice", key: "ключ", body: `{}`, want: result{status: 400,
+			body: `{"status":"error","error":{"code":"FIELD_BADFORMAT","desc":"Idempotency-Key must be 1-255 printable ASCII characters"}}`}},
+		// Токены без sub не делят ключи: запрос с ключом отклоняется, обработчик не вызывается
+		{name: "Токен без sub", key: "k4", body: `{}`, want: result{status: 400, body: `{"status":"error","error":{"code":"FIELD_INCORRECT","desc":"Idempotency-Key requires a token with sub claim"}}`}},
+		{name: "Другой токен без sub с тем же ключом", key: "k4", body: `{}`, want: result{status: 400, body: `{"status":"error","error":{"code":"FIELD_INCORRECT","desc":"Idempotency-Key requires a token with sub claim"}}`}},
+		{name: "Токен без sub без ключа", key: "", body: `{}`, want: result{status: 201, body: `{"call":8}`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status = fiber.StatusCreated
+			if tt.status != 0 {
+				status = tt.status
+			}
+			got := request(tt.subject, tt.key, tt.body)
+			assert.Equal(t, tt.want.status, got.status)
+			assert.JSONEq(t, tt.want.body, got.body)
+			assert.Equal(t, tt.want.replayed, got.replayed)
+		})
+	}
+}
+
+func TestIdempotencyInFlight(t *testing.T) {
+	store := memory.NewRepository()
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.SetUserContext(service.WithSubject(c.UserContext(), "alice"))
+		return c.Next()
+	})
+	app.Post("/test", Idempotency(store, time.Hour, time.Minute, zap.NewNop().Sugar()), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusCreated)
+	})
+
+	// Ключ захвачен выполняющимся запросом с тем же телом
+	hash := func() []byte {
+		app := fiber.New()
+		var h []byte
+		app.Post("/test", func(c *fiber.Ctx) error {
+			h = requestHash(c)
+			return nil
+		})
+		req, _ := http.NewRequest("POST", "/test", strings.NewReader(`{}`))
+		_, err := app.Test(req)
+		assert.NoError(t, err)
+		return h
+	}()
+	record, err := store.ReserveIdempotencyKey(context.Background(), "alice", "k1", hash, time.Minute)
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+
+	req, _ := http.NewRequest("POST", "/test", strings.NewReader(`{}`))
+	req.Header.Set(IdempotencyKeyHeader, "k1")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.JSONEq(t, `{"status":"error","error":{"code":"IDEMPOTENCY_KEY_IN_USE","desc":"A request with this Idempotency-Key is still in progress"}}`, string(body))
+}
+
+func TestIdempotencyOutcomeUnknown(t *testing.T) {
+	logger := zap.NewNop().Sugar()
+	repository := mocks.NewRepository(t)
+	repository.On("CreateTask", mock.Anything, mock.Anything).
+		Return(0, fmt.Errorf("%w: connection reset by peer", service.ErrOutcomeUnknown)).Once()
+	h := handlers.NewTaskHandler(service.NewService(repository, logger), logger, handlers.Limits{})
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.SetUserContext(service.WithSubject(c.UserContext(), "alice"))
+		return c.Next()
+	})
+	app.Post("/create_task", Idempotency(memory.NewRepository(), time.Hour, time.Minute, logger), h.CreateTask)
+
+	// Запись могла выполниться: повтор с тем же ключом получает сохранённый ответ, хранилище не вызывается
+	for i, replayed := range []string{"", "true"} {
+		req, _ := http.NewRequest("POST", "/create_task", strings.NewReader(`{"title":"once"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(IdempotencyKeyHeader, "k1")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, fiber.StatusInternalServerError, resp.StatusCode, "попытка %d", i+1)
+		assert.Contains(t, string(body), `"code":"OUTCOME_UNKNOWN"`)
+		assert.Equal(t, replayed, resp.Header.Get(IdempotentReplayedHeader))
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"simple-service/internal/dto"
+)
+
+// RateLimitFunc - текущие лимит запросов и размер окна, лимит 0 отключает ограничение
+type RateLimitFunc func() (limit int, window time.Duration)
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// RateLimit - middleware ограничения числа запросов с одного IP за фиксированное окно.
+// Лимиты читаются на каждый запрос, поэтому их можно менять без перезапуска
+func RateLimit(limits RateLimitFunc) fiber.Handler {
+	var (
+		mu        sync.Mutex
+		windows   = make(map[string]*rateWindow)
+		lastPurge = time.Now()
+	)
+
+	return func(c *fiber.Ctx) error {
+		limit, window := limits()
+		if limit <= 0 {
+			return c.Next()
+		}
+
+		now := time.Now()
+		key := c.IP()
+
+		mu.Lock()
+		// Периодически удаляем истёкшие окна, чтобы карта не росла бесконечно
+		if now.Sub(lastPurge) >= window {
+			for k, w := range windows {
+				if now.Sub(w.start) >= window {
+					delete(windows, k)
+				}
+			}
+			lastPurge = now
+		}
+
+		w, ok := windows[key]
+		if !ok || now.Sub(w.start) >= window {
+			w = &rateWindow{start: now}
+			windows[key] = w
+		}
+		w.count++
+		count, reset := w.count, w.start.Add(window)
+		mu.Unlock()
+
+		remaining := limit - count
+		if remaining < 0 {
+			remaining = 0
+		}
+		c.Set("X-RateLimit-Limit", strconv.Itoa(limit))
+		c.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+
+		if count > limit {
+			retryAfter := int(reset.Sub(now).Seconds() + 0.999)
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+			return dto.TooManyRequestsError(c, "Rate limit exceeded, retry after "+strconv.Itoa(retryAfter)+" seconds")
+		}
+
+		return c.Next()
+	}
+}
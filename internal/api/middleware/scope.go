@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"simple-service/internal/dto"
+	"simple-service/internal/service"
+)
+
+// RequireScope - middleware, пропускающий только запросы с токеном, у которого есть scope.
+// Ставится после JWTAuthorizationFunc
+func RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !service.HasScope(c.UserContext(), scope) {
+			return dto.ForbiddenError(c, "Token requires "+scope+" scope")
+		}
+
+		return c.Next()
+	}
+}
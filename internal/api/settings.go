@@ -0,0 +1,66 @@
+package api
+
+import (
+	"sync/atomic"
+	"time"
+
+	"simple-service/internal/config"
+)
+
+// RuntimeConfig - параметры API, которые применяются без перезапуска сервера
+type RuntimeConfig struct {
+	JWTSecret        string
+	CORSAllowOrigins []string
+	RateLimit        int
+	RateLimitWindow  time.Duration
+}
+
+// NewRuntimeConfig - выборка изменяемых параметров из конфигурации REST
+func NewRuntimeConfig(cfg config.Rest) RuntimeConfig {
+	return RuntimeConfig{
+		JWTSecret:        cfg.Token,
+		CORSAllowOrigins: cfg.CORSAllowOrigins,
+		RateLimit:        cfg.RateLimit,
+		RateLimitWindow:  cfg.RateLimitWindow,
+	}
+}
+
+// Settings - текущие параметры API, заменяются целиком одной атомарной операцией
+type Settings struct {
+	current atomic.Pointer[RuntimeConfig]
+}
+
+// NewSettings - конструктор с начальными параметрами
+func NewSettings(cfg RuntimeConfig) *Settings {
+	s := &Settings{}
+	s.Set(cfg)
+	return s
+}
+
+// Get - текущие параметры
+func (s *Settings) Get() *RuntimeConfig {
+	return s.current.Load()
+}
+
+// Set - замена параметров
+func (s *Settings) Set(cfg RuntimeConfig) {
+	s.current.Store(&cfg)
+}
+
+func (s *Settings) jwtSecret() string {
+	return s.Get().JWTSecret
+}
+
+func (s *Settings) allowOrigin(origin string) bool {
+	for _, allowed := range s.Get().CORSAllowOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Settings) rateLimit() (int, time.Duration) {
+	cfg := s.Get()
+	return cfg.RateLimit, cfg.RateLimitWindow
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// CertReloader - TLS сертификат, который перечитывается с диска при изменении файлов.
+// Позволяет ротировать короткоживущие сертификаты без перезапуска сервера
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	log      *zap.SugaredLogger
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	certMod time.Time
+	keyMod  time.Time
+}
+
+// NewCertReloader - загрузка сертификата и ключа, ошибка при первой загрузке фатальна
+func NewCertReloader(certFile, keyFile string, logger *zap.SugaredLogger) (*CertReloader, error) {
+	r := &CertReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		log:      logger,
+	}
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// TLSConfig - конфигурация TLS, сертификат выбирается на каждое рукопожатие
+func (r *CertReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.GetCertificate,
+	}
+}
+
+// GetCertificate - текущий сертификат
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Watch - проверка файлов с заданным интервалом до отмены ctx.
+// При ошибке чтения продолжает отдавать предыдущий сертификат
+func (r *CertReloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := r.reloadIfChanged()
+			if err != nil {
+				r.log.Errorw("Failed to reload TLS certificate, keeping previous one", "error", err)
+				continue
+			}
+			if reloaded {
+				r.log.Infow("TLS certificate reloaded", "cert_file", r.certFile)
+			}
+		}
+	}
+}
+
+// reloadIfChanged - перечитывает сертификат, если изменилось время модификации одного из файлов
+func (r *CertReloader) reloadIfChanged() (bool, error) {
+	certMod, keyMod, err := r.modTimes()
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.RLock()
+	changed := !certMod.Equal(r.certMod) || !keyMod.Equal(r.keyMod)
+	r.mu.RUnlock()
+
+	if !changed {
+		return false, nil
+	}
+
+	return true, r.load()
+}
+
+func (r *CertReloader) load() error {
+	certMod, keyMod, err := r.modTimes()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return errors.Wrap(err, "failed to load TLS certificate")
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.certMod = certMod
+	r.keyMod = keyMod
+	r.mu.Unlock()
+
+	return nil
+}
+
+func (r *CertReloader) modTimes() (time.Time, time.Time, error) {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.Wrap(err, "failed to stat TLS certificate")
+	}
+
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.Wrap(err, "failed to stat TLS key")
+	}
+
+	return certInfo.ModTime(), keyInfo.ModTime(), nil
+}
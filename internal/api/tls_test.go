@@ -0,0 +1,87 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// writeTestCert - самоподписанный сертификат с заданным CommonName
+func writeTestCert(t *testing.T, certFile, keyFile, commonName string, modTime time.Time) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	require.NoError(t, os.Chtimes(certFile, modTime, modTime))
+	require.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+}
+
+func currentCommonName(t *testing.T, r *CertReloader) string {
+	t.Helper()
+
+	cert, err := r.GetCertificate(nil)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	start := time.Now().Add(-time.Minute)
+
+	writeTestCert(t, certFile, keyFile, "first", start)
+
+	reloader, err := NewCertReloader(certFile, keyFile, zap.NewNop().Sugar())
+	require.NoError(t, err)
+	assert.Equal(t, "first", currentCommonName(t, reloader))
+
+	// Файлы не менялись - перезагрузки нет
+	reloaded, err := reloader.reloadIfChanged()
+	require.NoError(t, err)
+	assert.False(t, reloaded)
+
+	// Ротация сертификата
+	writeTestCert(t, certFile, keyFile, "second", start.Add(time.Second))
+	reloaded, err = reloader.reloadIfChanged()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.Equal(t, "second", currentCommonName(t, reloader))
+
+	// Битый файл - ошибка, продолжаем отдавать предыдущий сертификат
+	require.NoError(t, os.WriteFile(certFile, []byte("broken"), 0o600))
+	require.NoError(t, os.Chtimes(certFile, start.Add(2*time.Second), start.Add(2*time.Second)))
+	_, err = reloader.reloadIfChanged()
+	assert.Error(t, err)
+	assert.Equal(t, "second", currentCommonName(t, reloader))
+}
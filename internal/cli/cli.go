@@ -0,0 +1,290 @@
+// Package cli - дерево команд с общими кодами завершения и справкой, собранной из описаний команд.
+// Используется бинарниками simple-service и tasks
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+)
+
+// Коды завершения, одинаковые для всех команд
+const (
+	ExitOK = 0
+	// ExitError - команда не выполнена: нет подключения к БД, ошибка миграции и т.п.
+	ExitError = 1
+	// ExitUsage - неверные аргументы или флаги (тот же код использует пакет flag)
+	ExitUsage = 2
+	// ExitCheckFailed - проверка выполнена и нашла проблемы: расхождение схемы, невалидная конфигурация
+	ExitCheckFailed = 3
+)
+
+// Command - узел дерева команд. У группы есть Subcommands, у листа - Run
+type Command struct {
+	Name        string
+	Args        string
+	Summary     string
+	Description string
+	Subcommands []*Command
+	Run         func(c *Command, args []string) error
+	// Default - подкоманда корня, которая запускается без команды или только с флагами
+	Default string
+
+	parent *Command
+}
+
+// usageError - неверный вызов команды, к ошибке добавляется справка по команде
+type usageError struct {
+	cmd *Command
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+// checkFailedError - проверка прошла до конца, но обнаружила проблемы
+type checkFailedError struct {
+	msg string
+}
+
+func (e *checkFailedError) Error() string {
+	return e.msg
+}
+
+// UsageErrorf - ошибка вызова команды, завершается с ExitUsage и справкой по команде
+func UsageErrorf(c *Command, format string, args ...any) error {
+	return &usageError{cmd: c, msg: fmt.Sprintf(format, args...)}
+}
+
+// CheckFailedf - проверка нашла проблемы, завершается с ExitCheckFailed
+func CheckFailedf(format string, args ...any) error {
+	return &checkFailedError{msg: fmt.Sprintf(format, args...)}
+}
+
+// Path - полное имя команды, например "simple-service migrate up"
+func (c *Command) Path() string {
+	if c.parent == nil {
+		return c.Name
+	}
+	return c.parent.Path() + " " + c.Name
+}
+
+// Parent - родительская команда, nil у корня
+func (c *Command) Parent() *Command {
+	return c.parent
+}
+
+// Find - подкоманда по имени
+func (c *Command) Find(name string) *Command {
+	for _, sub := range c.Subcommands {
+		if sub.Name == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// Link - проставляет родителей во всём дереве
+func (c *Command) Link() *Command {
+	for _, sub := range c.Subcommands {
+		sub.parent = c
+		sub.Link()
+	}
+	return c
+}
+
+func (c *Command) root() *Command {
+	if c.parent == nil {
+		return c
+	}
+	return c.parent.root()
+}
+
+// FlagSet - набор флагов команды, -h выводит справку по команде вместе с флагами
+func (c *Command) FlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(c.Path(), flag.ContinueOnError)
+	fs.Usage = func() {
+		c.PrintHelp(fs.Output(), fs)
+	}
+	return fs
+}
+
+// flagError - ошибка разбора флагов, сообщение и справку уже вывел пакет flag
+type flagError struct {
+	err error
+}
+
+func (e *flagError) Error() string {
+	return e.err.Error()
+}
+
+// ParseFlags - разбор флагов команды. Флаги можно указывать и после аргументов
+// (tasks update 1 -status done), всё после "--" считается аргументами
+func ParseFlags(fs *flag.FlagSet, args []string) error {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return err
+			}
+			return &flagError{err: err}
+		}
+
+		rest := fs.Args()
+		if len(rest) == 0 {
+			break
+		}
+		// flag останавливается на первом аргументе или после "--"
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			positional = append(positional, rest...)
+			break
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+
+	// Повторный разбор оставляет в fs.Args() только аргументы
+	return fs.Parse(append([]string{"--"}, positional...))
+}
+
+// PrintHelp - справка, собранная из описания команды, подкоманд и флагов
+func (c *Command) PrintHelp(w io.Writer, fs *flag.FlagSet) {
+	switch {
+	case len(c.Subcommands) > 0:
+		fmt.Fprintf(w, "Usage: %s COMMAND\n", c.Path())
+	case fs != nil && hasFlags(fs):
+		fmt.Fprintf(w, "Usage: %s [flags] %s\n", c.Path(), c.Args)
+	default:
+		fmt.Fprintf(w, "Usage: %s %s\n", c.Path(), c.Args)
+	}
+
+	description := c.Description
+	if description == "" {
+		description = c.Summary
+	}
+	if description != "" {
+		fmt.Fprintf(w, "\n%s\n", description)
+	}
+
+	if len(c.Subcommands) > 0 {
+		fmt.Fprintln(w, "\nCommands:")
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, sub := range c.Subcommands {
+			fmt.Fprintf(tw, "  %s\t%s\n", sub.Name, sub.Summary)
+		}
+		_ = tw.Flush()
+		name := c.root().Name
+		fmt.Fprintf(w, "\nRun '%s help %sCOMMAND' for details.\n", name, strings.TrimPrefix(c.Path()+" ", name+" "))
+	}
+
+	if fs != nil && hasFlags(fs) {
+		fmt.Fprintln(w, "\nFlags:")
+		fs.PrintDefaults()
+	}
+
+	if c.parent == nil {
+		fmt.Fprintf(w, "\nExit codes: %d - success, %d - error, %d - invalid usage, %d - check found problems.\n",
+			ExitOK, ExitError, ExitUsage, ExitCheckFailed)
+	}
+}
+
+func hasFlags(fs *flag.FlagSet) bool {
+	has := false
+	fs.VisitAll(func(*flag.Flag) { has = true })
+	return has
+}
+
+// Execute - выбор команды по аргументам и запуск, возвращает код завершения.
+// Без команды (или только с флагами) запускается root.Default, если он задан
+func Execute(root *Command, args []string, stderr io.Writer) int {
+	c, rest := Resolve(root, args)
+	if c == root && root.Default != "" && (len(rest) == 0 || strings.HasPrefix(rest[0], "-") && rest[0] != "-h" && rest[0] != "--help") {
+		c = root.Find(root.Default)
+	}
+
+	var err error
+	if c.Run == nil {
+		if len(rest) > 0 && rest[0] != "-h" && rest[0] != "--help" && rest[0] != "help" {
+			err = UsageErrorf(c, "unknown command %q", strings.TrimSpace(c.Path()+" "+rest[0]))
+		} else {
+			c.PrintHelp(os.Stdout, nil)
+		}
+	} else {
+		err = c.Run(c, rest)
+	}
+
+	return exitCode(err, stderr)
+}
+
+// Resolve - спуск по дереву, пока аргументы совпадают с именами подкоманд
+func Resolve(root *Command, args []string) (*Command, []string) {
+	c := root
+	for len(args) > 0 {
+		sub := c.Find(args[0])
+		if sub == nil {
+			break
+		}
+		c, args = sub, args[1:]
+	}
+	return c, args
+}
+
+func exitCode(err error, stderr io.Writer) int {
+	if err == nil || errors.Is(err, flag.ErrHelp) {
+		return ExitOK
+	}
+
+	var flagErr *flagError
+	if errors.As(err, &flagErr) {
+		return ExitUsage
+	}
+
+	fmt.Fprintln(stderr, "Error:", err)
+
+	var usage *usageError
+	if errors.As(err, &usage) {
+		fmt.Fprintln(stderr)
+		usage.cmd.PrintHelp(stderr, nil)
+		return ExitUsage
+	}
+
+	var check *checkFailedError
+	if errors.As(err, &check) {
+		return ExitCheckFailed
+	}
+
+	return ExitError
+}
+
+// HelpCommand - команда help [COMMAND...] для корня дерева
+func HelpCommand() *Command {
+	return &Command{
+		Name:    "help",
+		Args:    "[COMMAND...]",
+		Summary: "Show help for a command",
+		Run:     runHelp,
+	}
+}
+
+func runHelp(c *Command, args []string) error {
+	root := c.parent
+	target, rest := Resolve(root, args)
+	if len(rest) > 0 {
+		return UsageErrorf(c, "unknown command %q", strings.Join(args, " "))
+	}
+
+	// Листовые команды регистрируют флаги сами, поэтому справку с флагами выводит их -h
+	if target.Run != nil && target != c {
+		return target.Run(target, []string{"-h"})
+	}
+
+	target.PrintHelp(os.Stdout, nil)
+
+	return nil
+}
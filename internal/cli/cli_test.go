@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecuteExitCodes(t *testing.T) {
+	var got []string
+	record := func(err error) func(c *Command, args []string) error {
+		return func(c *Command, args []string) error {
+			got = append(got, c.Path())
+			fs := c.FlagSet()
+			fs.SetOutput(&bytes.Buffer{})
+			fs.Bool("verbose", false, "")
+			if err := ParseFlags(fs, args); err != nil {
+				return err
+			}
+			return err
+		}
+	}
+
+	root := (&Command{
+		Name:    "simple-service",
+		Default: "serve",
+		Subcommands: []*Command{
+			{Name: "serve", Run: record(nil)},
+			{Name: "schema", Subcommands: []*Command{
+				{Name: "check", Run: record(CheckFailedf("drift"))},
+				{Name: "snapshot", Run: record(errors.New("no database"))},
+			}},
+		},
+	}).Link()
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantRun  string
+	}{
+		{name: "Без команды запускается сервер", args: nil, wantCode: ExitOK, wantRun: "simple-service serve"},
+		{name: "Только флаги - тоже сервер", args: []string{"-verbose"}, wantCode: ExitOK, wantRun: "simple-service serve"},
+		{name: "Вложенная команда", args: []string{"schema", "check"}, wantCode: ExitCheckFailed, wantRun: "simple-service schema check"},
+		{name: "Ошибка выполнения", args: []string{"schema", "snapshot"}, wantCode: ExitError, wantRun: "simple-service schema snapshot"},
+		{name: "Справка по флагам", args: []string{"serve", "-h"}, wantCode: ExitOK, wantRun: "simple-service serve"},
+		{name: "Неизвестный флаг", args: []string{"serve", "-unknown"}, wantCode: ExitUsage, wantRun: "simple-service serve"},
+		{name: "Неизвестная команда", args: []string{"schema", "fix"}, wantCode: ExitUsage},
+		{name: "Группа без команды выводит справку", args: []string{"schema"}, wantCode: ExitOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			var stderr bytes.Buffer
+
+			assert.Equal(t, tt.wantCode, Execute(root, tt.args, &stderr))
+			if tt.wantRun == "" {
+				assert.Empty(t, got)
+			} else {
+				assert.Equal(t, []string{tt.wantRun}, got)
+			}
+		})
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantArgs []string
+		wantDir  string
+	}{
+		{name: "Флаги перед аргументами", args: []string{"-dir", "x", "a", "b"}, wantArgs: []string{"a", "b"}, wantDir: "x"},
+		{name: "Флаги после аргументов", args: []string{"a", "-dir", "x", "b"}, wantArgs: []string{"a", "b"}, wantDir: "x"},
+		{name: "После -- только аргументы", args: []string{"a", "--", "-dir", "x"}, wantArgs: []string{"a", "-dir", "x"}},
+		{name: "Без аргументов", args: []string{"-dir=x"}, wantArgs: []string{}, wantDir: "x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			dir := fs.String("dir", "", "")
+
+			assert.NoError(t, ParseFlags(fs, tt.args))
+			assert.Equal(t, tt.wantArgs, fs.Args())
+			assert.Equal(t, tt.wantDir, *dir)
+		})
+	}
+}
@@ -1,34 +1,556 @@
 package config
 
 import (
+	"net"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
 )
 
-// Общая конфигурация сервиса, тут должны быть все переменные
+// Общая конфигурация сервиса, тут должны быть все переменные.
+// Теги: envconfig - переменная окружения (из неё же строится имя флага),
+// flag - имя флага, если оно отличается от построенного из envconfig,
+// yaml - ключ в файле конфигурации, secret - значение скрывается при выводе,
+// reload - параметр применяется без перезапуска (SIGHUP или изменение файла)
 
 const EnvPath = "local.env"
 
 type AppConfig struct {
-	LogLevel   string `envconfig:"LOG_LEVEL" default:"info"`
-	Rest       Rest
-	PostgreSQL PostgreSQL
+	LogLevel            string        `envconfig:"LOG_LEVEL" yaml:"log_level" default:"info" reload:"true"`
+	ConfigWatchInterval time.Duration `envconfig:"CONFIG_WATCH_INTERVAL" yaml:"config_watch_interval" default:"0s"`
+	Secrets             Secrets       `yaml:"secrets"`
+	Storage             Storage       `yaml:"storage"`
+	Rest                Rest          `yaml:"rest"`
+	PostgreSQL          PostgreSQL    `yaml:"postgresql"`
+	Migrations          Migrations    `yaml:"migrations"`
+	Cache               Cache         `yaml:"cache"`
+	Outbox              Outbox        `yaml:"outbox"`
+	Webhooks            Webhooks      `yaml:"webhooks"`
+}
+
+// Secrets - откуда брать секреты помимо переменных NAME и NAME_FILE
+type Secrets struct {
+	// Dir - каталог с файлами секретов (например /run/secrets)
+	Dir string `envconfig:"SECRETS_DIR" yaml:"dir"`
+	// RefreshInterval - период перечитывания секретов для ротации без перезапуска, 0 - отключено
+	RefreshInterval time.Duration `envconfig:"SECRETS_REFRESH_INTERVAL" yaml:"refresh_interval" default:"0s"`
+}
+
+// Хранилища задач
+const (
+	// StoragePostgres - PostgreSQL, единственный вариант для production
+	StoragePostgres = "postgres"
+	// StorageMemory - в памяти процесса, для локального запуска и тестов; данные теряются при перезапуске
+	StorageMemory = "memory"
+)
+
+var storageBackends = map[string]struct{}{
+	StoragePostgres: {},
+	StorageMemory:   {},
+}
+
+// Storage - выбор хранилища задач при запуске
+type Storage struct {
+	Backend string `envconfig:"STORAGE_BACKEND" flag:"storage" yaml:"backend" default:"postgres"`
 }
 
 type Rest struct {
-	ListenAddress string        `envconfig:"PORT" required:"true"`
-	WriteTimeout  time.Duration `envconfig:"WRITE_TIMEOUT" required:"true"`
-	ServerName    string        `envconfig:"SERVER_NAME" required:"true"`
-	Token         string        `envconfig:"TOKEN" required:"true"`
+	ListenAddress string        `envconfig:"PORT" yaml:"listen_address" required:"true"`
+	ReadTimeout   time.Duration `envconfig:"READ_TIMEOUT" yaml:"read_timeout" default:"15s"`
+	WriteTimeout  time.Duration `envconfig:"WRITE_TIMEOUT" yaml:"write_timeout" required:"true"`
+	IdleTimeout   time.Duration `envconfig:"IDLE_TIMEOUT" yaml:"idle_timeout" default:"60s"`
+	BodyLimit     int           `envconfig:"BODY_LIMIT" yaml:"body_limit" default:"4194304"`
+	Concurrency   int           `envconfig:"CONCURRENCY" yaml:"concurrency" default:"262144"`
+	ServerName    string        `envconfig:"SERVER_NAME" yaml:"server_name" required:"true"`
+	Token         string        `envconfig:"TOKEN" yaml:"token" required:"true" secret:"true" reload:"true"`
+	// ProxyHeader - заголовок с реальным IP клиента (X-Forwarded-For), учитывается только от TrustedProxies
+	ProxyHeader    string   `envconfig:"PROXY_HEADER" yaml:"proxy_header"`
+	TrustedProxies []string `envconfig:"TRUSTED_PROXIES" yaml:"trusted_proxies"`
+	// TLS включается, если заданы оба файла; сертификат перечитывается при изменении файлов
+	TLSCertFile       string        `envconfig:"TLS_CERT_FILE" yaml:"tls_cert_file"`
+	TLSKeyFile        string        `envconfig:"TLS_KEY_FILE" yaml:"tls_key_file"`
+	TLSReloadInterval time.Duration `envconfig:"TLS_RELOAD_INTERVAL" yaml:"tls_reload_interval" default:"1m"`
+	// RequestTimeout - бюджет времени на обработку запроса API, включая запросы к БД, 0 - без ограничения
+	RequestTimeout time.Duration `envconfig:"REQUEST_TIMEOUT" yaml:"request_timeout" default:"10s"`
+	// RouteTimeouts - бюджет для отдельных маршрутов в формате "GET /v1/tasks=30s"
+	RouteTimeouts []string `envconfig:"ROUTE_TIMEOUTS" yaml:"route_timeouts"`
+	// ExportTimeout - бюджет выгрузки GET /v1/tasks/export вместо REQUEST_TIMEOUT, включая отправку файла;
+	// ROUTE_TIMEOUTS для маршрута важнее, 0 - без ограничения
+	ExportTimeout time.Duration `envconfig:"EXPORT_TIMEOUT" yaml:"export_timeout" default:"10m"`
+	// BatchMaxSize - максимум задач в одном запросе пакетного создания
+	BatchMaxSize int `envconfig:"BATCH_MAX_SIZE" yaml:"batch_max_size" default:"1000"`
+	// BulkMaxAffected - максимум задач, затрагиваемых массовым изменением или удалением без scope tasks:admin
+	BulkMaxAffected int `envconfig:"BULK_MAX_AFFECTED" yaml:"bulk_max_affected" default:"1000"`
+	// ImportMaxRows - максимум задач в одном файле загрузки
+	ImportMaxRows int `envconfig:"IMPORT_MAX_ROWS" yaml:"import_max_rows" default:"10000"`
+	// IdempotencyTTL - сколько хранится ответ на запрос создания с заголовком Idempotency-Key
+	IdempotencyTTL time.Duration `envconfig:"IDEMPOTENCY_TTL" yaml:"idempotency_ttl" default:"24h"`
+	// IdempotencyLockTimeout - сколько ключ занят выполняющимся запросом; после сбоя реплики ключ освобождается через это время
+	IdempotencyLockTimeout time.Duration `envconfig:"IDEMPOTENCY_LOCK_TIMEOUT" yaml:"idempotency_lock_timeout" default:"1m"`
+
+	CORSAllowOrigins []string      `envconfig:"CORS_ALLOW_ORIGINS" yaml:"cors_allow_origins" default:"*" reload:"true"`
+	RateLimit        int           `envconfig:"RATE_LIMIT" yaml:"rate_limit" default:"0" reload:"true"`
+	RateLimitWindow  time.Duration `envconfig:"RATE_LIMIT_WINDOW" yaml:"rate_limit_window" default:"1m" reload:"true"`
+}
+
+// TLSEnabled - сервер принимает соединения по TLS
+func (r Rest) TLSEnabled() bool {
+	return r.TLSCertFile != "" && r.TLSKeyFile != ""
+}
+
+// ParseRouteTimeouts - ROUTE_TIMEOUTS в виде "METHOD /path" -> бюджет
+func (r Rest) ParseRouteTimeouts() (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration, len(r.RouteTimeouts))
+	for _, item := range r.RouteTimeouts {
+		route, value, ok := strings.Cut(item, "=")
+		method, path, hasPath := strings.Cut(strings.TrimSpace(route), " ")
+		if !ok || !hasPath || method == "" || !strings.HasPrefix(path, "/") {
+			return nil, errors.Errorf("invalid route timeout %q, expected \"METHOD /path=duration\"", item)
+		}
+
+		timeout, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || timeout < 0 {
+			return nil, errors.Errorf("invalid route timeout %q, expected non-negative duration", item)
+		}
+		timeouts[strings.ToUpper(method)+" "+path] = timeout
+	}
+	return timeouts, nil
+}
+
+// IdempotentRoutes - маршруты с заголовком Idempotency-Key в формате ROUTE_TIMEOUTS
+var IdempotentRoutes = []string{"POST /v1/create_task", "POST /v1/tasks:batchCreate"}
+
+// routeTimeout - бюджет маршрута: из ROUTE_TIMEOUTS или REQUEST_TIMEOUT, 0 - без ограничения
+func (r Rest) routeTimeout(timeouts map[string]time.Duration, route string) time.Duration {
+	if timeout, ok := timeouts[route]; ok {
+		return timeout
+	}
+	return r.RequestTimeout
+}
+
+// validateIdempotencyBudget - бюджет каждого маршрута с ключом идемпотентности ограничен и не больше
+// IDEMPOTENCY_LOCK_TIMEOUT. Иначе ключ освободится, пока исходный запрос ещё выполняется,
+// и повтор создаст задачу второй раз
+func (r Rest) validateIdempotencyBudget() []error {
+	// Ошибку формата ROUTE_TIMEOUTS сообщает общая проверка
+	timeouts, _ := r.ParseRouteTimeouts()
+
+	var errs []error
+	var longest string
+	for _, route := range IdempotentRoutes {
+		timeout := r.routeTimeout(timeouts, route)
+		switch {
+		case timeout == 0:
+			errs = append(errs, errors.Errorf("REQUEST_TIMEOUT, ROUTE_TIMEOUTS: %s must have a deadline to use idempotency keys", route))
+		case longest == "" || timeout > r.routeTimeout(timeouts, longest):
+			longest = route
+		}
+	}
+	if longest != "" && r.routeTimeout(timeouts, longest) > r.IdempotencyLockTimeout {
+		errs = append(errs, errors.Errorf("IDEMPOTENCY_LOCK_TIMEOUT: must be at least the budget of %s (%s), got %s",
+			longest, r.routeTimeout(timeouts, longest), r.IdempotencyLockTimeout))
+	}
+	return errs
 }
 
 type PostgreSQL struct {
-	Host                string        `envconfig:"DB_HOST" required:"true"`
-	Port                int           `envconfig:"DB_PORT" required:"true"`
-	Name                string        `envconfig:"DB_NAME" required:"true"`
-	User                string        `envconfig:"DB_USER" required:"true"`
-	Password            string        `envconfig:"DB_PASSWORD" required:"true"`
-	SSLMode             string        `envconfig:"DB_SSL_MODE" default:"disable"`
-	PoolMaxConns        int           `envconfig:"DB_POOL_MAX_CONNS" default:"5"`
-	PoolMaxConnLifetime time.Duration `envconfig:"DB_POOL_MAX_CONN_LIFETIME" default:"180s"`
-	PoolMaxConnIdleTime time.Duration `envconfig:"DB_POOL_MAX_CONN_IDLE_TIME" default:"100s"`
+	Host                string        `envconfig:"DB_HOST" yaml:"host" required:"true"`
+	Port                int           `envconfig:"DB_PORT" yaml:"port" required:"true"`
+	Name                string        `envconfig:"DB_NAME" yaml:"name" required:"true"`
+	User                string        `envconfig:"DB_USER" yaml:"user" required:"true"`
+	Password            string        `envconfig:"DB_PASSWORD" yaml:"password" required:"true" secret:"true" reload:"true"`
+	SSLMode             string        `envconfig:"DB_SSL_MODE" yaml:"ssl_mode" default:"disable"`
+	PoolMaxConns        int           `envconfig:"DB_POOL_MAX_CONNS" yaml:"pool_max_conns" default:"5"`
+	PoolMaxConnLifetime time.Duration `envconfig:"DB_POOL_MAX_CONN_LIFETIME" yaml:"pool_max_conn_lifetime" default:"180s"`
+	PoolMaxConnIdleTime time.Duration `envconfig:"DB_POOL_MAX_CONN_IDLE_TIME" yaml:"pool_max_conn_idle_time" default:"100s"`
+	// RetryAttempts - попыток на запрос, включая первую; повторяются только временные ошибки
+	RetryAttempts  int           `envconfig:"DB_RETRY_ATTEMPTS" yaml:"retry_attempts" default:"3"`
+	RetryBaseDelay time.Duration `envconfig:"DB_RETRY_BASE_DELAY" yaml:"retry_base_delay" default:"50ms"`
+	RetryMaxDelay  time.Duration `envconfig:"DB_RETRY_MAX_DELAY" yaml:"retry_max_delay" default:"1s"`
+	// BreakerThreshold - ошибок подключения подряд, после которых запросы отклоняются без обращения к БД, 0 - отключено
+	BreakerThreshold int `envconfig:"DB_BREAKER_THRESHOLD" yaml:"breaker_threshold" default:"5"`
+	// BreakerOpenTimeout - сколько отклонять запросы, прежде чем пропустить пробный
+	BreakerOpenTimeout time.Duration `envconfig:"DB_BREAKER_OPEN_TIMEOUT" yaml:"breaker_open_timeout" default:"10s"`
+	// TxIsolation - уровень изоляции транзакций, для которых он не задан явно
+	TxIsolation string `envconfig:"DB_TX_ISOLATION" yaml:"tx_isolation" default:"read_committed"`
+	// ReplicaHosts - реплики для чтения в формате host или host:port, остальные параметры подключения как у primary
+	ReplicaHosts []string `envconfig:"DB_REPLICA_HOSTS" yaml:"replica_hosts"`
+	// ReplicaMaxLag - реплика с большим отставанием исключается из чтения до следующей проверки
+	ReplicaMaxLag        time.Duration `envconfig:"DB_REPLICA_MAX_LAG" yaml:"replica_max_lag" default:"5s"`
+	ReplicaCheckInterval time.Duration `envconfig:"DB_REPLICA_CHECK_INTERVAL" yaml:"replica_check_interval" default:"5s"`
+	// ReadYourWrites - сколько после записи чтения того же пользователя идут в primary, 0 - не закреплять
+	ReadYourWrites time.Duration `envconfig:"DB_READ_YOUR_WRITES" yaml:"read_your_writes" default:"5s"`
+}
+
+// Replicas - адреса реплик, порт по умолчанию - DB_PORT
+func (p PostgreSQL) Replicas() ([]Address, error) {
+	addrs := make([]Address, 0, len(p.ReplicaHosts))
+	for _, hostPort := range p.ReplicaHosts {
+		addr := Address{Host: hostPort, Port: p.Port}
+		if host, port, err := net.SplitHostPort(hostPort); err == nil {
+			n, err := strconv.Atoi(port)
+			if err != nil || validatePort(n) != nil {
+				return nil, errors.Errorf("invalid replica %q, expected host or host:port", hostPort)
+			}
+			addr = Address{Host: host, Port: n}
+		}
+		if addr.Host == "" || strings.Contains(addr.Host, " ") ||
+			strings.Contains(addr.Host, ":") && net.ParseIP(addr.Host) == nil {
+			return nil, errors.Errorf("invalid replica %q, expected host or host:port", hostPort)
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+// Address - адрес сервера БД
+type Address struct {
+	Host string
+	Port int
+}
+
+func (a Address) String() string {
+	return net.JoinHostPort(a.Host, strconv.Itoa(a.Port))
+}
+
+// Cache - кеш чтения задач по ID в памяти процесса
+type Cache struct {
+	// Size - максимум задач в кеше, 0 - кеш отключён
+	Size int           `envconfig:"CACHE_SIZE" yaml:"size" default:"10000"`
+	TTL  time.Duration `envconfig:"CACHE_TTL" yaml:"ttl" default:"30s"`
+	// NegativeTTL - сколько помнить, что задачи нет, 0 - не кешировать отсутствие
+	NegativeTTL time.Duration `envconfig:"CACHE_NEGATIVE_TTL" yaml:"negative_ttl" default:"5s"`
+}
+
+// Получатели событий outbox
+const (
+	// OutboxSinkLog - событие пишется в лог сервиса
+	OutboxSinkLog = "log"
+	// OutboxSinkFile - событие дописывается строкой JSON в файл OUTBOX_FILE
+	OutboxSinkFile = "file"
+)
+
+var outboxSinks = map[string]struct{}{
+	OutboxSinkLog:  {},
+	OutboxSinkFile: {},
+}
+
+// Outbox - доставка событий изменения задач из outbox
+type Outbox struct {
+	Sink string `envconfig:"OUTBOX_SINK" yaml:"sink" default:"log"`
+	// File - файл для OUTBOX_SINK=file
+	File string `envconfig:"OUTBOX_FILE" yaml:"file" default:"events.ndjson"`
+	// PollInterval - как часто проверять новые события, если очередь пуста
+	PollInterval time.Duration `envconfig:"OUTBOX_POLL_INTERVAL" yaml:"poll_interval" default:"1s"`
+	// BatchSize - максимум событий, выбираемых за раз
+	BatchSize int `envconfig:"OUTBOX_BATCH_SIZE" yaml:"batch_size" default:"100"`
+	// Lease - сколько выбранные события недоступны другим репликам, больше времени доставки пачки
+	Lease time.Duration `envconfig:"OUTBOX_LEASE" yaml:"lease" default:"1m"`
+	// RetryBaseDelay и RetryMaxDelay - пауза перед повтором неудачной доставки, растёт экспоненциально
+	RetryBaseDelay time.Duration `envconfig:"OUTBOX_RETRY_BASE_DELAY" yaml:"retry_base_delay" default:"1s"`
+	RetryMaxDelay  time.Duration `envconfig:"OUTBOX_RETRY_MAX_DELAY" yaml:"retry_max_delay" default:"5m"`
+}
+
+// Webhooks - доставка событий задач подписчикам по HTTP
+type Webhooks struct {
+	// Timeout - ограничение одного запроса к подписчику
+	Timeout time.Duration `envconfig:"WEBHOOK_TIMEOUT" yaml:"timeout" default:"10s"`
+	// MaxAttempts - после стольких неудачных попыток доставка попадает в dead letter
+	MaxAttempts int `envconfig:"WEBHOOK_MAX_ATTEMPTS" yaml:"max_attempts" default:"8"`
+	// RetryBaseDelay и RetryMaxDelay - пауза перед повтором неудачной доставки, растёт экспоненциально
+	RetryBaseDelay time.Duration `envconfig:"WEBHOOK_RETRY_BASE_DELAY" yaml:"retry_base_delay" default:"10s"`
+	RetryMaxDelay  time.Duration `envconfig:"WEBHOOK_RETRY_MAX_DELAY" yaml:"retry_max_delay" default:"1h"`
+	// PollInterval - как часто проверять доставки, готовые к отправке
+	PollInterval time.Duration `envconfig:"WEBHOOK_POLL_INTERVAL" yaml:"poll_interval" default:"1s"`
+	// BatchSize - максимум доставок, отправляемых одновременно
+	BatchSize int `envconfig:"WEBHOOK_BATCH_SIZE" yaml:"batch_size" default:"20"`
+	// Retention - сколько хранить журнал завершённых доставок (доставленных и dead letter)
+	Retention time.Duration `envconfig:"WEBHOOK_DELIVERY_RETENTION" yaml:"delivery_retention" default:"168h"`
+}
+
+// Режимы применения миграций при запуске сервера
+const (
+	// MigrationsAuto - применить неприменённые миграции
+	MigrationsAuto = "auto"
+	// MigrationsVerify - только проверить, что схема актуальна, иначе не запускаться
+	MigrationsVerify = "verify"
+	// MigrationsOff - не трогать схему
+	MigrationsOff = "off"
+)
+
+// Migrations - применение миграций при запуске. Несколько реплик сервиса
+// применяют миграции по очереди под advisory lock
+type Migrations struct {
+	Mode string `envconfig:"MIGRATIONS_MODE" yaml:"mode" default:"auto"`
+	// LockTimeout - сколько ждать блокировку, пока миграции применяет другая реплика
+	LockTimeout time.Duration `envconfig:"MIGRATIONS_LOCK_TIMEOUT" yaml:"lock_timeout" default:"1m"`
+	// SchemaCheck - сравнение схемы БД со снимком при запуске: off, warn или fail
+	SchemaCheck string `envconfig:"SCHEMA_CHECK" yaml:"schema_check" default:"off"`
+}
+
+// Реакция на расхождение схемы БД со снимком при запуске
+const (
+	SchemaCheckOff  = "off"
+	SchemaCheckWarn = "warn"
+	SchemaCheckFail = "fail"
+)
+
+var schemaCheckModes = map[string]struct{}{
+	SchemaCheckOff:  {},
+	SchemaCheckWarn: {},
+	SchemaCheckFail: {},
+}
+
+var migrationModes = map[string]struct{}{
+	MigrationsAuto:   {},
+	MigrationsVerify: {},
+	MigrationsOff:    {},
+}
+
+// Допустимые значения sslmode для PostgreSQL
+var sslModes = map[string]struct{}{
+	"disable":     {},
+	"allow":       {},
+	"prefer":      {},
+	"require":     {},
+	"verify-ca":   {},
+	"verify-full": {},
+}
+
+// Допустимые уровни изоляции транзакций
+var txIsolations = map[string]struct{}{
+	"read_committed":  {},
+	"repeatable_read": {},
+	"serializable":    {},
+}
+
+// Validate - семантическая проверка конфигурации, возвращает все найденные ошибки сразу
+func (c *AppConfig) Validate() error {
+	var errs []error
+
+	if _, err := zap.ParseAtomicLevel(c.LogLevel); err != nil {
+		errs = append(errs, errors.Errorf("LOG_LEVEL: unknown log level %q", c.LogLevel))
+	}
+
+	if c.ConfigWatchInterval < 0 {
+		errs = append(errs, errors.Errorf("CONFIG_WATCH_INTERVAL: must not be negative, got %s", c.ConfigWatchInterval))
+	}
+
+	if c.Secrets.RefreshInterval < 0 {
+		errs = append(errs, errors.Errorf("SECRETS_REFRESH_INTERVAL: must not be negative, got %s", c.Secrets.RefreshInterval))
+	}
+
+	if err := validateListenAddress(c.Rest.ListenAddress); err != nil {
+		errs = append(errs, errors.Wrap(err, "PORT"))
+	}
+	if c.Rest.ReadTimeout <= 0 {
+		errs = append(errs, errors.Errorf("READ_TIMEOUT: must be positive, got %s", c.Rest.ReadTimeout))
+	}
+	if c.Rest.WriteTimeout <= 0 {
+		errs = append(errs, errors.Errorf("WRITE_TIMEOUT: must be positive, got %s", c.Rest.WriteTimeout))
+	}
+	if c.Rest.IdleTimeout <= 0 {
+		errs = append(errs, errors.Errorf("IDLE_TIMEOUT: must be positive, got %s", c.Rest.IdleTimeout))
+	}
+	if c.Rest.BodyLimit < 1 {
+		errs = append(errs, errors.Errorf("BODY_LIMIT: must be at least 1, got %d", c.Rest.BodyLimit))
+	}
+	if c.Rest.Concurrency < 1 {
+		errs = append(errs, errors.Errorf("CONCURRENCY: must be at least 1, got %d", c.Rest.Concurrency))
+	}
+	for _, proxy := range c.Rest.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				errs = append(errs, errors.Errorf("TRUSTED_PROXIES: %q is neither IP nor CIDR", proxy))
+			}
+		}
+	}
+	if (c.Rest.TLSCertFile == "") != (c.Rest.TLSKeyFile == "") {
+		errs = append(errs, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together"))
+	}
+	if c.Rest.TLSReloadInterval <= 0 {
+		errs = append(errs, errors.Errorf("TLS_RELOAD_INTERVAL: must be positive, got %s", c.Rest.TLSReloadInterval))
+	}
+	if c.Rest.RequestTimeout < 0 {
+		errs = append(errs, errors.Errorf("REQUEST_TIMEOUT: must not be negative, got %s", c.Rest.RequestTimeout))
+	}
+	if c.Rest.ExportTimeout < 0 {
+		errs = append(errs, errors.Errorf("EXPORT_TIMEOUT: must not be negative, got %s", c.Rest.ExportTimeout))
+	}
+	if _, err := c.Rest.ParseRouteTimeouts(); err != nil {
+		errs = append(errs, errors.Wrap(err, "ROUTE_TIMEOUTS"))
+	}
+	if c.Rest.BatchMaxSize < 1 {
+		errs = append(errs, errors.Errorf("BATCH_MAX_SIZE: must be at least 1, got %d", c.Rest.BatchMaxSize))
+	}
+	if c.Rest.BulkMaxAffected < 1 {
+		errs = append(errs, errors.Errorf("BULK_MAX_AFFECTED: must be at least 1, got %d", c.Rest.BulkMaxAffected))
+	}
+	if c.Rest.ImportMaxRows < 1 {
+		errs = append(errs, errors.Errorf("IMPORT_MAX_ROWS: must be at least 1, got %d", c.Rest.ImportMaxRows))
+	}
+	if c.Rest.IdempotencyTTL <= 0 {
+		errs = append(errs, errors.Errorf("IDEMPOTENCY_TTL: must be positive, got %s", c.Rest.IdempotencyTTL))
+	}
+	if c.Rest.IdempotencyLockTimeout <= 0 {
+		errs = append(errs, errors.Errorf("IDEMPOTENCY_LOCK_TIMEOUT: must be positive, got %s", c.Rest.IdempotencyLockTimeout))
+	} else {
+		errs = append(errs, c.Rest.validateIdempotencyBudget()...)
+	}
+	if len(c.Rest.CORSAllowOrigins) == 0 {
+		errs = append(errs, errors.New("CORS_ALLOW_ORIGINS: at least one origin or * is required"))
+	}
+	if c.Rest.RateLimit < 0 {
+		errs = append(errs, errors.Errorf("RATE_LIMIT: must not be negative, got %d", c.Rest.RateLimit))
+	}
+	if c.Rest.RateLimitWindow <= 0 {
+		errs = append(errs, errors.Errorf("RATE_LIMIT_WINDOW: must be positive, got %s", c.Rest.RateLimitWindow))
+	}
+
+	if _, ok := storageBackends[c.Storage.Backend]; !ok {
+		errs = append(errs, errors.Errorf("STORAGE_BACKEND: unknown backend %q, expected postgres or memory", c.Storage.Backend))
+	}
+
+	if c.UsesPostgres() {
+		if err := validatePort(c.PostgreSQL.Port); err != nil {
+			errs = append(errs, errors.Wrap(err, "DB_PORT"))
+		}
+		if _, ok := sslModes[c.PostgreSQL.SSLMode]; !ok {
+			errs = append(errs, errors.Errorf("DB_SSL_MODE: unknown ssl mode %q", c.PostgreSQL.SSLMode))
+		}
+		if c.PostgreSQL.PoolMaxConns < 1 {
+			errs = append(errs, errors.Errorf("DB_POOL_MAX_CONNS: must be at least 1, got %d", c.PostgreSQL.PoolMaxConns))
+		}
+		if c.PostgreSQL.PoolMaxConnLifetime <= 0 {
+			errs = append(errs, errors.Errorf("DB_POOL_MAX_CONN_LIFETIME: must be positive, got %s", c.PostgreSQL.PoolMaxConnLifetime))
+		}
+		if c.PostgreSQL.PoolMaxConnIdleTime <= 0 {
+			errs = append(errs, errors.Errorf("DB_POOL_MAX_CONN_IDLE_TIME: must be positive, got %s", c.PostgreSQL.PoolMaxConnIdleTime))
+		}
+		if c.PostgreSQL.RetryAttempts < 1 {
+			errs = append(errs, errors.Errorf("DB_RETRY_ATTEMPTS: must be at least 1, got %d", c.PostgreSQL.RetryAttempts))
+		}
+		if c.PostgreSQL.RetryBaseDelay <= 0 || c.PostgreSQL.RetryMaxDelay < c.PostgreSQL.RetryBaseDelay {
+			errs = append(errs, errors.Errorf("DB_RETRY_BASE_DELAY, DB_RETRY_MAX_DELAY: expected 0 < base <= max, got %s and %s",
+				c.PostgreSQL.RetryBaseDelay, c.PostgreSQL.RetryMaxDelay))
+		}
+		if c.PostgreSQL.BreakerThreshold < 0 {
+			errs = append(errs, errors.Errorf("DB_BREAKER_THRESHOLD: must not be negative, got %d", c.PostgreSQL.BreakerThreshold))
+		}
+		if c.PostgreSQL.BreakerOpenTimeout <= 0 {
+			errs = append(errs, errors.Errorf("DB_BREAKER_OPEN_TIMEOUT: must be positive, got %s", c.PostgreSQL.BreakerOpenTimeout))
+		}
+		if _, err := c.PostgreSQL.Replicas(); err != nil {
+			errs = append(errs, errors.Wrap(err, "DB_REPLICA_HOSTS"))
+		}
+		if c.PostgreSQL.ReplicaMaxLag <= 0 {
+			errs = append(errs, errors.Errorf("DB_REPLICA_MAX_LAG: must be positive, got %s", c.PostgreSQL.ReplicaMaxLag))
+		}
+		if c.PostgreSQL.ReplicaCheckInterval <= 0 {
+			errs = append(errs, errors.Errorf("DB_REPLICA_CHECK_INTERVAL: must be positive, got %s", c.PostgreSQL.ReplicaCheckInterval))
+		}
+		if c.PostgreSQL.ReadYourWrites < 0 {
+			errs = append(errs, errors.Errorf("DB_READ_YOUR_WRITES: must not be negative, got %s", c.PostgreSQL.ReadYourWrites))
+		}
+		if _, ok := txIsolations[c.PostgreSQL.TxIsolation]; !ok {
+			errs = append(errs, errors.Errorf("DB_TX_ISOLATION: unknown isolation level %q, expected read_committed, repeatable_read or serializable",
+				c.PostgreSQL.TxIsolation))
+		}
+	}
+
+	if _, ok := migrationModes[c.Migrations.Mode]; !ok {
+		errs = append(errs, errors.Errorf("MIGRATIONS_MODE: unknown mode %q, expected auto, verify or off", c.Migrations.Mode))
+	}
+	if c.Migrations.LockTimeout <= 0 {
+		errs = append(errs, errors.Errorf("MIGRATIONS_LOCK_TIMEOUT: must be positive, got %s", c.Migrations.LockTimeout))
+	}
+	if _, ok := schemaCheckModes[c.Migrations.SchemaCheck]; !ok {
+		errs = append(errs, errors.Errorf("SCHEMA_CHECK: unknown mode %q, expected off, warn or fail", c.Migrations.SchemaCheck))
+	}
+
+	if c.Cache.Size < 0 {
+		errs = append(errs, errors.Errorf("CACHE_SIZE: must not be negative, got %d", c.Cache.Size))
+	}
+	if c.Cache.TTL <= 0 {
+		errs = append(errs, errors.Errorf("CACHE_TTL: must be positive, got %s", c.Cache.TTL))
+	}
+	if c.Cache.NegativeTTL < 0 {
+		errs = append(errs, errors.Errorf("CACHE_NEGATIVE_TTL: must not be negative, got %s", c.Cache.NegativeTTL))
+	}
+
+	if _, ok := outboxSinks[c.Outbox.Sink]; !ok {
+		errs = append(errs, errors.Errorf("OUTBOX_SINK: unknown sink %q, expected log or file", c.Outbox.Sink))
+	}
+	if c.Outbox.Sink == OutboxSinkFile && c.Outbox.File == "" {
+		errs = append(errs, errors.New("OUTBOX_FILE: required for OUTBOX_SINK=file"))
+	}
+	if c.Outbox.PollInterval <= 0 {
+		errs = append(errs, errors.Errorf("OUTBOX_POLL_INTERVAL: must be positive, got %s", c.Outbox.PollInterval))
+	}
+	if c.Outbox.BatchSize < 1 {
+		errs = append(errs, errors.Errorf("OUTBOX_BATCH_SIZE: must be at least 1, got %d", c.Outbox.BatchSize))
+	}
+	if c.Outbox.Lease <= 0 {
+		errs = append(errs, errors.Errorf("OUTBOX_LEASE: must be positive, got %s", c.Outbox.Lease))
+	}
+	if c.Outbox.RetryBaseDelay <= 0 || c.Outbox.RetryMaxDelay < c.Outbox.RetryBaseDelay {
+		errs = append(errs, errors.Errorf("OUTBOX_RETRY_BASE_DELAY, OUTBOX_RETRY_MAX_DELAY: expected 0 < base <= max, got %s and %s",
+			c.Outbox.RetryBaseDelay, c.Outbox.RetryMaxDelay))
+	}
+
+	if c.Webhooks.Timeout <= 0 {
+		errs = append(errs, errors.Errorf("WEBHOOK_TIMEOUT: must be positive, got %s", c.Webhooks.Timeout))
+	}
+	if c.Webhooks.MaxAttempts < 1 {
+		errs = append(errs, errors.Errorf("WEBHOOK_MAX_ATTEMPTS: must be at least 1, got %d", c.Webhooks.MaxAttempts))
+	}
+	if c.Webhooks.RetryBaseDelay <= 0 || c.Webhooks.RetryMaxDelay < c.Webhooks.RetryBaseDelay {
+		errs = append(errs, errors.Errorf("WEBHOOK_RETRY_BASE_DELAY, WEBHOOK_RETRY_MAX_DELAY: expected 0 < base <= max, got %s and %s",
+			c.Webhooks.RetryBaseDelay, c.Webhooks.RetryMaxDelay))
+	}
+	if c.Webhooks.PollInterval <= 0 {
+		errs = append(errs, errors.Errorf("WEBHOOK_POLL_INTERVAL: must be positive, got %s", c.Webhooks.PollInterval))
+	}
+	if c.Webhooks.BatchSize < 1 {
+		errs = append(errs, errors.Errorf("WEBHOOK_BATCH_SIZE: must be at least 1, got %d", c.Webhooks.BatchSize))
+	}
+	if c.Webhooks.Retention <= 0 {
+		errs = append(errs, errors.Errorf("WEBHOOK_DELIVERY_RETENTION: must be positive, got %s", c.Webhooks.Retention))
+	}
+
+	return joinErrors(errs)
+}
+
+// UsesPostgres - задачи хранятся в PostgreSQL. Иначе параметры postgresql не обязательны и не проверяются
+func (c *AppConfig) UsesPostgres() bool {
+	return c.Storage.Backend != StorageMemory
+}
+
+// inactive - параметр не используется при выбранном хранилище
+func (c *AppConfig) inactive(key string) bool {
+	return !c.UsesPostgres() && strings.HasPrefix(key, "postgresql.")
+}
+
+func validateListenAddress(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return errors.Errorf("invalid listen address %q, expected host:port", addr)
+	}
+
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		return errors.Errorf("invalid port %q", port)
+	}
+
+	return validatePort(n)
+}
+
+func validatePort(port int) error {
+	if port < 1 || port > 65535 {
+		return errors.Errorf("port %d out of range 1-65535", port)
+	}
+	return nil
 }
@@ -0,0 +1,341 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"simple-service/internal/secrets"
+)
+
+const testYAML = `
+log_level: debug
+unknown_root: 1
+rest:
+  listen_address: ":9090"
+  write_timeout: 20s
+  server_name: from-file
+  typo_key: true
+postgresql:
+  host: file-host
+  port: 5432
+  name: simple_service
+  user: postgres
+  ssl_mode: require
+`
+
+const testTOML = `
+log_level = "warn"
+
+[rest]
+listen_address = ":7070"
+write_timeout = "5s"
+server_name = "toml"
+
+[postgresql]
+host = "toml-host"
+port = 6432
+name = "db"
+user = "user"
+`
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func load(t *testing.T, args ...string) (*Result, error) {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	loader := NewLoader(fs)
+	require.NoError(t, fs.Parse(append([]string{"-env-file", ""}, args...)))
+	return loader.Load()
+}
+
+func TestLoaderLayers(t *testing.T) {
+	path := writeFile(t, "config.yaml", testYAML)
+	t.Setenv("TOKEN", "secret-token")
+	t.Setenv("DB_PASSWORD", "secret-password")
+	t.Setenv("DB_HOST", "env-host")
+	t.Setenv("SERVER_NAME", "from-env")
+
+	res, err := load(t, "-config", path, "-server-name", "from-flag", "-db-pool-max-conns", "20")
+	require.NoError(t, err)
+
+	cfg := res.Config
+	assert.Equal(t, "debug", cfg.LogLevel)
+	assert.Equal(t, ":9090", cfg.Rest.ListenAddress)
+	assert.Equal(t, 20*time.Second, cfg.Rest.WriteTimeout)
+	assert.Equal(t, "from-flag", cfg.Rest.ServerName)
+	assert.Equal(t, "env-host", cfg.PostgreSQL.Host)
+	assert.Equal(t, 20, cfg.PostgreSQL.PoolMaxConns)
+	assert.Equal(t, 180*time.Second, cfg.PostgreSQL.PoolMaxConnLifetime)
+
+	assert.Equal(t, SourceFile, res.Sources["LOG_LEVEL"])
+	assert.Equal(t, SourceEnv, res.Sources["DB_HOST"])
+	assert.Equal(t, SourceFlag, res.Sources["SERVER_NAME"])
+	assert.Equal(t, SourceDefault, res.Sources["DB_POOL_MAX_CONN_LIFETIME"])
+
+	assert.Equal(t, []string{"rest.typo_key", "unknown_root"}, res.UnknownKeys)
+}
+
+func TestLoaderMemoryStorage(t *testing.T) {
+	t.Setenv("TOKEN", "secret-token")
+	t.Setenv("DB_PASSWORD", "")
+	t.Setenv("DB_HOST", "")
+
+	// Без PostgreSQL параметры postgresql не обязательны
+	res, err := load(t, "--storage=memory", "-port", ":8080", "-write-timeout", "15s", "-server-name", "local")
+	require.NoError(t, err)
+	assert.Equal(t, StorageMemory, res.Config.Storage.Backend)
+	assert.Equal(t, SourceFlag, res.Sources["STORAGE_BACKEND"])
+	assert.False(t, res.Config.UsesPostgres())
+
+	t.Setenv("STORAGE_BACKEND", "postgres")
+	_, err = load(t, "-port", ":8080", "-write-timeout", "15s", "-server-name", "local")
+	assert.ErrorContains(t, err, "required key DB_HOST missing value")
+}
+
+func TestLoaderTOML(t *testing.T) {
+	path := writeFile(t, "config.toml", testTOML)
+	t.Setenv("TOKEN", "secret-token")
+	t.Setenv("DB_PASSWORD", "secret-password")
+
+	res, err := load(t, "-config", path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "warn", res.Config.LogLevel)
+	assert.Equal(t, ":7070", res.Config.Rest.ListenAddress)
+	assert.Equal(t, 5*time.Second, res.Config.Rest.WriteTimeout)
+	assert.Equal(t, 6432, res.Config.PostgreSQL.Port)
+	assert.Empty(t, res.UnknownKeys)
+}
+
+func TestLoaderErrors(t *testing.T) {
+	path := writeFile(t, "config.yaml", testYAML)
+
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		wantErr string
+	}{
+		{
+			name:    "Отсутствуют обязательные параметры",
+			args:    []string{"-config", path},
+			wantErr: "invalid configuration:\n  - required key TOKEN missing value\n  - required key DB_PASSWORD missing value",
+		},
+		{
+			name: "Семантические ошибки",
+			args: []string{"-config", path, "-port", "localhost", "-db-ssl-mode", "strict", "-write-timeout", "-1s",
+				"-route-timeouts", "GET /v1/tasks=30s,/v1/tasks/:id=1s", "-batch-max-size", "0", "-bulk-max-affected", "-1",
+				"-import-max-rows", "0", "-request-timeout", "2m", "-export-timeout", "-1s"},
+			env: map[string]string{"TOKEN": "t", "DB_PASSWORD": "p"},
+			wantErr: "invalid configuration:\n  - PORT: invalid listen address \"localhost\", expected host:port" +
+				"\n  - WRITE_TIMEOUT: must be positive, got -1s" +
+				"\n  - EXPORT_TIMEOUT: must not be negative, got -1s" +
+				"\n  - ROUTE_TIMEOUTS: invalid route timeout \"/v1/tasks/:id=1s\", expected \"METHOD /path=duration\"" +
+				"\n  - BATCH_MAX_SIZE: must be at least 1, got 0" +
+				"\n  - BULK_MAX_AFFECTED: must be at least 1, got -1" +
+				"\n  - IMPORT_MAX_ROWS: must be at least 1, got 0" +
+				"\n  - IDEMPOTENCY_LOCK_TIMEOUT: must be at least the budget of POST /v1/create_task (2m0s), got 1m0s" +
+				"\n  - DB_SSL_MODE: unknown ssl mode \"strict\"",
+		},
+		{
+			name: "Бюджет маршрута с ключом идемпотентности больше IDEMPOTENCY_LOCK_TIMEOUT",
+			args: []string{"-config", path, "-route-timeouts", "POST /v1/tasks:batchCreate=5m"},
+			env:  map[string]string{"TOKEN": "t", "DB_PASSWORD": "p"},
+			wantErr: "invalid configuration:" +
+				"\n  - IDEMPOTENCY_LOCK_TIMEOUT: must be at least the budget of POST /v1/tasks:batchCreate (5m0s), got 1m0s",
+		},
+		{
+			name: "Маршрут с ключом идемпотентности без бюджета",
+			args: []string{"-config", path, "-request-timeout", "0s", "-route-timeouts", "POST /v1/tasks:batchCreate=30s"},
+			env:  map[string]string{"TOKEN": "t", "DB_PASSWORD": "p"},
+			wantErr: "invalid configuration:" +
+				"\n  - REQUEST_TIMEOUT, ROUTE_TIMEOUTS: POST /v1/create_task must have a deadline to use idempotency keys",
+		},
+		{
+			name: "Неизвестный режим миграций, нулевой TTL кеша",
+			args: []string{"-config", path, "-migrations-mode", "manual", "-migrations-lock-timeout", "0s", "-schema-check", "strict",
+				"-cache-ttl", "0s"},
+			env: map[string]string{"TOKEN": "t", "DB_PASSWORD": "p"},
+			wantErr: "invalid configuration:\n  - MIGRATIONS_MODE: unknown mode \"manual\", expected auto, verify or off" +
+				"\n  - MIGRATIONS_LOCK_TIMEOUT: must be positive, got 0s" +
+				"\n  - SCHEMA_CHECK: unknown mode \"strict\", expected off, warn or fail" +
+				"\n  - CACHE_TTL: must be positive, got 0s",
+		},
+		{
+			name: "Некорректные повторы, breaker и изоляция",
+			args: []string{"-config", path, "-db-retry-attempts", "0", "-db-retry-base-delay", "2s", "-db-breaker-threshold", "-1", "-db-tx-isolation", "snapshot"},
+			env:  map[string]string{"TOKEN": "t", "DB_PASSWORD": "p"},
+			wantErr: "invalid configuration:\n  - DB_RETRY_ATTEMPTS: must be at least 1, got 0" +
+				"\n  - DB_RETRY_BASE_DELAY, DB_RETRY_MAX_DELAY: expected 0 < base <= max, got 2s and 1s" +
+				"\n  - DB_BREAKER_THRESHOLD: must not be negative, got -1" +
+				"\n  - DB_TX_ISOLATION: unknown isolation level \"snapshot\", expected read_committed, repeatable_read or serializable",
+		},
+		{
+			name: "Некорректные параметры outbox",
+			args: []string{"-config", path, "-outbox-sink", "kafka", "-outbox-batch-size", "0", "-outbox-retry-base-delay", "10m"},
+			env:  map[string]string{"TOKEN": "t", "DB_PASSWORD": "p"},
+			wantErr: "invalid configuration:\n  - OUTBOX_SINK: unknown sink \"kafka\", expected log or file" +
+				"\n  - OUTBOX_BATCH_SIZE: must be at least 1, got 0" +
+				"\n  - OUTBOX_RETRY_BASE_DELAY, OUTBOX_RETRY_MAX_DELAY: expected 0 < base <= max, got 10m0s and 5m0s",
+		},
+		{
+			name: "Некорректные параметры webhooks",
+			args: []string{"-config", path, "-webhook-max-attempts", "0", "-webhook-retry-max-delay", "1s", "-webhook-timeout", "0s"},
+			env:  map[string]string{"TOKEN": "t", "DB_PASSWORD": "p"},
+			wantErr: "invalid configuration:\n  - WEBHOOK_TIMEOUT: must be positive, got 0s" +
+				"\n  - WEBHOOK_MAX_ATTEMPTS: must be at least 1, got 0" +
+				"\n  - WEBHOOK_RETRY_BASE_DELAY, WEBHOOK_RETRY_MAX_DELAY: expected 0 < base <= max, got 10s and 1s",
+		},
+		{
+			name:    "Неизвестное хранилище",
+			args:    []string{"-config", path, "--storage=redis"},
+			env:     map[string]string{"TOKEN": "t", "DB_PASSWORD": "p"},
+			wantErr: "invalid configuration:\n  - STORAGE_BACKEND: unknown backend \"redis\", expected postgres or memory",
+		},
+		{
+			name:    "Некорректный тип значения",
+			args:    []string{"-config", path, "-db-port", "five"},
+			env:     map[string]string{"TOKEN": "t", "DB_PASSWORD": "p"},
+			wantErr: "flag -db-port: strconv.ParseInt: parsing \"five\": invalid syntax",
+		},
+		{
+			name:    "Неподдерживаемое расширение файла",
+			args:    []string{"-config", "config.json"},
+			wantErr: "unsupported config file extension \".json\", expected .yaml, .yml or .toml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TOKEN", "")
+			t.Setenv("DB_PASSWORD", "")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			_, err := load(t, tt.args...)
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	path := writeFile(t, "config.yaml", testYAML)
+	t.Setenv("TOKEN", "secret-token")
+	t.Setenv("DB_PASSWORD", "secret-password")
+
+	res, err := load(t, "-config", path)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, Print(&buf, res))
+
+	out := buf.String()
+	assert.NotContains(t, out, "secret-token")
+	assert.NotContains(t, out, "secret-password")
+	assert.Contains(t, out, "TOKEN=******")
+	assert.Contains(t, out, "DB_PASSWORD=******")
+	assert.Contains(t, out, "DB_HOST=file-host")
+	assert.Contains(t, out, "# unknown key in config file: rest.typo_key")
+}
+
+func TestDiffAndApplyReloadable(t *testing.T) {
+	old := &AppConfig{LogLevel: "info", Rest: Rest{ListenAddress: ":8080", Token: "old", RateLimit: 10}}
+	next := &AppConfig{LogLevel: "debug", Rest: Rest{ListenAddress: ":9090", Token: "new", RateLimit: 10}}
+
+	assert.Equal(t, []Change{
+		{Key: "LOG_LEVEL", Reloadable: true},
+		{Key: "PORT", Reloadable: false},
+		{Key: "TOKEN", Reloadable: true},
+	}, Diff(old, next))
+
+	ApplyReloadable(old, next)
+	assert.Equal(t, "debug", old.LogLevel)
+	assert.Equal(t, "new", old.Rest.Token)
+	assert.Equal(t, ":8080", old.Rest.ListenAddress)
+}
+
+type staticSecrets map[string]string
+
+func (s staticSecrets) Secret(_ context.Context, name string) (string, error) {
+	if v, ok := s[name]; ok {
+		return v, nil
+	}
+	return "", secrets.ErrNotFound
+}
+
+func TestLoaderSecrets(t *testing.T) {
+	path := writeFile(t, "config.yaml", testYAML)
+	tokenFile := writeFile(t, "token", "token-from-file\n")
+	secretsDir := filepath.Dir(writeFile(t, "db_password", "password-from-dir\n"))
+
+	t.Run("NAME_FILE и каталог секретов", func(t *testing.T) {
+		t.Setenv("TOKEN", "")
+		t.Setenv("DB_PASSWORD", "")
+		t.Setenv("TOKEN_FILE", tokenFile)
+		t.Setenv("SECRETS_DIR", secretsDir)
+
+		res, err := load(t, "-config", path)
+		require.NoError(t, err)
+		assert.Equal(t, "token-from-file", res.Config.Rest.Token)
+		assert.Equal(t, "password-from-dir", res.Config.PostgreSQL.Password)
+		assert.Equal(t, SourceSecret, res.Sources["TOKEN"])
+	})
+
+	t.Run("Собственный источник секретов", func(t *testing.T) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		loader := NewLoader(fs)
+		loader.SetSecretProvider(staticSecrets{"TOKEN": "vault-token", "DB_PASSWORD": "vault-password"})
+		require.NoError(t, fs.Parse([]string{"-env-file", "", "-config", path, "-token", "flag-token"}))
+
+		res, err := loader.Load()
+		require.NoError(t, err)
+		assert.Equal(t, "flag-token", res.Config.Rest.Token)
+		assert.Equal(t, "vault-password", res.Config.PostgreSQL.Password)
+	})
+}
+
+func TestPostgreSQLReplicas(t *testing.T) {
+	tests := []struct {
+		name    string
+		hosts   []string
+		want    []string
+		wantErr string
+	}{
+		{name: "Без реплик", want: []string{}},
+		{name: "Порт по умолчанию и явный", hosts: []string{"replica-1", "10.0.0.2:5433", "[::1]:5434"},
+			want: []string{"replica-1:5432", "10.0.0.2:5433", "[::1]:5434"}},
+		{name: "Некорректный порт", hosts: []string{"replica-1:abc"}, wantErr: `invalid replica "replica-1:abc", expected host or host:port`},
+		{name: "Пустой хост", hosts: []string{":5432"}, wantErr: `invalid replica ":5432", expected host or host:port`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addrs, err := PostgreSQL{Port: 5432, ReplicaHosts: tt.hosts}.Replicas()
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			got := make([]string, 0, len(addrs))
+			for _, addr := range addrs {
+				got = append(got, addr.String())
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"reflect"
+)
+
+// Change - изменённый параметр конфигурации
+type Change struct {
+	// Key - имя переменной окружения параметра
+	Key string
+	// Reloadable - параметр применяется без перезапуска
+	Reloadable bool
+}
+
+// Diff - список параметров, значения которых отличаются в old и new
+func Diff(old, new *AppConfig) []Change {
+	oldFields := configFields(reflect.ValueOf(old).Elem(), "")
+	newFields := configFields(reflect.ValueOf(new).Elem(), "")
+
+	var changes []Change
+	for i, f := range oldFields {
+		if reflect.DeepEqual(f.value.Interface(), newFields[i].value.Interface()) {
+			continue
+		}
+		changes = append(changes, Change{Key: f.env, Reloadable: f.reloadable()})
+	}
+
+	return changes
+}
+
+// ApplyReloadable - копирование в dst только тех параметров src, которые применяются без перезапуска
+func ApplyReloadable(dst, src *AppConfig) {
+	dstFields := configFields(reflect.ValueOf(dst).Elem(), "")
+	srcFields := configFields(reflect.ValueOf(src).Elem(), "")
+
+	for i, f := range dstFields {
+		if f.reloadable() {
+			f.value.Set(srcFields[i].value)
+		}
+	}
+}
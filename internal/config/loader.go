@@ -0,0 +1,429 @@
+package config
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"simple-service/internal/secrets"
+)
+
+// Загрузка конфигурации по слоям: значения по умолчанию, файл, переменные окружения, флаги.
+// Каждый следующий слой переопределяет предыдущий. Секреты (тег secret) вместо
+// переменных окружения берутся из источника секретов: NAME, NAME_FILE, каталог SECRETS_DIR
+
+// Source - слой, из которого взято значение параметра
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceEnv     Source = "env"
+	SourceSecret  Source = "secret"
+	SourceFlag    Source = "flag"
+)
+
+// Result - результат загрузки конфигурации
+type Result struct {
+	Config *AppConfig
+	// Sources - источник значения для каждого параметра (ключ - имя переменной окружения)
+	Sources map[string]Source
+	// UnknownKeys - ключи файла конфигурации, которым не соответствует ни один параметр
+	UnknownKeys []string
+	// File - путь к файлу конфигурации, если он был задан
+	File string
+}
+
+// Loader - загрузчик конфигурации, может вызываться повторно с теми же флагами
+type Loader struct {
+	fs       *flag.FlagSet
+	filePath *string
+	envFile  *string
+	flags    map[string]*string
+	secrets  secrets.Provider
+}
+
+// NewLoader - регистрирует флаги конфигурации в fs: -config, -env-file
+// и по одному флагу на каждый параметр (DB_HOST -> -db-host, если в теге flag не задано другое имя)
+func NewLoader(fs *flag.FlagSet) *Loader {
+	l := &Loader{
+		fs:       fs,
+		filePath: fs.String("config", os.Getenv("CONFIG_FILE"), "path to YAML or TOML config file"),
+		envFile:  fs.String("env-file", EnvPath, "path to env file"),
+		flags:    make(map[string]*string),
+	}
+
+	for _, f := range configFields(reflect.ValueOf(&AppConfig{}).Elem(), "") {
+		l.flags[f.env] = fs.String(f.flagName(), "", "overrides "+f.env)
+	}
+
+	return l
+}
+
+// SetSecretProvider - замена источника секретов по умолчанию (переменные окружения и SECRETS_DIR)
+func (l *Loader) SetSecretProvider(p secrets.Provider) {
+	l.secrets = p
+}
+
+// Load - загрузка конфигурации. Флаги должны быть разобраны до вызова
+func (l *Loader) Load() (*Result, error) {
+	cfg := &AppConfig{}
+	res := &Result{
+		Config:  cfg,
+		Sources: make(map[string]Source),
+		File:    *l.filePath,
+	}
+	fields := configFields(reflect.ValueOf(cfg).Elem(), "")
+
+	// Значения по умолчанию
+	for _, f := range fields {
+		if def, ok := f.tag.Lookup("default"); ok {
+			if err := f.set(def); err != nil {
+				return nil, errors.Wrapf(err, "invalid default for %s", f.env)
+			}
+			res.Sources[f.env] = SourceDefault
+		}
+	}
+
+	// Файл конфигурации
+	if res.File != "" {
+		values, err := readConfigFile(res.File)
+		if err != nil {
+			return nil, err
+		}
+		res.UnknownKeys = unknownKeys(values, fields)
+
+		for _, f := range fields {
+			raw, ok := lookupKey(values, f.key)
+			if !ok {
+				continue
+			}
+			if err := f.setAny(raw); err != nil {
+				return nil, errors.Wrapf(err, "config file %s: key %s", res.File, f.key)
+			}
+			res.Sources[f.env] = SourceFile
+		}
+	}
+
+	// Переменные окружения, env файл используется для значений, не заданных в окружении
+	envFileValues, err := readEnvFile(*l.envFile)
+	if err != nil {
+		return nil, err
+	}
+	lookupEnv := func(name string) (string, bool) {
+		if value, ok := os.LookupEnv(name); ok && value != "" {
+			return value, true
+		}
+		value, ok := envFileValues[name]
+		return value, ok
+	}
+	for _, f := range fields {
+		if f.secret() {
+			continue
+		}
+		value, ok := lookupEnv(f.env)
+		if !ok || value == "" {
+			continue
+		}
+		if err := f.set(value); err != nil {
+			return nil, errors.Wrapf(err, "env %s", f.env)
+		}
+		res.Sources[f.env] = SourceEnv
+	}
+
+	// Флаги командной строки
+	set := make(map[string]bool)
+	l.fs.Visit(func(fl *flag.Flag) { set[fl.Name] = true })
+	for _, f := range fields {
+		if !set[f.flagName()] {
+			continue
+		}
+		if err := f.set(*l.flags[f.env]); err != nil {
+			return nil, errors.Wrapf(err, "flag -%s", f.flagName())
+		}
+		res.Sources[f.env] = SourceFlag
+	}
+
+	// Секреты: источник зависит от уже загруженного SECRETS_DIR, поэтому идут последними
+	provider := l.secrets
+	if provider == nil {
+		chain := secrets.Chain{secrets.EnvProvider{Lookup: lookupEnv}}
+		if cfg.Secrets.Dir != "" {
+			chain = append(chain, secrets.FileProvider{Dir: cfg.Secrets.Dir})
+		}
+		provider = chain
+	}
+	for _, f := range fields {
+		if !f.secret() || res.Sources[f.env] == SourceFlag {
+			continue
+		}
+		value, err := provider.Secret(context.Background(), f.env)
+		if errors.Is(err, secrets.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "secret %s", f.env)
+		}
+		if err := f.set(value); err != nil {
+			return nil, errors.Wrapf(err, "secret %s", f.env)
+		}
+		res.Sources[f.env] = SourceSecret
+	}
+
+	var problems []error
+	for _, f := range fields {
+		if f.tag.Get("required") == "true" && f.value.IsZero() && !cfg.inactive(f.key) {
+			problems = append(problems, errors.Errorf("required key %s missing value", f.env))
+		}
+	}
+	if len(problems) > 0 {
+		// Семантические ошибки по незаполненным параметрам не интересны, показываем только пропуски
+		return nil, joinErrors(problems)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// ValidationError - набор ошибок конфигурации
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+func joinErrors(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	problems := make([]string, 0, len(errs))
+	for _, err := range errs {
+		problems = append(problems, err.Error())
+	}
+
+	return &ValidationError{Problems: problems}
+}
+
+// field - параметр конфигурации (лист структуры AppConfig)
+type field struct {
+	env   string
+	key   string
+	tag   reflect.StructTag
+	value reflect.Value
+}
+
+func (f field) flagName() string {
+	if name := f.tag.Get("flag"); name != "" {
+		return name
+	}
+	return strings.ToLower(strings.ReplaceAll(f.env, "_", "-"))
+}
+
+func (f field) secret() bool {
+	return f.tag.Get("secret") == "true"
+}
+
+func (f field) reloadable() bool {
+	return f.tag.Get("reload") == "true"
+}
+
+// configFields - обход структуры конфигурации, вложенные структуры становятся секциями файла
+func configFields(v reflect.Value, prefix string) []field {
+	var fields []field
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		key := prefix + sf.Tag.Get("yaml")
+
+		if sf.Type.Kind() == reflect.Struct && sf.Type != reflect.TypeOf(time.Duration(0)) {
+			fields = append(fields, configFields(v.Field(i), key+".")...)
+			continue
+		}
+
+		fields = append(fields, field{
+			env:   sf.Tag.Get("envconfig"),
+			key:   key,
+			tag:   sf.Tag,
+			value: v.Field(i),
+		})
+	}
+
+	return fields
+}
+
+// set - установка значения из строки (env, флаги, значения по умолчанию)
+func (f field) set(raw string) error {
+	switch f.value.Interface().(type) {
+	case time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(int64(d))
+		return nil
+	case []string:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		f.value.Set(reflect.ValueOf(items))
+		return nil
+	}
+
+	switch f.value.Kind() {
+	case reflect.String:
+		f.value.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		f.value.SetBool(b)
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		f.value.SetFloat(n)
+	default:
+		return errors.Errorf("unsupported config field type %s", f.value.Type())
+	}
+
+	return nil
+}
+
+// setAny - установка значения, прочитанного из YAML или TOML
+func (f field) setAny(raw any) error {
+	switch v := raw.(type) {
+	case nil:
+		return nil
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, fmt.Sprint(item))
+		}
+		return f.set(strings.Join(items, ","))
+	case map[string]any:
+		return errors.New("expected a value, got a section")
+	default:
+		return f.set(fmt.Sprint(v))
+	}
+}
+
+func readConfigFile(path string) (map[string]any, error) {
+	var unmarshal func([]byte, any) error
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		unmarshal = yaml.Unmarshal
+	case ".toml":
+		unmarshal = toml.Unmarshal
+	default:
+		return nil, errors.Errorf("unsupported config file extension %q, expected .yaml, .yml or .toml", ext)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read config file")
+	}
+
+	values := make(map[string]any)
+	if err := unmarshal(data, &values); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse config file %s", path)
+	}
+
+	return values, nil
+}
+
+func readEnvFile(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	values, err := godotenv.Read(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to read env file %s", path)
+	}
+
+	return values, nil
+}
+
+func lookupKey(values map[string]any, key string) (any, bool) {
+	parts := strings.Split(key, ".")
+	current := values
+
+	for i, part := range parts {
+		v, ok := current[part]
+		if !ok {
+			return nil, false
+		}
+		if i == len(parts)-1 {
+			return v, true
+		}
+		if current, ok = v.(map[string]any); !ok {
+			return nil, false
+		}
+	}
+
+	return nil, false
+}
+
+// unknownKeys - ключи файла, не относящиеся ни к параметру, ни к секции
+func unknownKeys(values map[string]any, fields []field) []string {
+	known := make(map[string]bool)
+	for _, f := range fields {
+		known[f.key] = true
+		parts := strings.Split(f.key, ".")
+		for i := 1; i < len(parts); i++ {
+			known[strings.Join(parts[:i], ".")+"."] = true
+		}
+	}
+
+	var unknown []string
+	var walk func(m map[string]any, prefix string)
+	walk = func(m map[string]any, prefix string) {
+		for k, v := range m {
+			key := prefix + k
+			if nested, ok := v.(map[string]any); ok && known[key+"."] {
+				walk(nested, key+".")
+				continue
+			}
+			if !known[key] {
+				unknown = append(unknown, key)
+			}
+		}
+	}
+	walk(values, "")
+	sort.Strings(unknown)
+
+	return unknown
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+)
+
+const redacted = "******"
+
+// Print - вывод итоговой конфигурации в формате KEY=value с указанием источника.
+// Секреты (тег secret) заменяются на маску
+func Print(w io.Writer, res *Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	if res.File != "" {
+		fmt.Fprintf(tw, "# config file: %s\n", res.File)
+	}
+	for _, key := range res.UnknownKeys {
+		fmt.Fprintf(tw, "# unknown key in config file: %s\n", key)
+	}
+
+	for _, f := range configFields(reflect.ValueOf(res.Config).Elem(), "") {
+		source, ok := res.Sources[f.env]
+		if !ok {
+			source = "unset"
+		}
+		fmt.Fprintf(tw, "%s=%s\t# %s\n", f.env, f.display(), source)
+	}
+
+	return tw.Flush()
+}
+
+// display - строковое представление значения для вывода
+func (f field) display() string {
+	if f.secret() {
+		if f.value.IsZero() {
+			return ""
+		}
+		return redacted
+	}
+
+	if items, ok := f.value.Interface().([]string); ok {
+		return strings.Join(items, ",")
+	}
+
+	return fmt.Sprint(f.value.Interface())
+}
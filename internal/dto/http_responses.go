@@ -1,75 +1,66 @@
 package dto
 
 import (
+	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+
+	"simple-service/pkg/apitypes"
 )
 
 // DTO  некоторых компаниях используется такой подход
 
 const (
-	FieldBadFormat     = "FIELD_BADFORMAT"
-	FieldIncorrect     = "FIELD_INCORRECT"
-	ServiceUnavailable = "SERVICE_UNAVAILABLE"
-	NotFound           = "NOT_FOUND"
-	InternalError      = "Service is currently unavailable. Please try again later."
+	FieldBadFormat       = "FIELD_BADFORMAT"
+	FieldIncorrect       = "FIELD_INCORRECT"
+	ServiceUnavailable   = "SERVICE_UNAVAILABLE"
+	NotFound             = "NOT_FOUND"
+	UnsupportedMediaType = "UNSUPPORTED_MEDIA_TYPE"
+	PayloadTooLarge      = "PAYLOAD_TOO_LARGE"
+	TooManyRequests      = "TOO_MANY_REQUESTS"
+	DeadlineExceeded     = "DEADLINE_EXCEEDED"
+	BatchAborted         = "BATCH_ABORTED"
+	Forbidden            = "FORBIDDEN"
+	LimitExceeded        = "LIMIT_EXCEEDED"
+	IdempotencyKeyInUse  = "IDEMPOTENCY_KEY_IN_USE"
+	IdempotencyKeyReused = "IDEMPOTENCY_KEY_REUSED"
+	TaskExists           = "TASK_EXISTS"
+	OutcomeUnknown       = "OUTCOME_UNKNOWN"
+	DeliveryInFlight     = "DELIVERY_IN_FLIGHT"
+	InternalError        = "Service is currently unavailable. Please try again later."
 )
 
-// Swagger DTO structures
-
-// TaskRequest represents the request body for creating a task
-// @Description Task creation request
-type TaskRequest struct {
-	Title       string `json:"title" validate:"required,min=1,max=255" example:"Implement new feature"`
-	Description string `json:"description" validate:"max=1000" example:"Develop a new API endpoint for user management"`
-} // @name TaskRequest
-
-// TaskResponse represents a task in responses
-// @Description Task information
-type TaskResponse struct {
-	ID          int       `json:"id" example:"1"`
-	Title       string    `json:"title" example:"Implement new feature"`
-	Description string    `json:"description" example:"Develop a new API endpoint for user management"`
-	Status      string    `json:"status" example:"new"`
-	CreatedAt   time.Time `json:"created_at" example:"2024-01-15T10:30:00Z"`
-	UpdatedAt   time.Time `json:"updated_at" example:"2024-01-15T10:30:00Z"`
-} // @name TaskResponse
-
-// CreateTaskResponse represents the response after creating a task
-// @Description Response after task creation
-type CreateTaskResponse struct {
-	TaskID int `json:"task_id" example:"1"`
-} // @name CreateTaskResponse
-
-// Response represents the standard API response
-// @Description Standard API response
-type Response struct {
-	Status string `json:"status" example:"success"`
-	Error  *Error `json:"error,omitempty"`
-	Data   any    `json:"data,omitempty"`
-} // @name Response
-
-// SuccessResponse represents a successful API response
-// @Description Successful API response
-type SuccessResponse struct {
-	Status string `json:"status" example:"success"`
-	Data   any    `json:"data"`
-} // @name SuccessResponse
-
-// ErrorResponse represents an error API response
-// @Description Error API response
-type ErrorResponse struct {
-	Status string `json:"status" example:"error"`
-	Error  *Error `json:"error"`
-} // @name ErrorResponse
-
-// Error represents error details
-// @Description Error details
-type Error struct {
-	Code string `json:"code" example:"FIELD_INCORRECT"`
-	Desc string `json:"desc" example:"Invalid request body"`
-} // @name Error
+// Тела запросов и ответов API, общие с клиентом pkg/client. Сами структуры в pkg/apitypes без зависимости
+// от fiber; @name сохраняет прежние имена схем swagger
+type (
+	TaskRequest           = apitypes.TaskRequest           // @name TaskRequest
+	TaskResponse          = apitypes.TaskResponse          // @name TaskResponse
+	UpdateTaskRequest     = apitypes.UpdateTaskRequest     // @name UpdateTaskRequest
+	TaskListResponse      = apitypes.TaskListResponse      // @name TaskListResponse
+	ReadinessResponse     = apitypes.ReadinessResponse     // @name ReadinessResponse
+	CreateTaskResponse    = apitypes.CreateTaskResponse    // @name CreateTaskResponse
+	BatchCreateRequest    = apitypes.BatchCreateRequest    // @name BatchCreateRequest
+	BatchItemResult       = apitypes.BatchItemResult       // @name BatchItemResult
+	BatchCreateResponse   = apitypes.BatchCreateResponse   // @name BatchCreateResponse
+	TaskFilter            = apitypes.TaskFilter            // @name TaskFilter
+	BulkUpdateRequest     = apitypes.BulkUpdateRequest     // @name BulkUpdateRequest
+	BulkDeleteRequest     = apitypes.BulkDeleteRequest     // @name BulkDeleteRequest
+	BulkResponse          = apitypes.BulkResponse          // @name BulkResponse
+	ImportRowError        = apitypes.ImportRowError        // @name ImportRowError
+	ImportResponse        = apitypes.ImportResponse        // @name ImportResponse
+	WebhookRequest        = apitypes.WebhookRequest        // @name WebhookRequest
+	UpdateWebhookRequest  = apitypes.UpdateWebhookRequest  // @name UpdateWebhookRequest
+	WebhookResponse       = apitypes.WebhookResponse       // @name WebhookResponse
+	CreateWebhookResponse = apitypes.CreateWebhookResponse // @name CreateWebhookResponse
+	WebhookListResponse   = apitypes.WebhookListResponse   // @name WebhookListResponse
+	DeliveryResponse      = apitypes.DeliveryResponse      // @name DeliveryResponse
+	DeliveryListResponse  = apitypes.DeliveryListResponse  // @name DeliveryListResponse
+	Response              = apitypes.Response              // @name Response
+	SuccessResponse       = apitypes.SuccessResponse       // @name SuccessResponse
+	ErrorResponse         = apitypes.ErrorResponse         // @name ErrorResponse
+	Error                 = apitypes.Error                 // @name Error
+)
 
 func BadResponseError(ctx *fiber.Ctx, code, desc string) error {
 	return ctx.Status(fiber.StatusBadRequest).JSON(Response{
@@ -100,3 +91,168 @@ func NotFoundError(ctx *fiber.Ctx, desc string) error {
 		},
 	})
 }
+
+func UnsupportedMediaTypeError(ctx *fiber.Ctx, desc string) error {
+	return ctx.Status(fiber.StatusUnsupportedMediaType).JSON(Response{
+		Status: "error",
+		Error: &Error{
+			Code: UnsupportedMediaType,
+			Desc: desc,
+		},
+	})
+}
+
+func PayloadTooLargeError(ctx *fiber.Ctx, desc string) error {
+	return ctx.Status(fiber.StatusRequestEntityTooLarge).JSON(Response{
+		Status: "error",
+		Error: &Error{
+			Code: PayloadTooLarge,
+			Desc: desc,
+		},
+	})
+}
+
+func TooManyRequestsError(ctx *fiber.Ctx, desc string) error {
+	return ctx.Status(fiber.StatusTooManyRequests).JSON(Response{
+		Status: "error",
+		Error: &Error{
+			Code: TooManyRequests,
+			Desc: desc,
+		},
+	})
+}
+
+// ServiceUnavailableError - 503 с Retry-After
+func ServiceUnavailableError(ctx *fiber.Ctx, retryAfter time.Duration, desc string) error {
+	setRetryAfter(ctx, retryAfter)
+	return ctx.Status(fiber.StatusServiceUnavailable).JSON(Response{
+		Status: "error",
+		Error: &Error{
+			Code: ServiceUnavailable,
+			Desc: desc,
+		},
+	})
+}
+
+// GatewayTimeoutError - 504, бюджет времени запроса исчерпан
+func GatewayTimeoutError(ctx *fiber.Ctx, desc string) error {
+	return ctx.Status(fiber.StatusGatewayTimeout).JSON(Response{
+		Status: "error",
+		Error: &Error{
+			Code: DeadlineExceeded,
+			Desc: desc,
+		},
+	})
+}
+
+// OutcomeUnknownError - 500, соединение с хранилищем потеряно после записи: запрос мог быть выполнен
+func OutcomeUnknownError(ctx *fiber.Ctx, desc string) error {
+	return ctx.Status(fiber.StatusInternalServerError).JSON(Response{
+		Status: "error",
+		Error: &Error{
+			Code: OutcomeUnknown,
+			Desc: desc,
+		},
+	})
+}
+
+// NotReadyError - 503 для readiness с состоянием хранилища в data
+func NotReadyError(ctx *fiber.Ctx, retryAfter time.Duration, readiness ReadinessResponse) error {
+	setRetryAfter(ctx, retryAfter)
+	return ctx.Status(fiber.StatusServiceUnavailable).JSON(Response{
+		Status: "error",
+		Error: &Error{
+			Code: ServiceUnavailable,
+			Desc: "Storage is not ready",
+		},
+		Data: readiness,
+	})
+}
+
+// ForbiddenError - 403, у токена нет нужного scope
+func ForbiddenError(ctx *fiber.Ctx, desc string) error {
+	return ctx.Status(fiber.StatusForbidden).JSON(Response{
+		Status: "error",
+		Error: &Error{
+			Code: Forbidden,
+			Desc: desc,
+		},
+	})
+}
+
+// LimitExceededError - 422, массовая операция затрагивает больше задач, чем разрешено
+func LimitExceededError(ctx *fiber.Ctx, desc string) error {
+	return ctx.Status(fiber.StatusUnprocessableEntity).JSON(Response{
+		Status: "error",
+		Error: &Error{
+			Code: LimitExceeded,
+			Desc: desc,
+		},
+	})
+}
+
+// IdempotencyInUseError - 409, запрос с тем же ключом идемпотентности ещё выполняется
+func IdempotencyInUseError(ctx *fiber.Ctx, desc string) error {
+	return ctx.Status(fiber.StatusConflict).JSON(Response{
+		Status: "error",
+		Error: &Error{
+			Code: IdempotencyKeyInUse,
+			Desc: desc,
+		},
+	})
+}
+
+// DeliveryInFlightError - 409, доставка подписчику отправляется или ждёт повтора
+func DeliveryInFlightError(ctx *fiber.Ctx, desc string) error {
+	return ctx.Status(fiber.StatusConflict).JSON(Response{
+		Status: "error",
+		Error: &Error{
+			Code: DeliveryInFlight,
+			Desc: desc,
+		},
+	})
+}
+
+// IdempotencyReusedError - 422, ключ идемпотентности уже использован с другим запросом
+func IdempotencyReusedError(ctx *fiber.Ctx, desc string) error {
+	return ctx.Status(fiber.StatusUnprocessableEntity).JSON(Response{
+		Status: "error",
+		Error: &Error{
+			Code: IdempotencyKeyReused,
+			Desc: desc,
+		},
+	})
+}
+
+// BatchRejectedError - 400 для пакета, отклонённого целиком, с результатами по задачам в data
+func BatchRejectedError(ctx *fiber.Ctx, desc string, results BatchCreateResponse) error {
+	return ctx.Status(fiber.StatusBadRequest).JSON(Response{
+		Status: "error",
+		Error: &Error{
+			Code: FieldIncorrect,
+			Desc: desc,
+		},
+		Data: results,
+	})
+}
+
+// ImportRejectedError - загрузка отклонена целиком, в data отчёт по строкам файла
+func ImportRejectedError(ctx *fiber.Ctx, status int, code, desc string, report ImportResponse) error {
+	return ctx.Status(status).JSON(Response{
+		Status: "error",
+		Error: &Error{
+			Code: code,
+			Desc: desc,
+		},
+		Data: report,
+	})
+}
+
+// setRetryAfter - Retry-After в секундах, округлённый вверх, не меньше секунды
+func setRetryAfter(ctx *fiber.Ctx, retryAfter time.Duration) {
+	seconds := int((retryAfter + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+}
@@ -16,6 +16,11 @@ func NewLogger(level string) (*zap.SugaredLogger, error) {
 		return nil, errors.Wrapf(err, "error ParseAtomicLevel %s", level)
 	}
 
+	return NewLoggerWithLevel(logLevel)
+}
+
+// NewLoggerWithLevel - логгер с уровнем, который можно менять во время работы через logLevel.SetLevel
+func NewLoggerWithLevel(logLevel zap.AtomicLevel) (*zap.SugaredLogger, error) {
 	logger, err := zap.Config{
 		Level:       logLevel,
 		Encoding:    "json",
@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Метрики сервиса в формате Prometheus
+
+const namespace = "simple_service"
+
+var (
+	// ConfigReloads - число перезагрузок конфигурации по результату (success, failure)
+	ConfigReloads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "config_reloads_total",
+		Help:      "Number of configuration reload attempts by result.",
+	}, []string{"result"})
+
+	// DBBreakerState - состояние circuit breaker базы данных: 0 - closed, 1 - half_open, 2 - open
+	DBBreakerState = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "db_breaker_state",
+		Help:      "Database circuit breaker state: 0 - closed, 1 - half open, 2 - open.",
+	})
+
+	// DBBreakerTransitions - переходы circuit breaker по новому состоянию
+	DBBreakerTransitions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_breaker_transitions_total",
+		Help:      "Number of database circuit breaker state changes by new state.",
+	}, []string{"state"})
+
+	// DBBreakerRejected - запросы, отклонённые открытым circuit breaker без обращения к БД
+	DBBreakerRejected = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_breaker_rejected_total",
+		Help:      "Number of database operations rejected by the open circuit breaker.",
+	})
+
+	// DBRetries - повторы запросов к БД по операции и причине (transient, unavailable)
+	DBRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_retries_total",
+		Help:      "Number of retried database operations by operation and error class.",
+	}, []string{"operation", "reason"})
+
+	// DBReads - чтения по месту выполнения (primary, replica)
+	DBReads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_reads_total",
+		Help:      "Number of read-only repository operations by target: primary or replica.",
+	}, []string{"target"})
+
+	// DBReplicaLag - отставание реплики по последней проверке
+	DBReplicaLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "db_replica_lag_seconds",
+		Help:      "Replication lag of a read replica measured by the last check.",
+	}, []string{"replica"})
+
+	// DBReplicaHealthy - 1, если реплика участвует в чтении, 0 - исключена
+	DBReplicaHealthy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "db_replica_healthy",
+		Help:      "Whether a read replica is in rotation: 1 - yes, 0 - ejected as unreachable or lagging.",
+	}, []string{"replica"})
+
+	// TaskCacheLookups - обращения к кешу задач по результату (hit, negative_hit, miss)
+	TaskCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "task_cache_lookups_total",
+		Help:      "Number of task cache lookups by result: hit, negative hit (cached not found) or miss.",
+	}, []string{"result"})
+
+	// TaskCacheEntries - число записей в кеше задач
+	TaskCacheEntries = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "task_cache_entries",
+		Help:      "Number of entries in the task cache.",
+	})
+
+	// TaskCacheEvictions - записи, вытесненные из кеша задач при переполнении
+	TaskCacheEvictions = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "task_cache_evictions_total",
+		Help:      "Number of task cache entries evicted to stay within the size limit.",
+	})
+
+	// TaskCacheInvalidations - инвалидации кеша задач по источнику (local, notify, reset)
+	TaskCacheInvalidations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "task_cache_invalidations_total",
+		Help:      "Number of task cache invalidations by source: local write, notification from another instance or full reset.",
+	}, []string{"source"})
+
+	// IdempotencyRequests - запросы с Idempotency-Key по результату (new, replayed, in_flight, mismatch)
+	IdempotencyRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "idempotency_requests_total",
+		Help:      "Number of requests with Idempotency-Key by result: executed, replayed, rejected as in flight or as reused with another body.",
+	}, []string{"result"})
+
+	// OutboxDeliveries - попытки доставки событий outbox по типу события и результату (published, failed)
+	OutboxDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "outbox_deliveries_total",
+		Help:      "Number of outbox event delivery attempts by event type and result: published or failed.",
+	}, []string{"type", "result"})
+
+	// OutboxDeliveryLag - время от записи события до его доставки
+	OutboxDeliveryLag = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "outbox_delivery_lag_seconds",
+		Help:      "Time from writing an outbox event to its successful delivery.",
+		Buckets:   []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 300, 900, 3600},
+	})
+
+	// WebhookDeliveries - попытки доставки подписчикам по результату (delivered, failed, dead)
+	WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Number of webhook delivery attempts by result: delivered, failed and scheduled for retry, or moved to dead letter.",
+	}, []string{"result"})
+
+	// WebhookRequestDuration - длительность запроса к подписчику
+	WebhookRequestDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "webhook_request_duration_seconds",
+		Help:      "Duration of HTTP requests to webhook subscribers.",
+		Buckets:   prometheus.DefBuckets,
+	})
+)
+
+// Handler - обработчик для отдачи метрик
+func Handler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.Handler())
+}
@@ -0,0 +1,59 @@
+package migrations
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var nameSeparators = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Create - заготовки up и down файлов следующей по номеру миграции в каталоге dir.
+// Имя приводится к виду snake_case, возвращаются пути созданных файлов
+func Create(dir, name string) (string, string, error) {
+	name = strings.Trim(nameSeparators.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return "", "", fmt.Errorf("migration name must contain letters or digits")
+	}
+
+	existing, err := Load(os.DirFS(dir))
+	if err != nil {
+		return "", "", err
+	}
+
+	version := 1
+	if len(existing) > 0 {
+		version = existing[len(existing)-1].Version + 1
+	}
+
+	base := fmt.Sprintf("%06d_%s", version, name)
+	up := filepath.Join(dir, base+".up.sql")
+	down := filepath.Join(dir, base+".down.sql")
+
+	if err := writeNewFile(up, fmt.Sprintf("-- Миграция %d: %s\n", version, name)); err != nil {
+		return "", "", err
+	}
+	if err := writeNewFile(down, fmt.Sprintf("-- Откат миграции %d: %s\n", version, name)); err != nil {
+		_ = os.Remove(up)
+		return "", "", err
+	}
+
+	return up, down, nil
+}
+
+// writeNewFile - запись файла, который ещё не существует
+func writeNewFile(path, content string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create migration file: %w", err)
+	}
+
+	if _, err := f.WriteString(content); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write migration file: %w", err)
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,71 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// lockKey - ключ advisory lock, под которым применяются миграции.
+// Одинаковый у всех реплик, чтобы миграции применяла только одна из них
+const lockKey int64 = 0x73696d706c65 // "simple"
+
+// lockPollInterval - как часто пытаться взять блокировку, пока её держит другая реплика
+const lockPollInterval = 500 * time.Millisecond
+
+// withLock - выполнение fn под advisory lock. Блокировка сессионная, поэтому держится
+// на отдельном соединении и снимается сама, если процесс упадёт во время миграций
+func (m *Migrator) withLock(ctx context.Context, fn func() error) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection for migration lock: %w", err)
+	}
+	defer conn.Release()
+
+	if err := m.lock(ctx, conn); err != nil {
+		return err
+	}
+
+	defer func() {
+		// Снимаем блокировку, даже если ctx уже отменён
+		if _, err := conn.Exec(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", lockKey); err != nil {
+			m.log.Errorw("Failed to release migration lock", "error", err)
+		}
+	}()
+
+	return fn()
+}
+
+// lock - ожидание блокировки не дольше lockTimeout
+func (m *Migrator) lock(ctx context.Context, conn *pgxpool.Conn) error {
+	ctx, cancel := context.WithTimeout(ctx, m.lockTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(lockPollInterval)
+	defer ticker.Stop()
+
+	for waiting := false; ; waiting = true {
+		var locked bool
+		if err := conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", lockKey).Scan(&locked); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("timed out after %s waiting for migration lock", m.lockTimeout)
+			}
+			return fmt.Errorf("failed to take migration lock: %w", err)
+		}
+		if locked {
+			return nil
+		}
+
+		if !waiting {
+			m.log.Infow("Migrations are being applied by another instance, waiting", "timeout", m.lockTimeout)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out after %s waiting for migration lock", m.lockTimeout)
+		case <-ticker.C:
+		}
+	}
+}
@@ -2,82 +2,421 @@ package migrations
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"slices"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
+
+	"simple-service/internal/config"
 )
 
-type Migration struct {
-	Version     int
-	Description string
-	Query       string
-}
-
-var migrations = []Migration{
-	{
-		Version:     1,
-		Description: "Create tasks table",
-		Query: `
-			CREATE TABLE IF NOT EXISTS tasks (
-				id SERIAL PRIMARY KEY,
-				title TEXT NOT NULL,
-				description TEXT,
-				status TEXT CHECK (status IN ('new', 'in_progress', 'done')) DEFAULT 'new',
-				created_at TIMESTAMP DEFAULT now(),
-				updated_at TIMESTAMP DEFAULT now()
-			)`,
-	},
-	{
-		Version:     2,
-		Description: "Create schema_migrations table",
-		Query: `
-			CREATE TABLE IF NOT EXISTS schema_migrations (
-				version INTEGER PRIMARY KEY,
-				applied_at TIMESTAMP DEFAULT now()
-			)`,
-	},
-}
-
-func RunMigrations(ctx context.Context, pool *pgxpool.Pool, logger *zap.SugaredLogger) error {
-	// Создаем таблицу миграций если её нет
-	_, err := pool.Exec(ctx, migrations[1].Query)
+// Таблица применённых миграций. Колонки name и checksum добавлены позже,
+// поэтому создаются отдельно для баз, где таблица уже существует
+const createMigrationsTableQuery = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at TIMESTAMP DEFAULT now()
+	);
+	ALTER TABLE schema_migrations
+		ADD COLUMN IF NOT EXISTS name TEXT NOT NULL DEFAULT '',
+		ADD COLUMN IF NOT EXISTS checksum TEXT NOT NULL DEFAULT '';`
+
+const (
+	tableExistsQuery    = `SELECT to_regclass('schema_migrations') IS NOT NULL`
+	selectAppliedQuery  = `SELECT version, name, checksum, applied_at FROM schema_migrations ORDER BY version`
+	insertAppliedQuery  = `INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`
+	deleteAppliedQuery  = `DELETE FROM schema_migrations WHERE version = $1`
+	updateChecksumQuery = `UPDATE schema_migrations SET name = $2, checksum = $3 WHERE version = $1`
+)
+
+// defaultLockTimeout - ожидание блокировки миграций по умолчанию
+const defaultLockTimeout = time.Minute
+
+// Migrator - применение миграций из файлов к базе данных
+type Migrator struct {
+	pool        *pgxpool.Pool
+	migrations  []Migration
+	lockTimeout time.Duration
+	log         *zap.SugaredLogger
+}
+
+// NewMigrator - конструктор, миграции читаются из fsys (обычно FS)
+func NewMigrator(pool *pgxpool.Pool, fsys fs.FS, logger *zap.SugaredLogger) (*Migrator, error) {
+	migrations, err := Load(fsys)
 	if err != nil {
-		return fmt.Errorf("failed to create migrations table: %w", err)
+		return nil, err
 	}
 
-	for _, migration := range migrations {
-		if migration.Version == 2 {
-			continue // Пропускаем создание таблицы миграций
+	return &Migrator{
+		pool:        pool,
+		migrations:  migrations,
+		lockTimeout: defaultLockTimeout,
+		log:         logger,
+	}, nil
+}
+
+// SetLockTimeout - сколько ждать блокировку, пока миграции применяет другой процесс
+func (m *Migrator) SetLockTimeout(timeout time.Duration) {
+	m.lockTimeout = timeout
+}
+
+// RunMigrations - встроенные миграции при запуске сервера в зависимости от режима:
+// auto - применить, verify - только проверить, что схема актуальна, off - ничего не делать
+func RunMigrations(ctx context.Context, pool *pgxpool.Pool, cfg config.Migrations, logger *zap.SugaredLogger) error {
+	if cfg.Mode == config.MigrationsOff {
+		logger.Info("Migrations are disabled")
+		return nil
+	}
+
+	m, err := NewMigrator(pool, FS, logger)
+	if err != nil {
+		return err
+	}
+	m.SetLockTimeout(cfg.LockTimeout)
+
+	if cfg.Mode == config.MigrationsVerify {
+		return m.Verify(ctx)
+	}
+
+	return m.Up(ctx, 0)
+}
+
+// Up - применение неприменённых миграций по порядку, не больше n (n <= 0 - все)
+func (m *Migrator) Up(ctx context.Context, n int) error {
+	return m.locked(ctx, func(applied map[int]appliedMigration) error {
+		todo := pending(m.migrations, applied)
+		if n > 0 && n < len(todo) {
+			todo = todo[:n]
 		}
 
-		// Проверяем, применена ли миграция
-		var count int
-		err := pool.QueryRow(ctx, "SELECT COUNT(*) FROM schema_migrations WHERE version = $1", migration.Version).Scan(&count)
-		if err != nil {
-			return fmt.Errorf("failed to check migration status: %w", err)
+		return m.applyAll(ctx, todo)
+	})
+}
+
+// Down - откат n последних применённых миграций
+func (m *Migrator) Down(ctx context.Context, n int) error {
+	if n < 1 {
+		return fmt.Errorf("number of migrations to roll back must be at least 1, got %d", n)
+	}
+
+	return m.locked(ctx, func(applied map[int]appliedMigration) error {
+		versions := appliedVersions(applied)
+		if n > len(versions) {
+			n = len(versions)
 		}
 
-		if count > 0 {
-			logger.Infof("Migration %d already applied, skipping", migration.Version)
-			continue
+		return m.revertAll(ctx, versions[len(versions)-n:])
+	})
+}
+
+// Redo - откат и повторное применение последней применённой миграции
+func (m *Migrator) Redo(ctx context.Context) error {
+	return m.locked(ctx, func(applied map[int]appliedMigration) error {
+		versions := appliedVersions(applied)
+		if len(versions) == 0 {
+			return errors.New("no applied migrations to redo")
 		}
 
-		// Применяем миграцию
-		logger.Infof("Applying migration %d: %s", migration.Version, migration.Description)
-		_, err = pool.Exec(ctx, migration.Query)
-		if err != nil {
-			return fmt.Errorf("failed to apply migration %d: %w", migration.Version, err)
+		last := versions[len(versions)-1]
+		if err := m.revertAll(ctx, []int{last}); err != nil {
+			return err
+		}
+
+		migration, _ := m.find(last)
+		return m.apply(ctx, migration)
+	})
+}
+
+// Goto - приведение схемы к версии: применение миграций до неё включительно
+// или откат всех более новых. Версия 0 откатывает все миграции
+func (m *Migrator) Goto(ctx context.Context, version int) error {
+	if _, ok := m.find(version); !ok && version != 0 {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+
+	return m.locked(ctx, func(applied map[int]appliedMigration) error {
+		var newer []int
+		for _, v := range appliedVersions(applied) {
+			if v > version {
+				newer = append(newer, v)
+			}
+		}
+		if err := m.revertAll(ctx, newer); err != nil {
+			return err
+		}
+
+		var todo []Migration
+		for _, migration := range pending(m.migrations, applied) {
+			if migration.Version <= version {
+				todo = append(todo, migration)
+			}
+		}
+
+		return m.applyAll(ctx, todo)
+	})
+}
+
+// locked - выполнение fn под advisory lock с актуальным списком применённых миграций.
+// Состояние читается под блокировкой: другой процесс мог только что изменить схему
+func (m *Migrator) locked(ctx context.Context, fn func(applied map[int]appliedMigration) error) error {
+	return m.withLock(ctx, func() error {
+		if err := m.ensureTable(ctx); err != nil {
+			return err
 		}
 
-		// Записываем в таблицу миграций
-		_, err = pool.Exec(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", migration.Version)
+		applied, err := m.applied(ctx)
 		if err != nil {
-			return fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+			return err
 		}
 
-		logger.Infof("Migration %d applied successfully", migration.Version)
+		if err := m.verifyChecksums(ctx, applied); err != nil {
+			return err
+		}
+
+		return fn(applied)
+	})
+}
+
+// Verify - проверка без изменения схемы: все миграции применены и не изменены после применения
+func (m *Migrator) Verify(ctx context.Context) error {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := checkChecksums(m.migrations, applied); err != nil {
+		return err
+	}
+
+	if p := pending(m.migrations, applied); len(p) > 0 {
+		versions := make([]int, 0, len(p))
+		for _, migration := range p {
+			versions = append(versions, migration.Version)
+		}
+		return fmt.Errorf("database schema is behind: %d pending migrations %v, apply them before starting the service", len(p), versions)
 	}
 
+	m.log.Info("Database schema is up to date")
+
 	return nil
 }
+
+func (m *Migrator) applyAll(ctx context.Context, migrations []Migration) error {
+	if len(migrations) == 0 {
+		m.log.Info("No migrations to apply")
+		return nil
+	}
+
+	for _, migration := range migrations {
+		if err := m.apply(ctx, migration); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// apply - DDL миграции и запись в schema_migrations в одной транзакции
+func (m *Migrator) apply(ctx context.Context, migration Migration) error {
+	m.log.Infof("Applying migration %d: %s", migration.Version, migration.Name)
+
+	err := pgx.BeginFunc(ctx, m.pool, func(tx pgx.Tx) error {
+		// Файл может содержать несколько выражений, это поддерживает только простой протокол
+		if _, err := tx.Exec(ctx, migration.Up, pgx.QueryExecModeSimpleProtocol); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(ctx, insertAppliedQuery, migration.Version, migration.Name, migration.Checksum)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply migration %d: %w", migration.Version, err)
+	}
+
+	m.log.Infof("Migration %d applied successfully", migration.Version)
+
+	return nil
+}
+
+// revertAll - откат миграций, начиная с самой новой. Перед откатом проверяется,
+// что у всех есть down файл, чтобы не остановиться на середине
+func (m *Migrator) revertAll(ctx context.Context, versions []int) error {
+	if len(versions) == 0 {
+		m.log.Info("No migrations to roll back")
+		return nil
+	}
+
+	migrations := make([]Migration, 0, len(versions))
+	for i := len(versions) - 1; i >= 0; i-- {
+		migration, ok := m.find(versions[i])
+		if !ok {
+			return fmt.Errorf("cannot roll back migration %d: its files are missing", versions[i])
+		}
+		if migration.Down == "" {
+			return fmt.Errorf("cannot roll back migration %d (%s): it has no down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, migration)
+	}
+
+	for _, migration := range migrations {
+		if err := m.revert(ctx, migration); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// revert - откат миграции и удаление записи из schema_migrations в одной транзакции
+func (m *Migrator) revert(ctx context.Context, migration Migration) error {
+	m.log.Infof("Rolling back migration %d: %s", migration.Version, migration.Name)
+
+	err := pgx.BeginFunc(ctx, m.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, migration.Down, pgx.QueryExecModeSimpleProtocol); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(ctx, deleteAppliedQuery, migration.Version)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to roll back migration %d: %w", migration.Version, err)
+	}
+
+	m.log.Infof("Migration %d rolled back successfully", migration.Version)
+
+	return nil
+}
+
+// find - миграция из файлов по версии
+func (m *Migrator) find(version int) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+// appliedMigration - запись из schema_migrations
+type appliedMigration struct {
+	Version   int
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	if _, err := m.pool.Exec(ctx, createMigrationsTableQuery, pgx.QueryExecModeSimpleProtocol); err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+	return nil
+}
+
+// applied - применённые миграции, пустой результат, если таблицы ещё нет
+func (m *Migrator) applied(ctx context.Context) (map[int]appliedMigration, error) {
+	applied := make(map[int]appliedMigration)
+
+	var exists bool
+	if err := m.pool.QueryRow(ctx, tableExistsQuery).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to check migration status: %w", err)
+	}
+	if !exists {
+		return applied, nil
+	}
+
+	rows, err := m.pool.Query(ctx, selectAppliedQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check migration status: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a appliedMigration
+		if err := rows.Scan(&a.Version, &a.Name, &a.Checksum, &a.AppliedAt); err != nil {
+			return nil, fmt.Errorf("failed to read migration status: %w", err)
+		}
+		applied[a.Version] = a
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read migration status: %w", err)
+	}
+
+	return applied, nil
+}
+
+// verifyChecksums - применённые миграции не должны меняться. Записи без контрольной суммы
+// (применены до её появления) получают сумму текущего файла
+func (m *Migrator) verifyChecksums(ctx context.Context, applied map[int]appliedMigration) error {
+	unrecorded, err := checkChecksums(m.migrations, applied)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range unrecorded {
+		m.log.Infof("Recording checksum for previously applied migration %d", migration.Version)
+		if _, err := m.pool.Exec(ctx, updateChecksumQuery, migration.Version, migration.Name, migration.Checksum); err != nil {
+			return fmt.Errorf("failed to record checksum for migration %d: %w", migration.Version, err)
+		}
+	}
+
+	for version := range applied {
+		if _, ok := m.find(version); !ok {
+			m.log.Warnf("Migration %d is applied in database but missing in files", version)
+		}
+	}
+
+	return nil
+}
+
+// checkChecksums - сравнение контрольных сумм применённых миграций с файлами.
+// Возвращает применённые миграции, для которых сумма ещё не записана
+func checkChecksums(migrations []Migration, applied map[int]appliedMigration) ([]Migration, error) {
+	var unrecorded []Migration
+
+	for _, migration := range migrations {
+		a, ok := applied[migration.Version]
+		if !ok {
+			continue
+		}
+
+		if a.Checksum == "" {
+			unrecorded = append(unrecorded, migration)
+			continue
+		}
+
+		if a.Checksum != migration.Checksum {
+			return nil, fmt.Errorf("migration %d (%s) was modified after it was applied: checksum %s, file %s",
+				migration.Version, migration.Name, a.Checksum, migration.Checksum)
+		}
+	}
+
+	return unrecorded, nil
+}
+
+// pending - неприменённые миграции в порядке версий
+func pending(migrations []Migration, applied map[int]appliedMigration) []Migration {
+	var result []Migration
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			result = append(result, migration)
+		}
+	}
+	return result
+}
+
+// appliedVersions - версии применённых миграций по возрастанию
+func appliedVersions(applied map[int]appliedMigration) []int {
+	versions := make([]int, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	slices.Sort(versions)
+	return versions
+}
@@ -0,0 +1,126 @@
+package migrations
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPendingAndChecksums(t *testing.T) {
+	all := []Migration{
+		{Version: 1, Name: "first", Checksum: "aaa"},
+		{Version: 2, Name: "second", Checksum: "bbb"},
+		{Version: 3, Name: "third", Checksum: "ccc"},
+	}
+
+	tests := []struct {
+		name           string
+		applied        map[int]appliedMigration
+		wantPending    []int
+		wantUnrecorded []int
+		wantErr        string
+	}{
+		{
+			name:        "Пустая база",
+			applied:     map[int]appliedMigration{},
+			wantPending: []int{1, 2, 3},
+		},
+		{
+			name: "Схема актуальна",
+			applied: map[int]appliedMigration{
+				1: {Version: 1, Checksum: "aaa"},
+				2: {Version: 2, Checksum: "bbb"},
+				3: {Version: 3, Checksum: "ccc"},
+			},
+		},
+		{
+			name: "Миграция применена до появления контрольных сумм",
+			applied: map[int]appliedMigration{
+				1: {Version: 1},
+				2: {Version: 2, Checksum: "bbb"},
+			},
+			wantPending:    []int{3},
+			wantUnrecorded: []int{1},
+		},
+		{
+			name: "Файл изменён после применения",
+			applied: map[int]appliedMigration{
+				1: {Version: 1, Checksum: "aaa"},
+				2: {Version: 2, Checksum: "changed"},
+			},
+			wantPending: []int{3},
+			wantErr:     "migration 2 (second) was modified after it was applied: checksum changed, file bbb",
+		},
+	}
+
+	versions := func(migrations []Migration) []int {
+		var result []int
+		for _, m := range migrations {
+			result = append(result, m.Version)
+		}
+		return result
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantPending, versions(pending(all, tt.applied)))
+
+			unrecorded, err := checkChecksums(all, tt.applied)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantUnrecorded, versions(unrecorded))
+		})
+	}
+}
+
+func TestStatuses(t *testing.T) {
+	appliedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	all := []Migration{
+		{Version: 1, Name: "first", Checksum: "aaa"},
+		{Version: 2, Name: "second", Checksum: "bbb"},
+		{Version: 4, Name: "fourth", Checksum: "ddd"},
+		{Version: 5, Name: "fifth", Checksum: "eee"},
+	}
+	applied := map[int]appliedMigration{
+		1: {Version: 1, Name: "first", Checksum: "aaa", AppliedAt: appliedAt},
+		2: {Version: 2, Name: "second", Checksum: "changed", AppliedAt: appliedAt},
+		3: {Version: 3, Name: "removed", Checksum: "ccc", AppliedAt: appliedAt},
+		4: {Version: 4, Name: "fourth", AppliedAt: appliedAt},
+	}
+
+	assert.Equal(t, []Status{
+		{Version: 1, Name: "first", Applied: true, AppliedAt: appliedAt, Checksum: ChecksumOK},
+		{Version: 2, Name: "second", Applied: true, AppliedAt: appliedAt, Checksum: ChecksumModified},
+		{Version: 3, Name: "removed", Applied: true, AppliedAt: appliedAt, Checksum: ChecksumNoFile},
+		{Version: 4, Name: "fourth", Applied: true, AppliedAt: appliedAt, Checksum: ChecksumUnrecorded},
+		{Version: 5, Name: "fifth"},
+	}, statuses(all, applied))
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+
+	up, down, err := Create(dir, "Add tasks-index")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "000001_add_tasks_index.up.sql"), up)
+	assert.Equal(t, filepath.Join(dir, "000001_add_tasks_index.down.sql"), down)
+
+	up, _, err = Create(dir, "second")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "000002_second.up.sql"), up)
+
+	// Созданные заготовки читаются как обычные миграции
+	loaded, err := Load(os.DirFS(dir))
+	require.NoError(t, err)
+	assert.Len(t, loaded, 2)
+
+	_, _, err = Create(dir, "--")
+	assert.EqualError(t, err, "migration name must contain letters or digits")
+}
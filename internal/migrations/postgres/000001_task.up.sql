@@ -1,4 +1,4 @@
-CREATE TABLE tasks (
+CREATE TABLE IF NOT EXISTS tasks (
     id SERIAL PRIMARY KEY,             -- Уникальный идентификатор задачи
     title TEXT NOT NULL,               -- Заголовок задачи
     description TEXT,                  -- Описание задачи (необязательное поле)
@@ -0,0 +1,13 @@
+-- Уведомление реплик об изменении задачи для инвалидации кеша.
+-- NOTIFY доставляется только при COMMIT, откаченные изменения не рассылаются
+CREATE OR REPLACE FUNCTION notify_task_changed() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('tasks_changed', COALESCE(NEW.id, OLD.id)::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS tasks_notify_changed ON tasks;
+CREATE TRIGGER tasks_notify_changed
+    AFTER INSERT OR UPDATE OR DELETE ON tasks
+    FOR EACH ROW EXECUTE FUNCTION notify_task_changed();
@@ -0,0 +1,14 @@
+-- Ключи идемпотентности запросов создания задач.
+-- status_code NULL - запрос с ключом ещё выполняется, expires_at - до какого момента ключ занят
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    principal TEXT NOT NULL,           -- Субъект запроса (claim sub токена)
+    idempotency_key TEXT NOT NULL,     -- Значение заголовка Idempotency-Key
+    request_hash BYTEA NOT NULL,       -- SHA-256 метода, пути и тела запроса
+    status_code INTEGER,               -- HTTP статус сохранённого ответа
+    content_type TEXT,                 -- Content-Type сохранённого ответа
+    response BYTEA,                    -- Тело сохранённого ответа
+    expires_at TIMESTAMPTZ NOT NULL,   -- Время освобождения ключа
+    PRIMARY KEY (principal, idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
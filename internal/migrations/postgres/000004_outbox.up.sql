@@ -0,0 +1,16 @@
+-- Outbox событий изменения задач: событие пишется в транзакции изменения и доставляется relay.
+-- Доставленные события удаляются
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGSERIAL PRIMARY KEY,                             -- Порядок событий
+    event_type TEXT NOT NULL,                             -- Тип события (task.created, task.status_changed, ...)
+    event_version INTEGER NOT NULL,                       -- Версия схемы payload
+    task_id INTEGER NOT NULL,                             -- Задача события, без внешнего ключа: событие переживает удаление задачи
+    payload JSONB NOT NULL,                               -- Данные события
+    occurred_at TIMESTAMPTZ NOT NULL DEFAULT now(),       -- Время записи события
+    attempts INTEGER NOT NULL DEFAULT 0,                  -- Неудачные попытки доставки
+    last_error TEXT,                                      -- Ошибка последней попытки
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now()    -- Когда событие можно выбрать для доставки
+);
+
+CREATE INDEX IF NOT EXISTS outbox_next_attempt_at_idx ON outbox (next_attempt_at);
+CREATE INDEX IF NOT EXISTS outbox_task_id_idx ON outbox (task_id, id);
@@ -0,0 +1,30 @@
+-- Подписки на события задач и журнал доставок подписчикам
+CREATE TABLE IF NOT EXISTS webhooks (
+    id SERIAL PRIMARY KEY,
+    url TEXT NOT NULL,                                    -- Адрес подписчика (http или https)
+    event_types TEXT[] NOT NULL DEFAULT '{}',             -- Типы событий, пустой список - все события
+    secret TEXT NOT NULL,                                 -- Секрет подписи HMAC-SHA256
+    active BOOLEAN NOT NULL DEFAULT true,                 -- Неактивной подписке события не доставляются
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Доставка события одной подписке. Доставки удаляются вместе с подпиской,
+-- завершённые - по истечении WEBHOOK_DELIVERY_RETENTION
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id INTEGER NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event_id BIGINT NOT NULL,                             -- ID события outbox
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,                               -- Тело запроса: событие целиком
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,                  -- Попытки после создания или ручного повтора
+    last_status_code INTEGER,                             -- HTTP статус последней попытки
+    last_error TEXT,                                      -- Ошибка последней попытки
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),   -- Когда отправить: время повтора или окончание аренды
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    delivered_at TIMESTAMPTZ,
+    UNIQUE (webhook_id, event_id)                         -- Повторная публикация события не создаёт дубликатов
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS webhook_deliveries_task_idx;
+ALTER TABLE webhook_deliveries DROP COLUMN IF EXISTS task_id;
@@ -0,0 +1,5 @@
+-- Задача события доставки: доставки одной задачи одной подписке отправляются по порядку.
+-- У доставок, созданных до миграции, задачи нет, они отправляются без ограничения порядка
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS task_id INTEGER;
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_task_idx ON webhook_deliveries (webhook_id, task_id, id) WHERE status = 'pending';
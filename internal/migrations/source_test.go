@@ -0,0 +1,75 @@
+package migrations
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadEmbedded(t *testing.T) {
+	migrations, err := Load(FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	for i, m := range migrations {
+		assert.Equal(t, i+1, m.Version, "versions must be sequential")
+		assert.NotEmpty(t, m.Up)
+		assert.NotEmpty(t, m.Down, "migration %d must have a down file", m.Version)
+		assert.Len(t, m.Checksum, 64)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		want    []Migration
+		wantErr string
+	}{
+		{
+			name: "Миграции сортируются по версии",
+			files: fstest.MapFS{
+				"000002_second.up.sql":   {Data: []byte("SELECT 2;")},
+				"000002_second.down.sql": {Data: []byte("SELECT -2;")},
+				"000001_first.up.sql":    {Data: []byte("SELECT 1;")},
+			},
+			want: []Migration{
+				{Version: 1, Name: "first", Up: "SELECT 1;", Checksum: checksum("SELECT 1;")},
+				{Version: 2, Name: "second", Up: "SELECT 2;", Down: "SELECT -2;", Checksum: checksum("SELECT 2;")},
+			},
+		},
+		{
+			name:    "Неизвестный файл",
+			files:   fstest.MapFS{"README.md": {Data: []byte("docs")}},
+			wantErr: `unexpected file "README.md" in migrations, expected NNNNNN_name.up.sql or NNNNNN_name.down.sql`,
+		},
+		{
+			name:    "Нет up файла",
+			files:   fstest.MapFS{"000001_first.down.sql": {Data: []byte("SELECT 1;")}},
+			wantErr: "migration 1 (first) has no up file",
+		},
+		{
+			name: "Разные имена у одной версии",
+			files: fstest.MapFS{
+				"000001_first.up.sql":   {Data: []byte("SELECT 1;")},
+				"000001_other.up.sql":   {Data: []byte("SELECT 1;")},
+				"000001_first.down.sql": {Data: []byte("SELECT 1;")},
+			},
+			wantErr: `migration 1 has different names: "first" and "other"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Load(tt.files)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
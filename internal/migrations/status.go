@@ -0,0 +1,78 @@
+package migrations
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// Состояние контрольной суммы применённой миграции
+const (
+	ChecksumOK         = "ok"
+	ChecksumModified   = "modified"
+	ChecksumUnrecorded = "unrecorded"
+	ChecksumNoFile     = "missing file"
+)
+
+// Status - состояние одной миграции: есть в файлах и/или применена в базе
+type Status struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	// Checksum - состояние контрольной суммы, пусто для неприменённых миграций
+	Checksum string
+}
+
+// Status - все известные миграции по возрастанию версии, включая применённые,
+// для которых в файлах нет миграции. Схема не изменяется
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return statuses(m.migrations, applied), nil
+}
+
+func statuses(migrations []Migration, applied map[int]appliedMigration) []Status {
+	result := make([]Status, 0, len(migrations))
+	known := make(map[int]bool, len(migrations))
+
+	for _, migration := range migrations {
+		known[migration.Version] = true
+
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if a, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = a.AppliedAt
+			switch a.Checksum {
+			case "":
+				status.Checksum = ChecksumUnrecorded
+			case migration.Checksum:
+				status.Checksum = ChecksumOK
+			default:
+				status.Checksum = ChecksumModified
+			}
+		}
+		result = append(result, status)
+	}
+
+	for _, version := range appliedVersions(applied) {
+		if known[version] {
+			continue
+		}
+		a := applied[version]
+		result = append(result, Status{
+			Version:   version,
+			Name:      a.Name,
+			Applied:   true,
+			AppliedAt: a.AppliedAt,
+			Checksum:  ChecksumNoFile,
+		})
+	}
+
+	slices.SortFunc(result, func(a, b Status) int { return a.Version - b.Version })
+
+	return result
+}
@@ -0,0 +1,141 @@
+// Package outbox - доставка событий изменения задач из outbox получателю.
+// Relay выбирает события из хранилища и передаёт их Publisher не меньше одного раза:
+// событие удаляется только после успешной публикации, поэтому после сбоя оно может прийти
+// повторно, получатель распознаёт повтор по ID события. События одной задачи доставляются
+// в порядке записи: пока более раннее событие ждёт повтора, следующие не публикуются
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"simple-service/internal/config"
+	"simple-service/internal/metrics"
+	"simple-service/internal/service"
+	"simple-service/pkg/backoff"
+)
+
+// Store - очередь событий outbox
+type Store interface {
+	// ClaimEvents - до limit событий, готовых к доставке, по возрастанию ID. Выбранные события
+	// не выбираются повторно в течение lease, если раньше не отмечены доставленными или не возвращены
+	ClaimEvents(ctx context.Context, limit int, lease time.Duration) ([]service.Event, error)
+	// CompleteEvents - события доставлены
+	CompleteEvents(ctx context.Context, ids []int64) error
+	// RetryEvent - доставка не удалась, следующая попытка через delay
+	RetryEvent(ctx context.Context, id int64, delay time.Duration, reason string) error
+	// ReleaseEvents - выбранные события не доставлялись и снова доступны
+	ReleaseEvents(ctx context.Context, ids []int64) error
+}
+
+// Publisher - получатель событий. Ошибка Publish - событие будет опубликовано повторно
+type Publisher interface {
+	Publish(ctx context.Context, event service.Event) error
+}
+
+// bookkeepingTimeout - ограничение отметки результатов доставки, в том числе при остановке
+const bookkeepingTimeout = 5 * time.Second
+
+// Relay - периодическая доставка событий из Store в Publisher
+type Relay struct {
+	store     Store
+	publisher Publisher
+	cfg       config.Outbox
+	log       *zap.SugaredLogger
+}
+
+// NewRelay - relay с параметрами доставки из cfg
+func NewRelay(store Store, publisher Publisher, cfg config.Outbox, logger *zap.SugaredLogger) *Relay {
+	return &Relay{
+		store:     store,
+		publisher: publisher,
+		cfg:       cfg,
+		log:       logger,
+	}
+}
+
+// Run - доставка до отмены ctx. Пока события выбираются полными пачками, следующая пачка
+// выбирается сразу, иначе - через PollInterval
+func (r *Relay) Run(ctx context.Context) {
+	for {
+		n, err := r.Deliver(ctx)
+		if err != nil && ctx.Err() == nil {
+			r.log.Warnw("Failed to deliver outbox events", "error", err)
+		}
+		if err == nil && n == r.cfg.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.cfg.PollInterval):
+		}
+	}
+}
+
+// Deliver - доставка одной пачки событий, возвращает число выбранных событий.
+// После неудачной публикации следующие события той же задачи в пачке не публикуются
+func (r *Relay) Deliver(ctx context.Context) (int, error) {
+	events, err := r.store.ClaimEvents(ctx, r.cfg.BatchSize, r.cfg.Lease)
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+
+	// Результаты отмечаются и после отмены ctx: иначе доставленные события придут повторно
+	bookCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), bookkeepingTimeout)
+	defer cancel()
+
+	failed := make(map[int]struct{})
+	var published, skipped []int64
+	for _, event := range events {
+		if _, ok := failed[event.TaskID]; ok || ctx.Err() != nil {
+			skipped = append(skipped, event.ID)
+			continue
+		}
+
+		err := r.publisher.Publish(ctx, event)
+		switch {
+		case err == nil:
+			metrics.OutboxDeliveries.WithLabelValues(event.Type, "published").Inc()
+			metrics.OutboxDeliveryLag.Observe(time.Since(event.OccurredAt).Seconds())
+			published = append(published, event.ID)
+		case ctx.Err() != nil:
+			// Остановка, а не ошибка получателя: событие вернётся в очередь без паузы
+			skipped = append(skipped, event.ID)
+		default:
+			failed[event.TaskID] = struct{}{}
+			r.retry(bookCtx, event, err)
+		}
+	}
+
+	if len(published) > 0 {
+		if err := r.store.CompleteEvents(bookCtx, published); err != nil {
+			return len(events), errors.Wrap(err, "published events will be delivered again")
+		}
+	}
+	if len(skipped) > 0 {
+		if err := r.store.ReleaseEvents(bookCtx, skipped); err != nil {
+			return len(events), err
+		}
+	}
+	return len(events), nil
+}
+
+// retry - событие будет опубликовано повторно после паузы, растущей с числом попыток
+func (r *Relay) retry(ctx context.Context, event service.Event, cause error) {
+	metrics.OutboxDeliveries.WithLabelValues(event.Type, "failed").Inc()
+
+	attempt := event.Attempts + 1
+	delay := backoff.Exponential(r.cfg.RetryBaseDelay, r.cfg.RetryMaxDelay, attempt)
+	r.log.Warnw("Failed to publish outbox event", "error", cause, "event_id", event.ID, "type", event.Type,
+		"task_id", event.TaskID, "attempt", attempt, "retry_in", delay)
+
+	// Если отметить не удалось, событие вернётся в очередь по окончании аренды
+	if err := r.store.RetryEvent(ctx, event.ID, delay, cause.Error()); err != nil {
+		r.log.Warnw("Failed to reschedule outbox event", "error", err, "event_id", event.ID)
+	}
+}
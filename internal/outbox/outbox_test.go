@@ -0,0 +1,177 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"simple-service/internal/config"
+	"simple-service/internal/repo/memory"
+	"simple-service/internal/service"
+)
+
+var errUnavailable = errors.New("receiver unavailable")
+
+// recorder - получатель, запоминающий события; failures - сколько первых попыток по задаче завершаются ошибкой
+type recorder struct {
+	mu        sync.Mutex
+	failures  map[int]int
+	published []service.Event
+	attempts  []int64
+}
+
+func (p *recorder) Publish(_ context.Context, event service.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.attempts = append(p.attempts, event.ID)
+	if p.failures[event.TaskID] > 0 {
+		p.failures[event.TaskID]--
+		return errUnavailable
+	}
+	p.published = append(p.published, event)
+	return nil
+}
+
+func testConfig() config.Outbox {
+	return config.Outbox{
+		PollInterval:   time.Millisecond,
+		BatchSize:      10,
+		Lease:          time.Minute,
+		RetryBaseDelay: time.Millisecond,
+		RetryMaxDelay:  time.Millisecond,
+	}
+}
+
+func appendEvents(t *testing.T, store service.EventStore, taskIDs ...int) {
+	t.Helper()
+	events := make([]service.Event, 0, len(taskIDs))
+	for _, id := range taskIDs {
+		events = append(events, service.Event{Type: service.EventTaskUpdated, Version: 1, TaskID: id, Payload: []byte(`{}`)})
+	}
+	require.NoError(t, store.AppendEvents(context.Background(), events))
+}
+
+func taskOrder(events []service.Event, taskID int) []int64 {
+	var ids []int64
+	for _, e := range events {
+		if e.TaskID == taskID {
+			ids = append(ids, e.ID)
+		}
+	}
+	return ids
+}
+
+// delayRecorder - очередь, запоминающая паузы перед повторной публикацией
+type delayRecorder struct {
+	Store
+	mu     sync.Mutex
+	delays []time.Duration
+}
+
+func (s *delayRecorder) RetryEvent(ctx context.Context, id int64, delay time.Duration, reason string) error {
+	s.mu.Lock()
+	s.delays = append(s.delays, delay)
+	s.mu.Unlock()
+	return s.Store.RetryEvent(ctx, id, delay, reason)
+}
+
+func TestDeliver(t *testing.T) {
+	ctx := context.Background()
+	events := memory.NewRepository()
+	appendEvents(t, events, 1, 2, 1, 2)
+
+	store := &delayRecorder{Store: events}
+	publisher := &recorder{failures: map[int]int{1: 1}}
+	relay := NewRelay(store, publisher, testConfig(), zap.NewNop().Sugar())
+
+	// Первое событие задачи 1 не доставлено: второе событие задачи 1 в этой пачке не публикуется
+	n, err := relay.Deliver(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, []int64{1, 2, 4}, publisher.attempts)
+	assert.Equal(t, []int64{2, 4}, taskOrder(publisher.published, 2))
+
+	// Повтор отложен на паузу из RetryBaseDelay
+	require.Len(t, store.delays, 1)
+	assert.Positive(t, store.delays[0])
+
+	// После паузы события задачи 1 доставляются по порядку, счётчик попыток сохранён
+	time.Sleep(5 * time.Millisecond)
+	n, err = relay.Deliver(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []int64{1, 3}, taskOrder(publisher.published, 1))
+	assert.Equal(t, 1, publisher.published[2].Attempts)
+
+	// Доставленные события удалены
+	n, err = relay.Deliver(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestRun(t *testing.T) {
+	store := memory.NewRepository()
+	cfg := testConfig()
+	cfg.BatchSize = 3
+	appendEvents(t, store, 1, 2, 3, 1, 2, 3, 1)
+
+	publisher := &recorder{failures: map[int]int{2: 3}}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		NewRelay(store, publisher, cfg, zap.NewNop().Sugar()).Run(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		publisher.mu.Lock()
+		defer publisher.mu.Unlock()
+		return len(publisher.published) == 7
+	}, 5*time.Second, time.Millisecond)
+	cancel()
+	<-done
+
+	// Каждое событие доставлено один раз, события каждой задачи - в порядке записи
+	assert.Equal(t, []int64{1, 4, 7}, taskOrder(publisher.published, 1))
+	assert.Equal(t, []int64{2, 5}, taskOrder(publisher.published, 2))
+	assert.Equal(t, []int64{3, 6}, taskOrder(publisher.published, 3))
+}
+
+func TestFilePublisher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	require.NoError(t, os.WriteFile(path, []byte("{\"id\":0}\n"), 0o644))
+
+	publisher, err := NewFilePublisher(path)
+	require.NoError(t, err)
+	occurred := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for id := int64(1); id <= 2; id++ {
+		require.NoError(t, publisher.Publish(context.Background(), service.Event{
+			ID: id, Type: service.EventTaskCreated, Version: 1, TaskID: 7, Payload: []byte(`{"task":{"id":7}}`), OccurredAt: occurred,
+		}))
+	}
+	require.NoError(t, publisher.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	require.Len(t, lines, 3, "события дописываются в конец файла")
+
+	var event service.Event
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &event))
+	assert.Equal(t, int64(2), event.ID)
+	assert.Equal(t, service.EventTaskCreated, event.Type)
+	assert.Equal(t, 7, event.TaskID)
+	assert.True(t, occurred.Equal(event.OccurredAt))
+	assert.JSONEq(t, `{"task":{"id":7}}`, string(event.Payload))
+}
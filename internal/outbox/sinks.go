@@ -0,0 +1,117 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"simple-service/internal/config"
+	"simple-service/internal/service"
+)
+
+// Получатели для локального запуска: лог сервиса и файл
+
+// NewPublisher - получатель, выбранный OUTBOX_SINK. Если он держит ресурсы, он реализует io.Closer
+func NewPublisher(cfg config.Outbox, logger *zap.SugaredLogger) (Publisher, error) {
+	switch cfg.Sink {
+	case config.OutboxSinkLog:
+		return NewLogPublisher(logger), nil
+	case config.OutboxSinkFile:
+		return NewFilePublisher(cfg.File)
+	}
+	return nil, errors.Errorf("unknown outbox sink %q", cfg.Sink)
+}
+
+// Multi - публикация каждому получателю по порядку. Ошибка любого получателя - событие будет
+// опубликовано повторно всем, поэтому получатели должны переносить повторы
+type Multi []Publisher
+
+// Publish - публикация до первой ошибки
+func (m Multi) Publish(ctx context.Context, event service.Event) error {
+	for _, p := range m {
+		if err := p.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close - закрытие получателей, реализующих io.Closer; возвращается первая ошибка
+func (m Multi) Close() error {
+	var first error
+	for _, p := range m {
+		if closer, ok := p.(io.Closer); ok {
+			if err := closer.Close(); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+// LogPublisher - событие записью в лог сервиса
+type LogPublisher struct {
+	log *zap.SugaredLogger
+}
+
+// NewLogPublisher - получатель, пишущий события в logger
+func NewLogPublisher(logger *zap.SugaredLogger) *LogPublisher {
+	return &LogPublisher{log: logger}
+}
+
+// Publish - запись события в лог, не завершается ошибкой
+func (p *LogPublisher) Publish(_ context.Context, event service.Event) error {
+	p.log.Infow("Task event",
+		"event_id", event.ID,
+		"type", event.Type,
+		"version", event.Version,
+		"task_id", event.TaskID,
+		"occurred_at", event.OccurredAt,
+		"payload", string(event.Payload),
+	)
+	return nil
+}
+
+// FilePublisher - событие строкой JSON в конце файла. После записи файл сбрасывается на диск:
+// событие удаляется из outbox, только когда оно сохранено
+type FilePublisher struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFilePublisher - получатель, дописывающий события в path; файл создаётся, если его нет
+func NewFilePublisher(path string) (*FilePublisher, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open outbox file")
+	}
+	return &FilePublisher{file: file}, nil
+}
+
+// Publish - запись события. Если запись оборвалась, в файле остаётся неполная строка,
+// повтор дописывает событие заново
+func (p *FilePublisher) Publish(_ context.Context, event service.Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode event")
+	}
+	line = append(line, '\n')
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, err := p.file.Write(line); err != nil {
+		return errors.Wrap(err, "failed to write event")
+	}
+	return errors.Wrap(p.file.Sync(), "failed to sync outbox file")
+}
+
+// Close - закрытие файла
+func (p *FilePublisher) Close() error {
+	return p.file.Close()
+}
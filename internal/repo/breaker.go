@@ -0,0 +1,158 @@
+package repo
+
+import (
+	"sync"
+	"time"
+
+	"simple-service/internal/metrics"
+)
+
+// Состояния circuit breaker
+const (
+	// BreakerClosed - запросы идут в БД
+	BreakerClosed = "closed"
+	// BreakerHalfOpen - таймаут истёк, в БД пропускается один пробный запрос
+	BreakerHalfOpen = "half_open"
+	// BreakerOpen - БД недоступна, запросы отклоняются сразу
+	BreakerOpen = "open"
+)
+
+// breakerGauge - значение метрики db_breaker_state для состояния
+var breakerGauge = map[string]float64{
+	BreakerClosed:   0,
+	BreakerHalfOpen: 1,
+	BreakerOpen:     2,
+}
+
+// breaker - circuit breaker: после threshold ошибок подключения подряд запросы отклоняются
+// на openTimeout, затем один пробный запрос решает, закрыться или открыться снова.
+// threshold 0 отключает breaker
+type breaker struct {
+	threshold   int
+	openTimeout time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+
+	// now подменяется в тестах
+	now func() time.Time
+}
+
+func newBreaker(threshold int, openTimeout time.Duration) *breaker {
+	metrics.DBBreakerState.Set(breakerGauge[BreakerClosed])
+	return &breaker{
+		threshold:   threshold,
+		openTimeout: openTimeout,
+		state:       BreakerClosed,
+		now:         time.Now,
+	}
+}
+
+// allow - можно ли обращаться к БД. Если нельзя, возвращает через сколько повторить
+func (b *breaker) allow() (time.Duration, bool) {
+	if b.threshold <= 0 {
+		return 0, true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		wait := b.openedAt.Add(b.openTimeout).Sub(b.now())
+		if wait > 0 {
+			return wait, false
+		}
+		b.setState(BreakerHalfOpen)
+		b.probing = true
+		return 0, true
+
+	case BreakerHalfOpen:
+		// Пока пробный запрос не завершился, остальные отклоняются
+		if b.probing {
+			return time.Second, false
+		}
+		b.probing = true
+		return 0, true
+	}
+
+	return 0, true
+}
+
+// success - БД ответила (в том числе ошибкой запроса, например нарушением ограничения)
+func (b *breaker) success() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.probing = false
+	if b.state != BreakerClosed {
+		b.setState(BreakerClosed)
+	}
+}
+
+// failure - БД недоступна: нет соединения, сервер останавливается
+func (b *breaker) failure() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.openedAt = b.now()
+		b.setState(BreakerOpen)
+	}
+}
+
+// release - запрос завершился без ответа БД (отменён клиентом), пробный запрос можно повторить
+func (b *breaker) release() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+// State - текущее состояние: closed, half_open или open
+func (b *breaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && !b.now().Before(b.openedAt.Add(b.openTimeout)) {
+		// Таймаут истёк, следующий запрос будет пробным
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// retryAfter - через сколько имеет смысл повторить запрос после ошибки подключения
+func (b *breaker) retryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen {
+		if wait := b.openedAt.Add(b.openTimeout).Sub(b.now()); wait > 0 {
+			return wait
+		}
+	}
+	return time.Second
+}
+
+func (b *breaker) setState(state string) {
+	b.state = state
+	metrics.DBBreakerState.Set(breakerGauge[state])
+	metrics.DBBreakerTransitions.WithLabelValues(state).Inc()
+}
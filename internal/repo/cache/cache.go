@@ -0,0 +1,283 @@
+// Package cache - кеш чтения задач по ID поверх service.Repository.
+// Записи ограничены по числу (LRU) и времени жизни, отсутствие задачи тоже кешируется.
+// Одновременные промахи по одному ID превращаются в один запрос к хранилищу.
+// Свои записи инвалидируются сразу, изменения других реплик - через Invalidate
+// (в PostgreSQL - по LISTEN/NOTIFY, см. repo.ListenTaskChanges)
+package cache
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+
+	"simple-service/internal/config"
+	"simple-service/internal/metrics"
+	"simple-service/internal/service"
+)
+
+// Repository - service.Repository с кешем GetTask
+type Repository struct {
+	next        service.Repository
+	ttl         time.Duration
+	negativeTTL time.Duration
+
+	mu    sync.Mutex
+	items *lru
+	// epoch растёт при каждой инвалидации: результат загрузки, начатой до неё, не сохраняется
+	epoch uint64
+
+	loads singleflight.Group
+
+	// now подменяется в тестах
+	now func() time.Time
+}
+
+// New - кеш поверх next с параметрами из cfg, cfg.Size должен быть положительным
+func New(next service.Repository, cfg config.Cache) *Repository {
+	return &Repository{
+		next:        next,
+		ttl:         cfg.TTL,
+		negativeTTL: cfg.NegativeTTL,
+		items:       newLRU(cfg.Size),
+		now:         time.Now,
+	}
+}
+
+// txKey - ключ контекста для ID, изменённых в текущей транзакции
+type txKey struct{}
+
+// touched - ID, изменённые в транзакции; инвалидируются после её завершения
+type touched struct {
+	mu  sync.Mutex
+	ids []int
+}
+
+// GetTask - задача из кеша или из хранилища. Внутри транзакции кеш не используется:
+// транзакция должна видеть свои незафиксированные изменения
+func (r *Repository) GetTask(ctx context.Context, id int) (*service.TaskResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if ctx.Value(txKey{}) != nil {
+		return r.next.GetTask(ctx, id)
+	}
+
+	if e, ok := r.lookup(id); ok {
+		if e.task == nil {
+			metrics.TaskCacheLookups.WithLabelValues("negative_hit").Inc()
+			return nil, service.ErrTaskNotFound
+		}
+		metrics.TaskCacheLookups.WithLabelValues("hit").Inc()
+		return copyTask(e.task), nil
+	}
+	metrics.TaskCacheLookups.WithLabelValues("miss").Inc()
+
+	// Загрузка общая для всех ждущих, поэтому не отменяется вместе с контекстом первого из них,
+	// но ограничена его бюджетом времени. Читается всегда primary: отстающая реплика, выбранная
+	// для первого из ждущих, отдала бы устаревшую задачу и тому, кто только что её записал,
+	// и закешировала бы её уже после инвалидации
+	result := r.loads.DoChan(strconv.Itoa(id), func() (any, error) {
+		loadCtx := service.WithPrimary(context.WithoutCancel(ctx))
+		if deadline, ok := ctx.Deadline(); ok {
+			var cancel context.CancelFunc
+			loadCtx, cancel = context.WithDeadline(loadCtx, deadline)
+			defer cancel()
+		}
+		return r.load(loadCtx, id)
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-result:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return copyTask(res.Val.(*service.TaskResponse)), nil
+	}
+}
+
+// load - чтение из хранилища и сохранение результата, если за это время не было инвалидаций
+func (r *Repository) load(ctx context.Context, id int) (*service.TaskResponse, error) {
+	r.mu.Lock()
+	epoch := r.epoch
+	r.mu.Unlock()
+
+	task, err := r.next.GetTask(ctx, id)
+	switch {
+	case err == nil:
+		r.store(epoch, &entry{id: id, task: task, expires: r.now().Add(r.ttl)})
+	case errors.Is(err, service.ErrTaskNotFound) && r.negativeTTL > 0:
+		r.store(epoch, &entry{id: id, expires: r.now().Add(r.negativeTTL)})
+	}
+	return task, err
+}
+
+func (r *Repository) lookup(id int) (*entry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.items.get(id, r.now())
+	metrics.TaskCacheEntries.Set(float64(r.items.len()))
+	return e, ok
+}
+
+func (r *Repository) store(epoch uint64, e *entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if epoch != r.epoch {
+		return
+	}
+	if r.items.add(e) {
+		metrics.TaskCacheEvictions.Inc()
+	}
+	metrics.TaskCacheEntries.Set(float64(r.items.len()))
+}
+
+// Invalidate - задача изменена другой репликой
+func (r *Repository) Invalidate(id int) {
+	r.invalidate("notify", id)
+}
+
+// Purge - очистка кеша, если уведомления об изменениях могли быть пропущены
+func (r *Repository) Purge() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.epoch++
+	r.items.purge()
+	metrics.TaskCacheEntries.Set(0)
+	metrics.TaskCacheInvalidations.WithLabelValues("reset").Inc()
+}
+
+func (r *Repository) invalidate(source string, ids ...int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.epoch++
+	for _, id := range ids {
+		r.items.remove(id)
+	}
+	metrics.TaskCacheEntries.Set(float64(r.items.len()))
+	metrics.TaskCacheInvalidations.WithLabelValues(source).Add(float64(len(ids)))
+}
+
+// written - задачи изменены: в транзакции инвалидация откладывается до её завершения
+func (r *Repository) written(ctx context.Context, ids ...int) {
+	if t, ok := ctx.Value(txKey{}).(*touched); ok {
+		t.mu.Lock()
+		t.ids = append(t.ids, ids...)
+		t.mu.Unlock()
+		return
+	}
+	r.invalidate("local", ids...)
+}
+
+// WithinTx - транзакция хранилища; изменённые в ней задачи инвалидируются после завершения,
+// в том числе после отката, чтобы не осталось прочитанных внутри неё значений
+func (r *Repository) WithinTx(ctx context.Context, opts service.TxOptions, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*touched); ok {
+		return r.next.WithinTx(ctx, opts, fn)
+	}
+
+	t := &touched{}
+	err := r.next.WithinTx(context.WithValue(ctx, txKey{}, t), opts, fn)
+
+	t.mu.Lock()
+	ids := t.ids
+	t.mu.Unlock()
+	if len(ids) > 0 {
+		r.invalidate("local", ids...)
+	}
+
+	return err
+}
+
+// CreateTask - новая задача могла быть закеширована как отсутствующая
+func (r *Repository) CreateTask(ctx context.Context, task service.Task) (int, error) {
+	id, err := r.next.CreateTask(ctx, task)
+	if err == nil {
+		r.written(ctx, id)
+	}
+	return id, err
+}
+
+// CreateTasks - новые задачи могли быть закешированы как отсутствующие
+func (r *Repository) CreateTasks(ctx context.Context, tasks []service.Task) ([]int, error) {
+	ids, err := r.next.CreateTasks(ctx, tasks)
+	if err == nil && len(ids) > 0 {
+		r.written(ctx, ids...)
+	}
+	return ids, err
+}
+
+// GetTasks - чтение нескольких задач не кешируется: в транзакции оно блокирует строки
+func (r *Repository) GetTasks(ctx context.Context, ids []int) ([]service.TaskResponse, error) {
+	return r.next.GetTasks(ctx, ids)
+}
+
+// ListTasks - список не кешируется
+func (r *Repository) ListTasks(ctx context.Context, filter service.ListFilter) ([]service.TaskResponse, error) {
+	return r.next.ListTasks(ctx, filter)
+}
+
+// UpdateTask - обновление с инвалидацией задачи
+func (r *Repository) UpdateTask(ctx context.Context, id int, update service.TaskUpdate) (*service.TaskResponse, error) {
+	task, err := r.next.UpdateTask(ctx, id, update)
+	r.written(ctx, id)
+	return task, err
+}
+
+// DeleteTask - удаление с инвалидацией задачи
+func (r *Repository) DeleteTask(ctx context.Context, id int) error {
+	err := r.next.DeleteTask(ctx, id)
+	r.written(ctx, id)
+	return err
+}
+
+// FindTaskIDs - отбор не кешируется
+func (r *Repository) FindTaskIDs(ctx context.Context, sel service.TaskSelector) ([]int, error) {
+	return r.next.FindTaskIDs(ctx, sel)
+}
+
+// UpdateTasks - массовое обновление с инвалидацией задач
+func (r *Repository) UpdateTasks(ctx context.Context, ids []int, update service.TaskUpdate) (int, error) {
+	n, err := r.next.UpdateTasks(ctx, ids, update)
+	r.written(ctx, ids...)
+	return n, err
+}
+
+// DeleteTasks - массовое удаление с инвалидацией задач
+func (r *Repository) DeleteTasks(ctx context.Context, ids []int) (int, error) {
+	n, err := r.next.DeleteTasks(ctx, ids)
+	r.written(ctx, ids...)
+	return n, err
+}
+
+// ExportTasks - выгрузка не кешируется
+func (r *Repository) ExportTasks(ctx context.Context, filter service.TaskFilter) (service.TaskCursor, error) {
+	return r.next.ExportTasks(ctx, filter)
+}
+
+// ImportTasks - загруженные задачи, в том числе с ID из файла, могли быть закешированы как отсутствующие
+func (r *Repository) ImportTasks(ctx context.Context, tasks []service.TaskResponse) ([]int, error) {
+	ids, err := r.next.ImportTasks(ctx, tasks)
+	if err == nil && len(ids) > 0 {
+		r.written(ctx, ids...)
+	}
+	return ids, err
+}
+
+// copyTask - копия для вызывающего, чтобы изменения ответа не попали в кеш
+func copyTask(task *service.TaskResponse) *service.TaskResponse {
+	if task == nil {
+		return nil
+	}
+	c := *task
+	return &c
+}
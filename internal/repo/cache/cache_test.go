@@ -0,0 +1,304 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"simple-service/internal/config"
+	"simple-service/internal/repo/memory"
+	"simple-service/internal/repo/repotest"
+	"simple-service/internal/service"
+)
+
+// counting - хранилище, считающее обращения к GetTask; gate задерживает чтение
+type counting struct {
+	service.Repository
+	gets atomic.Int32
+	gate chan struct{}
+}
+
+func (c *counting) GetTask(ctx context.Context, id int) (*service.TaskResponse, error) {
+	c.gets.Add(1)
+	if c.gate != nil {
+		<-c.gate
+	}
+	return c.Repository.GetTask(ctx, id)
+}
+
+// lagging - primary и отстающая реплика: чтения не закреплённого за primary субъекта
+// получают версию с реплики
+type lagging struct {
+	service.Repository
+	replica service.Repository
+	pinned  string
+	gets    atomic.Int32
+	gate    chan struct{}
+}
+
+func (l *lagging) GetTask(ctx context.Context, id int) (*service.TaskResponse, error) {
+	l.gets.Add(1)
+	<-l.gate
+	if !service.ReadsPrimary(ctx) && service.SubjectFromContext(ctx) != l.pinned {
+		return l.replica.GetTask(ctx, id)
+	}
+	return l.Repository.GetTask(ctx, id)
+}
+
+func newTestCache(size int) (*Repository, *counting, *time.Time) {
+	next := &counting{Repository: memory.NewRepository()}
+	c := New(next, config.Cache{Size: size, TTL: time.Minute, NegativeTTL: 5 * time.Second})
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	return c, next, &now
+}
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) service.Repository {
+		return New(memory.NewRepository(), config.Cache{Size: 100, TTL: time.Minute, NegativeTTL: time.Minute})
+	})
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Повторное чтение из кеша до истечения TTL", func(t *testing.T) {
+		c, next, now := newTestCache(10)
+		id, err := c.CreateTask(ctx, service.Task{Title: "Hot"})
+		require.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			task, err := c.GetTask(ctx, id)
+			require.NoError(t, err)
+			assert.Equal(t, "Hot", task.Title)
+		}
+		assert.EqualValues(t, 1, next.gets.Load())
+
+		// Изменение ответа не портит кеш
+		task, _ := c.GetTask(ctx, id)
+		task.Title = "Changed by caller"
+		task, _ = c.GetTask(ctx, id)
+		assert.Equal(t, "Hot", task.Title)
+
+		*now = now.Add(time.Minute)
+		_, err = c.GetTask(ctx, id)
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, next.gets.Load())
+	})
+
+	t.Run("Отсутствие задачи кешируется на NegativeTTL", func(t *testing.T) {
+		c, next, now := newTestCache(10)
+
+		for i := 0; i < 2; i++ {
+			_, err := c.GetTask(ctx, 1)
+			assert.ErrorIs(t, err, service.ErrTaskNotFound)
+		}
+		assert.EqualValues(t, 1, next.gets.Load())
+
+		*now = now.Add(5 * time.Second)
+		_, err := c.GetTask(ctx, 1)
+		assert.ErrorIs(t, err, service.ErrTaskNotFound)
+		assert.EqualValues(t, 2, next.gets.Load())
+
+		// Созданная задача сразу видна, хотя её ID был закеширован как отсутствующий
+		id, err := c.CreateTask(ctx, service.Task{Title: "Created"})
+		require.NoError(t, err)
+		require.Equal(t, 1, id)
+		task, err := c.GetTask(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "Created", task.Title)
+
+		// То же для пакетного создания
+		_, err = c.GetTask(ctx, 3)
+		assert.ErrorIs(t, err, service.ErrTaskNotFound)
+		created, err := c.CreateTasks(ctx, []service.Task{{Title: "Second"}, {Title: "Third"}})
+		require.NoError(t, err)
+		require.Equal(t, []int{2, 3}, created)
+		task, err = c.GetTask(ctx, 3)
+		require.NoError(t, err)
+		assert.Equal(t, "Third", task.Title)
+	})
+
+	t.Run("Запись инвалидирует задачу", func(t *testing.T) {
+		c, _, _ := newTestCache(10)
+		id, _ := c.CreateTask(ctx, service.Task{Title: "Before"})
+		_, _ = c.GetTask(ctx, id)
+
+		_, err := c.UpdateTask(ctx, id, service.TaskUpdate{Title: ptr("After")})
+		require.NoError(t, err)
+		task, err := c.GetTask(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "After", task.Title)
+
+		require.NoError(t, c.DeleteTask(ctx, id))
+		_, err = c.GetTask(ctx, id)
+		assert.ErrorIs(t, err, service.ErrTaskNotFound)
+	})
+
+	t.Run("Вытеснение давно не читавшихся", func(t *testing.T) {
+		c, next, _ := newTestCache(2)
+		for i := 0; i < 3; i++ {
+			_, err := c.next.CreateTask(ctx, service.Task{Title: "Task"})
+			require.NoError(t, err)
+		}
+
+		_, _ = c.GetTask(ctx, 1)
+		_, _ = c.GetTask(ctx, 2)
+		_, _ = c.GetTask(ctx, 1) // 1 читалась последней, вытесняется 2
+		_, _ = c.GetTask(ctx, 3)
+		assert.EqualValues(t, 3, next.gets.Load())
+
+		_, _ = c.GetTask(ctx, 1)
+		assert.EqualValues(t, 3, next.gets.Load())
+		_, _ = c.GetTask(ctx, 2)
+		assert.EqualValues(t, 4, next.gets.Load())
+	})
+
+	t.Run("Одновременные промахи - один запрос к хранилищу", func(t *testing.T) {
+		c, next, _ := newTestCache(10)
+		id, _ := c.CreateTask(ctx, service.Task{Title: "Hot"})
+		next.gate = make(chan struct{})
+
+		const readers = 16
+		var wg sync.WaitGroup
+		for i := 0; i < readers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				task, err := c.GetTask(ctx, id)
+				if assert.NoError(t, err) {
+					assert.Equal(t, "Hot", task.Title)
+				}
+			}()
+		}
+
+		// Ждём, пока загрузка начнётся, и отпускаем её
+		require.Eventually(t, func() bool { return next.gets.Load() == 1 }, time.Second, time.Millisecond)
+		time.Sleep(10 * time.Millisecond)
+		close(next.gate)
+		wg.Wait()
+
+		assert.EqualValues(t, 1, next.gets.Load())
+	})
+
+	t.Run("Отмена ожидающего не отменяет общую загрузку", func(t *testing.T) {
+		c, next, _ := newTestCache(10)
+		id, _ := c.CreateTask(ctx, service.Task{Title: "Hot"})
+		next.gate = make(chan struct{})
+
+		canceled, cancel := context.WithCancel(ctx)
+		errs := make(chan error, 1)
+		go func() {
+			_, err := c.GetTask(canceled, id)
+			errs <- err
+		}()
+		require.Eventually(t, func() bool { return next.gets.Load() == 1 }, time.Second, time.Millisecond)
+
+		cancel()
+		assert.ErrorIs(t, <-errs, context.Canceled)
+
+		close(next.gate)
+		require.Eventually(t, func() bool {
+			_, ok := c.lookup(id)
+			return ok
+		}, time.Second, time.Millisecond, "результат загрузки сохранён в кеш")
+	})
+
+	t.Run("Загрузка, начатая до инвалидации, не сохраняется", func(t *testing.T) {
+		c, next, _ := newTestCache(10)
+		id, _ := c.CreateTask(ctx, service.Task{Title: "Old"})
+		next.gate = make(chan struct{})
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_, _ = c.GetTask(ctx, id)
+		}()
+		require.Eventually(t, func() bool { return next.gets.Load() == 1 }, time.Second, time.Millisecond)
+
+		// Другая реплика изменила задачу, пока шло чтение
+		c.Invalidate(id)
+		close(next.gate)
+		<-done
+
+		_, ok := c.lookup(id)
+		assert.False(t, ok)
+	})
+
+	t.Run("Загрузка читает primary: запись видна автору при общем промахе", func(t *testing.T) {
+		next := &lagging{Repository: memory.NewRepository(), replica: memory.NewRepository(), gate: make(chan struct{})}
+		c := New(next, config.Cache{Size: 10, TTL: time.Minute})
+		_, _ = next.replica.CreateTask(ctx, service.Task{Title: "Old"})
+		id, _ := next.Repository.CreateTask(ctx, service.Task{Title: "Old"})
+
+		alice := service.WithSubject(ctx, "alice")
+		bob := service.WithSubject(ctx, "bob")
+		_, err := c.UpdateTask(alice, id, service.TaskUpdate{Title: ptr("New")})
+		require.NoError(t, err)
+		next.pinned = "alice"
+
+		// Промах bob начинает загрузку, alice присоединяется к ней
+		titles := make(chan string, 2)
+		read := func(ctx context.Context) {
+			task, err := c.GetTask(ctx, id)
+			if assert.NoError(t, err) {
+				titles <- task.Title
+			}
+		}
+		go read(bob)
+		require.Eventually(t, func() bool { return next.gets.Load() == 1 }, time.Second, time.Millisecond)
+		go read(alice)
+		time.Sleep(10 * time.Millisecond)
+		close(next.gate)
+
+		assert.Equal(t, "New", <-titles)
+		assert.Equal(t, "New", <-titles)
+		assert.EqualValues(t, 1, next.gets.Load())
+
+		task, err := c.GetTask(alice, id)
+		require.NoError(t, err)
+		assert.Equal(t, "New", task.Title, "в кеше версия с primary")
+	})
+
+	t.Run("Транзакция", func(t *testing.T) {
+		c, next, _ := newTestCache(10)
+		id, _ := c.CreateTask(ctx, service.Task{Title: "Before"})
+		_, _ = c.GetTask(ctx, id)
+
+		err := c.WithinTx(ctx, service.TxOptions{}, func(ctx context.Context) error {
+			if _, err := c.UpdateTask(ctx, id, service.TaskUpdate{Title: ptr("After")}); err != nil {
+				return err
+			}
+			// Внутри транзакции чтение идёт мимо кеша и видит свои изменения
+			task, err := c.GetTask(ctx, id)
+			require.NoError(t, err)
+			assert.Equal(t, "After", task.Title)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, next.gets.Load())
+
+		task, err := c.GetTask(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "After", task.Title)
+	})
+
+	t.Run("Сброс кеша", func(t *testing.T) {
+		c, next, _ := newTestCache(10)
+		id, _ := c.CreateTask(ctx, service.Task{Title: "Task"})
+		_, _ = c.GetTask(ctx, id)
+
+		c.Purge()
+		_, _ = c.GetTask(ctx, id)
+		assert.EqualValues(t, 2, next.gets.Load())
+	})
+}
+
+func ptr(s string) *string {
+	return &s
+}
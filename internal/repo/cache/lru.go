@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"container/list"
+	"time"
+
+	"simple-service/internal/service"
+)
+
+// entry - запись кеша, task == nil - задачи нет (negative caching)
+type entry struct {
+	id      int
+	task    *service.TaskResponse
+	expires time.Time
+}
+
+// lru - ограниченный по размеру список записей, давно не читавшиеся вытесняются первыми.
+// Не потокобезопасен, синхронизация в Repository
+type lru struct {
+	capacity int
+	order    *list.List
+	items    map[int]*list.Element
+}
+
+func newLRU(capacity int) *lru {
+	return &lru{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[int]*list.Element, capacity),
+	}
+}
+
+// get - запись по ID, если она не истекла к now; истёкшая запись удаляется
+func (c *lru) get(id int, now time.Time) (*entry, bool) {
+	el, ok := c.items[id]
+	if !ok {
+		return nil, false
+	}
+
+	e := el.Value.(*entry)
+	if !now.Before(e.expires) {
+		c.order.Remove(el)
+		delete(c.items, id)
+		return nil, false
+	}
+
+	c.order.MoveToFront(el)
+	return e, true
+}
+
+// add - добавление или замена записи, возвращает true, если пришлось вытеснить другую
+func (c *lru) add(e *entry) bool {
+	if el, ok := c.items[e.id]; ok {
+		el.Value = e
+		c.order.MoveToFront(el)
+		return false
+	}
+
+	c.items[e.id] = c.order.PushFront(e)
+	if c.order.Len() <= c.capacity {
+		return false
+	}
+
+	oldest := c.order.Back()
+	c.order.Remove(oldest)
+	delete(c.items, oldest.Value.(*entry).id)
+	return true
+}
+
+// remove - удаление записи, если она есть
+func (c *lru) remove(id int) {
+	if el, ok := c.items[id]; ok {
+		c.order.Remove(el)
+		delete(c.items, id)
+	}
+}
+
+// purge - удаление всех записей
+func (c *lru) purge() {
+	c.order.Init()
+	c.items = make(map[int]*list.Element, c.capacity)
+}
+
+func (c *lru) len() int {
+	return c.order.Len()
+}
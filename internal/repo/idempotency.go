@@ -0,0 +1,115 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+
+	"simple-service/internal/service"
+)
+
+// Ключи идемпотентности. Время истечения считает сервер БД, чтобы реплики сервиса
+// с расходящимися часами одинаково решали, свободен ли ключ
+const (
+	// Захват свободного ключа; занятый и не истёкший ключ не меняется, тогда строка не возвращается
+	reserveKeyQuery = `INSERT INTO idempotency_keys (principal, idempotency_key, request_hash, expires_at)
+		VALUES ($1, $2, $3, now() + $4 * interval '1 millisecond')
+		ON CONFLICT (principal, idempotency_key) DO UPDATE SET
+			request_hash = EXCLUDED.request_hash,
+			status_code = NULL,
+			content_type = NULL,
+			response = NULL,
+			expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= now()
+		RETURNING true;`
+	getKeyQuery = `SELECT request_hash, status_code, content_type, response FROM idempotency_keys
+		WHERE principal = $1 AND idempotency_key = $2 AND expires_at > now();`
+	completeKeyQuery = `UPDATE idempotency_keys SET
+			status_code = $3,
+			content_type = $4,
+			response = $5,
+			expires_at = now() + $6 * interval '1 millisecond'
+		WHERE principal = $1 AND idempotency_key = $2 AND status_code IS NULL;`
+	releaseKeyQuery = `DELETE FROM idempotency_keys
+		WHERE principal = $1 AND idempotency_key = $2 AND status_code IS NULL;`
+	purgeKeysQuery = `DELETE FROM idempotency_keys WHERE expires_at <= now();`
+)
+
+// ReserveIdempotencyKey - захват ключа или текущая запись занятого ключа.
+// Повторяется только если запрос не дошёл до сервера: иначе повтор увидит собственный захват
+func (r *repository) ReserveIdempotencyKey(ctx context.Context, principal, key string, hash []byte, lease time.Duration) (*service.IdempotencyRecord, error) {
+	var record *service.IdempotencyRecord
+	err := r.run(ctx, "idempotency_reserve", false, func(ctx context.Context) error {
+		var reserved bool
+		err := r.conn(ctx).QueryRow(ctx, reserveKeyQuery, principal, key, hash, lease.Milliseconds()).Scan(&reserved)
+		if !errors.Is(err, pgx.ErrNoRows) {
+			record = nil
+			return err
+		}
+
+		record, err = scanKey(r.conn(ctx).QueryRow(ctx, getKeyQuery, principal, key))
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Ключ освободился между запросами: отвечаем как на занятый, клиент повторит запрос
+			record, err = &service.IdempotencyRecord{RequestHash: hash}, nil
+		}
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to reserve idempotency key")
+	}
+	return record, nil
+}
+
+// CompleteIdempotencyKey - сохранение ответа. Если захват истёк и ключ занял другой запрос, ответ не сохраняется
+func (r *repository) CompleteIdempotencyKey(ctx context.Context, principal, key string, resp service.IdempotentResponse, ttl time.Duration) error {
+	err := r.run(ctx, "idempotency_complete", true, func(ctx context.Context) error {
+		_, err := r.conn(ctx).Exec(ctx, completeKeyQuery, principal, key, resp.Status, resp.ContentType, resp.Body, ttl.Milliseconds())
+		return err
+	})
+	return errors.Wrap(err, "failed to complete idempotency key")
+}
+
+// ReleaseIdempotencyKey - освобождение захваченного ключа, сохранённый ответ не удаляется
+func (r *repository) ReleaseIdempotencyKey(ctx context.Context, principal, key string) error {
+	err := r.run(ctx, "idempotency_release", true, func(ctx context.Context) error {
+		_, err := r.conn(ctx).Exec(ctx, releaseKeyQuery, principal, key)
+		return err
+	})
+	return errors.Wrap(err, "failed to release idempotency key")
+}
+
+// PurgeIdempotencyKeys - удаление истёкших ключей, возвращает число удалённых
+func (r *repository) PurgeIdempotencyKeys(ctx context.Context) (int, error) {
+	var n int64
+	err := r.run(ctx, "idempotency_purge", true, func(ctx context.Context) error {
+		tag, err := r.conn(ctx).Exec(ctx, purgeKeysQuery)
+		n = tag.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to purge idempotency keys")
+	}
+	return int(n), nil
+}
+
+// scanKey - запись ключа; status_code NULL - запрос ещё выполняется
+func scanKey(row pgx.Row) (*service.IdempotencyRecord, error) {
+	var (
+		record      service.IdempotencyRecord
+		status      *int
+		contentType *string
+		body        []byte
+	)
+	if err := row.Scan(&record.RequestHash, &status, &contentType, &body); err != nil {
+		return nil, err
+	}
+	if status != nil {
+		record.Response = &service.IdempotentResponse{Status: *status, Body: body}
+		if contentType != nil {
+			record.Response.ContentType = *contentType
+		}
+	}
+	return &record, nil
+}
@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"simple-service/internal/service"
+)
+
+// sweepInterval - как часто при захвате ключа удаляются истёкшие ключи
+const sweepInterval = time.Minute
+
+type idempotencyKey struct {
+	principal string
+	key       string
+}
+
+// keyEntry - занятый ключ, response nil - запрос ещё выполняется
+type keyEntry struct {
+	hash     []byte
+	response *service.IdempotentResponse
+	expires  time.Time
+}
+
+// ReserveIdempotencyKey - захват свободного или истёкшего ключа, иначе копия текущей записи
+func (r *repository) ReserveIdempotencyKey(ctx context.Context, principal, key string, hash []byte, lease time.Duration) (*service.IdempotencyRecord, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.keysMu.Lock()
+	defer r.keysMu.Unlock()
+
+	now := r.now()
+	r.sweepKeys(now)
+
+	id := idempotencyKey{principal: principal, key: key}
+	if e, ok := r.keys[id]; ok && now.Before(e.expires) {
+		record := &service.IdempotencyRecord{RequestHash: bytes.Clone(e.hash)}
+		if e.response != nil {
+			resp := *e.response
+			resp.Body = bytes.Clone(resp.Body)
+			record.Response = &resp
+		}
+		return record, nil
+	}
+
+	r.keys[id] = &keyEntry{hash: bytes.Clone(hash), expires: now.Add(lease)}
+	return nil, nil
+}
+
+// CompleteIdempotencyKey - сохранение ответа захваченного ключа
+func (r *repository) CompleteIdempotencyKey(ctx context.Context, principal, key string, resp service.IdempotentResponse, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.keysMu.Lock()
+	defer r.keysMu.Unlock()
+
+	if e, ok := r.keys[idempotencyKey{principal: principal, key: key}]; ok && e.response == nil {
+		resp.Body = bytes.Clone(resp.Body)
+		e.response = &resp
+		e.expires = r.now().Add(ttl)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey - освобождение захваченного ключа, сохранённый ответ не удаляется
+func (r *repository) ReleaseIdempotencyKey(ctx context.Context, principal, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.keysMu.Lock()
+	defer r.keysMu.Unlock()
+
+	id := idempotencyKey{principal: principal, key: key}
+	if e, ok := r.keys[id]; ok && e.response == nil {
+		delete(r.keys, id)
+	}
+	return nil
+}
+
+// sweepKeys - удаление истёкших ключей не чаще sweepInterval, вызывается под keysMu
+func (r *repository) sweepKeys(now time.Time) {
+	if now.Sub(r.lastSweep) < sweepInterval {
+		return
+	}
+	r.lastSweep = now
+	for id, e := range r.keys {
+		if !now.Before(e.expires) {
+			delete(r.keys, id)
+		}
+	}
+}
@@ -0,0 +1,418 @@
+// Package memory - хранилище задач в памяти процесса с той же семантикой, что и PostgreSQL:
+// ID выдаются по возрастанию начиная с 1, новая задача получает статус new,
+// created_at и updated_at выставляет хранилище. Данные теряются при перезапуске
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"simple-service/internal/service"
+)
+
+// statuses - допустимые статусы, как в CHECK ограничении таблицы tasks
+var statuses = map[string]struct{}{
+	service.StatusNew:        {},
+	service.StatusInProgress: {},
+	service.StatusDone:       {},
+}
+
+type repository struct {
+	mu     sync.RWMutex
+	tasks  map[int]service.TaskResponse
+	nextID int
+
+	// outbox - недоставленные события по возрастанию ID, меняется вместе с задачами под mu
+	outbox      []*outboxEntry
+	nextEventID int64
+
+	// Ключи идемпотентности не участвуют в транзакциях задач и блокируются отдельно
+	keysMu    sync.Mutex
+	keys      map[idempotencyKey]*keyEntry
+	lastSweep time.Time
+
+	// Подписки и доставки тоже блокируются отдельно
+	hooksMu  sync.Mutex
+	webhooks webhooks
+
+	// now подменяется в тестах
+	now func() time.Time
+}
+
+// NewRepository - пустое хранилище
+func NewRepository() *repository {
+	return &repository{
+		tasks:       make(map[int]service.TaskResponse),
+		nextID:      1,
+		nextEventID: 1,
+		keys:        make(map[idempotencyKey]*keyEntry),
+		webhooks:    newWebhooks(),
+		now:         time.Now,
+	}
+}
+
+// Close - для совместимости с PostgreSQL репозиторием, ресурсов не держит
+func (r *repository) Close() {}
+
+// Ready - хранилище в памяти всегда готово
+func (r *repository) Ready(context.Context) error {
+	return nil
+}
+
+// tx - транзакция в контексте
+type tx struct {
+	repo     *repository
+	readOnly bool
+}
+
+type txKey struct{}
+
+// errReadOnly - запись в транзакции только для чтения, как SQLSTATE 25006 в PostgreSQL
+var errReadOnly = errors.New("cannot execute write in a read-only transaction")
+
+// WithinTx - транзакции выполняются по одной с исключительной блокировкой хранилища (serializable
+// при любом уровне изоляции). Ошибка fn восстанавливает задачи на момент начала транзакции,
+// выданные ID, как и sequence в PostgreSQL, не возвращаются
+func (r *repository) WithinTx(ctx context.Context, opts service.TxOptions, fn func(ctx context.Context) error) error {
+	if r.current(ctx) != nil {
+		return fn(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	snapshot := make(map[int]service.TaskResponse, len(r.tasks))
+	for id, task := range r.tasks {
+		snapshot[id] = task
+	}
+	// В транзакции события только добавляются, откат отбрасывает добавленные
+	events := len(r.outbox)
+
+	if err := fn(context.WithValue(ctx, txKey{}, &tx{repo: r, readOnly: opts.ReadOnly})); err != nil {
+		r.tasks = snapshot
+		r.outbox = r.outbox[:events]
+		return err
+	}
+	return nil
+}
+
+// current - транзакция этого хранилища в контексте
+func (r *repository) current(ctx context.Context) *tx {
+	if t, ok := ctx.Value(txKey{}).(*tx); ok && t.repo == r {
+		return t
+	}
+	return nil
+}
+
+// lock - блокировка для записи, внутри транзакции она уже захвачена в WithinTx
+func (r *repository) lock(ctx context.Context) (func(), error) {
+	if t := r.current(ctx); t != nil {
+		if t.readOnly {
+			return nil, errReadOnly
+		}
+		return func() {}, nil
+	}
+	r.mu.Lock()
+	return r.mu.Unlock, nil
+}
+
+// rlock - блокировка для чтения
+func (r *repository) rlock(ctx context.Context) func() {
+	if r.current(ctx) != nil {
+		return func() {}
+	}
+	r.mu.RLock()
+	return r.mu.RUnlock
+}
+
+// timestamp - текущее время с точностью PostgreSQL TIMESTAMP (микросекунды) в UTC
+func (r *repository) timestamp() time.Time {
+	return r.now().UTC().Truncate(time.Microsecond)
+}
+
+// CreateTask - добавление задачи со статусом new
+func (r *repository) CreateTask(ctx context.Context, task service.Task) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	unlock, err := r.lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	return r.insert(task, r.timestamp()), nil
+}
+
+// CreateTasks - добавление задач под одной блокировкой, у всех задач одно время создания,
+// как у строк, вставленных одной транзакцией PostgreSQL
+func (r *repository) CreateTasks(ctx context.Context, tasks []service.Task) ([]int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	now := r.timestamp()
+	ids := make([]int, 0, len(tasks))
+	for _, task := range tasks {
+		ids = append(ids, r.insert(task, now))
+	}
+
+	return ids, nil
+}
+
+// insert - новая задача со статусом new, вызывается под блокировкой для записи
+func (r *repository) insert(task service.Task, now time.Time) int {
+	id := r.nextID
+	r.nextID++
+
+	r.tasks[id] = service.TaskResponse{
+		ID:          id,
+		Title:       task.Title,
+		Description: task.Description,
+		Status:      service.StatusNew,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	return id
+}
+
+// GetTask - задача по ID, service.ErrTaskNotFound если её нет
+func (r *repository) GetTask(ctx context.Context, id int) (*service.TaskResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	defer r.rlock(ctx)()
+
+	task, ok := r.tasks[id]
+	if !ok {
+		return nil, service.ErrTaskNotFound
+	}
+	return &task, nil
+}
+
+// GetTasks - задачи из ids по возрастанию ID, отсутствующие пропускаются
+func (r *repository) GetTasks(ctx context.Context, ids []int) ([]service.TaskResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	defer r.rlock(ctx)()
+
+	tasks := make([]service.TaskResponse, 0, len(ids))
+	for _, id := range uniqueIDs(ids) {
+		if task, ok := r.tasks[id]; ok {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks, nil
+}
+
+// ListTasks - страница задач, отсортированных по ID
+func (r *repository) ListTasks(ctx context.Context, filter service.ListFilter) ([]service.TaskResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	unlock := r.rlock(ctx)
+	matched := make([]service.TaskResponse, 0, len(r.tasks))
+	for _, task := range r.tasks {
+		if filter.Status == "" || task.Status == filter.Status {
+			matched = append(matched, task)
+		}
+	}
+	unlock()
+
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID < matched[j].ID })
+
+	if filter.Offset >= len(matched) {
+		return []service.TaskResponse{}, nil
+	}
+	matched = matched[filter.Offset:]
+	if len(matched) > filter.Limit {
+		matched = matched[:filter.Limit]
+	}
+
+	return matched, nil
+}
+
+// UpdateTask - обновление переданных полей задачи и updated_at
+func (r *repository) UpdateTask(ctx context.Context, id int, update service.TaskUpdate) (*service.TaskResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	task, ok := r.tasks[id]
+	if !ok {
+		return nil, service.ErrTaskNotFound
+	}
+	if err := checkStatus(update); err != nil {
+		return nil, errors.Wrap(err, "failed to update task")
+	}
+
+	task = applyUpdate(task, update, r.timestamp())
+	r.tasks[id] = task
+
+	return &task, nil
+}
+
+// checkStatus - статус из update допустим, как проверяет CHECK ограничение
+func checkStatus(update service.TaskUpdate) error {
+	if update.Status != nil {
+		if _, ok := statuses[*update.Status]; !ok {
+			return errors.Errorf("invalid status %q", *update.Status)
+		}
+	}
+	return nil
+}
+
+// applyUpdate - задача с переданными полями update и новым updated_at
+func applyUpdate(task service.TaskResponse, update service.TaskUpdate, now time.Time) service.TaskResponse {
+	if update.Title != nil {
+		task.Title = *update.Title
+	}
+	if update.Description != nil {
+		task.Description = *update.Description
+	}
+	if update.Status != nil {
+		task.Status = *update.Status
+	}
+	task.UpdatedAt = now
+	return task
+}
+
+// DeleteTask - удаление задачи, ID удалённых задач повторно не выдаются
+func (r *repository) DeleteTask(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, ok := r.tasks[id]; !ok {
+		return service.ErrTaskNotFound
+	}
+	delete(r.tasks, id)
+
+	return nil
+}
+
+// FindTaskIDs - ID задач, подходящих под sel, по возрастанию
+func (r *repository) FindTaskIDs(ctx context.Context, sel service.TaskSelector) ([]int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	unlock := r.rlock(ctx)
+	ids := make([]int, 0)
+	for id, task := range r.tasks {
+		if matches(task, sel) {
+			ids = append(ids, id)
+		}
+	}
+	unlock()
+
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// matches - задача подходит под все заданные условия sel
+func matches(task service.TaskResponse, sel service.TaskSelector) bool {
+	if len(sel.IDs) > 0 && !slices.Contains(sel.IDs, task.ID) {
+		return false
+	}
+	f := sel.Filter
+	if f.Status != "" && task.Status != f.Status {
+		return false
+	}
+	if f.CreatedAfter != nil && task.CreatedAt.Before(*f.CreatedAfter) {
+		return false
+	}
+	if f.CreatedBefore != nil && !task.CreatedAt.Before(*f.CreatedBefore) {
+		return false
+	}
+	return true
+}
+
+// UpdateTasks - обновление задач из ids, отсутствующие ID пропускаются
+func (r *repository) UpdateTasks(ctx context.Context, ids []int, update service.TaskUpdate) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if err := checkStatus(update); err != nil {
+		return 0, errors.Wrap(err, "failed to update tasks")
+	}
+
+	unlock, err := r.lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	now := r.timestamp()
+	updated := 0
+	for _, id := range uniqueIDs(ids) {
+		if task, ok := r.tasks[id]; ok {
+			r.tasks[id] = applyUpdate(task, update, now)
+			updated++
+		}
+	}
+
+	return updated, nil
+}
+
+// DeleteTasks - удаление задач из ids, отсутствующие ID пропускаются
+func (r *repository) DeleteTasks(ctx context.Context, ids []int) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	unlock, err := r.lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	deleted := 0
+	for _, id := range uniqueIDs(ids) {
+		if _, ok := r.tasks[id]; ok {
+			delete(r.tasks, id)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// uniqueIDs - ids без повторов: как и id = ANY($1) в PostgreSQL, повтор не затрагивает строку дважды
+func uniqueIDs(ids []int) []int {
+	unique := slices.Clone(ids)
+	slices.Sort(unique)
+	return slices.Compact(unique)
+}
@@ -0,0 +1,88 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"simple-service/internal/repo/repotest"
+	"simple-service/internal/service"
+)
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(*testing.T) service.Repository {
+		return NewRepository()
+	})
+}
+
+// TestTimestamps - время как у PostgreSQL TIMESTAMP: UTC с точностью до микросекунд
+func TestTimestamps(t *testing.T) {
+	ctx := context.Background()
+	created := time.Date(2024, 1, 15, 10, 30, 0, 123456789, time.FixedZone("MSK", 3*60*60))
+
+	r := NewRepository()
+	r.now = func() time.Time { return created }
+
+	id, err := r.CreateTask(ctx, service.Task{Title: "first"})
+	require.NoError(t, err)
+
+	want := time.Date(2024, 1, 15, 7, 30, 0, 123456000, time.UTC)
+	task, err := r.GetTask(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, want, task.CreatedAt)
+	assert.Equal(t, want, task.UpdatedAt)
+
+	r.now = func() time.Time { return created.Add(time.Minute) }
+	status := service.StatusDone
+	task, err = r.UpdateTask(ctx, id, service.TaskUpdate{Status: &status})
+	require.NoError(t, err)
+	assert.Equal(t, want, task.CreatedAt)
+	assert.Equal(t, want.Add(time.Minute), task.UpdatedAt)
+}
+
+// TestIdempotencyKeys - захват, истечение захвата и сохранённого ответа
+func TestIdempotencyKeys(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	r := NewRepository()
+	r.now = func() time.Time { return now }
+
+	hash := []byte("hash")
+	record, err := r.ReserveIdempotencyKey(ctx, "alice", "k1", hash, time.Minute)
+	require.NoError(t, err)
+	assert.Nil(t, record, "свободный ключ захватывается")
+
+	record, err = r.ReserveIdempotencyKey(ctx, "alice", "k1", []byte("other"), time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	assert.Equal(t, hash, record.RequestHash)
+	assert.Nil(t, record.Response, "запрос ещё выполняется")
+
+	record, err = r.ReserveIdempotencyKey(ctx, "bob", "k1", hash, time.Minute)
+	require.NoError(t, err)
+	assert.Nil(t, record, "ключи субъектов независимы")
+
+	now = now.Add(time.Minute)
+	record, err = r.ReserveIdempotencyKey(ctx, "alice", "k1", hash, time.Minute)
+	require.NoError(t, err)
+	assert.Nil(t, record, "истёкший захват освобождается")
+
+	resp := service.IdempotentResponse{Status: 200, ContentType: "application/json", Body: []byte(`{}`)}
+	require.NoError(t, r.CompleteIdempotencyKey(ctx, "alice", "k1", resp, time.Hour))
+	require.NoError(t, r.ReleaseIdempotencyKey(ctx, "alice", "k1"))
+
+	now = now.Add(59 * time.Minute)
+	record, err = r.ReserveIdempotencyKey(ctx, "alice", "k1", hash, time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	assert.Equal(t, &resp, record.Response, "ответ хранится ttl и не удаляется Release")
+
+	now = now.Add(time.Minute)
+	record, err = r.ReserveIdempotencyKey(ctx, "alice", "k1", hash, time.Minute)
+	require.NoError(t, err)
+	assert.Nil(t, record, "ответ истёк")
+}
@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"simple-service/internal/service"
+)
+
+// outboxEntry - недоставленное событие, nextAttempt - когда его можно выбрать для доставки
+type outboxEntry struct {
+	event       service.Event
+	nextAttempt time.Time
+}
+
+// AppendEvents - события получают следующие ID и время записи
+func (r *repository) AppendEvents(ctx context.Context, events []service.Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	unlock, err := r.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	now := r.now().UTC()
+	for _, event := range events {
+		event.ID = r.nextEventID
+		r.nextEventID++
+		event.OccurredAt = now
+		event.Payload = slices.Clone(event.Payload)
+		r.outbox = append(r.outbox, &outboxEntry{event: event, nextAttempt: now})
+	}
+	return nil
+}
+
+// ClaimEvents - до limit готовых событий по возрастанию ID, пропуская задачи, более раннее
+// событие которых ждёт повтора или уже выбрано. Выбранные события недоступны lease
+func (r *repository) ClaimEvents(ctx context.Context, limit int, lease time.Duration) ([]service.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	blocked := make(map[int]struct{})
+	claimed := make([]service.Event, 0, limit)
+	for _, e := range r.outbox {
+		if len(claimed) == limit {
+			break
+		}
+		if _, ok := blocked[e.event.TaskID]; ok {
+			continue
+		}
+		if e.nextAttempt.After(now) {
+			blocked[e.event.TaskID] = struct{}{}
+			continue
+		}
+		e.nextAttempt = now.Add(lease)
+		event := e.event
+		event.Payload = slices.Clone(event.Payload)
+		claimed = append(claimed, event)
+	}
+	return claimed, nil
+}
+
+// CompleteEvents - удаление доставленных событий
+func (r *repository) CompleteEvents(ctx context.Context, ids []int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.outbox = slices.DeleteFunc(r.outbox, func(e *outboxEntry) bool {
+		return slices.Contains(ids, e.event.ID)
+	})
+	return nil
+}
+
+// RetryEvent - следующая попытка доставки через delay, причина неудачи не хранится
+func (r *repository) RetryEvent(ctx context.Context, id int64, delay time.Duration, reason string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if e := r.findEvent(id); e != nil {
+		e.event.Attempts++
+		e.nextAttempt = r.now().Add(delay)
+	}
+	return nil
+}
+
+// ReleaseEvents - выбранные события снова доступны для выбора
+func (r *repository) ReleaseEvents(ctx context.Context, ids []int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	for _, id := range ids {
+		if e := r.findEvent(id); e != nil {
+			e.nextAttempt = now
+		}
+	}
+	return nil
+}
+
+// findEvent - событие по ID, вызывается под блокировкой
+func (r *repository) findEvent(id int64) *outboxEntry {
+	i, found := slices.BinarySearchFunc(r.outbox, id, func(e *outboxEntry, id int64) int {
+		return cmp.Compare(e.event.ID, id)
+	})
+	if !found {
+		return nil
+	}
+	return r.outbox[i]
+}
@@ -0,0 +1,103 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"simple-service/internal/service"
+)
+
+// exportBatchSize - задач в порции курсора, как FETCH в PostgreSQL
+const exportBatchSize = 500
+
+// ExportTasks - курсор по копии подходящих задач на момент вызова
+func (r *repository) ExportTasks(ctx context.Context, filter service.TaskFilter) (service.TaskCursor, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sel := service.TaskSelector{Filter: filter}
+	unlock := r.rlock(ctx)
+	tasks := make([]service.TaskResponse, 0)
+	for _, task := range r.tasks {
+		if matches(task, sel) {
+			tasks = append(tasks, task)
+		}
+	}
+	unlock()
+
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+	return &taskCursor{tasks: tasks}, nil
+}
+
+// taskCursor - порции снимка задач
+type taskCursor struct {
+	tasks []service.TaskResponse
+}
+
+// Next - следующие exportBatchSize задач
+func (c *taskCursor) Next(ctx context.Context) ([]service.TaskResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	n := min(exportBatchSize, len(c.tasks))
+	batch := c.tasks[:n:n]
+	c.tasks = c.tasks[n:]
+	return batch, nil
+}
+
+// Close - курсор ресурсов не держит
+func (c *taskCursor) Close(context.Context) error {
+	return nil
+}
+
+// ImportTasks - вставка задач со всеми полями под одной блокировкой. Задача с занятым ID пропускается,
+// nextID сдвигается за наибольший загруженный ID, как sequence в PostgreSQL
+func (r *repository) ImportTasks(ctx context.Context, tasks []service.TaskResponse) ([]int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	for i, task := range tasks {
+		if _, ok := statuses[task.Status]; !ok {
+			return nil, errors.Errorf("failed to import tasks: task %d: invalid status %q", i, task.Status)
+		}
+	}
+
+	unlock, err := r.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	now := r.timestamp()
+	for _, task := range tasks {
+		r.nextID = max(r.nextID, task.ID+1)
+	}
+
+	ids := make([]int, len(tasks))
+	for i, task := range tasks {
+		if task.ID == 0 {
+			task.ID = r.nextID
+			r.nextID++
+		} else if _, ok := r.tasks[task.ID]; ok {
+			continue
+		}
+		task.CreatedAt = importTime(task.CreatedAt, now)
+		task.UpdatedAt = importTime(task.UpdatedAt, now)
+		r.tasks[task.ID] = task
+		ids[i] = task.ID
+	}
+
+	return ids, nil
+}
+
+// importTime - время задачи с точностью PostgreSQL TIMESTAMP, нулевое время - now
+func importTime(t, now time.Time) time.Time {
+	if t.IsZero() {
+		return now
+	}
+	return t.UTC().Truncate(time.Microsecond)
+}
@@ -0,0 +1,378 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"simple-service/internal/service"
+)
+
+// webhookEntry - подписка с секретом подписи
+type webhookEntry struct {
+	hook   service.Webhook
+	secret string
+}
+
+// deliveryKey - доставка события подписке создаётся один раз
+type deliveryKey struct {
+	webhookID int
+	eventID   int64
+}
+
+// taskKey - доставки задачи одной подписке отправляются по порядку
+type taskKey struct {
+	webhookID int
+	taskID    int
+}
+
+// webhooks - подписки и доставки. Не участвуют в транзакциях задач и блокируются отдельно
+type webhooks struct {
+	hooks      map[int]*webhookEntry
+	nextHookID int
+	// deliveries - по возрастанию ID
+	deliveries []*service.Delivery
+	enqueued   map[deliveryKey]struct{}
+	// tasks - задача события доставки по ID доставки
+	tasks          map[int64]int
+	nextDeliveryID int64
+}
+
+func newWebhooks() webhooks {
+	return webhooks{
+		hooks:          make(map[int]*webhookEntry),
+		nextHookID:     1,
+		enqueued:       make(map[deliveryKey]struct{}),
+		tasks:          make(map[int64]int),
+		nextDeliveryID: 1,
+	}
+}
+
+// CreateWebhook - подписка получает следующий ID
+func (r *repository) CreateWebhook(ctx context.Context, hook service.NewWebhook) (*service.Webhook, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.hooksMu.Lock()
+	defer r.hooksMu.Unlock()
+
+	now := r.timestamp()
+	entry := &webhookEntry{
+		hook: service.Webhook{
+			ID:         r.webhooks.nextHookID,
+			URL:        hook.URL,
+			EventTypes: slices.Clone(hook.EventTypes),
+			Active:     hook.Active,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		},
+		secret: hook.Secret,
+	}
+	r.webhooks.nextHookID++
+	r.webhooks.hooks[entry.hook.ID] = entry
+
+	return cloneWebhook(entry.hook), nil
+}
+
+// GetWebhook - подписка по ID, service.ErrWebhookNotFound если её нет
+func (r *repository) GetWebhook(ctx context.Context, id int) (*service.Webhook, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.hooksMu.Lock()
+	defer r.hooksMu.Unlock()
+
+	entry, ok := r.webhooks.hooks[id]
+	if !ok {
+		return nil, service.ErrWebhookNotFound
+	}
+	return cloneWebhook(entry.hook), nil
+}
+
+// ListWebhooks - все подписки по возрастанию ID
+func (r *repository) ListWebhooks(ctx context.Context) ([]service.Webhook, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.hooksMu.Lock()
+	defer r.hooksMu.Unlock()
+
+	hooks := make([]service.Webhook, 0, len(r.webhooks.hooks))
+	for _, entry := range r.webhooks.hooks {
+		hooks = append(hooks, *cloneWebhook(entry.hook))
+	}
+	slices.SortFunc(hooks, func(a, b service.Webhook) int { return a.ID - b.ID })
+	return hooks, nil
+}
+
+// UpdateWebhook - изменение переданных полей подписки и updated_at
+func (r *repository) UpdateWebhook(ctx context.Context, id int, update service.WebhookUpdate) (*service.Webhook, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.hooksMu.Lock()
+	defer r.hooksMu.Unlock()
+
+	entry, ok := r.webhooks.hooks[id]
+	if !ok {
+		return nil, service.ErrWebhookNotFound
+	}
+	if update.URL != nil {
+		entry.hook.URL = *update.URL
+	}
+	if update.EventTypes != nil {
+		entry.hook.EventTypes = slices.Clone(*update.EventTypes)
+	}
+	if update.Secret != nil {
+		entry.secret = *update.Secret
+	}
+	if update.Active != nil {
+		entry.hook.Active = *update.Active
+	}
+	entry.hook.UpdatedAt = r.timestamp()
+
+	return cloneWebhook(entry.hook), nil
+}
+
+// DeleteWebhook - удаление подписки и её доставок
+func (r *repository) DeleteWebhook(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.hooksMu.Lock()
+	defer r.hooksMu.Unlock()
+
+	if _, ok := r.webhooks.hooks[id]; !ok {
+		return service.ErrWebhookNotFound
+	}
+	delete(r.webhooks.hooks, id)
+	r.webhooks.deliveries = slices.DeleteFunc(r.webhooks.deliveries, func(d *service.Delivery) bool {
+		if d.WebhookID != id {
+			return false
+		}
+		delete(r.webhooks.enqueued, deliveryKey{webhookID: d.WebhookID, eventID: d.EventID})
+		delete(r.webhooks.tasks, d.ID)
+		return true
+	})
+	return nil
+}
+
+// ListDeliveries - доставки подписки по убыванию ID
+func (r *repository) ListDeliveries(ctx context.Context, webhookID int, filter service.DeliveryFilter) ([]service.Delivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.hooksMu.Lock()
+	defer r.hooksMu.Unlock()
+
+	if _, ok := r.webhooks.hooks[webhookID]; !ok {
+		return nil, service.ErrWebhookNotFound
+	}
+
+	deliveries := make([]service.Delivery, 0)
+	skipped := 0
+	for _, d := range slices.Backward(r.webhooks.deliveries) {
+		if len(deliveries) == filter.Limit {
+			break
+		}
+		if d.WebhookID != webhookID || (filter.Status != "" && d.Status != filter.Status) {
+			continue
+		}
+		if skipped < filter.Offset {
+			skipped++
+			continue
+		}
+		deliveries = append(deliveries, cloneDelivery(d))
+	}
+	return deliveries, nil
+}
+
+// ReplayDelivery - доставка снова ждёт отправки с нулевым счётчиком попыток.
+// Выбранную для отправки или ждущую повтора доставку повторить нельзя
+func (r *repository) ReplayDelivery(ctx context.Context, webhookID int, id int64) (*service.Delivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.hooksMu.Lock()
+	defer r.hooksMu.Unlock()
+
+	d := r.findDelivery(id)
+	if d == nil || d.WebhookID != webhookID {
+		return nil, service.ErrDeliveryNotFound
+	}
+	now := r.timestamp()
+	if d.Status == service.DeliveryPending && d.NextAttemptAt.After(now) {
+		return nil, service.ErrDeliveryInFlight
+	}
+	d.Status = service.DeliveryPending
+	d.Attempts = 0
+	d.NextAttemptAt = now
+	d.DeliveredAt = nil
+
+	delivery := cloneDelivery(d)
+	return &delivery, nil
+}
+
+// EnqueueDeliveries - доставка каждой активной подписке на eventType, кроме уже созданных для eventID
+func (r *repository) EnqueueDeliveries(ctx context.Context, eventID int64, eventType string, taskID int, body []byte) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	r.hooksMu.Lock()
+	defer r.hooksMu.Unlock()
+
+	ids := make([]int, 0, len(r.webhooks.hooks))
+	for id, entry := range r.webhooks.hooks {
+		if entry.hook.Active && entry.hook.Subscribed(eventType) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+
+	now := r.timestamp()
+	created := 0
+	for _, id := range ids {
+		key := deliveryKey{webhookID: id, eventID: eventID}
+		if _, ok := r.webhooks.enqueued[key]; ok {
+			continue
+		}
+		r.webhooks.enqueued[key] = struct{}{}
+		r.webhooks.tasks[r.webhooks.nextDeliveryID] = taskID
+		r.webhooks.deliveries = append(r.webhooks.deliveries, &service.Delivery{
+			ID:            r.webhooks.nextDeliveryID,
+			WebhookID:     id,
+			EventID:       eventID,
+			EventType:     eventType,
+			Payload:       slices.Clone(body),
+			Status:        service.DeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+		r.webhooks.nextDeliveryID++
+		created++
+	}
+	return created, nil
+}
+
+// ClaimDeliveries - до limit готовых доставок активных подписок по возрастанию ID.
+// Доставка ждёт, пока подписке не отправлены предыдущие доставки той же задачи
+func (r *repository) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]service.PendingDelivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.hooksMu.Lock()
+	defer r.hooksMu.Unlock()
+
+	now := r.timestamp()
+	claimed := make([]service.PendingDelivery, 0, limit)
+	// waiting - подписка и задача, у которых уже встретилась ждущая доставка
+	waiting := make(map[taskKey]struct{})
+	for _, d := range r.webhooks.deliveries {
+		if len(claimed) == limit {
+			break
+		}
+		if d.Status != service.DeliveryPending {
+			continue
+		}
+		task := taskKey{webhookID: d.WebhookID, taskID: r.webhooks.tasks[d.ID]}
+		if _, ok := waiting[task]; ok {
+			continue
+		}
+		waiting[task] = struct{}{}
+
+		entry := r.webhooks.hooks[d.WebhookID]
+		if d.NextAttemptAt.After(now) || entry == nil || !entry.hook.Active {
+			continue
+		}
+		d.NextAttemptAt = now.Add(lease)
+		claimed = append(claimed, service.PendingDelivery{
+			Delivery: cloneDelivery(d),
+			URL:      entry.hook.URL,
+			Secret:   entry.secret,
+		})
+	}
+	return claimed, nil
+}
+
+// RecordAttempt - результат попытки, удалённая вместе с подпиской доставка пропускается
+func (r *repository) RecordAttempt(ctx context.Context, id int64, attempt service.DeliveryAttempt) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.hooksMu.Lock()
+	defer r.hooksMu.Unlock()
+
+	d := r.findDelivery(id)
+	if d == nil {
+		return nil
+	}
+	now := r.timestamp()
+	d.Status = attempt.Status
+	d.Attempts++
+	d.LastStatusCode = attempt.StatusCode
+	d.LastError = attempt.Error
+	d.NextAttemptAt = now.Add(attempt.RetryIn)
+	if attempt.Status == service.DeliveryDelivered {
+		d.DeliveredAt = &now
+	}
+	return nil
+}
+
+// PurgeDeliveries - удаление завершённых доставок, созданных раньше retention назад
+func (r *repository) PurgeDeliveries(ctx context.Context, retention time.Duration) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	r.hooksMu.Lock()
+	defer r.hooksMu.Unlock()
+
+	cutoff := r.now().Add(-retention)
+	before := len(r.webhooks.deliveries)
+	r.webhooks.deliveries = slices.DeleteFunc(r.webhooks.deliveries, func(d *service.Delivery) bool {
+		if d.Status == service.DeliveryPending || !d.CreatedAt.Before(cutoff) {
+			return false
+		}
+		delete(r.webhooks.enqueued, deliveryKey{webhookID: d.WebhookID, eventID: d.EventID})
+		delete(r.webhooks.tasks, d.ID)
+		return true
+	})
+	return before - len(r.webhooks.deliveries), nil
+}
+
+// findDelivery - доставка по ID, вызывается под hooksMu
+func (r *repository) findDelivery(id int64) *service.Delivery {
+	i, found := slices.BinarySearchFunc(r.webhooks.deliveries, id, func(d *service.Delivery, id int64) int {
+		return cmp.Compare(d.ID, id)
+	})
+	if !found {
+		return nil
+	}
+	return r.webhooks.deliveries[i]
+}
+
+func cloneWebhook(hook service.Webhook) *service.Webhook {
+	hook.EventTypes = slices.Clone(hook.EventTypes)
+	return &hook
+}
+
+func cloneDelivery(d *service.Delivery) service.Delivery {
+	delivery := *d
+	delivery.Payload = slices.Clone(d.Payload)
+	if d.DeliveredAt != nil {
+		at := *d.DeliveredAt
+		delivery.DeliveredAt = &at
+	}
+	return delivery
+}
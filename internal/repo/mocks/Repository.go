@@ -42,6 +42,142 @@ func (_m *Repository) CreateTask(ctx context.Context, task service.Task) (int, e
 	return r0, r1
 }
 
+// CreateTasks provides a mock function with given fields: ctx, tasks
+func (_m *Repository) CreateTasks(ctx context.Context, tasks []service.Task) ([]int, error) {
+	ret := _m.Called(ctx, tasks)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateTasks")
+	}
+
+	var r0 []int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []service.Task) ([]int, error)); ok {
+		return rf(ctx, tasks)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []service.Task) []int); ok {
+		r0 = rf(ctx, tasks)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]int)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []service.Task) error); ok {
+		r1 = rf(ctx, tasks)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteTask provides a mock function with given fields: ctx, id
+func (_m *Repository) DeleteTask(ctx context.Context, id int) error {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteTask")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteTasks provides a mock function with given fields: ctx, ids
+func (_m *Repository) DeleteTasks(ctx context.Context, ids []int) (int, error) {
+	ret := _m.Called(ctx, ids)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteTasks")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []int) (int, error)); ok {
+		return rf(ctx, ids)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []int) int); ok {
+		r0 = rf(ctx, ids)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []int) error); ok {
+		r1 = rf(ctx, ids)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ExportTasks provides a mock function with given fields: ctx, filter
+func (_m *Repository) ExportTasks(ctx context.Context, filter service.TaskFilter) (service.TaskCursor, error) {
+	ret := _m.Called(ctx, filter)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ExportTasks")
+	}
+
+	var r0 service.TaskCursor
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, service.TaskFilter) (service.TaskCursor, error)); ok {
+		return rf(ctx, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, service.TaskFilter) service.TaskCursor); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(service.TaskCursor)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, service.TaskFilter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindTaskIDs provides a mock function with given fields: ctx, sel
+func (_m *Repository) FindTaskIDs(ctx context.Context, sel service.TaskSelector) ([]int, error) {
+	ret := _m.Called(ctx, sel)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindTaskIDs")
+	}
+
+	var r0 []int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, service.TaskSelector) ([]int, error)); ok {
+		return rf(ctx, sel)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, service.TaskSelector) []int); ok {
+		r0 = rf(ctx, sel)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]int)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, service.TaskSelector) error); ok {
+		r1 = rf(ctx, sel)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetTask provides a mock function with given fields: ctx, id
 func (_m *Repository) GetTask(ctx context.Context, id int) (*service.TaskResponse, error) {
 	ret := _m.Called(ctx, id)
@@ -72,6 +208,172 @@ func (_m *Repository) GetTask(ctx context.Context, id int) (*service.TaskRespons
 	return r0, r1
 }
 
+// GetTasks provides a mock function with given fields: ctx, ids
+func (_m *Repository) GetTasks(ctx context.Context, ids []int) ([]service.TaskResponse, error) {
+	ret := _m.Called(ctx, ids)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetTasks")
+	}
+
+	var r0 []service.TaskResponse
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []int) ([]service.TaskResponse, error)); ok {
+		return rf(ctx, ids)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []int) []service.TaskResponse); ok {
+		r0 = rf(ctx, ids)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]service.TaskResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []int) error); ok {
+		r1 = rf(ctx, ids)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ImportTasks provides a mock function with given fields: ctx, tasks
+func (_m *Repository) ImportTasks(ctx context.Context, tasks []service.TaskResponse) ([]int, error) {
+	ret := _m.Called(ctx, tasks)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ImportTasks")
+	}
+
+	var r0 []int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []service.TaskResponse) ([]int, error)); ok {
+		return rf(ctx, tasks)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []service.TaskResponse) []int); ok {
+		r0 = rf(ctx, tasks)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]int)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []service.TaskResponse) error); ok {
+		r1 = rf(ctx, tasks)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListTasks provides a mock function with given fields: ctx, filter
+func (_m *Repository) ListTasks(ctx context.Context, filter service.ListFilter) ([]service.TaskResponse, error) {
+	ret := _m.Called(ctx, filter)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListTasks")
+	}
+
+	var r0 []service.TaskResponse
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, service.ListFilter) ([]service.TaskResponse, error)); ok {
+		return rf(ctx, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, service.ListFilter) []service.TaskResponse); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]service.TaskResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, service.ListFilter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateTask provides a mock function with given fields: ctx, id, update
+func (_m *Repository) UpdateTask(ctx context.Context, id int, update service.TaskUpdate) (*service.TaskResponse, error) {
+	ret := _m.Called(ctx, id, update)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateTask")
+	}
+
+	var r0 *service.TaskResponse
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, service.TaskUpdate) (*service.TaskResponse, error)); ok {
+		return rf(ctx, id, update)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, service.TaskUpdate) *service.TaskResponse); ok {
+		r0 = rf(ctx, id, update)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*service.TaskResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, service.TaskUpdate) error); ok {
+		r1 = rf(ctx, id, update)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateTasks provides a mock function with given fields: ctx, ids, update
+func (_m *Repository) UpdateTasks(ctx context.Context, ids []int, update service.TaskUpdate) (int, error) {
+	ret := _m.Called(ctx, ids, update)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateTasks")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []int, service.TaskUpdate) (int, error)); ok {
+		return rf(ctx, ids, update)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []int, service.TaskUpdate) int); ok {
+		r0 = rf(ctx, ids, update)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []int, service.TaskUpdate) error); ok {
+		r1 = rf(ctx, ids, update)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// WithinTx provides a mock function with given fields: ctx, opts, fn
+func (_m *Repository) WithinTx(ctx context.Context, opts service.TxOptions, fn func(context.Context) error) error {
+	ret := _m.Called(ctx, opts, fn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WithinTx")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, service.TxOptions, func(context.Context) error) error); ok {
+		r0 = rf(ctx, opts, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewRepository creates a new instance of Repository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewRepository(t interface {
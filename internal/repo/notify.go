@@ -0,0 +1,47 @@
+package repo
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// TaskChangesChannel - канал NOTIFY, в который триггер таблицы tasks пишет ID изменённой задачи
+const TaskChangesChannel = "tasks_changed"
+
+// ListenTaskChanges - подписка на изменения задач, в том числе сделанные другими репликами.
+// Соединение забирается из пула на всё время подписки. reset вызывается после подписки:
+// изменения до неё могли быть пропущены. changed вызывается с ID задачи, reset - если payload не разобран.
+// Возвращает ошибку при потере соединения и nil после отмены ctx, переподключение - на вызывающем
+func (r *repository) ListenTaskChanges(ctx context.Context, changed func(id int), reset func()) error {
+	pooled, err := r.pool.Acquire(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to acquire connection for LISTEN")
+	}
+	// Соединение с активным LISTEN не возвращается в пул
+	conn := pooled.Hijack()
+	defer conn.Close(context.WithoutCancel(ctx))
+
+	if _, err := conn.Exec(ctx, "LISTEN "+TaskChangesChannel); err != nil {
+		return errors.Wrap(err, "failed to listen for task changes")
+	}
+	reset()
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return errors.Wrap(err, "failed to wait for task changes")
+		}
+
+		id, err := strconv.Atoi(notification.Payload)
+		if err != nil {
+			reset()
+			continue
+		}
+		changed(id)
+	}
+}
@@ -0,0 +1,130 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+
+	"simple-service/internal/service"
+)
+
+// Outbox событий задач. Доставленные события удаляются, next_attempt_at - когда событие можно
+// выбрать для доставки: после неудачи - время повтора, у выбранного - окончание аренды
+const (
+	appendEventQuery = `INSERT INTO outbox (event_type, event_version, task_id, payload) VALUES ($1, $2, $3, $4);`
+	// Одновременно события выбирает одна реплика: иначе две реплики могут взять события одной задачи,
+	// пока первая ещё не отметила свои как выбранные
+	claimLockQuery = `SELECT pg_try_advisory_xact_lock($1);`
+	// Событие не выбирается, пока не доставлено более раннее событие той же задачи, которое ждёт
+	// повтора или выбрано другой репликой: события задачи доставляются по порядку
+	claimEventsQuery = `SELECT id, event_type, event_version, task_id, payload, occurred_at, attempts FROM outbox o
+		WHERE next_attempt_at <= now()
+			AND NOT EXISTS (
+				SELECT 1 FROM outbox p WHERE p.task_id = o.task_id AND p.id < o.id AND p.next_attempt_at > now()
+			)
+		ORDER BY id LIMIT $1;`
+	leaseEventsQuery = `UPDATE outbox SET next_attempt_at = now() + $2 * interval '1 millisecond'
+		WHERE id = ANY($1::bigint[]);`
+	completeEventsQuery = `DELETE FROM outbox WHERE id = ANY($1::bigint[]);`
+	retryEventQuery     = `UPDATE outbox SET
+			attempts = attempts + 1,
+			last_error = $3,
+			next_attempt_at = now() + $2 * interval '1 millisecond'
+		WHERE id = $1;`
+	releaseEventsQuery = `UPDATE outbox SET next_attempt_at = now() WHERE id = ANY($1::bigint[]);`
+)
+
+// claimLockKey - ключ advisory lock выбора событий, отличается от ключа миграций
+const claimLockKey int64 = 0x6f7574626f78 // "outbox"
+
+// AppendEvents - вставка событий пакетом запросов; вне транзакции - отдельной транзакцией
+func (r *repository) AppendEvents(ctx context.Context, events []service.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, event := range events {
+		batch.Queue(appendEventQuery, event.Type, event.Version, event.TaskID, []byte(event.Payload))
+	}
+
+	err := r.WithinTx(ctx, service.TxOptions{}, func(ctx context.Context) error {
+		return r.conn(ctx).SendBatch(ctx, batch).Close()
+	})
+	return errors.Wrap(err, "failed to append events")
+}
+
+// ClaimEvents - до limit событий, готовых к доставке, по возрастанию ID. Выбранные события
+// недоступны другим репликам lease. Если события выбирает другая реплика, результат пустой
+func (r *repository) ClaimEvents(ctx context.Context, limit int, lease time.Duration) ([]service.Event, error) {
+	var events []service.Event
+	err := r.WithinTx(ctx, service.TxOptions{}, func(ctx context.Context) error {
+		var locked bool
+		if err := r.conn(ctx).QueryRow(ctx, claimLockQuery, claimLockKey).Scan(&locked); err != nil || !locked {
+			events = nil
+			return err
+		}
+
+		rows, err := r.conn(ctx).Query(ctx, claimEventsQuery, limit)
+		if err != nil {
+			return err
+		}
+		events, err = pgx.CollectRows(rows, scanEvent)
+		if err != nil || len(events) == 0 {
+			return err
+		}
+
+		_, err = r.conn(ctx).Exec(ctx, leaseEventsQuery, eventIDs(events), lease.Milliseconds())
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to claim events")
+	}
+	return events, nil
+}
+
+// CompleteEvents - удаление доставленных событий
+func (r *repository) CompleteEvents(ctx context.Context, ids []int64) error {
+	err := r.run(ctx, "outbox_complete", true, func(ctx context.Context) error {
+		_, err := r.conn(ctx).Exec(ctx, completeEventsQuery, ids)
+		return err
+	})
+	return errors.Wrap(err, "failed to complete events")
+}
+
+// RetryEvent - неудачная доставка: следующая попытка через delay
+func (r *repository) RetryEvent(ctx context.Context, id int64, delay time.Duration, reason string) error {
+	err := r.run(ctx, "outbox_retry", false, func(ctx context.Context) error {
+		_, err := r.conn(ctx).Exec(ctx, retryEventQuery, id, delay.Milliseconds(), reason)
+		return err
+	})
+	return errors.Wrap(err, "failed to reschedule event")
+}
+
+// ReleaseEvents - выбранные, но не доставленные события снова доступны для выбора
+func (r *repository) ReleaseEvents(ctx context.Context, ids []int64) error {
+	err := r.run(ctx, "outbox_release", true, func(ctx context.Context) error {
+		_, err := r.conn(ctx).Exec(ctx, releaseEventsQuery, ids)
+		return err
+	})
+	return errors.Wrap(err, "failed to release events")
+}
+
+func scanEvent(row pgx.CollectableRow) (service.Event, error) {
+	var event service.Event
+	var payload []byte
+	err := row.Scan(&event.ID, &event.Type, &event.Version, &event.TaskID, &payload, &event.OccurredAt, &event.Attempts)
+	event.Payload = payload
+	event.OccurredAt = event.OccurredAt.UTC()
+	return event, err
+}
+
+func eventIDs(events []service.Event) []int64 {
+	ids := make([]int64, 0, len(events))
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+	return ids
+}
@@ -0,0 +1,192 @@
+package repo
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"simple-service/internal/metrics"
+	"simple-service/internal/service"
+)
+
+// replicaLagQuery - отставание реплики в секундах. Если всё полученное WAL уже применено,
+// отставания нет, даже если на primary давно не было записей
+const replicaLagQuery = `SELECT CASE
+		WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+	END::float8;`
+
+// replica - реплика для чтения. Новая реплика не участвует в чтении до первой успешной проверки
+type replica struct {
+	name    string
+	pool    *pgxpool.Pool
+	db      database
+	healthy atomic.Bool
+
+	// lag - измерение отставания, в тестах подменяется
+	lag func(ctx context.Context) (time.Duration, error)
+}
+
+func newReplica(name string, pool *pgxpool.Pool) *replica {
+	rep := &replica{name: name, pool: pool, db: pgxPool{pool}}
+	rep.lag = func(ctx context.Context) (time.Duration, error) {
+		var seconds float64
+		if err := pool.QueryRow(ctx, replicaLagQuery).Scan(&seconds); err != nil {
+			return 0, err
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	metrics.DBReplicaHealthy.WithLabelValues(name).Set(0)
+	return rep
+}
+
+func (rep *replica) setHealthy(healthy bool) {
+	rep.healthy.Store(healthy)
+	value := 0.0
+	if healthy {
+		value = 1
+	}
+	metrics.DBReplicaHealthy.WithLabelValues(rep.name).Set(value)
+}
+
+// replicaSet - реплики с проверкой отставания и выбором по кругу среди исправных
+type replicaSet struct {
+	replicas []*replica
+	maxLag   time.Duration
+	interval time.Duration
+	next     atomic.Uint64
+}
+
+// pick - следующая по кругу исправная реплика, nil если таких нет
+func (s *replicaSet) pick() *replica {
+	healthy := make([]*replica, 0, len(s.replicas))
+	for _, rep := range s.replicas {
+		if rep.healthy.Load() {
+			healthy = append(healthy, rep)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+	return healthy[s.next.Add(1)%uint64(len(healthy))]
+}
+
+// check - проверка всех реплик: недоступные и отстающие больше maxLag исключаются из чтения
+func (s *replicaSet) check(ctx context.Context) {
+	for _, rep := range s.replicas {
+		checkCtx, cancel := context.WithTimeout(ctx, s.interval)
+		lag, err := rep.lag(checkCtx)
+		cancel()
+
+		if err != nil {
+			rep.setHealthy(false)
+			continue
+		}
+		metrics.DBReplicaLag.WithLabelValues(rep.name).Set(lag.Seconds())
+		rep.setHealthy(lag <= s.maxLag)
+	}
+}
+
+// watch - периодическая проверка реплик до отмены ctx
+func (s *replicaSet) watch(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// read - запрос только на чтение: на реплику, если она есть, пользователь не писал недавно
+// и контекст не требует primary (service.WithPrimary), иначе на primary. Недоступная реплика исключается до следующей проверки, запрос повторяется на primary
+func (r *repository) read(ctx context.Context, op string, fn func(ctx context.Context) error) error {
+	if txFromContext(ctx) == nil && r.replicas != nil && !service.ReadsPrimary(ctx) &&
+		!r.pins.pinned(service.SubjectFromContext(ctx)) {
+		if rep := r.replicas.pick(); rep != nil {
+			err := runOn(ctx, rep.db, fn)
+			if classify(err) != classUnavailable {
+				metrics.DBReads.WithLabelValues("replica").Inc()
+				if isTimeout(err) {
+					return timeoutError(err)
+				}
+				return err
+			}
+			rep.setHealthy(false)
+		}
+	}
+
+	metrics.DBReads.WithLabelValues("primary").Inc()
+	return r.run(ctx, op, true, fn)
+}
+
+// written - пользователь из ctx записал данные: его чтения идут в primary, пока реплики не догонят
+func (r *repository) written(ctx context.Context) {
+	if r.replicas != nil {
+		r.pins.pin(service.SubjectFromContext(ctx))
+	}
+}
+
+// pins - время, до которого чтения пользователя идут в primary (read-your-writes).
+// Хранится в памяти процесса: за балансировщиком гарантия действует в пределах одного экземпляра
+type pins struct {
+	window time.Duration
+
+	mu        sync.Mutex
+	until     map[string]time.Time
+	lastSweep time.Time
+
+	// now подменяется в тестах
+	now func() time.Time
+}
+
+func newPins(window time.Duration) *pins {
+	return &pins{
+		window: window,
+		until:  make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+// pin - закрепление пользователя за primary на window
+func (p *pins) pin(subject string) {
+	if p == nil || subject == "" || p.window <= 0 {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	p.until[subject] = now.Add(p.window)
+
+	// Истёкшие записи удаляются не чаще раза в window
+	if now.Sub(p.lastSweep) >= p.window {
+		for s, until := range p.until {
+			if !now.Before(until) {
+				delete(p.until, s)
+			}
+		}
+		p.lastSweep = now
+	}
+}
+
+// pinned - чтения пользователя должны идти в primary
+func (p *pins) pinned(subject string) bool {
+	if p == nil || subject == "" {
+		return false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	until, ok := p.until[subject]
+	return ok && p.now().Before(until)
+}
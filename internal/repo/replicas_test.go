@@ -0,0 +1,174 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"simple-service/internal/service"
+)
+
+// namedDB - пул без БД, по которому видно, куда направлен запрос
+type namedDB struct {
+	database
+	name string
+}
+
+func newTestReplica(name string, lag time.Duration, lagErr error) *replica {
+	rep := &replica{name: name, db: &namedDB{name: name}}
+	rep.lag = func(context.Context) (time.Duration, error) { return lag, lagErr }
+	return rep
+}
+
+func TestReplicaSet(t *testing.T) {
+	s := &replicaSet{
+		replicas: []*replica{
+			newTestReplica("r1", time.Second, nil),
+			newTestReplica("r2", 10*time.Second, nil),
+			newTestReplica("r3", 0, errors.New("connection refused")),
+			newTestReplica("r4", 0, nil),
+		},
+		maxLag:   5 * time.Second,
+		interval: time.Second,
+	}
+
+	// До первой проверки реплики не участвуют в чтении
+	assert.Nil(t, s.pick())
+
+	s.check(context.Background())
+	healthy := map[string]bool{}
+	for _, rep := range s.replicas {
+		healthy[rep.name] = rep.healthy.Load()
+	}
+	assert.Equal(t, map[string]bool{"r1": true, "r2": false, "r3": false, "r4": true}, healthy,
+		"отстающая и недоступная реплики исключены")
+
+	// Выбор по кругу среди исправных
+	picked := map[string]int{}
+	for i := 0; i < 10; i++ {
+		picked[s.pick().name]++
+	}
+	assert.Equal(t, map[string]int{"r1": 5, "r4": 5}, picked)
+
+	// Реплика догнала primary - возвращается после следующей проверки
+	s.replicas[1].lag = func(context.Context) (time.Duration, error) { return time.Second, nil }
+	s.check(context.Background())
+	assert.True(t, s.replicas[1].healthy.Load())
+}
+
+func TestPins(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	p := newPins(5 * time.Second)
+	p.now = func() time.Time { return now }
+
+	assert.False(t, p.pinned("alice"))
+
+	p.pin("alice")
+	p.pin("")
+	assert.True(t, p.pinned("alice"))
+	assert.False(t, p.pinned("bob"))
+	assert.False(t, p.pinned(""), "анонимные запросы не закрепляются")
+
+	now = now.Add(5 * time.Second)
+	assert.False(t, p.pinned("alice"))
+
+	// Истёкшие записи удаляются при следующих закреплениях
+	p.pin("bob")
+	assert.NotContains(t, p.until, "alice")
+
+	t.Run("Нулевое окно отключает закрепление", func(t *testing.T) {
+		p := newPins(0)
+		p.pin("alice")
+		assert.False(t, p.pinned("alice"))
+	})
+}
+
+func TestReadRouting(t *testing.T) {
+	connLost := &net.OpError{Op: "read", Err: errors.New("connection reset by peer")}
+
+	newRepo := func() *repository {
+		g, _ := newTestGuard(0)
+		r := &repository{
+			primary: &namedDB{name: "primary"},
+			guard:   g,
+			replicas: &replicaSet{
+				replicas: []*replica{newTestReplica("replica", 0, nil)},
+				maxLag:   time.Second,
+				interval: time.Second,
+			},
+			pins: newPins(time.Minute),
+		}
+		r.replicas.check(context.Background())
+		return r
+	}
+
+	// target - куда попал запрос
+	target := func(r *repository, ctx context.Context, replicaErr error) (string, error) {
+		var targets []string
+		err := r.read(ctx, "get", func(ctx context.Context) error {
+			name := r.conn(ctx).(*namedDB).name
+			targets = append(targets, name)
+			if name == "replica" {
+				return replicaErr
+			}
+			return nil
+		})
+		return targets[len(targets)-1], err
+	}
+
+	alice := service.WithSubject(context.Background(), "alice")
+
+	t.Run("Чтение идёт на реплику", func(t *testing.T) {
+		r := newRepo()
+		got, err := target(r, alice, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "replica", got)
+	})
+
+	t.Run("После записи чтения пользователя идут в primary", func(t *testing.T) {
+		r := newRepo()
+		r.written(alice)
+
+		got, _ := target(r, alice, nil)
+		assert.Equal(t, "primary", got)
+
+		bob := service.WithSubject(context.Background(), "bob")
+		got, _ = target(r, bob, nil)
+		assert.Equal(t, "replica", got, "другие пользователи читают с реплики")
+	})
+
+	t.Run("Контекст с WithPrimary читает из primary", func(t *testing.T) {
+		r := newRepo()
+		got, err := target(r, service.WithPrimary(alice), nil)
+		require.NoError(t, err)
+		assert.Equal(t, "primary", got)
+	})
+
+	t.Run("Недоступная реплика исключается, запрос повторяется на primary", func(t *testing.T) {
+		r := newRepo()
+		got, err := target(r, alice, connLost)
+		require.NoError(t, err)
+		assert.Equal(t, "primary", got)
+		assert.False(t, r.replicas.replicas[0].healthy.Load())
+	})
+
+	t.Run("Ошибка запроса на реплике возвращается как есть", func(t *testing.T) {
+		r := newRepo()
+		got, err := target(r, alice, service.ErrTaskNotFound)
+		assert.ErrorIs(t, err, service.ErrTaskNotFound)
+		assert.Equal(t, "replica", got)
+		assert.True(t, r.replicas.replicas[0].healthy.Load())
+	})
+
+	t.Run("Без исправных реплик - primary", func(t *testing.T) {
+		r := newRepo()
+		r.replicas.replicas[0].setHealthy(false)
+		got, _ := target(r, alice, nil)
+		assert.Equal(t, "primary", got)
+	})
+}
@@ -3,8 +3,11 @@ package repo
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgconn/ctxwatch"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/pkg/errors"
 
@@ -18,22 +21,115 @@ import (
 const (
 	insertTaskQuery = `INSERT INTO tasks (title, description) VALUES ($1, $2) RETURNING id;`
 	getTaskQuery    = `SELECT id, title, description, status, created_at, updated_at FROM tasks WHERE id = $1;`
+	listTasksQuery  = `SELECT id, title, description, status, created_at, updated_at FROM tasks
+		WHERE ($1 = '' OR status = $1) ORDER BY id LIMIT $2 OFFSET $3;`
+	updateTaskQuery = `UPDATE tasks SET
+			title = COALESCE($2, title),
+			description = COALESCE($3, description),
+			status = COALESCE($4, status),
+			updated_at = now()
+		WHERE id = $1
+		RETURNING id, title, description, status, created_at, updated_at;`
+	deleteTaskQuery = `DELETE FROM tasks WHERE id = $1;`
+	// FOR UPDATE в транзакции блокирует строки: состояние задач не изменится до её завершения
+	getTasksQuery = `SELECT id, title, description, status, created_at, updated_at FROM tasks
+		WHERE id = ANY($1::int[]) ORDER BY id FOR UPDATE;`
+	// Отбор для массовых операций: пустой (или NULL) список ID и пустые условия не ограничивают выборку.
+	// FOR UPDATE в транзакции блокирует строки, чтобы изменить именно отобранные задачи
+	findTaskIDsQuery = `SELECT id FROM tasks
+		WHERE (COALESCE(cardinality($1::int[]), 0) = 0 OR id = ANY($1::int[]))
+			AND ($2 = '' OR status = $2)
+			AND ($3::timestamp IS NULL OR created_at >= $3)
+			AND ($4::timestamp IS NULL OR created_at < $4)
+		ORDER BY id FOR UPDATE;`
+	updateTasksQuery = `UPDATE tasks SET
+			title = COALESCE($2, title),
+			description = COALESCE($3, description),
+			status = COALESCE($4, status),
+			updated_at = now()
+		WHERE id = ANY($1::int[]);`
+	deleteTasksQuery = `DELETE FROM tasks WHERE id = ANY($1::int[]);`
 )
 
+// readyTimeout - ограничение времени проверки готовности БД
+const readyTimeout = 2 * time.Second
+
+// cancelDeadlineDelay - сколько ждать ответа сервера на отмену запроса, прежде чем закрыть соединение
+const cancelDeadlineDelay = time.Second
+
 type repository struct {
 	pool *pgxpool.Pool
+	// primary - пул для запросов, в тестах подменяется
+	primary database
+	// replicas - реплики для чтения, nil если не настроены
+	replicas *replicaSet
+	// pins - пользователи, недавно записывавшие данные, читают из primary
+	pins  *pins
+	guard *guard
+	// stop - остановка проверки реплик
+	stop func()
+	// isolation - уровень изоляции транзакций по умолчанию
+	isolation service.IsolationLevel
 }
 
-// NewRepository - создание нового экземпляра репозитория с подключением к PostgreSQL
-func NewRepository(ctx context.Context, cfg config.PostgreSQL) (*repository, error) {
+// PasswordFunc - получение актуального пароля БД, вызывается перед каждым новым соединением пула.
+// Позволяет использовать ротированный пароль без перезапуска
+type PasswordFunc func(ctx context.Context) (string, error)
+
+// NewRepository - создание нового экземпляра репозитория с подключением к PostgreSQL.
+// Если password не nil, пароль из cfg используется только для разбора конфигурации.
+// Для реплик из cfg создаются отдельные пулы, их отставание проверяется до вызова Close
+func NewRepository(ctx context.Context, cfg config.PostgreSQL, password PasswordFunc) (*repository, error) {
+	pool, err := newPool(ctx, cfg, config.Address{Host: cfg.Host, Port: cfg.Port}, password)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &repository{
+		pool:      pool,
+		primary:   pgxPool{pool},
+		guard:     newGuard(cfg),
+		isolation: service.IsolationLevel(cfg.TxIsolation),
+		stop:      func() {},
+	}
+
+	addrs, err := cfg.Replicas()
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return r, nil
+	}
+
+	r.replicas = &replicaSet{maxLag: cfg.ReplicaMaxLag, interval: cfg.ReplicaCheckInterval}
+	r.pins = newPins(cfg.ReadYourWrites)
+	for _, addr := range addrs {
+		replicaPool, err := newPool(ctx, cfg, addr, password)
+		if err != nil {
+			r.Close()
+			return nil, errors.Wrapf(err, "replica %s", addr)
+		}
+		r.replicas.replicas = append(r.replicas.replicas, newReplica(addr.String(), replicaPool))
+	}
+
+	watchCtx, stop := context.WithCancel(context.WithoutCancel(ctx))
+	r.stop = stop
+	go r.replicas.watch(watchCtx)
+
+	return r, nil
+}
+
+// newPool - пул соединений с сервером addr
+func newPool(ctx context.Context, cfg config.PostgreSQL, addr config.Address, password PasswordFunc) (*pgxpool.Pool, error) {
 	// Формируем строку подключения
 	connString := fmt.Sprintf(
 		`user=%s password=%s host=%s port=%d dbname=%s sslmode=%s
         pool_max_conns=%d pool_max_conn_lifetime=%s pool_max_conn_idle_time=%s`,
 		cfg.User,
 		cfg.Password,
-		cfg.Host,
-		cfg.Port,
+		addr.Host,
+		addr.Port,
 		cfg.Name,
 		cfg.SSLMode,
 		cfg.PoolMaxConns,
@@ -50,39 +146,272 @@ func NewRepository(ctx context.Context, cfg config.PostgreSQL) (*repository, err
 	// Оптимизация выполнения запросов (кеширование запросов)
 	config.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeCacheDescribe
 
+	// При отмене контекста сервер получает запрос отмены и прерывает выполнение сам,
+	// соединение закрывается, только если он не ответил за cancelDeadlineDelay
+	config.ConnConfig.BuildContextWatcherHandler = func(conn *pgconn.PgConn) ctxwatch.Handler {
+		return &pgconn.CancelRequestContextWatcherHandler{Conn: conn, DeadlineDelay: cancelDeadlineDelay}
+	}
+
+	// Новые соединения получают текущий пароль
+	if password != nil {
+		config.BeforeConnect = func(ctx context.Context, cc *pgx.ConnConfig) error {
+			pwd, err := password(ctx)
+			if err != nil {
+				return errors.Wrap(err, "failed to get database password")
+			}
+			cc.Password = pwd
+			return nil
+		}
+	}
+
 	// Создаём пул соединений с базой данных
 	pool, err := pgxpool.NewWithConfig(ctx, config)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create PostgreSQL connection pool")
 	}
 
-	return &repository{pool}, nil
+	return pool, nil
 }
 
-// Close - закрытие пула соединений
+// Close - остановка проверки реплик и закрытие пулов соединений
 func (r *repository) Close() {
+	r.stop()
+	if r.replicas != nil {
+		for _, rep := range r.replicas.replicas {
+			rep.pool.Close()
+		}
+	}
 	r.pool.Close()
 }
 
+// Ready - проверка доступности БД для readiness; при открытом breaker БД не опрашивается
+func (r *repository) Ready(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+
+	return r.guard.do(ctx, "ping", true, r.pool.Ping)
+}
+
+// BreakerState - состояние circuit breaker: closed, half_open или open
+func (r *repository) BreakerState() string {
+	return r.guard.breaker.State()
+}
+
 // Pool - получение пула соединений
 func (r *repository) Pool() *pgxpool.Pool {
 	return r.pool
 }
 
-// CreateTask - вставка новой задачи в таблицу tasks
+// CreateTask - вставка новой задачи в таблицу tasks.
+// Повторяется только если запрос не дошёл до сервера, иначе задача может создаться дважды
 func (r *repository) CreateTask(ctx context.Context, task service.Task) (int, error) {
 	var id int
-	err := r.pool.QueryRow(ctx, insertTaskQuery, task.Title, task.Description).Scan(&id)
+	err := r.run(ctx, "create", false, func(ctx context.Context) error {
+		return r.conn(ctx).QueryRow(ctx, insertTaskQuery, task.Title, task.Description).Scan(&id)
+	})
 	if err != nil {
 		return 0, errors.Wrap(err, "failed to insert task")
 	}
+	r.written(ctx)
 	return id, nil
 }
 
+// CreateTasks - вставка задач одним пакетом запросов в транзакции: за один обмен с БД,
+// при ошибке любой вставки не создаётся ни одна задача
+func (r *repository) CreateTasks(ctx context.Context, tasks []service.Task) ([]int, error) {
+	if len(tasks) == 0 {
+		return []int{}, nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, task := range tasks {
+		batch.Queue(insertTaskQuery, task.Title, task.Description)
+	}
+
+	ids := make([]int, len(tasks))
+	err := r.WithinTx(ctx, service.TxOptions{}, func(ctx context.Context) error {
+		results := r.conn(ctx).SendBatch(ctx, batch)
+		for i := range ids {
+			if err := results.QueryRow().Scan(&ids[i]); err != nil {
+				_ = results.Close()
+				return errors.Wrapf(err, "task %d", i)
+			}
+		}
+		return results.Close()
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to insert tasks")
+	}
+	r.written(ctx)
+	return ids, nil
+}
+
 // GetTask - получение задачи по ID
 func (r *repository) GetTask(ctx context.Context, id int) (*service.TaskResponse, error) {
+	var task *service.TaskResponse
+	err := r.read(ctx, "get", func(ctx context.Context) (err error) {
+		task, err = scanTask(r.conn(ctx).QueryRow(ctx, getTaskQuery, id))
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, service.ErrTaskNotFound
+		}
+		return nil, errors.Wrap(err, "failed to get task")
+	}
+	return task, nil
+}
+
+// GetTasks - задачи из ids по возрастанию ID. Выполняется на primary, как и FindTaskIDs
+func (r *repository) GetTasks(ctx context.Context, ids []int) ([]service.TaskResponse, error) {
+	var tasks []service.TaskResponse
+	err := r.run(ctx, "get_many", true, func(ctx context.Context) error {
+		rows, err := r.conn(ctx).Query(ctx, getTasksQuery, ids)
+		if err != nil {
+			return err
+		}
+		tasks, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (service.TaskResponse, error) {
+			task, err := scanTask(row)
+			if err != nil {
+				return service.TaskResponse{}, err
+			}
+			return *task, nil
+		})
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tasks")
+	}
+	return tasks, nil
+}
+
+// ListTasks - страница задач, отсортированных по ID
+func (r *repository) ListTasks(ctx context.Context, filter service.ListFilter) ([]service.TaskResponse, error) {
+	var tasks []service.TaskResponse
+	err := r.read(ctx, "list", func(ctx context.Context) (err error) {
+		tasks, err = r.listTasks(ctx, filter)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list tasks")
+	}
+	return tasks, nil
+}
+
+func (r *repository) listTasks(ctx context.Context, filter service.ListFilter) ([]service.TaskResponse, error) {
+	rows, err := r.conn(ctx).Query(ctx, listTasksQuery, filter.Status, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := make([]service.TaskResponse, 0, filter.Limit)
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan task")
+		}
+		tasks = append(tasks, *task)
+	}
+	return tasks, rows.Err()
+}
+
+// UpdateTask - обновление переданных полей задачи. Повтор записывает те же значения, поэтому безопасен
+func (r *repository) UpdateTask(ctx context.Context, id int, update service.TaskUpdate) (*service.TaskResponse, error) {
+	var task *service.TaskResponse
+	err := r.run(ctx, "update", true, func(ctx context.Context) (err error) {
+		task, err = scanTask(r.conn(ctx).QueryRow(ctx, updateTaskQuery, id, update.Title, update.Description, update.Status))
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, service.ErrTaskNotFound
+		}
+		return nil, errors.Wrap(err, "failed to update task")
+	}
+	r.written(ctx)
+	return task, nil
+}
+
+// DeleteTask - удаление задачи по ID.
+// Повторяется только если запрос не дошёл до сервера, иначе повтор вернёт ErrTaskNotFound для удалённой задачи
+func (r *repository) DeleteTask(ctx context.Context, id int) error {
+	var tag pgconn.CommandTag
+	err := r.run(ctx, "delete", false, func(ctx context.Context) (err error) {
+		tag, err = r.conn(ctx).Exec(ctx, deleteTaskQuery, id)
+		return err
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to delete task")
+	}
+	if tag.RowsAffected() == 0 {
+		return service.ErrTaskNotFound
+	}
+	r.written(ctx)
+	return nil
+}
+
+// FindTaskIDs - ID задач, подходящих под sel, по возрастанию. Выполняется на primary:
+// отобранные строки блокируются для последующего изменения в той же транзакции
+func (r *repository) FindTaskIDs(ctx context.Context, sel service.TaskSelector) ([]int, error) {
+	var ids []int
+	err := r.run(ctx, "find", true, func(ctx context.Context) error {
+		rows, err := r.conn(ctx).Query(ctx, findTaskIDsQuery, sel.IDs, sel.Filter.Status,
+			utcTime(sel.Filter.CreatedAfter), utcTime(sel.Filter.CreatedBefore))
+		if err != nil {
+			return err
+		}
+		ids, err = pgx.CollectRows(rows, pgx.RowTo[int])
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find tasks")
+	}
+	return ids, nil
+}
+
+// UpdateTasks - обновление переданных полей задач из ids одним запросом
+func (r *repository) UpdateTasks(ctx context.Context, ids []int, update service.TaskUpdate) (int, error) {
+	var tag pgconn.CommandTag
+	err := r.run(ctx, "update_many", true, func(ctx context.Context) (err error) {
+		tag, err = r.conn(ctx).Exec(ctx, updateTasksQuery, ids, update.Title, update.Description, update.Status)
+		return err
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to update tasks")
+	}
+	r.written(ctx)
+	return int(tag.RowsAffected()), nil
+}
+
+// DeleteTasks - удаление задач из ids одним запросом.
+// Повторяется только если запрос не дошёл до сервера, иначе повтор вернёт неверное число удалённых
+func (r *repository) DeleteTasks(ctx context.Context, ids []int) (int, error) {
+	var tag pgconn.CommandTag
+	err := r.run(ctx, "delete_many", false, func(ctx context.Context) (err error) {
+		tag, err = r.conn(ctx).Exec(ctx, deleteTasksQuery, ids)
+		return err
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to delete tasks")
+	}
+	r.written(ctx)
+	return int(tag.RowsAffected()), nil
+}
+
+// utcTime - время в UTC: колонки TIMESTAMP без часового пояса читаются и сравниваются как UTC
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
+// scanTask - чтение строки с колонками id, title, description, status, created_at, updated_at
+func scanTask(row pgx.Row) (*service.TaskResponse, error) {
 	var task service.TaskResponse
-	err := r.pool.QueryRow(ctx, getTaskQuery, id).Scan(
+	err := row.Scan(
 		&task.ID,
 		&task.Title,
 		&task.Description,
@@ -91,10 +420,7 @@ func (r *repository) GetTask(ctx context.Context, id int) (*service.TaskResponse
 		&task.UpdatedAt,
 	)
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, errors.New("task not found")
-		}
-		return nil, errors.Wrap(err, "failed to get task")
+		return nil, err
 	}
 	return &task, nil
 }
@@ -0,0 +1,73 @@
+package repo_test
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"simple-service/internal/config"
+	"simple-service/internal/migrations"
+	"simple-service/internal/repo"
+	"simple-service/internal/repo/repotest"
+	"simple-service/internal/service"
+)
+
+// TestConformance - набор repotest против PostgreSQL. Запускается, только если задан
+// TEST_DB_HOST; база TEST_DB_NAME очищается перед каждым тестом, не используйте рабочую:
+//
+//	docker run -d --name repotest -e POSTGRES_PASSWORD=postgres -p 5433:5432 postgres
+//	TEST_DB_HOST=localhost TEST_DB_PORT=5433 TEST_DB_NAME=postgres go test ./internal/repo/
+func TestConformance(t *testing.T) {
+	cfg := testConfig(t)
+	ctx := context.Background()
+
+	repository, err := repo.NewRepository(ctx, cfg, nil)
+	require.NoError(t, err)
+	t.Cleanup(repository.Close)
+
+	migrator, err := migrations.NewMigrator(repository.Pool(), migrations.FS, zap.NewNop().Sugar())
+	require.NoError(t, err)
+	require.NoError(t, migrator.Up(ctx, 0))
+
+	repotest.Run(t, func(t *testing.T) service.Repository {
+		_, err := repository.Pool().Exec(ctx, "TRUNCATE tasks, outbox, webhooks, webhook_deliveries RESTART IDENTITY")
+		require.NoError(t, err)
+		return repository
+	})
+}
+
+func testConfig(t *testing.T) config.PostgreSQL {
+	t.Helper()
+
+	host := os.Getenv("TEST_DB_HOST")
+	if host == "" {
+		t.Skip("TEST_DB_HOST is not set, skipping PostgreSQL repository tests")
+	}
+
+	env := func(name, def string) string {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+		return def
+	}
+
+	port, err := strconv.Atoi(env("TEST_DB_PORT", "5432"))
+	require.NoError(t, err, "TEST_DB_PORT")
+
+	return config.PostgreSQL{
+		Host:                host,
+		Port:                port,
+		Name:                env("TEST_DB_NAME", "simple_service_test"),
+		User:                env("TEST_DB_USER", "postgres"),
+		Password:            env("TEST_DB_PASSWORD", "postgres"),
+		SSLMode:             env("TEST_DB_SSL_MODE", "disable"),
+		PoolMaxConns:        10,
+		PoolMaxConnLifetime: time.Hour,
+		PoolMaxConnIdleTime: time.Minute,
+	}
+}
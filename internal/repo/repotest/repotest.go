@@ -0,0 +1,959 @@
+// Package repotest - набор поведенческих тестов для реализаций service.Repository.
+// Реализация хранилища проверяется одним вызовом в своём _test.go файле:
+//
+//	func TestConformance(t *testing.T) {
+//		repotest.Run(t, func(t *testing.T) service.Repository {
+//			return memory.NewRepository()
+//		})
+//	}
+//
+// Тесты проверяют только поведение через интерфейс, поэтому одинаково работают
+// для PostgreSQL, памяти и будущих хранилищ
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"simple-service/internal/outbox"
+	"simple-service/internal/service"
+	"simple-service/internal/webhook"
+)
+
+// Factory - новое пустое хранилище для одного теста. Освобождение ресурсов
+// регистрируется через t.Cleanup. Тесты не запускаются параллельно, поэтому
+// фабрика может очищать общую базу данных
+type Factory func(t *testing.T) service.Repository
+
+// Run - запуск всех тестов набора
+func Run(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, r service.Repository)
+	}{
+		{name: "Создание и чтение", fn: testCreateAndGet},
+		{name: "Пакетное создание", fn: testCreateTasks},
+		{name: "Массовые изменение и удаление", fn: testBulk},
+		{name: "Выгрузка и загрузка", fn: testExportImport},
+		{name: "Чтение нескольких задач", fn: testGetTasks},
+		{name: "Outbox событий", fn: testOutbox},
+		{name: "Подписки и доставки", fn: testWebhooks},
+		{name: "Значения по умолчанию", fn: testDefaults},
+		{name: "Задача не найдена", fn: testNotFound},
+		{name: "Частичное обновление", fn: testUpdate},
+		{name: "Недопустимый статус", fn: testInvalidStatus},
+		{name: "Удаление", fn: testDelete},
+		{name: "Список, фильтр и страницы", fn: testList},
+		{name: "Монотонность времени", fn: testTimestamps},
+		{name: "Unicode и граничные длины", fn: testUnicodeAndLength},
+		{name: "Параллельное создание", fn: testConcurrentCreate},
+		{name: "Параллельное обновление", fn: testConcurrentUpdate},
+		{name: "Отменённый контекст", fn: testCanceledContext},
+		{name: "Фиксация транзакции", fn: testTxCommit},
+		{name: "Откат транзакции", fn: testTxRollback},
+		{name: "Вложенная транзакция", fn: testTxNested},
+		{name: "Транзакция только для чтения", fn: testTxReadOnly},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, factory(t))
+		})
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+func create(t *testing.T, r service.Repository, title, description string) int {
+	t.Helper()
+	id, err := r.CreateTask(context.Background(), service.Task{Title: title, Description: description})
+	require.NoError(t, err)
+	return id
+}
+
+func get(t *testing.T, r service.Repository, id int) *service.TaskResponse {
+	t.Helper()
+	task, err := r.GetTask(context.Background(), id)
+	require.NoError(t, err)
+	require.NotNil(t, task)
+	return task
+}
+
+func ids(tasks []service.TaskResponse) []int {
+	out := make([]int, 0, len(tasks))
+	for _, task := range tasks {
+		out = append(out, task.ID)
+	}
+	return out
+}
+
+func testCreateAndGet(t *testing.T, r service.Repository) {
+	first := create(t, r, "First", "first description")
+	second := create(t, r, "Second", "")
+
+	assert.Positive(t, first)
+	assert.Greater(t, second, first, "IDs must grow in creation order")
+
+	task := get(t, r, first)
+	assert.Equal(t, first, task.ID)
+	assert.Equal(t, "First", task.Title)
+	assert.Equal(t, "first description", task.Description)
+
+	// Результат - копия: его изменение не влияет на хранилище
+	task.Title = "changed"
+	assert.Equal(t, "First", get(t, r, first).Title)
+}
+
+func testCreateTasks(t *testing.T, r service.Repository) {
+	before := create(t, r, "Before", "")
+
+	created, err := r.CreateTasks(context.Background(), []service.Task{
+		{Title: "First", Description: "a"},
+		{Title: "Second"},
+		{Title: "Third", Description: "c"},
+	})
+	require.NoError(t, err)
+	require.Len(t, created, 3)
+
+	// ID выдаются по возрастанию в порядке задач пакета
+	assert.Greater(t, created[0], before)
+	assert.Greater(t, created[1], created[0])
+	assert.Greater(t, created[2], created[1])
+
+	first := get(t, r, created[0])
+	assert.Equal(t, "First", first.Title)
+	assert.Equal(t, "a", first.Description)
+	assert.Equal(t, service.StatusNew, first.Status)
+	assert.Equal(t, "Third", get(t, r, created[2]).Title)
+
+	empty, err := r.CreateTasks(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+
+	// Пакет внутри транзакции откатывается вместе с ней
+	var rolledBack []int
+	err = r.WithinTx(context.Background(), service.TxOptions{}, func(ctx context.Context) error {
+		var err error
+		if rolledBack, err = r.CreateTasks(ctx, []service.Task{{Title: "Rolled back"}, {Title: "Rolled back too"}}); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	assert.ErrorIs(t, err, errAbort)
+	require.Len(t, rolledBack, 2)
+	for _, id := range rolledBack {
+		_, err := r.GetTask(context.Background(), id)
+		assert.ErrorIs(t, err, service.ErrTaskNotFound)
+	}
+}
+
+func testBulk(t *testing.T, r service.Repository) {
+	ctx := context.Background()
+	first := create(t, r, "First", "")
+	second := create(t, r, "Second", "")
+	third := create(t, r, "Third", "")
+	_, err := r.UpdateTask(ctx, second, service.TaskUpdate{Status: ptr(service.StatusInProgress)})
+	require.NoError(t, err)
+
+	find := func(sel service.TaskSelector) []int {
+		t.Helper()
+		found, err := r.FindTaskIDs(ctx, sel)
+		require.NoError(t, err)
+		return found
+	}
+
+	// Пустой отбор - все задачи по возрастанию ID
+	assert.Equal(t, []int{first, second, third}, find(service.TaskSelector{}))
+	assert.Equal(t, []int{second}, find(service.TaskSelector{Filter: service.TaskFilter{Status: service.StatusInProgress}}))
+	assert.Equal(t, []int{first, third}, find(service.TaskSelector{IDs: []int{third, first, first, third + 100}}))
+	assert.Empty(t, find(service.TaskSelector{IDs: []int{first}, Filter: service.TaskFilter{Status: service.StatusDone}}))
+
+	// created_after включает границу, created_before - нет
+	created := get(t, r, third).CreatedAt
+	assert.Contains(t, find(service.TaskSelector{Filter: service.TaskFilter{CreatedAfter: &created}}), third)
+	assert.NotContains(t, find(service.TaskSelector{Filter: service.TaskFilter{CreatedBefore: &created}}), third)
+	later := created.Add(time.Hour)
+	assert.Empty(t, find(service.TaskSelector{Filter: service.TaskFilter{CreatedAfter: &later}}))
+
+	// Отсутствующие и повторяющиеся ID не учитываются в числе изменённых
+	updated, err := r.UpdateTasks(ctx, []int{first, third, third, third + 100}, service.TaskUpdate{Status: ptr(service.StatusDone)})
+	require.NoError(t, err)
+	assert.Equal(t, 2, updated)
+	assert.Equal(t, service.StatusDone, get(t, r, first).Status)
+	assert.Equal(t, "First", get(t, r, first).Title)
+	assert.Equal(t, service.StatusInProgress, get(t, r, second).Status)
+	assert.Equal(t, []int{first, third}, find(service.TaskSelector{Filter: service.TaskFilter{Status: service.StatusDone}}))
+
+	_, err = r.UpdateTasks(ctx, []int{first}, service.TaskUpdate{Status: ptr("archived")})
+	assert.Error(t, err, "недопустимый статус")
+	assert.Equal(t, service.StatusDone, get(t, r, first).Status)
+
+	deleted, err := r.DeleteTasks(ctx, []int{first, second, second + 100})
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+	assert.Equal(t, []int{third}, find(service.TaskSelector{}))
+
+	deleted, err = r.DeleteTasks(ctx, nil)
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+}
+
+func testExportImport(t *testing.T, r service.Repository) {
+	ctx := context.Background()
+	first := create(t, r, "First", "")
+	second := create(t, r, "Second", "")
+	_, err := r.UpdateTask(ctx, second, service.TaskUpdate{Status: ptr(service.StatusDone)})
+	require.NoError(t, err)
+
+	export := func(filter service.TaskFilter) []service.TaskResponse {
+		t.Helper()
+		cursor, err := r.ExportTasks(ctx, filter)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, cursor.Close(ctx)) }()
+
+		var all []service.TaskResponse
+		for {
+			tasks, err := cursor.Next(ctx)
+			require.NoError(t, err)
+			if len(tasks) == 0 {
+				return all
+			}
+			all = append(all, tasks...)
+		}
+	}
+
+	assert.Equal(t, []int{first, second}, ids(export(service.TaskFilter{})))
+	assert.Equal(t, []int{second}, ids(export(service.TaskFilter{Status: service.StatusDone})))
+
+	// Заданные ID и время сохраняются, занятый ID пропускается, sequence сдвигается за загруженные ID
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	updated := created.Add(time.Hour)
+	imported, err := r.ImportTasks(ctx, []service.TaskResponse{
+		{ID: second + 100, Title: "Preserved", Status: service.StatusInProgress, CreatedAt: created, UpdatedAt: updated},
+		{ID: first, Title: "Taken", Status: service.StatusNew},
+		{Title: "Reassigned", Description: "New ID", Status: service.StatusNew},
+	})
+	require.NoError(t, err)
+	require.Len(t, imported, 3)
+	assert.Equal(t, second+100, imported[0])
+	assert.Zero(t, imported[1])
+	assert.Greater(t, imported[2], second+100)
+
+	preserved := get(t, r, second+100)
+	assert.Equal(t, "Preserved", preserved.Title)
+	assert.Equal(t, service.StatusInProgress, preserved.Status)
+	assert.True(t, created.Equal(preserved.CreatedAt))
+	assert.True(t, updated.Equal(preserved.UpdatedAt))
+	assert.Equal(t, "First", get(t, r, first).Title)
+
+	reassigned := get(t, r, imported[2])
+	assert.Equal(t, "New ID", reassigned.Description)
+	assert.False(t, reassigned.CreatedAt.IsZero())
+	assert.Greater(t, create(t, r, "After import", ""), imported[2])
+
+	// Выгрузка больше одной порции курсора
+	tasks := make([]service.TaskResponse, 1200)
+	for i := range tasks {
+		tasks[i] = service.TaskResponse{Title: fmt.Sprintf("Task %d", i), Status: service.StatusNew}
+	}
+	_, err = r.ImportTasks(ctx, tasks)
+	require.NoError(t, err)
+	all := export(service.TaskFilter{})
+	assert.Len(t, all, 1205)
+	assert.True(t, sort.SliceIsSorted(all, func(i, j int) bool { return all[i].ID < all[j].ID }))
+
+	_, err = r.ImportTasks(ctx, []service.TaskResponse{{Title: "Invalid", Status: "archived"}})
+	assert.Error(t, err, "недопустимый статус")
+}
+
+func testGetTasks(t *testing.T, r service.Repository) {
+	first := create(t, r, "First", "")
+	second := create(t, r, "Second", "")
+
+	tasks, err := r.GetTasks(context.Background(), []int{second, first + second + 1, first, second})
+	require.NoError(t, err)
+	assert.Equal(t, []int{first, second}, ids(tasks), "по возрастанию ID, без отсутствующих и повторов")
+	assert.Equal(t, "Second", tasks[1].Title)
+}
+
+// eventStore - хранилище с outbox; хранилища без него, например кеш, тест пропускают
+type eventStore interface {
+	service.EventStore
+	outbox.Store
+}
+
+func testOutbox(t *testing.T, r service.Repository) {
+	store, ok := r.(eventStore)
+	if !ok {
+		t.Skip("хранилище не поддерживает outbox")
+	}
+	ctx := context.Background()
+
+	event := func(typ string, taskID int) service.Event {
+		return service.Event{Type: typ, Version: 1, TaskID: taskID, Payload: []byte(fmt.Sprintf(`{"task":{"id":%d}}`, taskID))}
+	}
+	claim := func() []service.Event {
+		t.Helper()
+		events, err := store.ClaimEvents(ctx, 10, time.Minute)
+		require.NoError(t, err)
+		return events
+	}
+	eventIDs := func(events []service.Event) []int64 {
+		ids := make([]int64, 0, len(events))
+		for _, e := range events {
+			ids = append(ids, e.ID)
+		}
+		return ids
+	}
+
+	// События откаченной транзакции не сохраняются
+	err := r.WithinTx(ctx, service.TxOptions{}, func(ctx context.Context) error {
+		if err := store.AppendEvents(ctx, []service.Event{event(service.EventTaskCreated, 1)}); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	require.ErrorIs(t, err, errAbort)
+	assert.Empty(t, claim())
+
+	require.NoError(t, store.AppendEvents(ctx, []service.Event{
+		event(service.EventTaskCreated, 1),
+		event(service.EventTaskCreated, 2),
+		event(service.EventTaskUpdated, 1),
+	}))
+
+	events := claim()
+	require.Len(t, events, 3)
+	assert.Equal(t, []int{1, 2, 1}, []int{events[0].TaskID, events[1].TaskID, events[2].TaskID}, "по порядку записи")
+	assert.Less(t, events[0].ID, events[1].ID)
+	assert.Less(t, events[1].ID, events[2].ID)
+	assert.Equal(t, service.EventTaskUpdated, events[2].Type)
+	assert.Equal(t, 1, events[2].Version)
+	assert.JSONEq(t, `{"task":{"id":1}}`, string(events[2].Payload))
+	assert.False(t, events[0].OccurredAt.IsZero())
+	assert.Zero(t, events[0].Attempts)
+	assert.Empty(t, claim(), "выбранные события недоступны до окончания аренды")
+
+	// Пока первое событие задачи ждёт повтора, следующие события задачи не выбираются
+	require.NoError(t, store.RetryEvent(ctx, events[0].ID, time.Hour, "unavailable"))
+	require.NoError(t, store.CompleteEvents(ctx, []int64{events[1].ID}))
+	require.NoError(t, store.ReleaseEvents(ctx, []int64{events[2].ID}))
+	assert.Empty(t, claim())
+
+	// Повтор без паузы: событие выбирается снова с учётом попыток
+	require.NoError(t, store.RetryEvent(ctx, events[0].ID, 0, "unavailable"))
+	retried := claim()
+	assert.Equal(t, []int64{events[0].ID, events[2].ID}, eventIDs(retried))
+	require.Len(t, retried, 2)
+	assert.Equal(t, 2, retried[0].Attempts, "обе неудачные попытки учтены")
+
+	require.NoError(t, store.CompleteEvents(ctx, eventIDs(retried)))
+	require.NoError(t, store.ReleaseEvents(ctx, eventIDs(retried)), "удалённые события пропускаются")
+	assert.Empty(t, claim())
+}
+
+// webhookStore - хранилище подписок и доставок; хранилища без них, например кеш, тест пропускают
+type webhookStore interface {
+	service.WebhookStore
+	webhook.Store
+}
+
+func testWebhooks(t *testing.T, r service.Repository) {
+	store, ok := r.(webhookStore)
+	if !ok {
+		t.Skip("хранилище не поддерживает подписки")
+	}
+	ctx := context.Background()
+
+	create := func(url string, active bool, eventTypes ...string) *service.Webhook {
+		t.Helper()
+		hook, err := store.CreateWebhook(ctx, service.NewWebhook{
+			URL: url, EventTypes: append([]string{}, eventTypes...), Secret: "secret-" + url, Active: active,
+		})
+		require.NoError(t, err)
+		return hook
+	}
+	list := func(webhookID int, status string) []service.Delivery {
+		t.Helper()
+		deliveries, err := store.ListDeliveries(ctx, webhookID, service.DeliveryFilter{Status: status, Limit: 10})
+		require.NoError(t, err)
+		return deliveries
+	}
+	claim := func() []service.PendingDelivery {
+		t.Helper()
+		claimed, err := store.ClaimDeliveries(ctx, 10, time.Minute)
+		require.NoError(t, err)
+		return claimed
+	}
+	enqueue := func(eventID int64, eventType string) int {
+		t.Helper()
+		n, err := store.EnqueueDeliveries(ctx, eventID, eventType, int(eventID), []byte(fmt.Sprintf(`{"id":%d}`, eventID)))
+		require.NoError(t, err)
+		return n
+	}
+
+	all := create("https://a.example", true)
+	deleted := create("https://b.example", true, service.EventTaskDeleted)
+	inactive := create("https://c.example", false)
+	assert.Equal(t, []string{}, all.EventTypes)
+	assert.False(t, all.CreatedAt.IsZero())
+
+	got, err := store.GetWebhook(ctx, deleted.ID)
+	require.NoError(t, err)
+	assert.Equal(t, deleted, got)
+	hooks, err := store.ListWebhooks(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []service.Webhook{*all, *deleted, *inactive}, hooks)
+
+	url := "https://b2.example"
+	updated, err := store.UpdateWebhook(ctx, deleted.ID, service.WebhookUpdate{URL: &url})
+	require.NoError(t, err)
+	assert.Equal(t, url, updated.URL)
+	assert.Equal(t, []string{service.EventTaskDeleted}, updated.EventTypes, "непереданные поля не меняются")
+
+	_, err = store.GetWebhook(ctx, 999)
+	assert.ErrorIs(t, err, service.ErrWebhookNotFound)
+	_, err = store.UpdateWebhook(ctx, 999, service.WebhookUpdate{URL: &url})
+	assert.ErrorIs(t, err, service.ErrWebhookNotFound)
+	assert.ErrorIs(t, store.DeleteWebhook(ctx, 999), service.ErrWebhookNotFound)
+	_, err = store.ListDeliveries(ctx, 999, service.DeliveryFilter{Limit: 10})
+	assert.ErrorIs(t, err, service.ErrWebhookNotFound)
+
+	// Доставки создаются активным подпискам на тип события, повтор события дубликатов не создаёт
+	assert.Equal(t, 1, enqueue(1, service.EventTaskCreated))
+	assert.Equal(t, 2, enqueue(2, service.EventTaskDeleted))
+	assert.Zero(t, enqueue(2, service.EventTaskDeleted))
+	assert.Empty(t, list(inactive.ID, ""))
+	require.Len(t, list(deleted.ID, ""), 1)
+
+	pending := list(all.ID, "")
+	require.Len(t, pending, 2)
+	assert.Equal(t, []int64{2, 1}, []int64{pending[0].EventID, pending[1].EventID}, "новые первыми")
+	assert.Equal(t, service.DeliveryPending, pending[0].Status)
+	assert.JSONEq(t, `{"id":2}`, string(pending[0].Payload))
+	assert.Zero(t, pending[0].Attempts)
+	assert.Nil(t, pending[0].DeliveredAt)
+
+	claimed := claim()
+	require.Len(t, claimed, 3)
+	assert.Less(t, claimed[0].ID, claimed[1].ID)
+	assert.Equal(t, all.URL, claimed[0].URL)
+	assert.Equal(t, "secret-"+all.URL, claimed[0].Secret)
+	assert.Equal(t, service.EventTaskCreated, claimed[0].EventType)
+	assert.Empty(t, claim(), "выбранные доставки недоступны до окончания аренды")
+
+	// Повтор без паузы, доставка и dead letter
+	require.NoError(t, store.RecordAttempt(ctx, claimed[0].ID, service.DeliveryAttempt{
+		Status: service.DeliveryPending, StatusCode: 503, Error: "unexpected status 503",
+	}))
+	require.NoError(t, store.RecordAttempt(ctx, claimed[1].ID, service.DeliveryAttempt{Status: service.DeliveryDelivered, StatusCode: 200}))
+	require.NoError(t, store.RecordAttempt(ctx, claimed[2].ID, service.DeliveryAttempt{Status: service.DeliveryDead, Error: "connection refused"}))
+
+	retried := claim()
+	require.Len(t, retried, 1)
+	assert.Equal(t, claimed[0].ID, retried[0].ID)
+	assert.Equal(t, 1, retried[0].Attempts)
+	assert.Equal(t, 503, retried[0].LastStatusCode)
+	assert.Equal(t, "unexpected status 503", retried[0].LastError)
+
+	delivered := list(all.ID, service.DeliveryDelivered)
+	require.Len(t, delivered, 1)
+	assert.Equal(t, claimed[1].ID, delivered[0].ID)
+	assert.NotNil(t, delivered[0].DeliveredAt)
+	dead := list(deleted.ID, service.DeliveryDead)
+	require.Len(t, dead, 1)
+	assert.Zero(t, dead[0].LastStatusCode)
+	assert.Equal(t, "connection refused", dead[0].LastError)
+
+	page, err := store.ListDeliveries(ctx, all.ID, service.DeliveryFilter{Limit: 1, Offset: 1})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, int64(1), page[0].EventID)
+
+	// Ручной повтор: только своей подписки, счётчик попыток сбрасывается
+	_, err = store.ReplayDelivery(ctx, all.ID, dead[0].ID)
+	assert.ErrorIs(t, err, service.ErrDeliveryNotFound)
+	replayed, err := store.ReplayDelivery(ctx, deleted.ID, dead[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, service.DeliveryPending, replayed.Status)
+	assert.Zero(t, replayed.Attempts)
+	again := claim()
+	require.Len(t, again, 1)
+	assert.Equal(t, dead[0].ID, again[0].ID)
+	_, err = store.ReplayDelivery(ctx, deleted.ID, dead[0].ID)
+	assert.ErrorIs(t, err, service.ErrDeliveryInFlight, "выбранная доставка не сбрасывается до окончания аренды")
+
+	// Неактивная подписка: доставки ждут, пока её не включат
+	off := false
+	_, err = store.UpdateWebhook(ctx, all.ID, service.WebhookUpdate{Active: &off})
+	require.NoError(t, err)
+	require.NoError(t, store.RecordAttempt(ctx, retried[0].ID, service.DeliveryAttempt{Status: service.DeliveryPending}))
+	assert.Empty(t, claim())
+
+	// Удаляются только завершённые доставки старше retention
+	time.Sleep(10 * time.Millisecond)
+	purged, err := store.PurgeDeliveries(ctx, time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	assert.Len(t, list(all.ID, ""), 1)
+
+	// Удаление подписки удаляет её доставки, запись попытки удалённой доставки пропускается
+	require.NoError(t, store.DeleteWebhook(ctx, deleted.ID))
+	require.NoError(t, store.RecordAttempt(ctx, again[0].ID, service.DeliveryAttempt{Status: service.DeliveryDelivered}))
+	assert.Zero(t, enqueue(3, service.EventTaskDeleted), "удалённой и неактивным подпискам доставки не создаются")
+	_, err = store.GetWebhook(ctx, deleted.ID)
+	assert.ErrorIs(t, err, service.ErrWebhookNotFound)
+
+	// Доставки одной задачи выбираются по порядку: следующая ждёт, пока предыдущая в pending
+	ordered := create("https://d.example", true)
+	for _, event := range []struct {
+		id     int64
+		taskID int
+	}{{4, 7}, {5, 7}, {6, 8}} {
+		_, err := store.EnqueueDeliveries(ctx, event.id, service.EventTaskUpdated, event.taskID, []byte(`{}`))
+		require.NoError(t, err)
+	}
+	eventIDs := func(claimed []service.PendingDelivery) []int64 {
+		ids := make([]int64, 0, len(claimed))
+		for _, p := range claimed {
+			ids = append(ids, p.EventID)
+		}
+		return ids
+	}
+	first := claim()
+	assert.Equal(t, []int64{4, 6}, eventIDs(first))
+	require.NoError(t, store.RecordAttempt(ctx, first[0].ID, service.DeliveryAttempt{Status: service.DeliveryPending}))
+	require.NoError(t, store.RecordAttempt(ctx, first[1].ID, service.DeliveryAttempt{Status: service.DeliveryDelivered}))
+	assert.Equal(t, []int64{4}, eventIDs(claim()), "повтор предыдущей доставки задачи")
+	require.NoError(t, store.RecordAttempt(ctx, first[0].ID, service.DeliveryAttempt{Status: service.DeliveryDead}))
+	assert.Equal(t, []int64{5}, eventIDs(claim()), "после dead letter предыдущей")
+	require.NoError(t, store.DeleteWebhook(ctx, ordered.ID))
+}
+
+func testDefaults(t *testing.T, r service.Repository) {
+	task := get(t, r, create(t, r, "Defaults", ""))
+
+	assert.Equal(t, service.StatusNew, task.Status)
+	assert.Empty(t, task.Description)
+	assert.False(t, task.CreatedAt.IsZero())
+	assert.Equal(t, task.CreatedAt, task.UpdatedAt, "new task must have updated_at equal to created_at")
+}
+
+func testNotFound(t *testing.T, r service.Repository) {
+	ctx := context.Background()
+	missing := create(t, r, "Exists", "") + 1000
+
+	_, err := r.GetTask(ctx, missing)
+	assert.ErrorIs(t, err, service.ErrTaskNotFound)
+
+	_, err = r.UpdateTask(ctx, missing, service.TaskUpdate{Title: ptr("x")})
+	assert.ErrorIs(t, err, service.ErrTaskNotFound)
+
+	assert.ErrorIs(t, r.DeleteTask(ctx, missing), service.ErrTaskNotFound)
+
+	for _, id := range []int{0, -1} {
+		_, err = r.GetTask(ctx, id)
+		assert.ErrorIs(t, err, service.ErrTaskNotFound, "id %d", id)
+	}
+}
+
+func testUpdate(t *testing.T, r service.Repository) {
+	ctx := context.Background()
+	id := create(t, r, "Title", "Description")
+
+	tests := []struct {
+		name   string
+		update service.TaskUpdate
+		want   service.TaskResponse
+	}{
+		{
+			name:   "Только статус",
+			update: service.TaskUpdate{Status: ptr(service.StatusInProgress)},
+			want:   service.TaskResponse{Title: "Title", Description: "Description", Status: service.StatusInProgress},
+		},
+		{
+			name:   "Только заголовок",
+			update: service.TaskUpdate{Title: ptr("New title")},
+			want:   service.TaskResponse{Title: "New title", Description: "Description", Status: service.StatusInProgress},
+		},
+		{
+			name:   "Пустое описание очищает поле",
+			update: service.TaskUpdate{Description: ptr("")},
+			want:   service.TaskResponse{Title: "New title", Description: "", Status: service.StatusInProgress},
+		},
+		{
+			name:   "Все поля",
+			update: service.TaskUpdate{Title: ptr("Final"), Description: ptr("Done"), Status: ptr(service.StatusDone)},
+			want:   service.TaskResponse{Title: "Final", Description: "Done", Status: service.StatusDone},
+		},
+		{
+			name:   "Без полей",
+			update: service.TaskUpdate{},
+			want:   service.TaskResponse{Title: "Final", Description: "Done", Status: service.StatusDone},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated, err := r.UpdateTask(ctx, id, tt.update)
+			require.NoError(t, err)
+
+			stored := get(t, r, id)
+			assert.Equal(t, stored, updated, "update must return the stored task")
+			assert.Equal(t, id, stored.ID)
+			assert.Equal(t, tt.want.Title, stored.Title)
+			assert.Equal(t, tt.want.Description, stored.Description)
+			assert.Equal(t, tt.want.Status, stored.Status)
+		})
+	}
+}
+
+func testInvalidStatus(t *testing.T, r service.Repository) {
+	id := create(t, r, "Task", "")
+
+	_, err := r.UpdateTask(context.Background(), id, service.TaskUpdate{Title: ptr("Changed"), Status: ptr("closed")})
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, service.ErrTaskNotFound)
+
+	// Обновление не применяется частично
+	task := get(t, r, id)
+	assert.Equal(t, "Task", task.Title)
+	assert.Equal(t, service.StatusNew, task.Status)
+}
+
+func testDelete(t *testing.T, r service.Repository) {
+	ctx := context.Background()
+	first := create(t, r, "First", "")
+	second := create(t, r, "Second", "")
+
+	require.NoError(t, r.DeleteTask(ctx, first))
+
+	_, err := r.GetTask(ctx, first)
+	assert.ErrorIs(t, err, service.ErrTaskNotFound)
+	assert.ErrorIs(t, r.DeleteTask(ctx, first), service.ErrTaskNotFound)
+	assert.Equal(t, "Second", get(t, r, second).Title)
+
+	// ID удалённых задач не выдаются повторно
+	third := create(t, r, "Third", "")
+	assert.Greater(t, third, second)
+}
+
+func testList(t *testing.T, r service.Repository) {
+	ctx := context.Background()
+
+	empty, err := r.ListTasks(ctx, service.ListFilter{Limit: 10})
+	require.NoError(t, err)
+	assert.NotNil(t, empty, "empty list must be an empty slice, not nil")
+	assert.Empty(t, empty)
+
+	var all []int
+	for i := 1; i <= 7; i++ {
+		all = append(all, create(t, r, fmt.Sprintf("Task %d", i), ""))
+	}
+	var done []int
+	for _, i := range []int{1, 4, 6} {
+		_, err := r.UpdateTask(ctx, all[i], service.TaskUpdate{Status: ptr(service.StatusDone)})
+		require.NoError(t, err)
+		done = append(done, all[i])
+	}
+
+	tests := []struct {
+		name   string
+		filter service.ListFilter
+		want   []int
+	}{
+		{name: "Все по возрастанию ID", filter: service.ListFilter{Limit: 100}, want: all},
+		{name: "Лимит", filter: service.ListFilter{Limit: 3}, want: all[:3]},
+		{name: "Смещение", filter: service.ListFilter{Limit: 3, Offset: 5}, want: all[5:]},
+		{name: "Смещение за концом", filter: service.ListFilter{Limit: 3, Offset: 7}, want: []int{}},
+		{name: "Фильтр по статусу", filter: service.ListFilter{Status: service.StatusDone, Limit: 100}, want: done},
+		{name: "Фильтр и страница", filter: service.ListFilter{Status: service.StatusDone, Limit: 1, Offset: 1}, want: done[1:2]},
+		{name: "Нет задач со статусом", filter: service.ListFilter{Status: service.StatusInProgress, Limit: 100}, want: []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tasks, err := r.ListTasks(ctx, tt.filter)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ids(tasks))
+			for _, task := range tasks {
+				assert.Equal(t, get(t, r, task.ID), &task)
+			}
+		})
+	}
+}
+
+func testTimestamps(t *testing.T, r service.Repository) {
+	ctx := context.Background()
+
+	var previous *service.TaskResponse
+	for i := 0; i < 5; i++ {
+		task := get(t, r, create(t, r, "Task", ""))
+		if previous != nil {
+			assert.False(t, task.CreatedAt.Before(previous.CreatedAt), "created_at must not decrease")
+		}
+		previous = task
+	}
+
+	id := previous.ID
+	before := get(t, r, id)
+	for _, status := range []string{service.StatusInProgress, service.StatusDone, service.StatusNew} {
+		updated, err := r.UpdateTask(ctx, id, service.TaskUpdate{Status: ptr(status)})
+		require.NoError(t, err)
+
+		assert.Equal(t, before.CreatedAt, updated.CreatedAt, "created_at must not change on update")
+		assert.False(t, updated.UpdatedAt.Before(before.UpdatedAt), "updated_at must not decrease")
+		assert.False(t, updated.UpdatedAt.Before(updated.CreatedAt), "updated_at must not be before created_at")
+		before = updated
+	}
+}
+
+func testUnicodeAndLength(t *testing.T, r service.Repository) {
+	tests := []struct {
+		name        string
+		title       string
+		description string
+	}{
+		{name: "Кириллица", title: "Задача", description: "Описание задачи"},
+		{name: "Эмодзи и составные символы", title: "🚀 Launch 👩‍💻", description: "é ñ 漢字 العربية"},
+		{name: "Спецсимволы SQL и JSON", title: `'; DROP TABLE tasks; --`, description: `"quoted" \backslash% _under`},
+		{name: "Переводы строк и табуляция", title: "line\tone", description: "first\nsecond\r\nthird"},
+		{name: "Максимальная длина в многобайтных символах", title: strings.Repeat("я", 255), description: strings.Repeat("🙂", 1000)},
+		{name: "Один символ", title: "x", description: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := get(t, r, create(t, r, tt.title, tt.description))
+			assert.Equal(t, tt.title, task.Title)
+			assert.Equal(t, tt.description, task.Description)
+
+			updated, err := r.UpdateTask(context.Background(), task.ID, service.TaskUpdate{Title: ptr(tt.description + tt.title)})
+			require.NoError(t, err)
+			assert.Equal(t, tt.description+tt.title, updated.Title)
+		})
+	}
+}
+
+func testConcurrentCreate(t *testing.T, r service.Repository) {
+	const workers, perWorker = 8, 25
+	ctx := context.Background()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		seen = make(map[int]string)
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				title := fmt.Sprintf("worker %d task %d", w, i)
+				id, err := r.CreateTask(ctx, service.Task{Title: title})
+				if !assert.NoError(t, err) {
+					return
+				}
+
+				mu.Lock()
+				other, duplicate := seen[id]
+				seen[id] = title
+				mu.Unlock()
+				assert.False(t, duplicate, "id %d assigned to %q and %q", id, other, title)
+
+				// Чтение во время записи других горутин
+				_, err = r.ListTasks(ctx, service.ListFilter{Limit: 10})
+				assert.NoError(t, err)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	require.Len(t, seen, workers*perWorker)
+	tasks, err := r.ListTasks(ctx, service.ListFilter{Limit: workers * perWorker})
+	require.NoError(t, err)
+	require.Len(t, tasks, workers*perWorker)
+	for _, task := range tasks {
+		assert.Equal(t, seen[task.ID], task.Title)
+	}
+}
+
+func testConcurrentUpdate(t *testing.T, r service.Repository) {
+	const writers = 8
+	ctx := context.Background()
+	id := create(t, r, "Shared", "")
+
+	titles := make(map[string]bool, writers)
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		title := fmt.Sprintf("writer %d", w)
+		titles[title] = true
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				_, err := r.UpdateTask(ctx, id, service.TaskUpdate{Title: ptr(title), Description: ptr(title)})
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Каждое обновление атомарно: заголовок и описание от одного писателя
+	task := get(t, r, id)
+	assert.True(t, titles[task.Title], "unexpected title %q", task.Title)
+	assert.Equal(t, task.Title, task.Description)
+}
+
+func testCanceledContext(t *testing.T, r service.Repository) {
+	id := create(t, r, "Task", "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := r.CreateTask(ctx, service.Task{Title: "Canceled"})
+	assert.Error(t, err)
+	_, err = r.GetTask(ctx, id)
+	assert.Error(t, err)
+	_, err = r.ListTasks(ctx, service.ListFilter{Limit: 10})
+	assert.Error(t, err)
+	_, err = r.UpdateTask(ctx, id, service.TaskUpdate{Title: ptr("Canceled")})
+	assert.Error(t, err)
+	assert.Error(t, r.DeleteTask(ctx, id))
+
+	// Ничего не изменилось
+	tasks, err := r.ListTasks(context.Background(), service.ListFilter{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, []int{id}, ids(tasks))
+	assert.Equal(t, "Task", tasks[0].Title)
+}
+
+// errAbort - ошибка fn, откатывающая транзакцию
+var errAbort = errors.New("abort transaction")
+
+func testTxCommit(t *testing.T, r service.Repository) {
+	existing := create(t, r, "Existing", "")
+	removed := create(t, r, "Removed", "")
+
+	var created int
+	err := r.WithinTx(context.Background(), service.TxOptions{}, func(ctx context.Context) error {
+		var err error
+		created, err = r.CreateTask(ctx, service.Task{Title: "In tx"})
+		if err != nil {
+			return err
+		}
+
+		// Транзакция видит свои изменения
+		task, err := r.GetTask(ctx, created)
+		if err != nil {
+			return err
+		}
+		assert.Equal(t, "In tx", task.Title)
+
+		if _, err := r.UpdateTask(ctx, existing, service.TaskUpdate{Status: ptr(service.StatusDone)}); err != nil {
+			return err
+		}
+		return r.DeleteTask(ctx, removed)
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "In tx", get(t, r, created).Title)
+	assert.Equal(t, service.StatusDone, get(t, r, existing).Status)
+	_, err = r.GetTask(context.Background(), removed)
+	assert.ErrorIs(t, err, service.ErrTaskNotFound)
+}
+
+func testTxRollback(t *testing.T, r service.Repository) {
+	existing := create(t, r, "Existing", "before")
+
+	var created int
+	err := r.WithinTx(context.Background(), service.TxOptions{Isolation: service.IsolationSerializable}, func(ctx context.Context) error {
+		var err error
+		if created, err = r.CreateTask(ctx, service.Task{Title: "Rolled back"}); err != nil {
+			return err
+		}
+		if _, err := r.UpdateTask(ctx, existing, service.TaskUpdate{Description: ptr("after")}); err != nil {
+			return err
+		}
+		return fmt.Errorf("business rule: %w", errAbort)
+	})
+	assert.ErrorIs(t, err, errAbort, "ошибка fn возвращается без потери типа")
+
+	_, err = r.GetTask(context.Background(), created)
+	assert.ErrorIs(t, err, service.ErrTaskNotFound)
+	assert.Equal(t, "before", get(t, r, existing).Description)
+
+	// ID из откаченной транзакции не выдаются повторно, как значения sequence
+	next := create(t, r, "Next", "")
+	assert.Greater(t, next, created)
+
+	// Ошибка репозитория внутри транзакции тоже откатывает её
+	err = r.WithinTx(context.Background(), service.TxOptions{}, func(ctx context.Context) error {
+		if _, err := r.UpdateTask(ctx, existing, service.TaskUpdate{Description: ptr("after")}); err != nil {
+			return err
+		}
+		return r.DeleteTask(ctx, created)
+	})
+	assert.ErrorIs(t, err, service.ErrTaskNotFound)
+	assert.Equal(t, "before", get(t, r, existing).Description)
+}
+
+func testTxNested(t *testing.T, r service.Repository) {
+	var outer, inner int
+	err := r.WithinTx(context.Background(), service.TxOptions{}, func(ctx context.Context) error {
+		var err error
+		if outer, err = r.CreateTask(ctx, service.Task{Title: "Outer"}); err != nil {
+			return err
+		}
+		err = r.WithinTx(ctx, service.TxOptions{Isolation: service.IsolationSerializable}, func(ctx context.Context) error {
+			inner, err = r.CreateTask(ctx, service.Task{Title: "Inner"})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		return errAbort
+	})
+	assert.ErrorIs(t, err, errAbort)
+
+	// Вложенная транзакция - часть внешней и откатывается вместе с ней
+	for _, id := range []int{outer, inner} {
+		_, err = r.GetTask(context.Background(), id)
+		assert.ErrorIs(t, err, service.ErrTaskNotFound)
+	}
+}
+
+func testTxReadOnly(t *testing.T, r service.Repository) {
+	id := create(t, r, "Task", "")
+
+	err := r.WithinTx(context.Background(), service.TxOptions{ReadOnly: true}, func(ctx context.Context) error {
+		task, err := r.GetTask(ctx, id)
+		if err != nil {
+			return err
+		}
+		assert.Equal(t, "Task", task.Title)
+
+		tasks, err := r.ListTasks(ctx, service.ListFilter{Limit: 10})
+		if err != nil {
+			return err
+		}
+		assert.Equal(t, []int{id}, ids(tasks))
+
+		_, err = r.UpdateTask(ctx, id, service.TaskUpdate{Title: ptr("Changed")})
+		return err
+	})
+	assert.Error(t, err, "запись в транзакции только для чтения")
+	assert.Equal(t, "Task", get(t, r, id).Title)
+}
@@ -0,0 +1,201 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"simple-service/internal/config"
+	"simple-service/internal/metrics"
+	"simple-service/internal/service"
+	"simple-service/pkg/backoff"
+)
+
+// errorClass - класс ошибки запроса к БД для решения о повторе
+type errorClass int
+
+const (
+	// classNone - запрос выполнен
+	classNone errorClass = iota
+	// classFatal - повтор не поможет: нет строки, нарушение ограничения, ошибка в запросе, отмена клиентом
+	classFatal
+	// classTransient - транзакция откачена сервером (конфликт сериализации, deadlock), повтор безопасен
+	classTransient
+	// classUnavailable - нет соединения или сервер останавливается
+	classUnavailable
+)
+
+func (c errorClass) String() string {
+	switch c {
+	case classNone:
+		return "none"
+	case classTransient:
+		return "transient"
+	case classUnavailable:
+		return "unavailable"
+	}
+	return "fatal"
+}
+
+// SQLSTATE коды, которые имеет смысл повторять
+var (
+	transientCodes = map[string]struct{}{
+		"40001": {}, // serialization_failure
+		"40P01": {}, // deadlock_detected
+	}
+	unavailableCodes = map[string]struct{}{
+		"57P01": {}, // admin_shutdown
+		"57P02": {}, // crash_shutdown
+		"57P03": {}, // cannot_connect_now
+		"53300": {}, // too_many_connections
+	}
+)
+
+// classify - классификация ошибки pgx
+func classify(err error) errorClass {
+	if err == nil {
+		return classNone
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return classFatal
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		if _, ok := transientCodes[pgErr.Code]; ok {
+			return classTransient
+		}
+		// Класс 08 - connection exception
+		if _, ok := unavailableCodes[pgErr.Code]; ok || len(pgErr.Code) == 5 && pgErr.Code[:2] == "08" {
+			return classUnavailable
+		}
+		return classFatal
+	}
+
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &connectErr),
+		errors.As(err, &netErr),
+		errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, net.ErrClosed),
+		pgconn.SafeToRetry(err):
+		return classUnavailable
+	}
+
+	return classFatal
+}
+
+// isTimeout - запрос прерван по бюджету времени: истёк дедлайн контекста
+// или сервер отменил запрос по statement_timeout (query_canceled)
+func isTimeout(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.Is(err, context.DeadlineExceeded) || errors.As(err, &pgErr) && pgErr.Code == "57014"
+}
+
+// timeoutError - ошибка, совпадающая с service.ErrTimeout через errors.Is
+func timeoutError(err error) error {
+	return fmt.Errorf("%w: %w", service.ErrTimeout, err)
+}
+
+// outcomeUnknownError - ошибка, совпадающая с service.ErrOutcomeUnknown через errors.Is
+func outcomeUnknownError(err error) error {
+	return fmt.Errorf("%w: %w", service.ErrOutcomeUnknown, err)
+}
+
+// guard - повторы временных ошибок и circuit breaker для запросов к БД
+type guard struct {
+	attempts  int
+	baseDelay time.Duration
+	maxDelay  time.Duration
+	breaker   *breaker
+
+	// sleep подменяется в тестах
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+func newGuard(cfg config.PostgreSQL) *guard {
+	g := &guard{
+		attempts:  cfg.RetryAttempts,
+		baseDelay: cfg.RetryBaseDelay,
+		maxDelay:  cfg.RetryMaxDelay,
+		breaker:   newBreaker(cfg.BreakerThreshold, cfg.BreakerOpenTimeout),
+		sleep:     sleep,
+	}
+	if g.attempts < 1 {
+		g.attempts = 1
+	}
+	return g
+}
+
+// do - выполнение fn с повторами. Идемпотентные операции повторяются и после потери соединения,
+// остальные - только если сервер точно не получил запрос или откатил транзакцию.
+// Недоступность БД возвращается как *service.UnavailableError, а потеря соединения после отправки
+// неидемпотентного запроса - как service.ErrOutcomeUnknown: запрос мог быть выполнен
+func (g *guard) do(ctx context.Context, op string, idempotent bool, fn func(ctx context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		if wait, ok := g.breaker.allow(); !ok {
+			metrics.DBBreakerRejected.Inc()
+			return &service.UnavailableError{RetryAfter: wait, Err: errors.New("database circuit breaker is open")}
+		}
+
+		err := fn(ctx)
+		class := classify(err)
+		switch {
+		case class == classUnavailable:
+			g.breaker.failure()
+		case ctx.Err() != nil:
+			g.breaker.release()
+		default:
+			g.breaker.success()
+		}
+
+		if err == nil {
+			return nil
+		}
+
+		retry := class == classTransient ||
+			class == classUnavailable && (idempotent || pgconn.SafeToRetry(err))
+		if !retry || attempt >= g.attempts || ctx.Err() != nil {
+			switch {
+			case isTimeout(err):
+				return timeoutError(err)
+			case class == classUnavailable && !idempotent && !pgconn.SafeToRetry(err):
+				return outcomeUnknownError(err)
+			case class == classUnavailable:
+				return &service.UnavailableError{RetryAfter: g.breaker.retryAfter(), Err: err}
+			}
+			return err
+		}
+
+		metrics.DBRetries.WithLabelValues(op, class.String()).Inc()
+		if sleepErr := g.sleep(ctx, backoff.Exponential(g.baseDelay, g.maxDelay, attempt)); sleepErr != nil {
+			if isTimeout(sleepErr) {
+				return timeoutError(err)
+			}
+			return err
+		}
+	}
+}
+
+// sleep - ожидание с учётом отмены контекста
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,263 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"simple-service/internal/config"
+	"simple-service/internal/service"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want errorClass
+	}{
+		{name: "Без ошибки", err: nil, want: classNone},
+		{name: "Нет строки", err: pgx.ErrNoRows, want: classFatal},
+		{name: "Нарушение ограничения", err: &pgconn.PgError{Code: "23514"}, want: classFatal},
+		{name: "Конфликт сериализации", err: &pgconn.PgError{Code: "40001"}, want: classTransient},
+		{name: "Deadlock", err: pkgerrors.Wrap(&pgconn.PgError{Code: "40P01"}, "failed to update task"), want: classTransient},
+		{name: "Остановка сервера", err: &pgconn.PgError{Code: "57P01"}, want: classUnavailable},
+		{name: "Сервер запускается", err: &pgconn.PgError{Code: "57P03"}, want: classUnavailable},
+		{name: "Слишком много соединений", err: &pgconn.PgError{Code: "53300"}, want: classUnavailable},
+		{name: "Ошибка соединения 08006", err: &pgconn.PgError{Code: "08006"}, want: classUnavailable},
+		{name: "Обрыв соединения", err: io.ErrUnexpectedEOF, want: classUnavailable},
+		{name: "Сетевая ошибка", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, want: classUnavailable},
+		{name: "Отмена клиентом", err: context.Canceled, want: classFatal},
+		{name: "Дедлайн", err: pkgerrors.Wrap(context.DeadlineExceeded, "failed to get task"), want: classFatal},
+		{name: "Неизвестная ошибка", err: errors.New("boom"), want: classFatal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, classify(tt.err))
+		})
+	}
+}
+
+// fakeClock - управляемое время для breaker
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestBreaker(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)}
+	b := newBreaker(3, 10*time.Second)
+	b.now = clock.now
+
+	// Успешный ответ сбрасывает счётчик ошибок
+	b.failure()
+	b.failure()
+	b.success()
+	b.failure()
+	b.failure()
+	assert.Equal(t, BreakerClosed, b.State())
+	_, ok := b.allow()
+	assert.True(t, ok)
+
+	// Третья ошибка подряд открывает breaker
+	b.failure()
+	assert.Equal(t, BreakerOpen, b.State())
+	wait, ok := b.allow()
+	assert.False(t, ok)
+	assert.Equal(t, 10*time.Second, wait)
+
+	clock.advance(4 * time.Second)
+	wait, ok = b.allow()
+	assert.False(t, ok)
+	assert.Equal(t, 6*time.Second, wait)
+
+	// После таймаута пропускается один пробный запрос
+	clock.advance(6 * time.Second)
+	assert.Equal(t, BreakerHalfOpen, b.State())
+	_, ok = b.allow()
+	assert.True(t, ok)
+	_, ok = b.allow()
+	assert.False(t, ok, "второй запрос во время пробного отклоняется")
+
+	// Неудачная проба снова открывает breaker
+	b.failure()
+	assert.Equal(t, BreakerOpen, b.State())
+	_, ok = b.allow()
+	assert.False(t, ok)
+
+	// Отменённая проба не решает судьбу breaker
+	clock.advance(10 * time.Second)
+	_, ok = b.allow()
+	assert.True(t, ok)
+	b.release()
+	_, ok = b.allow()
+	assert.True(t, ok)
+
+	// Удачная проба закрывает breaker
+	b.success()
+	assert.Equal(t, BreakerClosed, b.State())
+
+	t.Run("Порог 0 отключает breaker", func(t *testing.T) {
+		b := newBreaker(0, time.Second)
+		for i := 0; i < 10; i++ {
+			b.failure()
+		}
+		_, ok := b.allow()
+		assert.True(t, ok)
+		assert.Equal(t, BreakerClosed, b.State())
+	})
+}
+
+func newTestGuard(threshold int) (*guard, *[]time.Duration) {
+	g := newGuard(config.PostgreSQL{
+		RetryAttempts:      3,
+		RetryBaseDelay:     100 * time.Millisecond,
+		RetryMaxDelay:      time.Second,
+		BreakerThreshold:   threshold,
+		BreakerOpenTimeout: 10 * time.Second,
+	})
+	sleeps := &[]time.Duration{}
+	g.sleep = func(_ context.Context, d time.Duration) error {
+		*sleeps = append(*sleeps, d)
+		return nil
+	}
+	return g, sleeps
+}
+
+// notSentError - ошибка соединения до отправки запроса, как её помечает pgconn
+type notSentError struct{}
+
+func (notSentError) Error() string     { return "failed to write startup message" }
+func (notSentError) SafeToRetry() bool { return true }
+
+func TestGuard(t *testing.T) {
+	connLost := &net.OpError{Op: "read", Err: errors.New("connection reset by peer")}
+	notSent := &net.OpError{Op: "write", Err: notSentError{}}
+
+	tests := []struct {
+		name       string
+		idempotent bool
+		errs       []error
+		wantCalls  int
+		wantErr    error
+	}{
+		{
+			name:       "Успех с первой попытки",
+			idempotent: true,
+			errs:       []error{nil},
+			wantCalls:  1,
+		},
+		{
+			name:      "Конфликт сериализации повторяется и для неидемпотентных",
+			errs:      []error{&pgconn.PgError{Code: "40001"}, nil},
+			wantCalls: 2,
+		},
+		{
+			name:       "Потеря соединения повторяется для идемпотентных",
+			idempotent: true,
+			errs:       []error{connLost, connLost, nil},
+			wantCalls:  3,
+		},
+		{
+			name:      "Потеря соединения не повторяется для неидемпотентных, исход неизвестен",
+			errs:      []error{connLost},
+			wantCalls: 1,
+			wantErr:   service.ErrOutcomeUnknown,
+		},
+		{
+			name:      "Запрос не отправлен - повтор безопасен и для неидемпотентных",
+			errs:      []error{notSent, notSent, notSent},
+			wantCalls: 3,
+			wantErr:   service.ErrUnavailable,
+		},
+		{
+			name:       "Попытки закончились",
+			idempotent: true,
+			errs:       []error{connLost, connLost, connLost},
+			wantCalls:  3,
+			wantErr:    service.ErrUnavailable,
+		},
+		{
+			name:       "statement_timeout - исчерпан бюджет запроса",
+			idempotent: true,
+			errs:       []error{&pgconn.PgError{Code: "57014"}},
+			wantCalls:  1,
+			wantErr:    service.ErrTimeout,
+		},
+		{
+			name:       "Дедлайн контекста",
+			idempotent: true,
+			errs:       []error{pkgerrors.Wrap(context.DeadlineExceeded, "timeout")},
+			wantCalls:  1,
+			wantErr:    service.ErrTimeout,
+		},
+		{
+			name:       "Ошибка запроса не повторяется",
+			idempotent: true,
+			errs:       []error{pgx.ErrNoRows},
+			wantCalls:  1,
+			wantErr:    pgx.ErrNoRows,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, sleeps := newTestGuard(0)
+
+			calls := 0
+			err := g.do(context.Background(), "test", tt.idempotent, func(context.Context) error {
+				err := tt.errs[calls]
+				calls++
+				return err
+			})
+
+			assert.Equal(t, tt.wantCalls, calls)
+			assert.Len(t, *sleeps, tt.wantCalls-1)
+			for i, d := range *sleeps {
+				base := 100 * time.Millisecond << i
+				assert.GreaterOrEqual(t, d, base/2)
+				assert.LessOrEqual(t, d, base)
+			}
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+			if errors.Is(tt.wantErr, service.ErrOutcomeUnknown) {
+				assert.NotErrorIs(t, err, service.ErrUnavailable)
+			}
+		})
+	}
+
+	t.Run("Открытый breaker отклоняет запросы без обращения к БД", func(t *testing.T) {
+		g, _ := newTestGuard(2)
+
+		calls := 0
+		down := func(context.Context) error {
+			calls++
+			return &pgconn.PgError{Code: "57P01"}
+		}
+
+		err := g.do(context.Background(), "test", true, down)
+		assert.Equal(t, 2, calls, "две ошибки подключения открывают breaker")
+		assert.Equal(t, BreakerOpen, g.breaker.State())
+
+		var unavailable *service.UnavailableError
+		require.ErrorAs(t, err, &unavailable)
+		assert.InDelta(t, 10*time.Second, unavailable.RetryAfter, float64(time.Second))
+
+		err = g.do(context.Background(), "test", true, down)
+		assert.Equal(t, 2, calls)
+		require.ErrorAs(t, err, &unavailable)
+		assert.ErrorIs(t, err, service.ErrUnavailable)
+	})
+}
@@ -0,0 +1,162 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+
+	"simple-service/internal/service"
+)
+
+// Выгрузка и загрузка задач
+const (
+	// Серверный курсор: строки читаются порциями по exportFetchSize, память не зависит от числа задач
+	declareExportQuery = `DECLARE tasks_export NO SCROLL CURSOR FOR
+		SELECT id, title, description, status, created_at, updated_at FROM tasks
+		WHERE ($1 = '' OR status = $1)
+			AND ($2::timestamp IS NULL OR created_at >= $2)
+			AND ($3::timestamp IS NULL OR created_at < $3)
+		ORDER BY id;`
+	fetchExportQuery = `FETCH 500 FROM tasks_export;`
+	importTaskQuery  = `INSERT INTO tasks (title, description, status, created_at, updated_at)
+		VALUES ($1, $2, $3, COALESCE($4, now()), COALESCE($5, now()))
+		RETURNING id;`
+	// Задача с занятым ID пропускается: строка не возвращается
+	importTaskWithIDQuery = `INSERT INTO tasks (id, title, description, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, COALESCE($5, now()), COALESCE($6, now()))
+		ON CONFLICT (id) DO NOTHING
+		RETURNING id;`
+	// Sequence только растёт: новые задачи не должны получить ID загруженных.
+	// setval не откатывается вместе с транзакцией, после отмены загрузки остаётся пропуск в ID
+	advanceTaskIDQuery = `SELECT setval('tasks_id_seq', $1) WHERE $1 > (SELECT last_value FROM tasks_id_seq);`
+)
+
+// ExportTasks - курсор выгрузки в транзакции REPEATABLE READ только для чтения: все порции
+// читаются с одного снимка данных. Выгрузка идёт с primary, на реплике долгий запрос может быть
+// отменён конфликтом с репликацией
+func (r *repository) ExportTasks(ctx context.Context, filter service.TaskFilter) (service.TaskCursor, error) {
+	var cursor *taskCursor
+	err := r.guard.do(ctx, "export", true, func(ctx context.Context) error {
+		tx, err := r.primary.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+		if err != nil {
+			return err
+		}
+		if err := setStatementTimeout(ctx, tx); err != nil {
+			_ = tx.Rollback(context.WithoutCancel(ctx))
+			return err
+		}
+		_, err = tx.Exec(ctx, declareExportQuery, filter.Status, utcTime(filter.CreatedAfter), utcTime(filter.CreatedBefore))
+		if err != nil {
+			_ = tx.Rollback(context.WithoutCancel(ctx))
+			return err
+		}
+		cursor = &taskCursor{tx: tx}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open export cursor")
+	}
+	return cursor, nil
+}
+
+// taskCursor - курсор tasks_export, соединение занято до Close
+type taskCursor struct {
+	tx pgx.Tx
+}
+
+// Next - следующая порция курсора. Выгрузка длится дольше одного запроса, поэтому statement_timeout
+// перед каждой порцией обновляется по оставшемуся бюджету
+func (c *taskCursor) Next(ctx context.Context) ([]service.TaskResponse, error) {
+	if err := setStatementTimeout(ctx, c.tx); err != nil {
+		if isTimeout(err) {
+			return nil, timeoutError(err)
+		}
+		return nil, errors.Wrap(err, "failed to fetch tasks")
+	}
+	rows, err := c.tx.Query(ctx, fetchExportQuery)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch tasks")
+	}
+	tasks, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (service.TaskResponse, error) {
+		task, err := scanTask(row)
+		if err != nil {
+			return service.TaskResponse{}, err
+		}
+		return *task, nil
+	})
+	if err != nil {
+		if isTimeout(err) {
+			return nil, timeoutError(err)
+		}
+		return nil, errors.Wrap(err, "failed to fetch tasks")
+	}
+	return tasks, nil
+}
+
+// Close - откат транзакции закрывает курсор и возвращает соединение в пул
+func (c *taskCursor) Close(ctx context.Context) error {
+	return errors.Wrap(c.tx.Rollback(ctx), "failed to close export cursor")
+}
+
+// ImportTasks - вставка задач пакетом запросов в транзакции. Перед вставкой sequence сдвигается за
+// наибольший ID из tasks, чтобы одновременно создаваемые задачи не заняли загружаемые ID
+func (r *repository) ImportTasks(ctx context.Context, tasks []service.TaskResponse) ([]int, error) {
+	if len(tasks) == 0 {
+		return []int{}, nil
+	}
+
+	batch := &pgx.Batch{}
+	maxID := 0
+	for _, task := range tasks {
+		maxID = max(maxID, task.ID)
+	}
+	if maxID > 0 {
+		batch.Queue(advanceTaskIDQuery, maxID)
+	}
+	for _, task := range tasks {
+		created, updated := importTime(task.CreatedAt), importTime(task.UpdatedAt)
+		if task.ID > 0 {
+			batch.Queue(importTaskWithIDQuery, task.ID, task.Title, task.Description, task.Status, created, updated)
+		} else {
+			batch.Queue(importTaskQuery, task.Title, task.Description, task.Status, created, updated)
+		}
+	}
+
+	ids := make([]int, len(tasks))
+	err := r.WithinTx(ctx, service.TxOptions{}, func(ctx context.Context) error {
+		results := r.conn(ctx).SendBatch(ctx, batch)
+		if maxID > 0 {
+			if _, err := results.Exec(); err != nil {
+				_ = results.Close()
+				return errors.Wrap(err, "failed to advance task ID sequence")
+			}
+		}
+		for i := range ids {
+			err := results.QueryRow().Scan(&ids[i])
+			if errors.Is(err, pgx.ErrNoRows) {
+				ids[i] = 0
+				continue
+			}
+			if err != nil {
+				_ = results.Close()
+				return errors.Wrapf(err, "task %d", i)
+			}
+		}
+		return results.Close()
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to import tasks")
+	}
+	r.written(ctx)
+	return ids, nil
+}
+
+// importTime - время задачи для вставки в UTC, нулевое время - NULL (текущее время сервера)
+func importTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return utcTime(&t)
+}
@@ -0,0 +1,201 @@
+package repo
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"simple-service/internal/service"
+)
+
+// txKey - ключ контекста для текущей транзакции
+type txKey struct{}
+
+// dbKey - ключ контекста для пула, на котором выполняется одиночный запрос
+type dbKey struct{}
+
+// querier - общие методы пула и транзакции
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+// database - пул соединений primary или реплики, в тестах подменяется
+type database interface {
+	querier
+	BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error)
+	// acquire - отдельное соединение пула для одиночного запроса
+	acquire(ctx context.Context) (sessionConn, error)
+}
+
+// sessionConn - соединение, выданное пулом одному запросу
+type sessionConn interface {
+	querier
+	// release - возврат в пул; reusable false - состояние соединения неизвестно, оно закрывается
+	release(reusable bool)
+}
+
+// pgxPool - пул pgx как database
+type pgxPool struct {
+	*pgxpool.Pool
+}
+
+func (p pgxPool) acquire(ctx context.Context) (sessionConn, error) {
+	conn, err := p.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return pgxConn{conn}, nil
+}
+
+// pgxConn - соединение пула pgx как sessionConn
+type pgxConn struct {
+	*pgxpool.Conn
+}
+
+func (c pgxConn) release(reusable bool) {
+	if reusable {
+		c.Release()
+		return
+	}
+	_ = c.Hijack().Close(context.Background())
+}
+
+// resetTimeout - ограничение сброса statement_timeout после одиночного запроса
+const resetTimeout = 5 * time.Second
+
+// isoLevels - уровни изоляции service в термины pgx
+var isoLevels = map[service.IsolationLevel]pgx.TxIsoLevel{
+	service.IsolationReadCommitted:  pgx.ReadCommitted,
+	service.IsolationRepeatableRead: pgx.RepeatableRead,
+	service.IsolationSerializable:   pgx.Serializable,
+}
+
+// WithinTx - выполнение fn в транзакции, переданной через контекст.
+// Транзакция повторяется целиком при конфликте сериализации и deadlock, а также если
+// не удалось её начать. При потере соединения во время COMMIT результат неизвестен, повтора нет
+func (r *repository) WithinTx(ctx context.Context, opts service.TxOptions, fn func(ctx context.Context) error) error {
+	// Вложенная транзакция присоединяется к внешней
+	if txFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+
+	isolation := opts.Isolation
+	if isolation == service.IsolationDefault {
+		isolation = r.isolation
+	}
+	txOpts := pgx.TxOptions{IsoLevel: isoLevels[isolation]}
+	if opts.ReadOnly {
+		txOpts.AccessMode = pgx.ReadOnly
+	}
+
+	return r.guard.do(ctx, "tx", false, func(ctx context.Context) error {
+		return inTx(ctx, r.primary, txOpts, fn)
+	})
+}
+
+// inTx - одна попытка транзакции на db с statement_timeout из бюджета ctx
+func inTx(ctx context.Context, db database, opts pgx.TxOptions, fn func(ctx context.Context) error) error {
+	return pgx.BeginTxFunc(ctx, db, opts, func(tx pgx.Tx) error {
+		if err := setStatementTimeout(ctx, tx); err != nil {
+			return err
+		}
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// conn - транзакция из контекста, выбранное для запроса соединение или пул, иначе primary
+func (r *repository) conn(ctx context.Context) querier {
+	if tx := txFromContext(ctx); tx != nil {
+		return tx
+	}
+	if db, ok := ctx.Value(dbKey{}).(querier); ok {
+		return db
+	}
+	return r.primary
+}
+
+// run - выполнение запроса на primary. Вне транзакции запрос идёт через guard с повторами,
+// внутри выполняется один раз: после ошибки транзакция прервана и повторяется целиком в WithinTx.
+// statement_timeout транзакции выставлен в inTx, отдельный SET LOCAL перед каждым запросом не нужен
+func (r *repository) run(ctx context.Context, op string, idempotent bool, fn func(ctx context.Context) error) error {
+	if txFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+
+	return r.guard.do(ctx, op, idempotent, func(ctx context.Context) error {
+		return runOn(ctx, r.primary, fn)
+	})
+}
+
+// runOn - одиночный запрос на db. Если у ctx есть дедлайн, запрос выполняется на отдельном соединении
+// со statement_timeout по оставшемуся бюджету: сервер прервёт запрос, даже если клиент пропал.
+// SET LOCAL потребовал бы транзакции, поэтому таймаут сессионный и сбрасывается после запроса в фоне,
+// не задерживая ответ. Соединение, на котором сбросить не удалось, закрывается
+func runOn(ctx context.Context, db database, fn func(ctx context.Context) error) error {
+	ms, ok, err := statementTimeout(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fn(context.WithValue(ctx, dbKey{}, db))
+	}
+
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { go resetStatementTimeout(conn) }()
+
+	if _, err := conn.Exec(ctx, "SET statement_timeout = "+ms); err != nil {
+		return err
+	}
+	return fn(context.WithValue(ctx, dbKey{}, conn))
+}
+
+// resetStatementTimeout - сброс сессионного statement_timeout и возврат соединения в пул
+func resetStatementTimeout(conn sessionConn) {
+	ctx, cancel := context.WithTimeout(context.Background(), resetTimeout)
+	defer cancel()
+
+	_, err := conn.Exec(ctx, "RESET statement_timeout")
+	conn.release(err == nil)
+}
+
+// setStatementTimeout - statement_timeout до конца транзакции по оставшемуся бюджету ctx.
+// Сервер прерывает запрос сам, не дожидаясь отмены со стороны клиента
+func setStatementTimeout(ctx context.Context, tx pgx.Tx) error {
+	ms, ok, err := statementTimeout(ctx)
+	if !ok || err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, "SET LOCAL statement_timeout = "+ms)
+	return err
+}
+
+// statementTimeout - оставшийся бюджет ctx в миллисекундах для statement_timeout; ok false - дедлайна нет
+func statementTimeout(ctx context.Context) (string, bool, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return "", false, nil
+	}
+
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return "", true, context.DeadlineExceeded
+	}
+
+	// 0 в statement_timeout отключает ограничение, поэтому не меньше 1 мс
+	return strconv.FormatInt(max(remaining.Milliseconds(), 1), 10), true, nil
+}
+
+func txFromContext(ctx context.Context) pgx.Tx {
+	tx, _ := ctx.Value(txKey{}).(pgx.Tx)
+	return tx
+}
@@ -0,0 +1,233 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"simple-service/internal/service"
+)
+
+// fakeTx - транзакция без БД, COMMIT возвращает очередную ошибку из commitErrs
+type fakeTx struct {
+	pgx.Tx
+	b *fakeBeginner
+}
+
+func (tx *fakeTx) Commit(context.Context) error {
+	tx.b.commits++
+	if len(tx.b.commitErrs) > 0 {
+		err := tx.b.commitErrs[0]
+		tx.b.commitErrs = tx.b.commitErrs[1:]
+		return err
+	}
+	return nil
+}
+
+func (tx *fakeTx) Exec(_ context.Context, sql string, _ ...any) (pgconn.CommandTag, error) {
+	tx.b.execs = append(tx.b.execs, sql)
+	return pgconn.CommandTag{}, nil
+}
+
+func (tx *fakeTx) Rollback(context.Context) error {
+	tx.b.rollbacks++
+	return pgx.ErrTxClosed
+}
+
+// fakeConn - соединение пула без БД, записывает запросы в execs
+type fakeConn struct {
+	querier
+	b *fakeBeginner
+}
+
+func (c *fakeConn) Exec(_ context.Context, sql string, _ ...any) (pgconn.CommandTag, error) {
+	c.b.execs = append(c.b.execs, sql)
+	return pgconn.CommandTag{}, nil
+}
+
+func (c *fakeConn) release(reusable bool) {
+	c.b.released <- reusable
+}
+
+// fakeBeginner - пул без БД: транзакции fakeTx, соединения fakeConn
+type fakeBeginner struct {
+	querier
+	opts       []pgx.TxOptions
+	commitErrs []error
+	execs      []string
+	commits    int
+	rollbacks  int
+	released   chan bool
+}
+
+func (b *fakeBeginner) BeginTx(_ context.Context, opts pgx.TxOptions) (pgx.Tx, error) {
+	b.opts = append(b.opts, opts)
+	return &fakeTx{b: b}, nil
+}
+
+func (b *fakeBeginner) acquire(context.Context) (sessionConn, error) {
+	return &fakeConn{b: b}, nil
+}
+
+func TestWithinTx(t *testing.T) {
+	serialization := &pgconn.PgError{Code: "40001"}
+
+	newRepo := func(commitErrs ...error) (*repository, *fakeBeginner) {
+		b := &fakeBeginner{commitErrs: commitErrs, released: make(chan bool, 1)}
+		g, _ := newTestGuard(0)
+		return &repository{primary: b, guard: g, isolation: service.IsolationRepeatableRead}, b
+	}
+
+	t.Run("Конфликт сериализации в fn повторяет транзакцию", func(t *testing.T) {
+		r, b := newRepo()
+
+		calls := 0
+		err := r.WithinTx(context.Background(), service.TxOptions{Isolation: service.IsolationSerializable}, func(ctx context.Context) error {
+			calls++
+			assert.NotNil(t, txFromContext(ctx), "транзакция передаётся через контекст")
+			if calls == 1 {
+				return serialization
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 2, calls)
+		assert.Equal(t, 1, b.commits)
+		assert.Equal(t, []pgx.TxOptions{{IsoLevel: pgx.Serializable}, {IsoLevel: pgx.Serializable}}, b.opts)
+	})
+
+	t.Run("Конфликт при COMMIT повторяет транзакцию", func(t *testing.T) {
+		r, b := newRepo(serialization)
+
+		calls := 0
+		err := r.WithinTx(context.Background(), service.TxOptions{}, func(context.Context) error {
+			calls++
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 2, calls)
+		assert.Equal(t, 2, b.commits)
+		assert.Equal(t, pgx.RepeatableRead, b.opts[0].IsoLevel, "уровень изоляции из конфигурации")
+	})
+
+	t.Run("Попытки закончились", func(t *testing.T) {
+		r, b := newRepo(serialization, serialization, serialization)
+
+		err := r.WithinTx(context.Background(), service.TxOptions{}, func(context.Context) error { return nil })
+		assert.ErrorIs(t, err, serialization)
+		assert.Equal(t, 3, b.commits)
+	})
+
+	t.Run("Ошибка fn откатывает транзакцию без повтора", func(t *testing.T) {
+		r, b := newRepo()
+
+		calls := 0
+		err := r.WithinTx(context.Background(), service.TxOptions{ReadOnly: true}, func(context.Context) error {
+			calls++
+			return service.ErrTaskNotFound
+		})
+		assert.ErrorIs(t, err, service.ErrTaskNotFound)
+		assert.Equal(t, 1, calls)
+		assert.Zero(t, b.commits)
+		assert.Positive(t, b.rollbacks)
+		assert.Equal(t, pgx.ReadOnly, b.opts[0].AccessMode)
+	})
+
+	t.Run("Вложенный вызов присоединяется к внешней транзакции", func(t *testing.T) {
+		r, b := newRepo()
+
+		err := r.WithinTx(context.Background(), service.TxOptions{}, func(ctx context.Context) error {
+			outer := txFromContext(ctx)
+			return r.WithinTx(ctx, service.TxOptions{Isolation: service.IsolationSerializable}, func(ctx context.Context) error {
+				assert.Same(t, outer, txFromContext(ctx))
+				return nil
+			})
+		})
+		require.NoError(t, err)
+		assert.Len(t, b.opts, 1)
+		assert.Equal(t, 1, b.commits)
+	})
+
+	t.Run("Запрос внутри транзакции не повторяется отдельно", func(t *testing.T) {
+		r, _ := newRepo()
+
+		calls := 0
+		err := r.WithinTx(context.Background(), service.TxOptions{}, func(ctx context.Context) error {
+			return r.run(ctx, "get", true, func(context.Context) error {
+				calls++
+				return &net.OpError{Op: "read", Err: errors.New("connection reset by peer")}
+			})
+		})
+		// Транзакция могла успеть зафиксироваться: исход неизвестен, а не временная недоступность
+		assert.ErrorIs(t, err, service.ErrOutcomeUnknown)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Бюджет запроса ограничивает statement_timeout", func(t *testing.T) {
+		r, b := newRepo()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		// Одиночный запрос идёт без транзакции на отдельном соединении с сессионным statement_timeout
+		err := r.run(ctx, "get", true, func(ctx context.Context) error {
+			assert.Nil(t, txFromContext(ctx))
+			assert.IsType(t, &fakeConn{}, r.conn(ctx))
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Empty(t, b.opts)
+
+		// После запроса таймаут сбрасывается и соединение возвращается в пул
+		assert.True(t, <-b.released)
+		require.Len(t, b.execs, 2)
+		assert.Equal(t, "RESET statement_timeout", b.execs[1])
+
+		var ms int
+		_, err = fmt.Sscanf(b.execs[0], "SET statement_timeout = %d", &ms)
+		require.NoError(t, err)
+		assert.InDelta(t, 3000, ms, 100)
+
+		// В транзакции таймаут выставляется один раз после BEGIN
+		b.execs = nil
+		err = r.WithinTx(ctx, service.TxOptions{}, func(ctx context.Context) error {
+			for i := 0; i < 2; i++ {
+				if err := r.run(ctx, "get", true, func(context.Context) error { return nil }); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, b.execs, 1)
+		assert.Equal(t, 1, b.commits)
+
+		_, err = fmt.Sscanf(b.execs[0], "SET LOCAL statement_timeout = %d", &ms)
+		require.NoError(t, err)
+		assert.InDelta(t, 3000, ms, 100)
+	})
+
+	t.Run("Бюджет исчерпан до запроса", func(t *testing.T) {
+		r, b := newRepo()
+
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+
+		calls := 0
+		err := r.run(ctx, "get", true, func(context.Context) error {
+			calls++
+			return nil
+		})
+		assert.ErrorIs(t, err, service.ErrTimeout)
+		assert.Zero(t, calls)
+		assert.Empty(t, b.execs)
+	})
+}
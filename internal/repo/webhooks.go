@@ -0,0 +1,318 @@
+package repo
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+
+	"simple-service/internal/service"
+)
+
+// Подписки и журнал доставок. next_attempt_at у ждущей доставки - время следующей попытки,
+// у выбранной - окончание аренды
+const (
+	webhookColumns  = `id, url, event_types, active, created_at, updated_at`
+	deliveryColumns = `d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.status, d.attempts,
+		COALESCE(d.last_status_code, 0), COALESCE(d.last_error, ''), d.next_attempt_at, d.created_at, d.delivered_at`
+
+	createWebhookQuery = `INSERT INTO webhooks (url, event_types, secret, active) VALUES ($1, $2, $3, $4)
+		RETURNING ` + webhookColumns + `;`
+	getWebhookQuery    = `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = $1;`
+	listWebhooksQuery  = `SELECT ` + webhookColumns + ` FROM webhooks ORDER BY id;`
+	updateWebhookQuery = `UPDATE webhooks SET
+			url = COALESCE($2, url),
+			event_types = COALESCE($3::text[], event_types),
+			secret = COALESCE($4, secret),
+			active = COALESCE($5, active),
+			updated_at = now()
+		WHERE id = $1
+		RETURNING ` + webhookColumns + `;`
+	// Доставки удаляются каскадом
+	deleteWebhookQuery = `DELETE FROM webhooks WHERE id = $1;`
+
+	listDeliveriesQuery = `SELECT ` + deliveryColumns + ` FROM webhook_deliveries d
+		WHERE d.webhook_id = $1 AND ($2::text = '' OR d.status = $2)
+		ORDER BY d.id DESC LIMIT $3 OFFSET $4;`
+	// Выбранная доставка ждёт окончания аренды: сброс next_attempt_at отправил бы её второй раз
+	replayDeliveryQuery = `UPDATE webhook_deliveries d SET
+			status = 'pending',
+			attempts = 0,
+			next_attempt_at = now(),
+			delivered_at = NULL
+		WHERE d.id = $2 AND d.webhook_id = $1 AND (d.status <> 'pending' OR d.next_attempt_at <= now())
+		RETURNING ` + deliveryColumns + `;`
+	deliveryExistsQuery = `SELECT true FROM webhook_deliveries WHERE id = $2 AND webhook_id = $1;`
+
+	// Пустой event_types - подписка на все события; повтор события не создаёт доставок благодаря UNIQUE
+	enqueueDeliveriesQuery = `INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, task_id, payload)
+		SELECT id, $1::bigint, $2::text, $4::integer, $3::jsonb FROM webhooks
+		WHERE active AND (cardinality(event_types) = 0 OR $2 = ANY(event_types))
+		ON CONFLICT (webhook_id, event_id) DO NOTHING;`
+	// Реплики выбирают разные доставки: строки, выбранные другой репликой, пропускаются.
+	// Доставка ждёт, пока подписке не отправлены предыдущие доставки той же задачи
+	claimDeliveriesQuery = `WITH claimed AS (
+			SELECT d.id FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+			WHERE d.status = 'pending' AND d.next_attempt_at <= now() AND w.active
+				AND NOT EXISTS (
+					SELECT 1 FROM webhook_deliveries p
+					WHERE p.webhook_id = d.webhook_id AND p.task_id = d.task_id AND p.id < d.id AND p.status = 'pending'
+				)
+			ORDER BY d.id LIMIT $1
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d SET next_attempt_at = now() + $2 * interval '1 millisecond'
+		FROM claimed, webhooks w
+		WHERE d.id = claimed.id AND w.id = d.webhook_id
+		RETURNING ` + deliveryColumns + `, w.url, w.secret;`
+	recordAttemptQuery = `UPDATE webhook_deliveries SET
+			status = $2,
+			attempts = attempts + 1,
+			last_status_code = NULLIF($3::integer, 0),
+			last_error = NULLIF($4::text, ''),
+			next_attempt_at = now() + $5 * interval '1 millisecond',
+			delivered_at = CASE WHEN $2 = 'delivered' THEN now() ELSE delivered_at END
+		WHERE id = $1;`
+	purgeDeliveriesQuery = `DELETE FROM webhook_deliveries
+		WHERE status <> 'pending' AND created_at < now() - $1 * interval '1 millisecond';`
+)
+
+// CreateWebhook - новая подписка
+func (r *repository) CreateWebhook(ctx context.Context, hook service.NewWebhook) (*service.Webhook, error) {
+	var created *service.Webhook
+	err := r.run(ctx, "webhook_create", false, func(ctx context.Context) (err error) {
+		created, err = scanWebhook(r.conn(ctx).QueryRow(ctx, createWebhookQuery, hook.URL, hook.EventTypes, hook.Secret, hook.Active))
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create webhook")
+	}
+	return created, nil
+}
+
+// GetWebhook - подписка по ID
+func (r *repository) GetWebhook(ctx context.Context, id int) (*service.Webhook, error) {
+	var hook *service.Webhook
+	err := r.run(ctx, "webhook_get", true, func(ctx context.Context) (err error) {
+		hook, err = scanWebhook(r.conn(ctx).QueryRow(ctx, getWebhookQuery, id))
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, service.ErrWebhookNotFound
+		}
+		return nil, errors.Wrap(err, "failed to get webhook")
+	}
+	return hook, nil
+}
+
+// ListWebhooks - все подписки по возрастанию ID
+func (r *repository) ListWebhooks(ctx context.Context) ([]service.Webhook, error) {
+	var hooks []service.Webhook
+	err := r.run(ctx, "webhook_list", true, func(ctx context.Context) error {
+		rows, err := r.conn(ctx).Query(ctx, listWebhooksQuery)
+		if err != nil {
+			return err
+		}
+		hooks, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (service.Webhook, error) {
+			hook, err := scanWebhook(row)
+			if err != nil {
+				return service.Webhook{}, err
+			}
+			return *hook, nil
+		})
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list webhooks")
+	}
+	return hooks, nil
+}
+
+// UpdateWebhook - изменение переданных полей подписки. Повтор записывает те же значения, поэтому безопасен
+func (r *repository) UpdateWebhook(ctx context.Context, id int, update service.WebhookUpdate) (*service.Webhook, error) {
+	var hook *service.Webhook
+	err := r.run(ctx, "webhook_update", true, func(ctx context.Context) (err error) {
+		hook, err = scanWebhook(r.conn(ctx).QueryRow(ctx, updateWebhookQuery,
+			id, update.URL, update.EventTypes, update.Secret, update.Active))
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, service.ErrWebhookNotFound
+		}
+		return nil, errors.Wrap(err, "failed to update webhook")
+	}
+	return hook, nil
+}
+
+// DeleteWebhook - удаление подписки вместе с журналом доставок.
+// Повторяется только если запрос не дошёл до сервера, иначе повтор вернёт ErrWebhookNotFound
+func (r *repository) DeleteWebhook(ctx context.Context, id int) error {
+	var tag pgconn.CommandTag
+	err := r.run(ctx, "webhook_delete", false, func(ctx context.Context) (err error) {
+		tag, err = r.conn(ctx).Exec(ctx, deleteWebhookQuery, id)
+		return err
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to delete webhook")
+	}
+	if tag.RowsAffected() == 0 {
+		return service.ErrWebhookNotFound
+	}
+	return nil
+}
+
+// ListDeliveries - доставки подписки, новые первыми
+func (r *repository) ListDeliveries(ctx context.Context, webhookID int, filter service.DeliveryFilter) ([]service.Delivery, error) {
+	var deliveries []service.Delivery
+	err := r.run(ctx, "webhook_deliveries", true, func(ctx context.Context) error {
+		var id int
+		if err := r.conn(ctx).QueryRow(ctx, `SELECT id FROM webhooks WHERE id = $1;`, webhookID).Scan(&id); err != nil {
+			return err
+		}
+
+		rows, err := r.conn(ctx).Query(ctx, listDeliveriesQuery, webhookID, filter.Status, filter.Limit, filter.Offset)
+		if err != nil {
+			return err
+		}
+		deliveries, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (service.Delivery, error) {
+			return scanDelivery(row)
+		})
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, service.ErrWebhookNotFound
+		}
+		return nil, errors.Wrap(err, "failed to list webhook deliveries")
+	}
+	return deliveries, nil
+}
+
+// ReplayDelivery - доставка снова ждёт отправки с нулевым счётчиком попыток, service.ErrDeliveryInFlight
+// для ждущей отправки. Повторяется только если запрос не дошёл до сервера: иначе повтор сбросил бы уже
+// отправленную доставку
+func (r *repository) ReplayDelivery(ctx context.Context, webhookID int, id int64) (*service.Delivery, error) {
+	var delivery service.Delivery
+	err := r.run(ctx, "webhook_replay", false, func(ctx context.Context) (err error) {
+		delivery, err = scanDelivery(r.conn(ctx).QueryRow(ctx, replayDeliveryQuery, webhookID, id))
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+		var exists bool
+		if err := r.conn(ctx).QueryRow(ctx, deliveryExistsQuery, webhookID, id).Scan(&exists); err != nil {
+			return err
+		}
+		return service.ErrDeliveryInFlight
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, service.ErrDeliveryNotFound
+		}
+		if errors.Is(err, service.ErrDeliveryInFlight) {
+			return nil, err
+		}
+		return nil, errors.Wrap(err, "failed to replay webhook delivery")
+	}
+	return &delivery, nil
+}
+
+// EnqueueDeliveries - доставка каждой активной подписке на eventType, кроме уже созданных для eventID
+func (r *repository) EnqueueDeliveries(ctx context.Context, eventID int64, eventType string, taskID int, body []byte) (int, error) {
+	var tag pgconn.CommandTag
+	err := r.run(ctx, "webhook_enqueue", true, func(ctx context.Context) (err error) {
+		tag, err = r.conn(ctx).Exec(ctx, enqueueDeliveriesQuery, eventID, eventType, body, taskID)
+		return err
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to enqueue webhook deliveries")
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// ClaimDeliveries - до limit готовых доставок активных подписок по возрастанию ID,
+// не больше одной ждущей доставки задачи на подписку.
+// Повторяется только если запрос не дошёл до сервера: иначе выбранные доставки ждали бы окончания аренды
+func (r *repository) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]service.PendingDelivery, error) {
+	var claimed []service.PendingDelivery
+	err := r.run(ctx, "webhook_claim", false, func(ctx context.Context) error {
+		rows, err := r.conn(ctx).Query(ctx, claimDeliveriesQuery, limit, lease.Milliseconds())
+		if err != nil {
+			return err
+		}
+		claimed, err = pgx.CollectRows(rows, scanPendingDelivery)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to claim webhook deliveries")
+	}
+	// RETURNING не сохраняет порядок выборки
+	slices.SortFunc(claimed, func(a, b service.PendingDelivery) int { return cmp.Compare(a.ID, b.ID) })
+	return claimed, nil
+}
+
+// RecordAttempt - результат попытки; доставка, удалённая вместе с подпиской, пропускается
+func (r *repository) RecordAttempt(ctx context.Context, id int64, attempt service.DeliveryAttempt) error {
+	err := r.run(ctx, "webhook_record", false, func(ctx context.Context) error {
+		_, err := r.conn(ctx).Exec(ctx, recordAttemptQuery,
+			id, attempt.Status, attempt.StatusCode, attempt.Error, attempt.RetryIn.Milliseconds())
+		return err
+	})
+	return errors.Wrap(err, "failed to record webhook delivery attempt")
+}
+
+// PurgeDeliveries - удаление завершённых доставок, созданных раньше retention назад
+func (r *repository) PurgeDeliveries(ctx context.Context, retention time.Duration) (int, error) {
+	var tag pgconn.CommandTag
+	err := r.run(ctx, "webhook_purge", true, func(ctx context.Context) (err error) {
+		tag, err = r.conn(ctx).Exec(ctx, purgeDeliveriesQuery, retention.Milliseconds())
+		return err
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to purge webhook deliveries")
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// scanWebhook - чтение строки с колонками webhookColumns
+func scanWebhook(row pgx.Row) (*service.Webhook, error) {
+	var hook service.Webhook
+	if err := row.Scan(&hook.ID, &hook.URL, &hook.EventTypes, &hook.Active, &hook.CreatedAt, &hook.UpdatedAt); err != nil {
+		return nil, err
+	}
+	hook.CreatedAt = hook.CreatedAt.UTC()
+	hook.UpdatedAt = hook.UpdatedAt.UTC()
+	return &hook, nil
+}
+
+// scanDelivery - чтение строки с колонками deliveryColumns
+func scanDelivery(row pgx.Row) (service.Delivery, error) {
+	var d service.Delivery
+	err := row.Scan(deliveryDest(&d)...)
+	return normalizeDelivery(d), err
+}
+
+// scanPendingDelivery - чтение строки с колонками deliveryColumns, url и secret подписки
+func scanPendingDelivery(row pgx.CollectableRow) (service.PendingDelivery, error) {
+	var p service.PendingDelivery
+	err := row.Scan(append(deliveryDest(&p.Delivery), &p.URL, &p.Secret)...)
+	p.Delivery = normalizeDelivery(p.Delivery)
+	return p, err
+}
+
+func deliveryDest(d *service.Delivery) []any {
+	return []any{&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+		&d.LastStatusCode, &d.LastError, &d.NextAttemptAt, &d.CreatedAt, &d.DeliveredAt}
+}
+
+func normalizeDelivery(d service.Delivery) service.Delivery {
+	d.NextAttemptAt = d.NextAttemptAt.UTC()
+	d.CreatedAt = d.CreatedAt.UTC()
+	d.DeliveredAt = utcTime(d.DeliveredAt)
+	return d
+}
@@ -0,0 +1,163 @@
+package schema
+
+import (
+	"fmt"
+	"io"
+)
+
+// Difference - одно расхождение между ожидаемой и фактической схемой
+type Difference struct {
+	Table string
+	// Object - что именно отличается: "table", "column title", "index tasks_pkey" и т.п.
+	Object   string
+	Problem  string
+	Expected string
+	Actual   string
+}
+
+// String - строка отчёта
+func (d Difference) String() string {
+	s := fmt.Sprintf("%s: %s %s", d.Table, d.Object, d.Problem)
+	if d.Expected != "" || d.Actual != "" {
+		s += fmt.Sprintf("\n      expected: %s\n      actual:   %s", d.Expected, d.Actual)
+	}
+	return s
+}
+
+// Diff - расхождения фактической схемы с ожидаемой, пусто если схемы совпадают
+func Diff(expected, actual *Schema) []Difference {
+	var diffs []Difference
+
+	actualTables := make(map[string]Table, len(actual.Tables))
+	for _, t := range actual.Tables {
+		actualTables[t.Name] = t
+	}
+
+	for _, want := range expected.Tables {
+		got, ok := actualTables[want.Name]
+		delete(actualTables, want.Name)
+		if !ok {
+			diffs = append(diffs, Difference{Table: want.Name, Object: "table", Problem: "is missing"})
+			continue
+		}
+		diffs = append(diffs, diffTable(want, got)...)
+	}
+
+	for _, t := range actual.Tables {
+		if _, ok := actualTables[t.Name]; ok {
+			diffs = append(diffs, Difference{Table: t.Name, Object: "table", Problem: "is not expected"})
+		}
+	}
+
+	return diffs
+}
+
+func diffTable(want, got Table) []Difference {
+	var diffs []Difference
+	add := func(object, problem, expected, actual string) {
+		diffs = append(diffs, Difference{Table: want.Name, Object: object, Problem: problem, Expected: expected, Actual: actual})
+	}
+
+	diffObjects(want.Columns, got.Columns, func(c Column) string { return c.Name },
+		func(name string, w, g *Column) {
+			object := "column " + name
+			switch {
+			case g == nil:
+				add(object, "is missing", "", "")
+			case w == nil:
+				add(object, "is not expected", "", "")
+			default:
+				if w.Type != g.Type {
+					add(object, "has different type", w.Type, g.Type)
+				}
+				if w.NotNull != g.NotNull {
+					add(object, "has different nullability", nullability(w.NotNull), nullability(g.NotNull))
+				}
+				if w.Default != g.Default {
+					add(object, "has different default", orNone(w.Default), orNone(g.Default))
+				}
+			}
+		})
+
+	diffObjects(want.Constraints, got.Constraints, func(c Constraint) string { return c.Name },
+		func(name string, w, g *Constraint) {
+			object := "constraint " + name
+			switch {
+			case g == nil:
+				add(object, "is missing", "", "")
+			case w == nil:
+				add(object, "is not expected", "", "")
+			case w.Definition != g.Definition:
+				add(object, "has different definition", w.Definition, g.Definition)
+			}
+		})
+
+	diffObjects(want.Indexes, got.Indexes, func(i Index) string { return i.Name },
+		func(name string, w, g *Index) {
+			object := "index " + name
+			switch {
+			case g == nil:
+				add(object, "is missing", "", "")
+			case w == nil:
+				add(object, "is not expected", "", "")
+			case w.Definition != g.Definition:
+				add(object, "has different definition", w.Definition, g.Definition)
+			}
+		})
+
+	return diffs
+}
+
+// diffObjects - сопоставление объектов по имени: сначала ожидаемые в их порядке, затем лишние.
+// Отсутствующая сторона передаётся как nil
+func diffObjects[T any](want, got []T, name func(T) string, compare func(name string, w, g *T)) {
+	gotByName := make(map[string]*T, len(got))
+	for i := range got {
+		gotByName[name(got[i])] = &got[i]
+	}
+
+	for i := range want {
+		n := name(want[i])
+		compare(n, &want[i], gotByName[n])
+		delete(gotByName, n)
+	}
+
+	for i := range got {
+		if _, ok := gotByName[name(got[i])]; ok {
+			compare(name(got[i]), nil, &got[i])
+		}
+	}
+}
+
+func nullability(notNull bool) string {
+	if notNull {
+		return "NOT NULL"
+	}
+	return "NULL"
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
+
+// Report - читаемый отчёт о расхождениях
+func Report(w io.Writer, diffs []Difference) error {
+	if len(diffs) == 0 {
+		_, err := fmt.Fprintln(w, "Schema matches the expected snapshot")
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "Schema drift detected, %d differences:\n", len(diffs)); err != nil {
+		return err
+	}
+	for _, d := range diffs {
+		if _, err := fmt.Fprintf(w, "  - %s\n", d); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
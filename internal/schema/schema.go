@@ -0,0 +1,211 @@
+package schema
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Name - схема PostgreSQL, в которой живут таблицы сервиса
+const Name = "public"
+
+// Таблицы, которые не сравниваются: служебная таблица миграций ведётся мигратором
+var ignoredTables = []string{"schema_migrations"}
+
+// Schema - структура таблиц сервиса: колонки, ограничения и индексы
+type Schema struct {
+	// Version - последняя миграция, после которой снят снимок
+	Version int     `json:"version"`
+	Tables  []Table `json:"tables"`
+}
+
+// Table - таблица со всеми объектами, объекты отсортированы по имени
+type Table struct {
+	Name        string       `json:"name"`
+	Columns     []Column     `json:"columns"`
+	Constraints []Constraint `json:"constraints"`
+	Indexes     []Index      `json:"indexes"`
+}
+
+// Column - колонка в том виде, в котором её описывает pg_catalog
+type Column struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	NotNull bool   `json:"not_null"`
+	Default string `json:"default,omitempty"`
+}
+
+// Constraint - ограничение, Definition - результат pg_get_constraintdef
+type Constraint struct {
+	Name       string `json:"name"`
+	Definition string `json:"definition"`
+}
+
+// Index - индекс, Definition - результат pg_get_indexdef
+type Index struct {
+	Name       string `json:"name"`
+	Definition string `json:"definition"`
+}
+
+// snapshot - ожидаемая схема после всех миграций, обновляется командой schema snapshot
+//
+//go:embed snapshot.json
+var snapshot []byte
+
+// Expected - ожидаемая схема из снимка
+func Expected() (*Schema, error) {
+	return Parse(snapshot)
+}
+
+// Parse - схема из JSON снимка
+func Parse(data []byte) (*Schema, error) {
+	var s Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse schema snapshot: %w", err)
+	}
+	return &s, nil
+}
+
+// Marshal - JSON снимка с отступами, удобный для ревью
+func (s *Schema) Marshal() ([]byte, error) {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Querier - источник запросов: пул, соединение или транзакция
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+const (
+	tablesQuery = `
+		SELECT c.relname
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = $1 AND c.relkind IN ('r', 'p')
+		ORDER BY c.relname`
+
+	columnsQuery = `
+		SELECT c.relname, a.attname, format_type(a.atttypid, a.atttypmod), a.attnotnull,
+			COALESCE(pg_get_expr(d.adbin, d.adrelid), '')
+		FROM pg_attribute a
+		JOIN pg_class c ON c.oid = a.attrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		WHERE n.nspname = $1 AND c.relkind IN ('r', 'p') AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY c.relname, a.attname`
+
+	// NOT NULL с PostgreSQL 18 тоже попадает в pg_constraint, но он уже учтён в колонках
+	constraintsQuery = `
+		SELECT c.relname, con.conname, pg_get_constraintdef(con.oid)
+		FROM pg_constraint con
+		JOIN pg_class c ON c.oid = con.conrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = $1 AND con.contype <> 'n'
+		ORDER BY c.relname, con.conname`
+
+	indexesQuery = `
+		SELECT c.relname, i.relname, pg_get_indexdef(i.oid)
+		FROM pg_index x
+		JOIN pg_class c ON c.oid = x.indrelid
+		JOIN pg_class i ON i.oid = x.indexrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = $1
+		ORDER BY c.relname, i.relname`
+)
+
+// Inspect - текущая схема базы из pg_catalog
+func Inspect(ctx context.Context, q Querier) (*Schema, error) {
+	tables := make(map[string]*Table)
+	var order []string
+
+	err := scan(ctx, q, tablesQuery, func(rows pgx.Rows) error {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if !slices.Contains(ignoredTables, name) {
+			tables[name] = &Table{Name: name}
+			order = append(order, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tables: %w", err)
+	}
+
+	err = scan(ctx, q, columnsQuery, func(rows pgx.Rows) error {
+		var table string
+		var c Column
+		if err := rows.Scan(&table, &c.Name, &c.Type, &c.NotNull, &c.Default); err != nil {
+			return err
+		}
+		if t, ok := tables[table]; ok {
+			t.Columns = append(t.Columns, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns: %w", err)
+	}
+
+	err = scan(ctx, q, constraintsQuery, func(rows pgx.Rows) error {
+		var table string
+		var c Constraint
+		if err := rows.Scan(&table, &c.Name, &c.Definition); err != nil {
+			return err
+		}
+		if t, ok := tables[table]; ok {
+			t.Constraints = append(t.Constraints, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read constraints: %w", err)
+	}
+
+	err = scan(ctx, q, indexesQuery, func(rows pgx.Rows) error {
+		var table string
+		var i Index
+		if err := rows.Scan(&table, &i.Name, &i.Definition); err != nil {
+			return err
+		}
+		if t, ok := tables[table]; ok {
+			t.Indexes = append(t.Indexes, i)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read indexes: %w", err)
+	}
+
+	s := &Schema{Tables: make([]Table, 0, len(order))}
+	for _, name := range order {
+		s.Tables = append(s.Tables, *tables[name])
+	}
+
+	return s, nil
+}
+
+func scan(ctx context.Context, q Querier, sql string, fn func(pgx.Rows) error) error {
+	rows, err := q.Query(ctx, sql, Name)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
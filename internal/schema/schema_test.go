@@ -0,0 +1,119 @@
+package schema
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"simple-service/internal/migrations"
+)
+
+func TestSnapshotIsUpToDate(t *testing.T) {
+	expected, err := Expected()
+	require.NoError(t, err)
+
+	all, err := migrations.Load(migrations.FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, all)
+
+	assert.Equal(t, all[len(all)-1].Version, expected.Version,
+		"snapshot.json is stale, regenerate it with `schema snapshot` after applying migrations")
+
+	// Файл в том же формате, что пишет schema snapshot
+	data, err := expected.Marshal()
+	require.NoError(t, err)
+	assert.Equal(t, string(snapshot), string(data))
+}
+
+func testSchema() *Schema {
+	return &Schema{Tables: []Table{{
+		Name: "tasks",
+		Columns: []Column{
+			{Name: "id", Type: "integer", NotNull: true, Default: "nextval('tasks_id_seq'::regclass)"},
+			{Name: "status", Type: "text", Default: "'new'::text"},
+			{Name: "title", Type: "text", NotNull: true},
+		},
+		Constraints: []Constraint{{Name: "tasks_pkey", Definition: "PRIMARY KEY (id)"}},
+		Indexes:     []Index{{Name: "tasks_pkey", Definition: "CREATE UNIQUE INDEX tasks_pkey ON public.tasks USING btree (id)"}},
+	}}}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(s *Schema)
+		want   []string
+	}{
+		{
+			name:   "Схемы совпадают",
+			modify: func(*Schema) {},
+		},
+		{
+			name: "Изменены тип, nullability и default колонки",
+			modify: func(s *Schema) {
+				s.Tables[0].Columns[1] = Column{Name: "status", Type: "character varying(20)", NotNull: true}
+			},
+			want: []string{
+				"tasks: column status has different type\n      expected: text\n      actual:   character varying(20)",
+				"tasks: column status has different nullability\n      expected: NULL\n      actual:   NOT NULL",
+				"tasks: column status has different default\n      expected: 'new'::text\n      actual:   (none)",
+			},
+		},
+		{
+			name: "Пропавшая и лишняя колонки, лишний индекс",
+			modify: func(s *Schema) {
+				s.Tables[0].Columns = append(s.Tables[0].Columns[:2], Column{Name: "priority", Type: "integer"})
+				s.Tables[0].Indexes = append(s.Tables[0].Indexes, Index{Name: "tasks_title_idx", Definition: "CREATE INDEX ..."})
+			},
+			want: []string{
+				"tasks: column title is missing",
+				"tasks: column priority is not expected",
+				"tasks: index tasks_title_idx is not expected",
+			},
+		},
+		{
+			name: "Изменено ограничение",
+			modify: func(s *Schema) {
+				s.Tables[0].Constraints[0].Definition = "PRIMARY KEY (id, title)"
+			},
+			want: []string{
+				"tasks: constraint tasks_pkey has different definition\n      expected: PRIMARY KEY (id)\n      actual:   PRIMARY KEY (id, title)",
+			},
+		},
+		{
+			name: "Пропавшая и лишняя таблицы",
+			modify: func(s *Schema) {
+				s.Tables[0].Name = "tasks_old"
+			},
+			want: []string{
+				"tasks: table is missing",
+				"tasks_old: table is not expected",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := testSchema()
+			tt.modify(actual)
+
+			var got []string
+			for _, d := range Diff(testSchema(), actual) {
+				got = append(got, d.String())
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Report(&buf, nil))
+	assert.Equal(t, "Schema matches the expected snapshot\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, Report(&buf, []Difference{{Table: "tasks", Object: "column title", Problem: "is missing"}}))
+	assert.Equal(t, "Schema drift detected, 1 differences:\n  - tasks: column title is missing\n", buf.String())
+}
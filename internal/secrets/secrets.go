@@ -0,0 +1,98 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Источники секретов (пароли, ключи). Секрет идентифицируется именем переменной окружения, например DB_PASSWORD
+
+// ErrNotFound - секрет отсутствует в источнике
+var ErrNotFound = errors.New("secret not found")
+
+// Provider - источник секретов
+type Provider interface {
+	Secret(ctx context.Context, name string) (string, error)
+}
+
+// LookupFunc - поиск переменной окружения, по умолчанию os.LookupEnv
+type LookupFunc func(name string) (string, bool)
+
+// EnvProvider - секрет из переменной NAME или из файла, путь к которому задан в NAME_FILE
+// (так передаются Docker и Kubernetes secrets)
+type EnvProvider struct {
+	Lookup LookupFunc
+}
+
+// Secret - значение NAME, иначе содержимое файла NAME_FILE
+func (p EnvProvider) Secret(_ context.Context, name string) (string, error) {
+	lookup := p.Lookup
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+
+	if value, ok := lookup(name); ok && value != "" {
+		return value, nil
+	}
+
+	if path, ok := lookup(name + "_FILE"); ok && path != "" {
+		value, err := readSecretFile(path)
+		if err != nil {
+			return "", errors.Wrapf(err, "%s_FILE", name)
+		}
+		return value, nil
+	}
+
+	return "", ErrNotFound
+}
+
+// FileProvider - секреты в виде файлов каталога Dir, имя файла - имя секрета
+// в нижнем регистре (db_password) или как есть (DB_PASSWORD)
+type FileProvider struct {
+	Dir string
+}
+
+// Secret - содержимое файла секрета
+func (p FileProvider) Secret(_ context.Context, name string) (string, error) {
+	for _, file := range []string{strings.ToLower(name), name} {
+		value, err := readSecretFile(filepath.Join(p.Dir, file))
+		if err == nil {
+			return value, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+	}
+
+	return "", ErrNotFound
+}
+
+// Chain - секрет из первого источника, в котором он найден
+type Chain []Provider
+
+// Secret - обход источников по порядку
+func (c Chain) Secret(ctx context.Context, name string) (string, error) {
+	for _, p := range c {
+		value, err := p.Secret(ctx, name)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		return value, err
+	}
+
+	return "", ErrNotFound
+}
+
+// readSecretFile - содержимое файла без завершающего перевода строки
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read secret file %s", path)
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
@@ -0,0 +1,58 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviders(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "db_password"), []byte("from-dir\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "token.txt"), []byte("from-file\n"), 0o600))
+
+	env := map[string]string{
+		"PLAIN":        "from-env",
+		"TOKEN_FILE":   filepath.Join(dir, "token.txt"),
+		"MISSING_FILE": filepath.Join(dir, "missing"),
+	}
+	envProvider := EnvProvider{Lookup: func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}}
+	provider := Chain{envProvider, FileProvider{Dir: dir}}
+
+	tests := []struct {
+		name      string
+		secret    string
+		want      string
+		wantErr   error
+		wantError bool
+	}{
+		{name: "Переменная окружения", secret: "PLAIN", want: "from-env"},
+		{name: "Файл из NAME_FILE", secret: "TOKEN", want: "from-file"},
+		{name: "Файл в каталоге секретов", secret: "DB_PASSWORD", want: "from-dir"},
+		{name: "Секрет не найден", secret: "UNKNOWN", wantErr: ErrNotFound},
+		{name: "NAME_FILE указывает на несуществующий файл", secret: "MISSING", wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := provider.Secret(context.Background(), tt.secret)
+			switch {
+			case tt.wantErr != nil:
+				assert.ErrorIs(t, err, tt.wantErr)
+			case tt.wantError:
+				assert.Error(t, err)
+				assert.NotErrorIs(t, err, ErrNotFound)
+			default:
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, value)
+			}
+		})
+	}
+}
@@ -1,5 +1,7 @@
 package service
 
+import "time"
+
 // TaskRequest - структура, представляющая тело запроса
 type TaskRequest struct {
 	Title       string `json:"title" validate:"required,min=1,max=255"`
@@ -10,3 +12,152 @@ type TaskRequest struct {
 func (tr TaskRequest) ToTask() Task {
 	return Task(tr)
 }
+
+// Режимы пакетного создания задач
+const (
+	// BatchAllOrNothing - при ошибке проверки любой задачи не создаётся ни одна
+	BatchAllOrNothing = "all_or_nothing"
+	// BatchBestEffort - создаются задачи, прошедшие проверку, остальные возвращаются с ошибкой
+	BatchBestEffort = "best_effort"
+)
+
+// BatchCreateRequest - пакет задач для создания, каждая проверяется отдельно
+type BatchCreateRequest struct {
+	Mode  string        `json:"mode" validate:"omitempty,oneof=all_or_nothing best_effort"`
+	Tasks []TaskRequest `json:"tasks"`
+}
+
+// Статусы задачи, соответствуют CHECK ограничению в таблице tasks
+const (
+	StatusNew        = "new"
+	StatusInProgress = "in_progress"
+	StatusDone       = "done"
+)
+
+// UpdateTaskRequest - частичное обновление задачи, nil поля не меняются
+type UpdateTaskRequest struct {
+	Title       *string `json:"title,omitempty" validate:"omitempty,min=1,max=255"`
+	Description *string `json:"description,omitempty" validate:"omitempty,max=1000"`
+	Status      *string `json:"status,omitempty" validate:"omitempty,oneof=new in_progress done"`
+}
+
+// Empty - в запросе нет ни одного поля для обновления
+func (r UpdateTaskRequest) Empty() bool {
+	return r.Title == nil && r.Description == nil && r.Status == nil
+}
+
+// ToTaskUpdate - конвертирует UpdateTaskRequest в TaskUpdate
+func (r UpdateTaskRequest) ToTaskUpdate() TaskUpdate {
+	return TaskUpdate(r)
+}
+
+// ListFilter - фильтр и страница списка задач
+type ListFilter struct {
+	Status string `validate:"omitempty,oneof=new in_progress done"`
+	Limit  int    `validate:"gte=1,lte=1000"`
+	Offset int    `validate:"gte=0"`
+}
+
+// TaskFilter - условия отбора задач для массовых операций, нулевые поля не проверяются
+type TaskFilter struct {
+	Status        string     `json:"status,omitempty" validate:"omitempty,oneof=new in_progress done"`
+	CreatedAfter  *time.Time `json:"created_after,omitempty"`
+	CreatedBefore *time.Time `json:"created_before,omitempty"`
+}
+
+// Empty - в фильтре нет ни одного условия
+func (f TaskFilter) Empty() bool {
+	return f.Status == "" && f.CreatedAfter == nil && f.CreatedBefore == nil
+}
+
+// TaskSelector - задачи массовой операции: список ID или фильтр
+type TaskSelector struct {
+	IDs    []int
+	Filter TaskFilter
+}
+
+// BulkUpdateRequest - изменение отобранных задач. Задаётся ровно одно из IDs и Filter
+type BulkUpdateRequest struct {
+	IDs    []int             `json:"ids,omitempty" validate:"omitempty,dive,gte=1"`
+	Filter *TaskFilter       `json:"filter,omitempty"`
+	Patch  UpdateTaskRequest `json:"patch"`
+	DryRun bool              `json:"dry_run"`
+	// OverrideLimit - снять ограничение на число задач, только со scope ScopeAdmin
+	OverrideLimit bool `json:"override_limit"`
+}
+
+// BulkDeleteRequest - удаление отобранных задач. Задаётся ровно одно из IDs и Filter
+type BulkDeleteRequest struct {
+	IDs           []int       `json:"ids,omitempty" validate:"omitempty,dive,gte=1"`
+	Filter        *TaskFilter `json:"filter,omitempty"`
+	DryRun        bool        `json:"dry_run"`
+	OverrideLimit bool        `json:"override_limit"`
+}
+
+// BulkOptions - параметры массовой операции
+type BulkOptions struct {
+	// DryRun - только найти задачи, ничего не меняя
+	DryRun bool
+	// MaxAffected - если задач больше, операция не выполняется; 0 - без ограничения
+	MaxAffected int
+}
+
+// BulkResult - задачи, затронутые массовой операцией (или которые будут затронуты при DryRun)
+type BulkResult struct {
+	Affected int
+	IDs      []int
+	DryRun   bool
+	// LimitExceeded - задач больше MaxAffected; при DryRun операция не выполнялась бы
+	LimitExceeded bool
+}
+
+// Форматы выгрузки и загрузки задач
+const (
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+)
+
+// ImportTaskRequest - задача из файла загрузки, поля как в выгрузке.
+// ID и время учитываются, только если это задано в ImportOptions
+type ImportTaskRequest struct {
+	ID          int        `json:"id" validate:"gte=0"`
+	Title       string     `json:"title" validate:"required,min=1,max=255"`
+	Description string     `json:"description" validate:"max=1000"`
+	Status      string     `json:"status" validate:"omitempty,oneof=new in_progress done"`
+	CreatedAt   *time.Time `json:"created_at"`
+	UpdatedAt   *time.Time `json:"updated_at"`
+}
+
+// ImportOptions - параметры загрузки задач
+type ImportOptions struct {
+	// Mode - BatchAllOrNothing или BatchBestEffort, как у пакетного создания
+	Mode string
+	// PreserveIDs - задачи получают ID из файла, иначе новые
+	PreserveIDs bool
+	// PreserveTimestamps - created_at и updated_at из файла, иначе время загрузки
+	PreserveTimestamps bool
+}
+
+// ToTask - задача для хранилища: ID 0 и нулевое время заполняет хранилище.
+// Без updated_at в файле задача считается не менявшейся после создания
+func (r ImportTaskRequest) ToTask(opts ImportOptions) TaskResponse {
+	task := TaskResponse{
+		Title:       r.Title,
+		Description: r.Description,
+		Status:      r.Status,
+	}
+	if task.Status == "" {
+		task.Status = StatusNew
+	}
+	if opts.PreserveIDs {
+		task.ID = r.ID
+	}
+	if opts.PreserveTimestamps && r.CreatedAt != nil {
+		task.CreatedAt = *r.CreatedAt
+		task.UpdatedAt = *r.CreatedAt
+	}
+	if opts.PreserveTimestamps && r.UpdatedAt != nil {
+		task.UpdatedAt = *r.UpdatedAt
+	}
+	return task
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Типы событий изменения задач
+const (
+	EventTaskCreated       = "task.created"
+	EventTaskUpdated       = "task.updated"
+	EventTaskStatusChanged = "task.status_changed"
+	EventTaskDeleted       = "task.deleted"
+)
+
+// EventVersion - версия схемы payload событий задач, увеличивается при несовместимом изменении TaskEvent
+const EventVersion = 1
+
+// Event - событие изменения задачи в outbox
+type Event struct {
+	// ID - номер события, растёт в порядке записи. Выдаёт хранилище
+	ID      int64           `json:"id"`
+	Type    string          `json:"type"`
+	Version int             `json:"version"`
+	TaskID  int             `json:"task_id"`
+	Payload json.RawMessage `json:"payload"`
+	// OccurredAt - время записи события, выставляет хранилище
+	OccurredAt time.Time `json:"occurred_at"`
+	// Attempts - число неудачных попыток доставки
+	Attempts int `json:"-"`
+}
+
+// TaskEvent - payload событий задач
+type TaskEvent struct {
+	// Task - задача после изменения, для task.deleted - на момент удаления
+	Task TaskResponse `json:"task"`
+	// Changed - изменённые поля задачи для task.updated
+	Changed []string `json:"changed,omitempty"`
+	// From и To - статус до и после изменения для task.status_changed
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+// EventStore - запись событий в outbox. Должен быть тем же хранилищем, что и репозиторий сервиса:
+// события пишутся в транзакции изменения и фиксируются или откатываются вместе с ним
+type EventStore interface {
+	// AppendEvents - добавление событий в порядке events
+	AppendEvents(ctx context.Context, events []Event) error
+}
+
+// newEvent - событие типа eventType с payload версии EventVersion
+func newEvent(eventType string, payload TaskEvent) (Event, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, errors.Wrapf(err, "failed to encode %s event", eventType)
+	}
+	return Event{Type: eventType, Version: EventVersion, TaskID: payload.Task.ID, Payload: data}, nil
+}
+
+// changeEvents - события изменения задачи before в after: task.updated при изменении заголовка
+// или описания, task.status_changed при смене статуса. Запись тех же значений событий не создаёт
+func changeEvents(before, after TaskResponse) ([]Event, error) {
+	var changed []string
+	if before.Title != after.Title {
+		changed = append(changed, "title")
+	}
+	if before.Description != after.Description {
+		changed = append(changed, "description")
+	}
+
+	events := make([]Event, 0, 2)
+	if len(changed) > 0 {
+		event, err := newEvent(EventTaskUpdated, TaskEvent{Task: after, Changed: changed})
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	if before.Status != after.Status {
+		event, err := newEvent(EventTaskStatusChanged, TaskEvent{Task: after, From: before.Status, To: after.Status})
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// write - fn одной транзакцией с событиями, которые она записывает. Без хранилища событий
+// fn выполняется как есть: одиночные операции репозитория атомарны и без транзакции
+func (s *service) write(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s.events == nil {
+		return fn(ctx)
+	}
+	return s.repo.WithinTx(ctx, TxOptions{}, fn)
+}
+
+// snapshot - задачи ids до изменения. Строки блокируются до конца транзакции, поэтому события
+// одной задачи записываются в порядке её изменений. Без хранилища событий ничего не читается
+func (s *service) snapshot(ctx context.Context, ids []int) ([]TaskResponse, error) {
+	if s.events == nil || len(ids) == 0 {
+		return nil, nil
+	}
+	return s.repo.GetTasks(ctx, ids)
+}
+
+// recordCreated - task.created для новых задач ids, ID 0 (задача не создана) пропускаются
+func (s *service) recordCreated(ctx context.Context, ids []int) error {
+	if s.events == nil {
+		return nil
+	}
+
+	created := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if id != 0 {
+			created = append(created, id)
+		}
+	}
+	tasks, err := s.snapshot(ctx, created)
+	if err != nil {
+		return err
+	}
+
+	events := make([]Event, 0, len(tasks))
+	for _, task := range tasks {
+		event, err := newEvent(EventTaskCreated, TaskEvent{Task: task})
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+	}
+	return s.append(ctx, events)
+}
+
+// recordChanged - события изменения задач before по их состоянию after, задачи сопоставляются по ID
+func (s *service) recordChanged(ctx context.Context, before, after []TaskResponse) error {
+	if s.events == nil {
+		return nil
+	}
+
+	current := make(map[int]TaskResponse, len(after))
+	for _, task := range after {
+		current[task.ID] = task
+	}
+
+	var events []Event
+	for _, old := range before {
+		task, ok := current[old.ID]
+		if !ok {
+			continue
+		}
+		changes, err := changeEvents(old, task)
+		if err != nil {
+			return err
+		}
+		events = append(events, changes...)
+	}
+	return s.append(ctx, events)
+}
+
+// recordDeleted - task.deleted для удалённых задач в состоянии на момент удаления
+func (s *service) recordDeleted(ctx context.Context, deleted []TaskResponse) error {
+	if s.events == nil {
+		return nil
+	}
+
+	events := make([]Event, 0, len(deleted))
+	for _, task := range deleted {
+		event, err := newEvent(EventTaskDeleted, TaskEvent{Task: task})
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+	}
+	return s.append(ctx, events)
+}
+
+func (s *service) append(ctx context.Context, events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return errors.Wrap(s.events.AppendEvents(ctx, events), "failed to record task events")
+}
@@ -0,0 +1,126 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"simple-service/internal/repo/memory"
+	"simple-service/internal/service"
+)
+
+func ptr(s string) *string {
+	return &s
+}
+
+func TestTaskEvents(t *testing.T) {
+	ctx := context.Background()
+	repository := memory.NewRepository()
+	svc := service.NewService(repository, zap.NewNop().Sugar(), service.WithEvents(repository))
+
+	pending := func() []service.Event {
+		t.Helper()
+		events, err := repository.ClaimEvents(ctx, 100, time.Minute)
+		require.NoError(t, err)
+		ids := make([]int64, 0, len(events))
+		for _, e := range events {
+			ids = append(ids, e.ID)
+		}
+		require.NoError(t, repository.CompleteEvents(ctx, ids))
+		return events
+	}
+	payload := func(e service.Event) service.TaskEvent {
+		t.Helper()
+		var p service.TaskEvent
+		require.NoError(t, json.Unmarshal(e.Payload, &p))
+		return p
+	}
+
+	tests := []struct {
+		name  string
+		apply func(t *testing.T, id int)
+		want  []string
+		check func(t *testing.T, events []service.Event)
+	}{
+		{
+			name: "Изменение заголовка и статуса",
+			apply: func(t *testing.T, id int) {
+				_, err := svc.UpdateTask(ctx, id, service.UpdateTaskRequest{Title: ptr("Renamed"), Status: ptr(service.StatusDone)})
+				require.NoError(t, err)
+			},
+			want: []string{service.EventTaskUpdated, service.EventTaskStatusChanged},
+			check: func(t *testing.T, events []service.Event) {
+				updated := payload(events[0])
+				assert.Equal(t, []string{"title"}, updated.Changed)
+				assert.Equal(t, "Renamed", updated.Task.Title)
+				changed := payload(events[1])
+				assert.Equal(t, service.StatusNew, changed.From)
+				assert.Equal(t, service.StatusDone, changed.To)
+			},
+		},
+		{
+			name: "Запись тех же значений",
+			apply: func(t *testing.T, id int) {
+				_, err := svc.UpdateTask(ctx, id, service.UpdateTaskRequest{Title: ptr("Task")})
+				require.NoError(t, err)
+			},
+		},
+		{
+			name: "Массовое изменение статуса",
+			apply: func(t *testing.T, id int) {
+				_, err := svc.UpdateTasks(ctx, service.TaskSelector{IDs: []int{id}},
+					service.UpdateTaskRequest{Status: ptr(service.StatusInProgress)}, service.BulkOptions{})
+				require.NoError(t, err)
+			},
+			want: []string{service.EventTaskStatusChanged},
+		},
+		{
+			name: "Удаление",
+			apply: func(t *testing.T, id int) {
+				require.NoError(t, svc.DeleteTask(ctx, id))
+			},
+			want: []string{service.EventTaskDeleted},
+			check: func(t *testing.T, events []service.Event) {
+				assert.Equal(t, "Task", payload(events[0]).Task.Title, "задача на момент удаления")
+			},
+		},
+		{
+			name: "Задача не найдена",
+			apply: func(t *testing.T, id int) {
+				_, err := svc.UpdateTask(ctx, id+1000, service.UpdateTaskRequest{Title: ptr("Missing")})
+				require.ErrorIs(t, err, service.ErrTaskNotFound)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := svc.CreateTask(ctx, service.TaskRequest{Title: "Task"})
+			require.NoError(t, err)
+
+			created := pending()
+			require.Len(t, created, 1)
+			assert.Equal(t, service.EventTaskCreated, created[0].Type)
+			assert.Equal(t, service.EventVersion, created[0].Version)
+			assert.Equal(t, id, payload(created[0]).Task.ID)
+
+			tt.apply(t, id)
+
+			events := pending()
+			var types []string
+			for _, e := range events {
+				assert.Equal(t, id, e.TaskID)
+				types = append(types, e.Type)
+			}
+			assert.Equal(t, tt.want, types, "типы событий")
+			if tt.check != nil {
+				tt.check(t, events)
+			}
+		})
+	}
+}
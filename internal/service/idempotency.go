@@ -0,0 +1,30 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// IdempotentResponse - сохранённый ответ на запрос с ключом идемпотентности
+type IdempotentResponse struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// IdempotencyRecord - ключ, уже занятый запросом. Response nil - запрос ещё выполняется
+type IdempotencyRecord struct {
+	RequestHash []byte
+	Response    *IdempotentResponse
+}
+
+// IdempotencyStore - хранилище ключей идемпотентности. Ключи разных субъектов (principal) независимы
+type IdempotencyStore interface {
+	// ReserveIdempotencyKey - захват ключа для запроса с хешем hash на время lease.
+	// Свободный или истёкший ключ захватывается, результат (nil, nil); иначе возвращается текущая запись
+	ReserveIdempotencyKey(ctx context.Context, principal, key string, hash []byte, lease time.Duration) (*IdempotencyRecord, error)
+	// CompleteIdempotencyKey - сохранение ответа захваченного ключа на ttl
+	CompleteIdempotencyKey(ctx context.Context, principal, key string, resp IdempotentResponse, ttl time.Duration) error
+	// ReleaseIdempotencyKey - освобождение захваченного ключа без ответа, запрос можно повторить с тем же ключом
+	ReleaseIdempotencyKey(ctx context.Context, principal, key string) error
+}
@@ -2,17 +2,102 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"time"
 
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 )
 
 // Слой бизнес-логики. Тут должна быть основная логика сервиса
 
+// ErrTaskNotFound - задачи с таким ID нет
+var ErrTaskNotFound = errors.New("task not found")
+
+// ErrTimeout - бюджет времени запроса исчерпан до ответа хранилища
+var ErrTimeout = errors.New("request deadline exceeded")
+
+// ErrBulkLimitExceeded - массовая операция затрагивает больше задач, чем разрешено.
+// Сервис возвращает *BulkLimitError, который совпадает с ErrBulkLimitExceeded через errors.Is
+var ErrBulkLimitExceeded = errors.New("bulk operation limit exceeded")
+
+// BulkLimitError - операция затронула бы Affected задач при ограничении Limit
+type BulkLimitError struct {
+	Affected int
+	Limit    int
+}
+
+func (e *BulkLimitError) Error() string {
+	return fmt.Sprintf("bulk operation affects %d tasks, limit is %d", e.Affected, e.Limit)
+}
+
+func (e *BulkLimitError) Is(target error) bool {
+	return target == ErrBulkLimitExceeded
+}
+
+// ErrTaskExists - задача с таким ID уже есть
+var ErrTaskExists = errors.New("task already exists")
+
+// ImportConflictError - ID задач с позициями Indexes уже заняты, загрузка отменена целиком.
+// Совпадает с ErrTaskExists через errors.Is
+type ImportConflictError struct {
+	Indexes []int
+}
+
+func (e *ImportConflictError) Error() string {
+	return fmt.Sprintf("%d imported tasks already exist", len(e.Indexes))
+}
+
+func (e *ImportConflictError) Is(target error) bool {
+	return target == ErrTaskExists
+}
+
+// ErrOutcomeUnknown - соединение с хранилищем оборвалось после отправки изменяющего запроса,
+// и неизвестно, выполнен ли он. В отличие от ErrUnavailable повторять такой запрос небезопасно
+var ErrOutcomeUnknown = errors.New("storage connection lost, outcome unknown")
+
+// ErrUnavailable - хранилище временно недоступно, запрос стоит повторить позже.
+// Хранилище возвращает *UnavailableError, который совпадает с ErrUnavailable через errors.Is
+var ErrUnavailable = errors.New("storage unavailable")
+
+// UnavailableError - хранилище недоступно, RetryAfter - через сколько имеет смысл повторить
+type UnavailableError struct {
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *UnavailableError) Error() string {
+	return "storage unavailable: " + e.Err.Error()
+}
+
+func (e *UnavailableError) Unwrap() error {
+	return e.Err
+}
+
+func (e *UnavailableError) Is(target error) bool {
+	return target == ErrUnavailable
+}
+
 // Service - интерфейс для бизнес-логики
 type Service interface {
 	CreateTask(ctx context.Context, req TaskRequest) (int, error)
+	CreateTasks(ctx context.Context, reqs []TaskRequest) ([]int, error)
 	GetTask(ctx context.Context, id int) (*TaskResponse, error)
+	ListTasks(ctx context.Context, filter ListFilter) ([]TaskResponse, error)
+	UpdateTask(ctx context.Context, id int, req UpdateTaskRequest) (*TaskResponse, error)
+	DeleteTask(ctx context.Context, id int) error
+	UpdateTasks(ctx context.Context, sel TaskSelector, req UpdateTaskRequest, opts BulkOptions) (*BulkResult, error)
+	DeleteTasks(ctx context.Context, sel TaskSelector, opts BulkOptions) (*BulkResult, error)
+	ExportTasks(ctx context.Context, filter TaskFilter) (TaskCursor, error)
+	ImportTasks(ctx context.Context, reqs []ImportTaskRequest, opts ImportOptions) ([]int, error)
+}
+
+// TaskCursor - последовательное чтение задач по возрастанию ID. Курсор держит ресурсы хранилища до Close
+type TaskCursor interface {
+	// Next - следующая порция задач, пустая порция - задачи закончились
+	Next(ctx context.Context) ([]TaskResponse, error)
+	// Close - освобождение курсора, вызывается и после ошибки Next
+	Close(ctx context.Context) error
 }
 
 // Task - модель задачи для бизнес-логики
@@ -21,6 +106,13 @@ type Task struct {
 	Description string
 }
 
+// TaskUpdate - изменяемые поля задачи, nil поля не меняются
+type TaskUpdate struct {
+	Title       *string
+	Description *string
+	Status      *string
+}
+
 // TaskResponse - модель ответа с полной информацией о задаче
 type TaskResponse struct {
 	ID          int       `json:"id"`
@@ -31,30 +123,98 @@ type TaskResponse struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// IsolationLevel - уровень изоляции транзакции
+type IsolationLevel string
+
+// Уровни изоляции, пустой уровень - из конфигурации хранилища (DB_TX_ISOLATION)
+const (
+	IsolationDefault        IsolationLevel = ""
+	IsolationReadCommitted  IsolationLevel = "read_committed"
+	IsolationRepeatableRead IsolationLevel = "repeatable_read"
+	IsolationSerializable   IsolationLevel = "serializable"
+)
+
+// TxOptions - параметры транзакции
+type TxOptions struct {
+	Isolation IsolationLevel
+	ReadOnly  bool
+}
+
+// Transactor - выполнение нескольких вызовов репозитория как одной транзакции
+type Transactor interface {
+	// WithinTx - выполнение fn в транзакции. Вызовы репозитория с ctx, переданным в fn, идут в этой транзакции.
+	// Ошибка fn откатывает транзакцию и возвращается как есть. При конфликте сериализации или deadlock
+	// транзакция повторяется целиком, поэтому fn не должна иметь побочных эффектов вне репозитория.
+	// Вложенный вызов присоединяется к внешней транзакции, его opts игнорируются
+	WithinTx(ctx context.Context, opts TxOptions, fn func(ctx context.Context) error) error
+}
+
 // Repository - интерфейс для работы с задачами (только в service слое)
 type Repository interface {
+	Transactor
 	CreateTask(ctx context.Context, task Task) (int, error)
+	// CreateTasks - вставка нескольких задач одной транзакцией, ID в порядке tasks
+	CreateTasks(ctx context.Context, tasks []Task) ([]int, error)
 	GetTask(ctx context.Context, id int) (*TaskResponse, error)
+	// GetTasks - задачи с ID из ids по возрастанию ID, отсутствующие пропускаются.
+	// В транзакции строки блокируются до её завершения
+	GetTasks(ctx context.Context, ids []int) ([]TaskResponse, error)
+	ListTasks(ctx context.Context, filter ListFilter) ([]TaskResponse, error)
+	UpdateTask(ctx context.Context, id int, update TaskUpdate) (*TaskResponse, error)
+	DeleteTask(ctx context.Context, id int) error
+	// FindTaskIDs - ID задач, подходящих под sel, по возрастанию. В транзакции строки блокируются до её завершения
+	FindTaskIDs(ctx context.Context, sel TaskSelector) ([]int, error)
+	// UpdateTasks - изменение задач с ID из ids, возвращает число изменённых
+	UpdateTasks(ctx context.Context, ids []int, update TaskUpdate) (int, error)
+	// DeleteTasks - удаление задач с ID из ids, возвращает число удалённых
+	DeleteTasks(ctx context.Context, ids []int) (int, error)
+	// ExportTasks - курсор по задачам, подходящим под filter, на одном снимке данных
+	ExportTasks(ctx context.Context, filter TaskFilter) (TaskCursor, error)
+	// ImportTasks - вставка задач со всеми полями одной транзакцией, ID в порядке tasks.
+	// ID 0 - новый ID, нулевое время - текущее. Задача с занятым ID не вставляется, её ID в результате 0
+	ImportTasks(ctx context.Context, tasks []TaskResponse) ([]int, error)
 }
 
 type service struct {
 	repo Repository
-	log  *zap.SugaredLogger
+	// events - outbox событий изменения задач, nil - события не пишутся
+	events EventStore
+	log    *zap.SugaredLogger
+}
+
+// Option - необязательная зависимость сервиса
+type Option func(s *service)
+
+// WithEvents - каждое изменение задач пишет события в store в той же транзакции
+func WithEvents(store EventStore) Option {
+	return func(s *service) {
+		s.events = store
+	}
 }
 
 // NewService - конструктор сервиса
-func NewService(repository Repository, logger *zap.SugaredLogger) Service {
-	return &service{
+func NewService(repository Repository, logger *zap.SugaredLogger, opts ...Option) Service {
+	s := &service{
 		repo: repository,
 		log:  logger,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // CreateTask - бизнес-логика создания задачи
 func (s *service) CreateTask(ctx context.Context, req TaskRequest) (int, error) {
 	task := req.ToTask()
 
-	taskID, err := s.repo.CreateTask(ctx, task)
+	var taskID int
+	err := s.write(ctx, func(ctx context.Context) (err error) {
+		if taskID, err = s.repo.CreateTask(ctx, task); err != nil {
+			return err
+		}
+		return s.recordCreated(ctx, []int{taskID})
+	})
 	if err != nil {
 		s.log.Errorw("Failed to insert task", "error", err)
 		return 0, err
@@ -63,6 +223,28 @@ func (s *service) CreateTask(ctx context.Context, req TaskRequest) (int, error)
 	return taskID, nil
 }
 
+// CreateTasks - бизнес-логика создания нескольких задач: создаются все или ни одной
+func (s *service) CreateTasks(ctx context.Context, reqs []TaskRequest) ([]int, error) {
+	tasks := make([]Task, 0, len(reqs))
+	for _, req := range reqs {
+		tasks = append(tasks, req.ToTask())
+	}
+
+	var ids []int
+	err := s.write(ctx, func(ctx context.Context) (err error) {
+		if ids, err = s.repo.CreateTasks(ctx, tasks); err != nil {
+			return err
+		}
+		return s.recordCreated(ctx, ids)
+	})
+	if err != nil {
+		s.log.Errorw("Failed to insert tasks", "error", err, "count", len(tasks))
+		return nil, err
+	}
+
+	return ids, nil
+}
+
 // GetTask - бизнес-логика получения задачи по ID
 func (s *service) GetTask(ctx context.Context, id int) (*TaskResponse, error) {
 	task, err := s.repo.GetTask(ctx, id)
@@ -73,3 +255,185 @@ func (s *service) GetTask(ctx context.Context, id int) (*TaskResponse, error) {
 
 	return task, nil
 }
+
+// ListTasks - бизнес-логика получения списка задач
+func (s *service) ListTasks(ctx context.Context, filter ListFilter) ([]TaskResponse, error) {
+	tasks, err := s.repo.ListTasks(ctx, filter)
+	if err != nil {
+		s.log.Errorw("Failed to list tasks", "error", err)
+		return nil, err
+	}
+
+	return tasks, nil
+}
+
+// UpdateTask - бизнес-логика частичного обновления задачи
+func (s *service) UpdateTask(ctx context.Context, id int, req UpdateTaskRequest) (*TaskResponse, error) {
+	var task *TaskResponse
+	err := s.write(ctx, func(ctx context.Context) error {
+		before, err := s.snapshot(ctx, []int{id})
+		if err != nil {
+			return err
+		}
+		if task, err = s.repo.UpdateTask(ctx, id, req.ToTaskUpdate()); err != nil {
+			return err
+		}
+		return s.recordChanged(ctx, before, []TaskResponse{*task})
+	})
+	if err != nil {
+		s.log.Errorw("Failed to update task", "error", err, "task_id", id)
+		return nil, err
+	}
+
+	return task, nil
+}
+
+// DeleteTask - бизнес-логика удаления задачи
+func (s *service) DeleteTask(ctx context.Context, id int) error {
+	err := s.write(ctx, func(ctx context.Context) error {
+		before, err := s.snapshot(ctx, []int{id})
+		if err != nil {
+			return err
+		}
+		if err := s.repo.DeleteTask(ctx, id); err != nil {
+			return err
+		}
+		return s.recordDeleted(ctx, before)
+	})
+	if err != nil {
+		s.log.Errorw("Failed to delete task", "error", err, "task_id", id)
+		return err
+	}
+
+	return nil
+}
+
+// UpdateTasks - изменение задач из sel одной транзакцией. Задачи отбираются и блокируются
+// в той же транзакции, поэтому ограничение MaxAffected проверяется по тем строкам, которые будут изменены
+func (s *service) UpdateTasks(ctx context.Context, sel TaskSelector, req UpdateTaskRequest, opts BulkOptions) (*BulkResult, error) {
+	result, err := s.bulk(ctx, sel, opts, func(ctx context.Context, ids []int) (int, error) {
+		before, err := s.snapshot(ctx, ids)
+		if err != nil {
+			return 0, err
+		}
+		n, err := s.repo.UpdateTasks(ctx, ids, req.ToTaskUpdate())
+		if err != nil {
+			return 0, err
+		}
+		after, err := s.snapshot(ctx, ids)
+		if err != nil {
+			return 0, err
+		}
+		return n, s.recordChanged(ctx, before, after)
+	})
+	if err != nil {
+		s.log.Errorw("Failed to update tasks", "error", err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// DeleteTasks - удаление задач из sel одной транзакцией
+func (s *service) DeleteTasks(ctx context.Context, sel TaskSelector, opts BulkOptions) (*BulkResult, error) {
+	result, err := s.bulk(ctx, sel, opts, func(ctx context.Context, ids []int) (int, error) {
+		before, err := s.snapshot(ctx, ids)
+		if err != nil {
+			return 0, err
+		}
+		n, err := s.repo.DeleteTasks(ctx, ids)
+		if err != nil {
+			return 0, err
+		}
+		return n, s.recordDeleted(ctx, before)
+	})
+	if err != nil {
+		s.log.Errorw("Failed to delete tasks", "error", err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// bulk - отбор задач, проверка ограничения и apply в одной транзакции.
+// При DryRun транзакция только читает, превышение ограничения отмечается в результате
+func (s *service) bulk(ctx context.Context, sel TaskSelector, opts BulkOptions,
+	apply func(ctx context.Context, ids []int) (int, error)) (*BulkResult, error) {
+	var result *BulkResult
+	err := s.repo.WithinTx(ctx, TxOptions{}, func(ctx context.Context) error {
+		ids, err := s.repo.FindTaskIDs(ctx, sel)
+		if err != nil {
+			return err
+		}
+
+		result = &BulkResult{
+			Affected:      len(ids),
+			IDs:           ids,
+			DryRun:        opts.DryRun,
+			LimitExceeded: opts.MaxAffected > 0 && len(ids) > opts.MaxAffected,
+		}
+		if opts.DryRun || len(ids) == 0 {
+			return nil
+		}
+		if result.LimitExceeded {
+			return &BulkLimitError{Affected: len(ids), Limit: opts.MaxAffected}
+		}
+
+		// Строки заблокированы при отборе, поэтому затронуты будут ровно они
+		result.Affected, err = apply(ctx, ids)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ExportTasks - курсор выгрузки задач, подходящих под filter
+func (s *service) ExportTasks(ctx context.Context, filter TaskFilter) (TaskCursor, error) {
+	cursor, err := s.repo.ExportTasks(ctx, filter)
+	if err != nil {
+		s.log.Errorw("Failed to open export cursor", "error", err)
+		return nil, err
+	}
+
+	return cursor, nil
+}
+
+// ImportTasks - загрузка задач одной транзакцией, ID в порядке reqs. Задача с уже занятым ID
+// в режиме best_effort пропускается (ID 0), в all_or_nothing отменяет загрузку с *ImportConflictError
+func (s *service) ImportTasks(ctx context.Context, reqs []ImportTaskRequest, opts ImportOptions) ([]int, error) {
+	tasks := make([]TaskResponse, 0, len(reqs))
+	for _, req := range reqs {
+		tasks = append(tasks, req.ToTask(opts))
+	}
+
+	var ids []int
+	err := s.repo.WithinTx(ctx, TxOptions{}, func(ctx context.Context) error {
+		var err error
+		if ids, err = s.repo.ImportTasks(ctx, tasks); err != nil {
+			return err
+		}
+		if opts.Mode == BatchBestEffort {
+			return s.recordCreated(ctx, ids)
+		}
+
+		var conflicts []int
+		for i, id := range ids {
+			if id == 0 {
+				conflicts = append(conflicts, i)
+			}
+		}
+		if len(conflicts) > 0 {
+			return &ImportConflictError{Indexes: conflicts}
+		}
+		return s.recordCreated(ctx, ids)
+	})
+	if err != nil {
+		s.log.Errorw("Failed to import tasks", "error", err, "count", len(tasks))
+		return nil, err
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"slices"
+)
+
+// subjectKey - ключ контекста для субъекта запроса
+type subjectKey struct{}
+
+// WithSubject - контекст с субъектом запроса (claim sub токена). Хранилище по нему
+// направляет чтения после записи того же пользователя в primary
+func WithSubject(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, subjectKey{}, subject)
+}
+
+// SubjectFromContext - субъект запроса, пустая строка для анонимных вызовов
+func SubjectFromContext(ctx context.Context) string {
+	subject, _ := ctx.Value(subjectKey{}).(string)
+	return subject
+}
+
+// ScopeAdmin - scope администратора: снимает ограничение на число задач в массовых операциях
+const ScopeAdmin = "tasks:admin"
+
+// scopesKey - ключ контекста для scope токена
+type scopesKey struct{}
+
+// WithScopes - контекст со scope токена (claim scope через пробел)
+func WithScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, scopesKey{}, scopes)
+}
+
+// HasScope - у субъекта запроса есть scope
+func HasScope(ctx context.Context, scope string) bool {
+	scopes, _ := ctx.Value(scopesKey{}).([]string)
+	return slices.Contains(scopes, scope)
+}
+
+// primaryKey - ключ контекста для чтения из primary
+type primaryKey struct{}
+
+// WithPrimary - контекст, чтения которого хранилище направляет в primary независимо от субъекта.
+// Нужен тем, кто отдаёт прочитанное другим пользователям, например общему кешу
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// ReadsPrimary - чтения контекста должны идти в primary
+func ReadsPrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey{}).(bool)
+	return primary
+}
@@ -89,3 +89,47 @@ func TestTaskRequestValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdateTaskRequestValidation(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+
+	tests := []struct {
+		name       string
+		request    UpdateTaskRequest
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name:    "Только статус",
+			request: UpdateTaskRequest{Status: ptr(StatusDone)},
+		},
+		{
+			name:    "Пустое описание допустимо",
+			request: UpdateTaskRequest{Description: ptr("")},
+		},
+		{
+			name:       "Пустой title",
+			request:    UpdateTaskRequest{Title: ptr("")},
+			wantErr:    true,
+			wantErrMsg: "Field is below minimum length (min: 1 characters) for field: Title",
+		},
+		{
+			name:       "Неизвестный статус",
+			request:    UpdateTaskRequest{Status: ptr("closed")},
+			wantErr:    true,
+			wantErrMsg: "Field must be one of (new, in_progress, done) for field: Status",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validator.Validate(context.Background(), tt.request)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Equal(t, tt.wantErrMsg, err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
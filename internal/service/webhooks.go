@@ -0,0 +1,281 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// ErrWebhookNotFound - подписки с таким ID нет
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// ErrDeliveryNotFound - доставки с таким ID у подписки нет
+var ErrDeliveryNotFound = errors.New("delivery not found")
+
+// ErrDeliveryInFlight - доставка выбрана для отправки или ждёт повтора, повторять её вручную нельзя
+var ErrDeliveryInFlight = errors.New("delivery is in flight")
+
+// Статусы доставки события подписчику
+const (
+	// DeliveryPending - доставка ждёт отправки или повтора
+	DeliveryPending = "pending"
+	// DeliveryDelivered - подписчик ответил 2xx
+	DeliveryDelivered = "delivered"
+	// DeliveryDead - попытки исчерпаны (dead letter), доставку можно повторить вручную
+	DeliveryDead = "dead"
+)
+
+// webhookSecretBytes - длина секрета, созданного сервисом
+const webhookSecretBytes = 32
+
+// Webhook - подписка на события задач
+type Webhook struct {
+	ID  int    `json:"id"`
+	URL string `json:"url"`
+	// EventTypes - типы событий подписки, пустой список - все события
+	EventTypes []string  `json:"event_types"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Subscribed - подписка получает события типа eventType
+func (w Webhook) Subscribed(eventType string) bool {
+	return len(w.EventTypes) == 0 || slices.Contains(w.EventTypes, eventType)
+}
+
+// NewWebhook - новая подписка с секретом подписи
+type NewWebhook struct {
+	URL        string
+	EventTypes []string
+	Secret     string
+	Active     bool
+}
+
+// WebhookUpdate - изменяемые поля подписки, nil поля не меняются
+type WebhookUpdate struct {
+	URL        *string
+	EventTypes *[]string
+	Secret     *string
+	Active     *bool
+}
+
+// WebhookRequest - создание подписки. Без secret сервис создаёт случайный секрет
+type WebhookRequest struct {
+	URL        string   `json:"url" validate:"required,max=2048"`
+	EventTypes []string `json:"event_types" validate:"dive,oneof=task.created task.updated task.status_changed task.deleted"`
+	Secret     string   `json:"secret" validate:"omitempty,min=16,max=256"`
+	Active     *bool    `json:"active"`
+}
+
+// UpdateWebhookRequest - частичное изменение подписки, nil поля не меняются
+type UpdateWebhookRequest struct {
+	URL        *string   `json:"url,omitempty" validate:"omitempty,max=2048"`
+	EventTypes *[]string `json:"event_types,omitempty" validate:"omitempty,dive,oneof=task.created task.updated task.status_changed task.deleted"`
+	Secret     *string   `json:"secret,omitempty" validate:"omitempty,min=16,max=256"`
+	Active     *bool     `json:"active,omitempty"`
+}
+
+// Empty - в запросе нет ни одного поля для изменения
+func (r UpdateWebhookRequest) Empty() bool {
+	return r.URL == nil && r.EventTypes == nil && r.Secret == nil && r.Active == nil
+}
+
+// ToWebhookUpdate - конвертирует UpdateWebhookRequest в WebhookUpdate
+func (r UpdateWebhookRequest) ToWebhookUpdate() WebhookUpdate {
+	return WebhookUpdate(r)
+}
+
+// CheckWebhookURL - адрес подписки: абсолютный http или https URL с хостом
+func CheckWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.Errorf("invalid webhook url %q, expected absolute http or https URL", raw)
+	}
+	return nil
+}
+
+// Delivery - доставка события одному подписчику, запись журнала доставок
+type Delivery struct {
+	ID        int64  `json:"id"`
+	WebhookID int    `json:"webhook_id"`
+	EventID   int64  `json:"event_id"`
+	EventType string `json:"event_type"`
+	// Payload - тело запроса к подписчику: событие целиком
+	Payload  json.RawMessage `json:"payload"`
+	Status   string          `json:"status"`
+	Attempts int             `json:"attempts"`
+	// LastStatusCode - HTTP статус последней попытки, 0 - ответа не было
+	LastStatusCode int    `json:"last_status_code,omitempty"`
+	LastError      string `json:"last_error,omitempty"`
+	// NextAttemptAt - когда доставка будет отправлена, для pending
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+}
+
+// PendingDelivery - доставка, выбранная для отправки, с адресом и секретом подписки
+type PendingDelivery struct {
+	Delivery
+	URL    string
+	Secret string
+}
+
+// DeliveryAttempt - результат попытки доставки
+type DeliveryAttempt struct {
+	// Status - статус доставки после попытки
+	Status     string
+	StatusCode int
+	Error      string
+	// RetryIn - пауза до следующей попытки для Status pending
+	RetryIn time.Duration
+}
+
+// DeliveryFilter - фильтр и страница журнала доставок
+type DeliveryFilter struct {
+	Status string `validate:"omitempty,oneof=pending delivered dead"`
+	Limit  int    `validate:"gte=1,lte=1000"`
+	Offset int    `validate:"gte=0"`
+}
+
+// WebhookStore - хранилище подписок и журнала доставок
+type WebhookStore interface {
+	CreateWebhook(ctx context.Context, hook NewWebhook) (*Webhook, error)
+	GetWebhook(ctx context.Context, id int) (*Webhook, error)
+	// ListWebhooks - все подписки по возрастанию ID
+	ListWebhooks(ctx context.Context) ([]Webhook, error)
+	UpdateWebhook(ctx context.Context, id int, update WebhookUpdate) (*Webhook, error)
+	// DeleteWebhook - удаление подписки вместе с журналом её доставок
+	DeleteWebhook(ctx context.Context, id int) error
+	// ListDeliveries - доставки подписки, новые первыми; ErrWebhookNotFound, если подписки нет
+	ListDeliveries(ctx context.Context, webhookID int, filter DeliveryFilter) ([]Delivery, error)
+	// ReplayDelivery - доставка снова ждёт отправки с нулевым счётчиком попыток. Для pending доставки,
+	// у которой next_attempt_at ещё не наступил, - ErrDeliveryInFlight: её отправляют или повторят сами
+	ReplayDelivery(ctx context.Context, webhookID int, id int64) (*Delivery, error)
+}
+
+// WebhookService - управление подписками на события задач
+type WebhookService interface {
+	// CreateWebhook - новая подписка и её секрет. Секрет возвращается только здесь
+	CreateWebhook(ctx context.Context, req WebhookRequest) (*Webhook, string, error)
+	GetWebhook(ctx context.Context, id int) (*Webhook, error)
+	ListWebhooks(ctx context.Context) ([]Webhook, error)
+	UpdateWebhook(ctx context.Context, id int, req UpdateWebhookRequest) (*Webhook, error)
+	DeleteWebhook(ctx context.Context, id int) error
+	ListDeliveries(ctx context.Context, webhookID int, filter DeliveryFilter) ([]Delivery, error)
+	ReplayDelivery(ctx context.Context, webhookID int, id int64) (*Delivery, error)
+}
+
+type webhookService struct {
+	store WebhookStore
+	log   *zap.SugaredLogger
+}
+
+// NewWebhookService - конструктор сервиса подписок
+func NewWebhookService(store WebhookStore, logger *zap.SugaredLogger) WebhookService {
+	return &webhookService{
+		store: store,
+		log:   logger,
+	}
+}
+
+// CreateWebhook - подписка активна, если в запросе не указано обратное
+func (s *webhookService) CreateWebhook(ctx context.Context, req WebhookRequest) (*Webhook, string, error) {
+	secret := req.Secret
+	if secret == "" {
+		var err error
+		if secret, err = newWebhookSecret(); err != nil {
+			return nil, "", err
+		}
+	}
+	active := req.Active == nil || *req.Active
+	eventTypes := req.EventTypes
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+
+	hook, err := s.store.CreateWebhook(ctx, NewWebhook{URL: req.URL, EventTypes: eventTypes, Secret: secret, Active: active})
+	if err != nil {
+		s.log.Errorw("Failed to create webhook", "error", err)
+		return nil, "", err
+	}
+	s.log.Infow("Webhook created", "webhook_id", hook.ID, "url", hook.URL, "event_types", hook.EventTypes)
+
+	return hook, secret, nil
+}
+
+func (s *webhookService) GetWebhook(ctx context.Context, id int) (*Webhook, error) {
+	hook, err := s.store.GetWebhook(ctx, id)
+	if err != nil {
+		s.log.Errorw("Failed to get webhook", "error", err, "webhook_id", id)
+		return nil, err
+	}
+	return hook, nil
+}
+
+func (s *webhookService) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	hooks, err := s.store.ListWebhooks(ctx)
+	if err != nil {
+		s.log.Errorw("Failed to list webhooks", "error", err)
+		return nil, err
+	}
+	return hooks, nil
+}
+
+func (s *webhookService) UpdateWebhook(ctx context.Context, id int, req UpdateWebhookRequest) (*Webhook, error) {
+	update := req.ToWebhookUpdate()
+	if update.EventTypes != nil && *update.EventTypes == nil {
+		update.EventTypes = &[]string{}
+	}
+
+	hook, err := s.store.UpdateWebhook(ctx, id, update)
+	if err != nil {
+		s.log.Errorw("Failed to update webhook", "error", err, "webhook_id", id)
+		return nil, err
+	}
+	return hook, nil
+}
+
+func (s *webhookService) DeleteWebhook(ctx context.Context, id int) error {
+	if err := s.store.DeleteWebhook(ctx, id); err != nil {
+		s.log.Errorw("Failed to delete webhook", "error", err, "webhook_id", id)
+		return err
+	}
+	s.log.Infow("Webhook deleted", "webhook_id", id)
+	return nil
+}
+
+func (s *webhookService) ListDeliveries(ctx context.Context, webhookID int, filter DeliveryFilter) ([]Delivery, error) {
+	deliveries, err := s.store.ListDeliveries(ctx, webhookID, filter)
+	if err != nil {
+		s.log.Errorw("Failed to list webhook deliveries", "error", err, "webhook_id", webhookID)
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (s *webhookService) ReplayDelivery(ctx context.Context, webhookID int, id int64) (*Delivery, error) {
+	delivery, err := s.store.ReplayDelivery(ctx, webhookID, id)
+	if err != nil {
+		s.log.Errorw("Failed to replay webhook delivery", "error", err, "webhook_id", webhookID, "delivery_id", id)
+		return nil, err
+	}
+	s.log.Infow("Webhook delivery replayed", "webhook_id", webhookID, "delivery_id", id, "event_id", delivery.EventID)
+	return delivery, nil
+}
+
+// newWebhookSecret - случайный секрет подписи в hex
+func newWebhookSecret() (string, error) {
+	b := make([]byte, webhookSecretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "failed to generate webhook secret")
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,59 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Заголовки запроса к подписчику
+const (
+	// HeaderDelivery - ID доставки, один для всех попыток и ручных повторов
+	HeaderDelivery = "X-Webhook-Delivery"
+	// HeaderEvent - тип события
+	HeaderEvent = "X-Webhook-Event"
+	// HeaderTimestamp - время отправки попытки, Unix секунды
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	// HeaderSignature - подпись "sha256=<hex>" от HeaderTimestamp и тела
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+const signaturePrefix = "sha256="
+
+// ErrInvalidSignature - подпись не совпадает с телом и временем запроса
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// ErrStaleTimestamp - время запроса дальше допустимого от текущего, запрос мог быть перехвачен и повторён
+var ErrStaleTimestamp = errors.New("webhook timestamp outside tolerance")
+
+// Sign - значение HeaderSignature: HMAC-SHA256 секретом от "<timestamp>.<body>".
+// Время входит в подпись, поэтому перехваченный запрос нельзя повторить позже с новым временем
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify - проверка запроса на стороне подписчика по значениям HeaderTimestamp и HeaderSignature:
+// подпись совпадает, и время запроса отличается от now не больше чем на tolerance
+func Verify(secret, timestamp, signature string, body []byte, tolerance time.Duration, now time.Time) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.Wrapf(ErrInvalidSignature, "invalid timestamp %q", timestamp)
+	}
+	if !strings.HasPrefix(signature, signaturePrefix) ||
+		!hmac.Equal([]byte(signature), []byte(Sign(secret, ts, body))) {
+		return ErrInvalidSignature
+	}
+	if skew := now.Sub(time.Unix(ts, 0)).Abs(); skew > tolerance {
+		return errors.Wrapf(ErrStaleTimestamp, "skew %s", skew)
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+// Package webhook - доставка событий задач подписчикам по HTTP.
+// Publisher превращает событие из outbox в доставки для каждой подписки на его тип,
+// Dispatcher отправляет доставки POST запросом с подписью HMAC-SHA256 (см. Sign), повторяет
+// неудачные с экспоненциальной паузой и после WEBHOOK_MAX_ATTEMPTS попыток переводит доставку
+// в dead letter. Доставка не меньше одного раза: подписчик распознаёт повтор по ID события в теле.
+// События одной задачи подписчик получает в порядке записи: следующая доставка задачи ждёт,
+// пока предыдущая не доставлена или не ушла в dead letter
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"simple-service/internal/config"
+	"simple-service/internal/metrics"
+	"simple-service/internal/service"
+	"simple-service/pkg/backoff"
+)
+
+// Store - очередь доставок подписчикам
+type Store interface {
+	// EnqueueDeliveries - доставка body события задачи taskID каждой активной подписке на eventType,
+	// возвращает число созданных. Повтор с тем же eventID новых доставок не создаёт
+	EnqueueDeliveries(ctx context.Context, eventID int64, eventType string, taskID int, body []byte) (int, error)
+	// ClaimDeliveries - до limit доставок, готовых к отправке. Выбранные доставки не выбираются
+	// повторно в течение lease, если раньше не записан результат попытки. Доставка не выбирается,
+	// пока у подписки есть ждущая доставка той же задачи с меньшим ID
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]service.PendingDelivery, error)
+	// RecordAttempt - результат попытки доставки id, счётчик попыток увеличивается
+	RecordAttempt(ctx context.Context, id int64, attempt service.DeliveryAttempt) error
+	// PurgeDeliveries - удаление доставленных и dead letter доставок старше retention, возвращает число удалённых
+	PurgeDeliveries(ctx context.Context, retention time.Duration) (int, error)
+}
+
+// Publisher - получатель outbox: событие становится доставками подписчикам.
+// Повторная публикация того же события дубликатов не создаёт
+type Publisher struct {
+	store Store
+}
+
+// NewPublisher - получатель, ставящий доставки в store
+func NewPublisher(store Store) *Publisher {
+	return &Publisher{store: store}
+}
+
+// Publish - доставки события подписчикам, тело запроса - событие целиком
+func (p *Publisher) Publish(ctx context.Context, event service.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode event")
+	}
+	_, err = p.store.EnqueueDeliveries(ctx, event.ID, event.Type, event.TaskID, body)
+	return err
+}
+
+const (
+	// leaseMargin - запас аренды доставки сверх WEBHOOK_TIMEOUT на запись результата
+	leaseMargin = 30 * time.Second
+	// purgeInterval - как часто удалять устаревший журнал доставок
+	purgeInterval = time.Hour
+	// bookkeepingTimeout - ограничение записи результата попытки, в том числе при остановке
+	bookkeepingTimeout = 5 * time.Second
+	// maxResponseBody - сколько читать из ответа подписчика, остальное отбрасывается
+	maxResponseBody = 64 * 1024
+)
+
+// Dispatcher - отправка доставок подписчикам
+type Dispatcher struct {
+	store  Store
+	client *http.Client
+	cfg    config.Webhooks
+	log    *zap.SugaredLogger
+
+	// now подменяется в тестах
+	now func() time.Time
+}
+
+// NewDispatcher - отправка с параметрами из cfg. Перенаправления не выполняются:
+// ответ 3xx - неудачная попытка, адрес подписки нужно исправить
+func NewDispatcher(store Store, cfg config.Webhooks, logger *zap.SugaredLogger) *Dispatcher {
+	return &Dispatcher{
+		store: store,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		cfg: cfg,
+		log: logger,
+		now: time.Now,
+	}
+}
+
+// Run - отправка до отмены ctx. Пока доставки выбираются полными пачками, следующая пачка
+// выбирается сразу, иначе - через PollInterval. Раз в purgeInterval удаляется устаревший журнал
+func (d *Dispatcher) Run(ctx context.Context) {
+	var lastPurge time.Time
+	for {
+		if time.Since(lastPurge) >= purgeInterval {
+			lastPurge = time.Now()
+			if n, err := d.store.PurgeDeliveries(ctx, d.cfg.Retention); err != nil {
+				d.log.Warnw("Failed to purge webhook deliveries", "error", err)
+			} else if n > 0 {
+				d.log.Infow("Purged webhook deliveries", "deleted", n)
+			}
+		}
+
+		n, err := d.Deliver(ctx)
+		if err != nil && ctx.Err() == nil {
+			d.log.Warnw("Failed to deliver webhooks", "error", err)
+		}
+		if err == nil && n == d.cfg.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(d.cfg.PollInterval):
+		}
+	}
+}
+
+// Deliver - одновременная отправка одной пачки доставок, возвращает число выбранных.
+// Доставки, прерванные остановкой, не учитываются и отправляются снова по окончании аренды
+func (d *Dispatcher) Deliver(ctx context.Context) (int, error) {
+	pending, err := d.store.ClaimDeliveries(ctx, d.cfg.BatchSize, d.cfg.Timeout+leaseMargin)
+	if err != nil || len(pending) == 0 {
+		return 0, err
+	}
+
+	// Результаты записываются и после отмены ctx: иначе доставленное будет отправлено повторно
+	bookCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), d.cfg.Timeout+bookkeepingTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, p := range pending {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			attempt := d.send(ctx, p)
+			if ctx.Err() != nil && attempt.Status != service.DeliveryDelivered {
+				return
+			}
+			if err := d.store.RecordAttempt(bookCtx, p.ID, attempt); err != nil {
+				d.log.Warnw("Failed to record webhook delivery attempt", "error", err, "delivery_id", p.ID)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return len(pending), nil
+}
+
+// send - одна попытка доставки и её результат
+func (d *Dispatcher) send(ctx context.Context, p service.PendingDelivery) service.DeliveryAttempt {
+	start := time.Now()
+	code, err := d.post(ctx, p)
+	metrics.WebhookRequestDuration.Observe(time.Since(start).Seconds())
+
+	if err == nil {
+		metrics.WebhookDeliveries.WithLabelValues("delivered").Inc()
+		return service.DeliveryAttempt{Status: service.DeliveryDelivered, StatusCode: code}
+	}
+	if ctx.Err() != nil {
+		// Остановка, а не ошибка подписчика: попытка не учитывается
+		return service.DeliveryAttempt{Status: service.DeliveryPending, Error: err.Error()}
+	}
+
+	attempt := service.DeliveryAttempt{Status: service.DeliveryPending, StatusCode: code, Error: err.Error()}
+	attempts := p.Attempts + 1
+	if attempts >= d.cfg.MaxAttempts {
+		attempt.Status = service.DeliveryDead
+		metrics.WebhookDeliveries.WithLabelValues("dead").Inc()
+		d.log.Warnw("Webhook delivery moved to dead letter", "error", err, "delivery_id", p.ID,
+			"webhook_id", p.WebhookID, "event_id", p.EventID, "attempts", attempts)
+		return attempt
+	}
+
+	attempt.RetryIn = backoff.Exponential(d.cfg.RetryBaseDelay, d.cfg.RetryMaxDelay, attempts)
+	metrics.WebhookDeliveries.WithLabelValues("failed").Inc()
+	d.log.Warnw("Webhook delivery failed", "error", err, "delivery_id", p.ID, "webhook_id", p.WebhookID,
+		"event_id", p.EventID, "attempt", attempts, "retry_in", attempt.RetryIn)
+	return attempt
+}
+
+// post - подписанный запрос к подписчику; ошибка, если ответа нет или он не 2xx
+func (d *Dispatcher) post(ctx context.Context, p service.PendingDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(p.Payload))
+	if err != nil {
+		return 0, errors.Wrap(err, "invalid request")
+	}
+
+	timestamp := d.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(p.ID, 10))
+	req.Header.Set(HeaderEvent, p.EventType)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(p.Secret, timestamp, p.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Тело дочитывается, чтобы соединение вернулось в пул
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, errors.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,292 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"simple-service/internal/config"
+	"simple-service/internal/repo/memory"
+	"simple-service/internal/service"
+)
+
+const testSecret = "0123456789abcdef"
+
+// receivedRequest - запрос, принятый подписчиком
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver - подписчик на httptest: проверяет подпись и отвечает status
+type receiver struct {
+	mu       sync.Mutex
+	requests []receivedRequest
+	verified []error
+	status   atomic.Int32
+}
+
+func newReceiver(t *testing.T) (*receiver, *httptest.Server) {
+	t.Helper()
+	rec := &receiver{}
+	rec.status.Store(http.StatusOK)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		err := Verify(testSecret, r.Header.Get(HeaderTimestamp), r.Header.Get(HeaderSignature), body, time.Minute, time.Now())
+
+		rec.mu.Lock()
+		rec.requests = append(rec.requests, receivedRequest{header: r.Header.Clone(), body: body})
+		rec.verified = append(rec.verified, err)
+		rec.mu.Unlock()
+
+		w.WriteHeader(int(rec.status.Load()))
+	}))
+	t.Cleanup(server.Close)
+	return rec, server
+}
+
+func (rec *receiver) count() int {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return len(rec.requests)
+}
+
+func testConfig() config.Webhooks {
+	return config.Webhooks{
+		Timeout:        time.Second,
+		MaxAttempts:    3,
+		RetryBaseDelay: time.Millisecond,
+		RetryMaxDelay:  time.Millisecond,
+		PollInterval:   time.Millisecond,
+		BatchSize:      10,
+		Retention:      time.Hour,
+	}
+}
+
+func createWebhook(t *testing.T, store service.WebhookStore, url string, eventTypes ...string) *service.Webhook {
+	t.Helper()
+	hook, err := store.CreateWebhook(context.Background(), service.NewWebhook{
+		URL: url, EventTypes: eventTypes, Secret: testSecret, Active: true,
+	})
+	require.NoError(t, err)
+	return hook
+}
+
+func publish(t *testing.T, store Store, id int64, eventType string) {
+	t.Helper()
+	event := service.Event{ID: id, Type: eventType, Version: 1, TaskID: 7, Payload: []byte(`{"task":{"id":7}}`)}
+	require.NoError(t, NewPublisher(store).Publish(context.Background(), event))
+}
+
+func deliveries(t *testing.T, store service.WebhookStore, webhookID int) []service.Delivery {
+	t.Helper()
+	list, err := store.ListDeliveries(context.Background(), webhookID, service.DeliveryFilter{Limit: 100})
+	require.NoError(t, err)
+	return list
+}
+
+func TestDeliver(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewRepository()
+	rec, server := newReceiver(t)
+	hook := createWebhook(t, store, server.URL)
+
+	// Повторная публикация события из outbox не создаёт второй доставки
+	publish(t, store, 1, service.EventTaskCreated)
+	publish(t, store, 1, service.EventTaskCreated)
+
+	dispatcher := NewDispatcher(store, testConfig(), zap.NewNop().Sugar())
+	n, err := dispatcher.Deliver(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	require.Equal(t, 1, rec.count())
+	req := rec.requests[0]
+	assert.NoError(t, rec.verified[0], "подпись проверяется секретом подписки")
+	assert.Equal(t, "application/json", req.header.Get("Content-Type"))
+	assert.Equal(t, service.EventTaskCreated, req.header.Get(HeaderEvent))
+
+	var event service.Event
+	require.NoError(t, json.Unmarshal(req.body, &event))
+	assert.Equal(t, int64(1), event.ID)
+	assert.JSONEq(t, `{"task":{"id":7}}`, string(event.Payload))
+
+	list := deliveries(t, store, hook.ID)
+	require.Len(t, list, 1)
+	assert.Equal(t, strconv.FormatInt(list[0].ID, 10), req.header.Get(HeaderDelivery))
+	assert.Equal(t, service.DeliveryDelivered, list[0].Status)
+	assert.Equal(t, 1, list[0].Attempts)
+	assert.Equal(t, http.StatusOK, list[0].LastStatusCode)
+	assert.NotNil(t, list[0].DeliveredAt)
+
+	// Доставленное не отправляется повторно
+	n, err = dispatcher.Deliver(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestSubscriptions(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewRepository()
+	_, server := newReceiver(t)
+
+	all := createWebhook(t, store, server.URL)
+	deleted := createWebhook(t, store, server.URL, service.EventTaskDeleted)
+	inactive := createWebhook(t, store, server.URL)
+	off := false
+	_, err := store.UpdateWebhook(ctx, inactive.ID, service.WebhookUpdate{Active: &off})
+	require.NoError(t, err)
+
+	publish(t, store, 1, service.EventTaskCreated)
+	publish(t, store, 2, service.EventTaskDeleted)
+
+	tests := []struct {
+		name     string
+		hook     *service.Webhook
+		expected []int64
+	}{
+		{name: "Подписка на все события", hook: all, expected: []int64{2, 1}},
+		{name: "Фильтр по типу события", hook: deleted, expected: []int64{2}},
+		{name: "Неактивная подписка", hook: inactive, expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var events []int64
+			for _, d := range deliveries(t, store, tt.hook.ID) {
+				events = append(events, d.EventID)
+			}
+			assert.Equal(t, tt.expected, events)
+		})
+	}
+}
+
+func TestDeadLetterAndReplay(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewRepository()
+	rec, server := newReceiver(t)
+	rec.status.Store(http.StatusInternalServerError)
+	hook := createWebhook(t, store, server.URL)
+	publish(t, store, 1, service.EventTaskUpdated)
+
+	cfg := testConfig()
+	dispatcher := NewDispatcher(store, cfg, zap.NewNop().Sugar())
+
+	// Каждая неудачная попытка откладывает следующую, после MaxAttempts доставка в dead letter
+	for i := 0; deliveries(t, store, hook.ID)[0].Status != service.DeliveryDead; i++ {
+		require.Less(t, i, 100, "доставка не перешла в dead letter")
+		_, err := dispatcher.Deliver(ctx)
+		require.NoError(t, err)
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	dead := deliveries(t, store, hook.ID)[0]
+	assert.Equal(t, cfg.MaxAttempts, dead.Attempts)
+	assert.Equal(t, cfg.MaxAttempts, rec.count())
+	assert.Equal(t, http.StatusInternalServerError, dead.LastStatusCode)
+	assert.Equal(t, "unexpected status 500", dead.LastError)
+
+	// Dead letter не отправляется, пока её не повторят вручную
+	time.Sleep(5 * time.Millisecond)
+	n, err := dispatcher.Deliver(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	_, err = store.ReplayDelivery(ctx, hook.ID+1, dead.ID)
+	assert.ErrorIs(t, err, service.ErrDeliveryNotFound, "доставка чужой подписки")
+
+	rec.status.Store(http.StatusNoContent)
+	replayed, err := store.ReplayDelivery(ctx, hook.ID, dead.ID)
+	require.NoError(t, err)
+	assert.Equal(t, service.DeliveryPending, replayed.Status)
+	assert.Zero(t, replayed.Attempts)
+
+	n, err = dispatcher.Deliver(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	delivered := deliveries(t, store, hook.ID)[0]
+	assert.Equal(t, service.DeliveryDelivered, delivered.Status)
+	assert.Equal(t, 1, delivered.Attempts)
+	assert.Equal(t, http.StatusNoContent, delivered.LastStatusCode)
+	assert.Empty(t, delivered.LastError)
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	assert.Equal(t, rec.requests[0].header.Get(HeaderDelivery), rec.requests[len(rec.requests)-1].header.Get(HeaderDelivery),
+		"повтор отправляется с тем же ID доставки")
+}
+
+func TestRun(t *testing.T) {
+	store := memory.NewRepository()
+	rec, server := newReceiver(t)
+	hook := createWebhook(t, store, server.URL)
+	for id := range int64(5) {
+		publish(t, store, id+1, service.EventTaskCreated)
+	}
+
+	cfg := testConfig()
+	cfg.BatchSize = 2
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		NewDispatcher(store, cfg, zap.NewNop().Sugar()).Run(ctx)
+	}()
+
+	require.Eventually(t, func() bool { return rec.count() == 5 }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+
+	for _, d := range deliveries(t, store, hook.ID) {
+		assert.Equal(t, service.DeliveryDelivered, d.Status)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"id":1}`)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	signature := Sign(testSecret, now.Unix(), body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		signature string
+		body      []byte
+		now       time.Time
+		expected  error
+	}{
+		{name: "Верная подпись", secret: testSecret, timestamp: ts, signature: signature, body: body, now: now},
+		{name: "Допустимое расхождение часов", secret: testSecret, timestamp: ts, signature: signature, body: body, now: now.Add(-time.Minute)},
+		{name: "Другой секрет", secret: "fedcba9876543210", timestamp: ts, signature: signature, body: body, now: now, expected: ErrInvalidSignature},
+		{name: "Изменённое тело", secret: testSecret, timestamp: ts, signature: signature, body: []byte(`{"id":2}`), now: now, expected: ErrInvalidSignature},
+		{name: "Подменённое время", secret: testSecret, timestamp: strconv.FormatInt(now.Unix()+1, 10), signature: signature, body: body, now: now, expected: ErrInvalidSignature},
+		{name: "Время не числом", secret: testSecret, timestamp: "now", signature: signature, body: body, now: now, expected: ErrInvalidSignature},
+		{name: "Подпись без префикса", secret: testSecret, timestamp: ts, signature: signature[len("sha256="):], body: body, now: now, expected: ErrInvalidSignature},
+		{name: "Устаревший запрос", secret: testSecret, timestamp: ts, signature: signature, body: body, now: now.Add(6 * time.Minute), expected: ErrStaleTimestamp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.timestamp, tt.signature, tt.body, 5*time.Minute, tt.now)
+			if tt.expected == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.expected)
+		})
+	}
+}
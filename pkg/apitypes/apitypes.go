@@ -0,0 +1,230 @@
+// Package apitypes - тела запросов и ответов REST API Simple Service. Пакет не зависит от HTTP
+// фреймворка сервера: его используют и обработчики (через internal/dto), и клиент pkg/client
+package apitypes
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Swagger DTO structures
+
+// TaskRequest represents the request body for creating a task
+// @Description Task creation request
+type TaskRequest struct {
+	Title       string `json:"title" validate:"required,min=1,max=255" example:"Implement new feature"`
+	Description string `json:"description" validate:"max=1000" example:"Develop a new API endpoint for user management"`
+} // @name TaskRequest
+
+// TaskResponse represents a task in responses
+// @Description Task information
+type TaskResponse struct {
+	ID          int       `json:"id" example:"1"`
+	Title       string    `json:"title" example:"Implement new feature"`
+	Description string    `json:"description" example:"Develop a new API endpoint for user management"`
+	Status      string    `json:"status" example:"new"`
+	CreatedAt   time.Time `json:"created_at" example:"2024-01-15T10:30:00Z"`
+	UpdatedAt   time.Time `json:"updated_at" example:"2024-01-15T10:30:00Z"`
+} // @name TaskResponse
+
+// UpdateTaskRequest represents the request body for a partial task update
+// @Description Partial task update, omitted fields are left unchanged
+type UpdateTaskRequest struct {
+	Title       *string `json:"title,omitempty" validate:"omitempty,min=1,max=255" example:"Implement new feature"`
+	Description *string `json:"description,omitempty" validate:"omitempty,max=1000" example:"Develop a new API endpoint for user management"`
+	Status      *string `json:"status,omitempty" validate:"omitempty,oneof=new in_progress done" enums:"new,in_progress,done" example:"in_progress"`
+} // @name UpdateTaskRequest
+
+// TaskListResponse represents a page of tasks
+// @Description Page of tasks ordered by ID
+type TaskListResponse struct {
+	Tasks  []TaskResponse `json:"tasks"`
+	Limit  int            `json:"limit" example:"50"`
+	Offset int            `json:"offset" example:"0"`
+} // @name TaskListResponse
+
+// ReadinessResponse represents the readiness probe result
+// @Description Storage readiness and database circuit breaker state
+type ReadinessResponse struct {
+	Storage string `json:"storage" enums:"ok,unavailable" example:"ok"`
+	Breaker string `json:"breaker,omitempty" enums:"closed,half_open,open" example:"closed"`
+} // @name ReadinessResponse
+
+// CreateTaskResponse represents the response after creating a task
+// @Description Response after task creation
+type CreateTaskResponse struct {
+	TaskID int `json:"task_id" example:"1"`
+} // @name CreateTaskResponse
+
+// BatchCreateRequest represents the request body for creating several tasks
+// @Description Tasks to create. In all_or_nothing mode (default) an invalid task aborts the whole batch,
+// @Description in best_effort mode valid tasks are created and invalid ones are reported
+type BatchCreateRequest struct {
+	Mode  string        `json:"mode,omitempty" validate:"omitempty,oneof=all_or_nothing best_effort" enums:"all_or_nothing,best_effort" example:"best_effort"`
+	Tasks []TaskRequest `json:"tasks"`
+} // @name BatchCreateRequest
+
+// BatchItemResult represents the outcome for one task of a batch
+// @Description Created task ID or the reason the task was not created
+type BatchItemResult struct {
+	Index  int    `json:"index" example:"0"`
+	TaskID int    `json:"task_id,omitempty" example:"1"`
+	Error  *Error `json:"error,omitempty"`
+} // @name BatchItemResult
+
+// BatchCreateResponse represents the per-task results in request order
+// @Description Results of batch creation in request order
+type BatchCreateResponse struct {
+	Created int               `json:"created" example:"2"`
+	Failed  int               `json:"failed" example:"1"`
+	Results []BatchItemResult `json:"results"`
+} // @name BatchCreateResponse
+
+// TaskFilter represents conditions for selecting tasks, omitted fields are not checked
+// @Description Task selection conditions, at least one is required. created_after is inclusive, created_before is exclusive
+type TaskFilter struct {
+	Status        string     `json:"status,omitempty" validate:"omitempty,oneof=new in_progress done" enums:"new,in_progress,done" example:"in_progress"`
+	CreatedAfter  *time.Time `json:"created_after,omitempty" example:"2024-01-01T00:00:00Z"`
+	CreatedBefore *time.Time `json:"created_before,omitempty" example:"2024-01-15T00:00:00Z"`
+} // @name TaskFilter
+
+// BulkUpdateRequest represents the request body for updating tasks selected by IDs or filter
+// @Description Exactly one of ids and filter is required. dry_run only reports the tasks that would be updated
+type BulkUpdateRequest struct {
+	IDs           []int             `json:"ids,omitempty" example:"1,2,3"`
+	Filter        *TaskFilter       `json:"filter,omitempty"`
+	Patch         UpdateTaskRequest `json:"patch"`
+	DryRun        bool              `json:"dry_run" example:"false"`
+	OverrideLimit bool              `json:"override_limit" example:"false"`
+} // @name BulkUpdateRequest
+
+// BulkDeleteRequest represents the request body for deleting tasks selected by IDs or filter
+// @Description Exactly one of ids and filter is required. dry_run only reports the tasks that would be deleted
+type BulkDeleteRequest struct {
+	IDs           []int       `json:"ids,omitempty" example:"1,2,3"`
+	Filter        *TaskFilter `json:"filter,omitempty"`
+	DryRun        bool        `json:"dry_run" example:"false"`
+	OverrideLimit bool        `json:"override_limit" example:"false"`
+} // @name BulkDeleteRequest
+
+// BulkResponse represents the tasks affected by a bulk operation
+// @Description Affected tasks. For dry_run - tasks that would be affected and whether the limit would be exceeded
+type BulkResponse struct {
+	Affected      int   `json:"affected" example:"3"`
+	IDs           []int `json:"ids" example:"1,2,3"`
+	DryRun        bool  `json:"dry_run" example:"false"`
+	LimitExceeded bool  `json:"limit_exceeded" example:"false"`
+} // @name BulkResponse
+
+// ImportRowError represents a row of the import file that was not imported
+// @Description Row that was not imported, line is the 1-based line number in the file
+type ImportRowError struct {
+	Line  int   `json:"line" example:"3"`
+	Error Error `json:"error"`
+} // @name ImportRowError
+
+// ImportResponse represents the import result with a row-level error report
+// @Description Number of imported tasks and the rows that were not imported, in file order.
+// @Description When the import is rejected as a whole, imported is 0
+type ImportResponse struct {
+	Imported int              `json:"imported" example:"2"`
+	Failed   int              `json:"failed" example:"1"`
+	Errors   []ImportRowError `json:"errors"`
+} // @name ImportResponse
+
+// WebhookRequest represents the request body for creating a webhook subscription
+// @Description Webhook subscription. Empty event_types subscribes to all task events.
+// @Description Without secret the service generates one; the secret is returned only in the creation response
+type WebhookRequest struct {
+	URL        string   `json:"url" validate:"required,max=2048" example:"https://example.com/hooks/tasks"`
+	EventTypes []string `json:"event_types" enums:"task.created,task.updated,task.status_changed,task.deleted" example:"task.created,task.deleted"`
+	Secret     string   `json:"secret,omitempty" validate:"omitempty,min=16,max=256" example:"8f2c1e0b7d4a49e6b3c5"`
+	Active     *bool    `json:"active,omitempty" example:"true"`
+} // @name WebhookRequest
+
+// UpdateWebhookRequest represents the request body for a partial webhook update
+// @Description Partial webhook update, omitted fields are left unchanged. Empty event_types subscribes to all task events
+type UpdateWebhookRequest struct {
+	URL        *string   `json:"url,omitempty" example:"https://example.com/hooks/tasks"`
+	EventTypes *[]string `json:"event_types,omitempty" enums:"task.created,task.updated,task.status_changed,task.deleted" example:"task.status_changed"`
+	Secret     *string   `json:"secret,omitempty" example:"8f2c1e0b7d4a49e6b3c5"`
+	Active     *bool     `json:"active,omitempty" example:"false"`
+} // @name UpdateWebhookRequest
+
+// WebhookResponse represents a webhook subscription without its secret
+// @Description Webhook subscription
+type WebhookResponse struct {
+	ID         int       `json:"id" example:"1"`
+	URL        string    `json:"url" example:"https://example.com/hooks/tasks"`
+	EventTypes []string  `json:"event_types" example:"task.created,task.deleted"`
+	Active     bool      `json:"active" example:"true"`
+	CreatedAt  time.Time `json:"created_at" example:"2024-01-15T10:30:00Z"`
+	UpdatedAt  time.Time `json:"updated_at" example:"2024-01-15T10:30:00Z"`
+} // @name WebhookResponse
+
+// CreateWebhookResponse represents the created subscription with its signing secret
+// @Description Created webhook subscription. The secret is shown only here
+type CreateWebhookResponse struct {
+	WebhookResponse
+	Secret string `json:"secret" example:"8f2c1e0b7d4a49e6b3c5"`
+} // @name CreateWebhookResponse
+
+// WebhookListResponse represents all webhook subscriptions
+// @Description Webhook subscriptions ordered by ID
+type WebhookListResponse struct {
+	Webhooks []WebhookResponse `json:"webhooks"`
+} // @name WebhookListResponse
+
+// DeliveryResponse represents one delivery of an event to a subscriber
+// @Description Delivery log entry. dead deliveries exhausted their attempts and can be replayed
+type DeliveryResponse struct {
+	ID             int64           `json:"id" example:"10"`
+	WebhookID      int             `json:"webhook_id" example:"1"`
+	EventID        int64           `json:"event_id" example:"42"`
+	EventType      string          `json:"event_type" example:"task.created"`
+	Payload        json.RawMessage `json:"payload" swaggertype:"object"`
+	Status         string          `json:"status" enums:"pending,delivered,dead" example:"delivered"`
+	Attempts       int             `json:"attempts" example:"1"`
+	LastStatusCode int             `json:"last_status_code,omitempty" example:"200"`
+	LastError      string          `json:"last_error,omitempty" example:"unexpected status 500"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at" example:"2024-01-15T10:30:00Z"`
+	CreatedAt      time.Time       `json:"created_at" example:"2024-01-15T10:30:00Z"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty" example:"2024-01-15T10:30:01Z"`
+} // @name DeliveryResponse
+
+// DeliveryListResponse represents a page of the delivery log
+// @Description Page of deliveries, newest first
+type DeliveryListResponse struct {
+	Deliveries []DeliveryResponse `json:"deliveries"`
+	Limit      int                `json:"limit" example:"50"`
+	Offset     int                `json:"offset" example:"0"`
+} // @name DeliveryListResponse
+
+// Response represents the standard API response
+// @Description Standard API response
+type Response struct {
+	Status string `json:"status" example:"success"`
+	Error  *Error `json:"error,omitempty"`
+	Data   any    `json:"data,omitempty"`
+} // @name Response
+
+// SuccessResponse represents a successful API response
+// @Description Successful API response
+type SuccessResponse struct {
+	Status string `json:"status" example:"success"`
+	Data   any    `json:"data"`
+} // @name SuccessResponse
+
+// ErrorResponse represents an error API response
+// @Description Error API response
+type ErrorResponse struct {
+	Status string `json:"status" example:"error"`
+	Error  *Error `json:"error"`
+} // @name ErrorResponse
+
+// Error represents error details
+// @Description Error details
+type Error struct {
+	Code string `json:"code" example:"FIELD_INCORRECT"`
+	Desc string `json:"desc" example:"Invalid request body"`
+} // @name Error
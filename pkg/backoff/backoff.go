@@ -0,0 +1,18 @@
+// Package backoff - паузы между повторами с экспоненциальным ростом и случайным разбросом.
+package backoff
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Exponential - пауза перед повтором attempt (начиная с 1): base, удвоенная attempt-1 раз и ограниченная
+// limit, со случайным разбросом от -50% до 0, то есть в [d/2, d]. Разброс не даёт клиентам, упавшим
+// одновременно, повторять запросы тоже одновременно
+func Exponential(base, limit time.Duration, attempt int) time.Duration {
+	d := limit
+	if shift := max(attempt-1, 0); shift < 63 && base > 0 && base <= limit>>shift {
+		d = base << shift
+	}
+	return d/2 + rand.N(d/2+1)
+}
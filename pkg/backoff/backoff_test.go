@@ -0,0 +1,36 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExponential(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    time.Duration
+		limit   time.Duration
+		attempt int
+		max     time.Duration
+	}{
+		{name: "Первая попытка", base: time.Second, limit: time.Minute, attempt: 1, max: time.Second},
+		{name: "Удвоение", base: time.Second, limit: time.Minute, attempt: 3, max: 4 * time.Second},
+		{name: "Ограничение сверху", base: time.Second, limit: time.Minute, attempt: 10, max: time.Minute},
+		{name: "Переполнение длительности", base: time.Second, limit: time.Minute, attempt: 40, max: time.Minute},
+		{name: "Переполнение сдвига", base: time.Second, limit: time.Minute, attempt: 100, max: time.Minute},
+		{name: "Нулевая попытка как первая", base: time.Second, limit: time.Minute, attempt: 0, max: time.Second},
+		{name: "Без паузы", attempt: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 100 {
+				d := Exponential(tt.base, tt.limit, tt.attempt)
+				assert.GreaterOrEqual(t, d, tt.max/2)
+				assert.LessOrEqual(t, d, tt.max)
+			}
+		})
+	}
+}
@@ -0,0 +1,422 @@
+// Package client - типизированный клиент REST API Simple Service.
+//
+// Клиент подставляет JWT токен, повторяет запросы при 5xx и 429 с учётом Retry-After
+// и возвращает ошибки API как *APIError, которые можно проверять через errors.Is:
+//
+//	c, err := client.New("http://localhost:8080", client.WithToken(token))
+//	task, err := c.GetTask(ctx, 1)
+//	if errors.Is(err, client.ErrNotFound) { ... }
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"simple-service/pkg/apitypes"
+	"simple-service/pkg/backoff"
+)
+
+// Типы запросов и ответов API, общие с сервером
+type (
+	TaskRequest       = apitypes.TaskRequest
+	BatchRequest      = apitypes.BatchCreateRequest
+	BatchResult       = apitypes.BatchCreateResponse
+	BatchItem         = apitypes.BatchItemResult
+	TaskFilter        = apitypes.TaskFilter
+	BulkUpdateRequest = apitypes.BulkUpdateRequest
+	BulkDeleteRequest = apitypes.BulkDeleteRequest
+	BulkResult        = apitypes.BulkResponse
+	UpdateTaskRequest = apitypes.UpdateTaskRequest
+	Task              = apitypes.TaskResponse
+	TaskList          = apitypes.TaskListResponse
+	ErrorDetails      = apitypes.Error
+)
+
+// Режимы пакетного создания задач
+const (
+	BatchAllOrNothing = "all_or_nothing"
+	BatchBestEffort   = "best_effort"
+)
+
+// Статусы задачи
+const (
+	StatusNew        = "new"
+	StatusInProgress = "in_progress"
+	StatusDone       = "done"
+)
+
+// ListOptions - фильтр и страница списка задач, нулевые поля не передаются
+type ListOptions struct {
+	Status string
+	Limit  int
+	Offset int
+}
+
+// Doer - HTTP транспорт, *http.Client или адаптер для тестов
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// TokenFunc - получение токена. Вызывается при первом запросе и повторно после 401,
+// что позволяет обновлять короткоживущие токены
+type TokenFunc func(ctx context.Context) (string, error)
+
+// Client - клиент API, безопасен для использования из нескольких горутин
+type Client struct {
+	baseURL   *url.URL
+	http      Doer
+	retry     RetryPolicy
+	userAgent string
+	tokenFunc TokenFunc
+	// idempotencyKeys - создание задач с заголовком Idempotency-Key
+	idempotencyKeys bool
+
+	mu    sync.Mutex
+	token string
+
+	// sleep подменяется в тестах
+	sleep func(ctx context.Context, d time.Duration) error
+	now   func() time.Time
+}
+
+// Option - настройка клиента
+type Option func(*Client)
+
+// WithHTTPClient - свой транспорт, по умолчанию http.Client с таймаутом 30 секунд
+func WithHTTPClient(doer Doer) Option {
+	return func(c *Client) { c.http = doer }
+}
+
+// WithToken - постоянный токен
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.tokenFunc = func(context.Context) (string, error) { return token, nil }
+	}
+}
+
+// WithTokenFunc - токен из callback, он же вызывается для обновления после 401
+func WithTokenFunc(fn TokenFunc) Option {
+	return func(c *Client) { c.tokenFunc = fn }
+}
+
+// WithRetryPolicy - политика повторов, по умолчанию DefaultRetryPolicy
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) { c.retry = policy }
+}
+
+// WithUserAgent - значение заголовка User-Agent
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) { c.userAgent = userAgent }
+}
+
+// WithIdempotencyKeys - создание задач с заголовком Idempotency-Key, один ключ на все попытки вызова.
+// Сервер выполняет такой запрос один раз, поэтому создание повторяется и после 5xx, сетевых ошибок
+// и 409, пока предыдущая попытка ещё выполняется. Исключение - ErrOutcomeUnknown, он не повторяется.
+// Токен должен содержать claim sub
+func WithIdempotencyKeys() Option {
+	return func(c *Client) { c.idempotencyKeys = true }
+}
+
+// New - клиент для сервиса по адресу baseURL, например http://localhost:8080
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base URL %q: scheme must be http or https", baseURL)
+	}
+
+	c := &Client{
+		baseURL:   u,
+		http:      &http.Client{Timeout: 30 * time.Second},
+		retry:     DefaultRetryPolicy,
+		userAgent: "simple-service-client",
+		sleep:     sleep,
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.retry.MaxAttempts < 1 {
+		c.retry.MaxAttempts = 1
+	}
+
+	return c, nil
+}
+
+// CreateTask - создание задачи, возвращает ID
+func (c *Client) CreateTask(ctx context.Context, req TaskRequest) (int, error) {
+	var resp apitypes.CreateTaskResponse
+	if err := c.create(ctx, "/v1/create_task", req, &resp); err != nil {
+		return 0, err
+	}
+	return resp.TaskID, nil
+}
+
+// CreateTasks - создание нескольких задач, результаты в порядке req.Tasks.
+// В режиме all_or_nothing пакет с некорректной задачей отклоняется с ErrBadRequest
+func (c *Client) CreateTasks(ctx context.Context, req BatchRequest) (*BatchResult, error) {
+	var result BatchResult
+	if err := c.create(ctx, "/v1/tasks:batchCreate", req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetTask - задача по ID, ErrNotFound если её нет
+func (c *Client) GetTask(ctx context.Context, id int) (*Task, error) {
+	var task Task
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/v1/tasks/%d", id), nil, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// ListTasks - страница задач, отсортированных по ID
+func (c *Client) ListTasks(ctx context.Context, opts ListOptions) (*TaskList, error) {
+	query := url.Values{}
+	if opts.Status != "" {
+		query.Set("status", opts.Status)
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Offset > 0 {
+		query.Set("offset", strconv.Itoa(opts.Offset))
+	}
+
+	path := "/v1/tasks"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var list TaskList
+	if err := c.do(ctx, http.MethodGet, path, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// UpdateTask - изменение полей задачи, не nil поля req, возвращает задачу после обновления
+func (c *Client) UpdateTask(ctx context.Context, id int, req UpdateTaskRequest) (*Task, error) {
+	var task Task
+	if err := c.do(ctx, http.MethodPatch, fmt.Sprintf("/v1/tasks/%d", id), req, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// DeleteTask - удаление задачи, ErrNotFound если её нет
+func (c *Client) DeleteTask(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/v1/tasks/%d", id), nil, nil)
+}
+
+// UpdateTasks - изменение задач по списку ID или фильтру одной транзакцией.
+// Превышение ограничения сервера на число задач возвращается как ErrUnprocessable
+func (c *Client) UpdateTasks(ctx context.Context, req BulkUpdateRequest) (*BulkResult, error) {
+	var result BulkResult
+	if err := c.do(ctx, http.MethodPost, "/v1/tasks:batchUpdate", req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// DeleteTasks - удаление задач по списку ID или фильтру одной транзакцией
+func (c *Client) DeleteTasks(ctx context.Context, req BulkDeleteRequest) (*BulkResult, error) {
+	var result BulkResult
+	if err := c.do(ctx, http.MethodPost, "/v1/tasks:batchDelete", req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// create - POST создания задач, с ключом идемпотентности если он включён
+func (c *Client) create(ctx context.Context, path string, in, out any) error {
+	if !c.idempotencyKeys {
+		return c.send(ctx, http.MethodPost, path, "", in, out)
+	}
+	key, err := newIdempotencyKey()
+	if err != nil {
+		return err
+	}
+	return c.send(ctx, http.MethodPost, path, key, in, out)
+}
+
+// newIdempotencyKey - случайный ключ из 128 бит
+func newIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate idempotency key: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// do - запрос с повторами. Тело ответа apitypes.SuccessResponse разбирается в out, если он не nil
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	return c.send(ctx, method, path, "", in, out)
+}
+
+// send - запрос с повторами и заголовком Idempotency-Key, если key не пуст
+func (c *Client) send(ctx context.Context, method, path, key string, in, out any) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	refreshed := false
+	for attempt := 1; ; attempt++ {
+		status, retryAfter, err := c.attempt(ctx, method, path, key, body, out)
+		if err == nil {
+			return nil
+		}
+
+		// Токен мог истечь: один раз запрашиваем новый и повторяем без учёта попыток
+		if status == http.StatusUnauthorized && !refreshed && c.tokenFunc != nil {
+			refreshed = true
+			c.setToken("")
+			attempt--
+			continue
+		}
+
+		if attempt >= c.retry.MaxAttempts || !c.shouldRetry(ctx, method, key != "", status, err) {
+			return err
+		}
+
+		delay := backoff.Exponential(c.retry.BaseDelay, c.retry.MaxDelay, attempt)
+		if retryAfter > 0 {
+			delay = retryAfter
+		}
+
+		// Не ждём, если ответ всё равно не успеет прийти до дедлайна
+		if deadline, ok := ctx.Deadline(); ok && c.now().Add(delay).After(deadline) {
+			return err
+		}
+		if sleepErr := c.sleep(ctx, delay); sleepErr != nil {
+			return err
+		}
+	}
+}
+
+// shouldRetry - повтор при 429/5xx или сетевой ошибке безопасного или идемпотентного запроса.
+// OUTCOME_UNKNOWN не повторяется и с ключом идемпотентности: сервер не знает, выполнен ли запрос
+func (c *Client) shouldRetry(ctx context.Context, method string, idempotent bool, status int, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, ErrOutcomeUnknown) {
+		return false
+	}
+	if status != 0 {
+		return retryable(method, idempotent, status)
+	}
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		return false
+	}
+	return idempotent || method == http.MethodGet || method == http.MethodHead
+}
+
+// permanentError - ошибка, которую повтор запроса не исправит: нет токена, ответ не разбирается
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// attempt - одна попытка. Возвращает HTTP статус ошибочного ответа (0 при сетевой ошибке)
+// и Retry-After
+func (c *Client) attempt(ctx context.Context, method, path, key string, body []byte, out any) (int, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL.String()+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+
+	token, err := c.currentToken(ctx)
+	if err != nil {
+		return 0, 0, &permanentError{err: fmt.Errorf("failed to get token: %w", err)}
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s %s: failed to read response: %w", method, path, err)
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if out == nil || len(data) == 0 {
+			return 0, 0, nil
+		}
+		envelope := apitypes.SuccessResponse{Data: out}
+		if err := json.Unmarshal(data, &envelope); err != nil {
+			return 0, 0, &permanentError{err: fmt.Errorf("%s %s: failed to decode response: %w", method, path, err)}
+		}
+		return 0, 0, nil
+	}
+
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), c.now()),
+	}
+	var envelope apitypes.ErrorResponse
+	if json.Unmarshal(data, &envelope) == nil && envelope.Error != nil {
+		apiErr.Code = envelope.Error.Code
+		apiErr.Desc = envelope.Error.Desc
+	}
+
+	return resp.StatusCode, apiErr.RetryAfter, apiErr
+}
+
+func (c *Client) currentToken(ctx context.Context) (string, error) {
+	if c.tokenFunc == nil {
+		return "", nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token == "" {
+		token, err := c.tokenFunc(ctx)
+		if err != nil {
+			return "", err
+		}
+		c.token = token
+	}
+
+	return c.token, nil
+}
+
+func (c *Client) setToken(token string) {
+	c.mu.Lock()
+	c.token = token
+	c.mu.Unlock()
+}
@@ -0,0 +1,601 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"simple-service/internal/api"
+	"simple-service/internal/config"
+	"simple-service/internal/repo/memory"
+	"simple-service/internal/repo/mocks"
+	"simple-service/internal/service"
+)
+
+const testSecret = "test-secret"
+
+// appDoer - транспорт, отправляющий запросы в fiber.App без сети.
+// fail позволяет подменить ответ, не доходя до приложения
+type appDoer struct {
+	app      *fiber.App
+	requests int
+	fail     func(n int) *http.Response
+}
+
+func (d *appDoer) Do(req *http.Request) (*http.Response, error) {
+	d.requests++
+	if d.fail != nil {
+		if resp := d.fail(d.requests); resp != nil {
+			return resp, nil
+		}
+	}
+	return d.app.Test(req, -1)
+}
+
+func newTestApp(t *testing.T, repository *mocks.Repository, rateLimit int) *fiber.App {
+	t.Helper()
+
+	logger := zap.NewNop().Sugar()
+	cfg := config.Rest{
+		ServerName:             "test",
+		Token:                  testSecret,
+		CORSAllowOrigins:       []string{"*"},
+		RateLimit:              rateLimit,
+		RateLimitWindow:        time.Minute,
+		RequestTimeout:         time.Minute,
+		RouteTimeouts:          []string{"GET /v1/tasks=2m"},
+		BatchMaxSize:           3,
+		BulkMaxAffected:        3,
+		IdempotencyTTL:         time.Hour,
+		IdempotencyLockTimeout: time.Minute,
+	}
+
+	return api.NewRouters(
+		&api.Routers{Service: service.NewService(repository, logger), Idempotency: memory.NewRepository(), Logger: logger},
+		cfg,
+		api.NewSettings(api.NewRuntimeConfig(cfg)),
+	)
+}
+
+func signToken(t *testing.T, secret string) string {
+	t.Helper()
+	return signScopedToken(t, secret, "")
+}
+
+// signScopedToken - токен с claim scope, пустой scope не добавляется
+func signScopedToken(t *testing.T, secret, scope string) string {
+	t.Helper()
+	claims := jwt.MapClaims{
+		"sub": "test",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	if scope != "" {
+		claims["scope"] = scope
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	require.NoError(t, err)
+	return token
+}
+
+// newTestClient - клиент без реальных задержек, задержки записываются в sleeps
+func newTestClient(t *testing.T, doer Doer, sleeps *[]time.Duration, opts ...Option) *Client {
+	t.Helper()
+
+	c, err := New("http://simple-service.test", append([]Option{WithHTTPClient(doer)}, opts...)...)
+	require.NoError(t, err)
+
+	c.sleep = func(_ context.Context, d time.Duration) error {
+		*sleeps = append(*sleeps, d)
+		return nil
+	}
+
+	return c
+}
+
+func response(status int, header http.Header, body string) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{StatusCode: status, Header: header, Body: io.NopCloser(strings.NewReader(body))}
+}
+
+func TestClientTasks(t *testing.T) {
+	createdAt := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+
+	repository := mocks.NewRepository(t)
+	repository.On("CreateTask", mock.Anything, service.Task{Title: "Write SDK", Description: "typed client"}).Return(42, nil).Once()
+	repository.On("GetTask", mock.Anything, 42).Return(&service.TaskResponse{
+		ID: 42, Title: "Write SDK", Description: "typed client", Status: "new", CreatedAt: createdAt, UpdatedAt: createdAt,
+	}, nil).Once()
+	repository.On("GetTask", mock.Anything, 7).Return(nil, service.ErrTaskNotFound).Once()
+
+	var sleeps []time.Duration
+	c := newTestClient(t, &appDoer{app: newTestApp(t, repository, 0)}, &sleeps, WithToken(signToken(t, testSecret)))
+	ctx := context.Background()
+
+	id, err := c.CreateTask(ctx, TaskRequest{Title: "Write SDK", Description: "typed client"})
+	require.NoError(t, err)
+	assert.Equal(t, 42, id)
+
+	task, err := c.GetTask(ctx, 42)
+	require.NoError(t, err)
+	assert.Equal(t, &Task{ID: 42, Title: "Write SDK", Description: "typed client", Status: "new", CreatedAt: createdAt, UpdatedAt: createdAt}, task)
+
+	_, err = c.GetTask(ctx, 7)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "NOT_FOUND", apiErr.Code)
+
+	_, err = c.CreateTask(ctx, TaskRequest{Title: ""})
+	assert.True(t, errors.Is(err, ErrBadRequest))
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "FIELD_INCORRECT", apiErr.Code)
+
+	assert.Empty(t, sleeps)
+}
+
+func TestClientListUpdateDelete(t *testing.T) {
+	createdAt := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	task := service.TaskResponse{ID: 3, Title: "Ship CLI", Status: "in_progress", CreatedAt: createdAt, UpdatedAt: createdAt}
+	status := StatusInProgress
+
+	repository := mocks.NewRepository(t)
+	repository.On("ListTasks", mock.Anything, service.ListFilter{Status: "in_progress", Limit: 10, Offset: 20}).
+		Return([]service.TaskResponse{task}, nil).Once()
+	repository.On("ListTasks", mock.Anything, service.ListFilter{Limit: 50}).Return([]service.TaskResponse{}, nil).Once()
+	repository.On("UpdateTask", mock.Anything, 3, service.TaskUpdate{Status: &status}).Return(&task, nil).Once()
+	repository.On("DeleteTask", mock.Anything, 3).Return(nil).Once()
+	repository.On("DeleteTask", mock.Anything, 4).Return(service.ErrTaskNotFound).Once()
+
+	var sleeps []time.Duration
+	c := newTestClient(t, &appDoer{app: newTestApp(t, repository, 0)}, &sleeps, WithToken(signToken(t, testSecret)))
+	ctx := context.Background()
+
+	list, err := c.ListTasks(ctx, ListOptions{Status: StatusInProgress, Limit: 10, Offset: 20})
+	require.NoError(t, err)
+	assert.Equal(t, &TaskList{Tasks: []Task{Task(task)}, Limit: 10, Offset: 20}, list)
+
+	list, err = c.ListTasks(ctx, ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, list.Tasks)
+	assert.Equal(t, 50, list.Limit)
+
+	_, err = c.ListTasks(ctx, ListOptions{Status: "closed"})
+	assert.True(t, errors.Is(err, ErrBadRequest))
+
+	updated, err := c.UpdateTask(ctx, 3, UpdateTaskRequest{Status: &status})
+	require.NoError(t, err)
+	assert.Equal(t, "in_progress", updated.Status)
+
+	_, err = c.UpdateTask(ctx, 3, UpdateTaskRequest{})
+	assert.True(t, errors.Is(err, ErrBadRequest))
+
+	require.NoError(t, c.DeleteTask(ctx, 3))
+	assert.True(t, errors.Is(c.DeleteTask(ctx, 4), ErrNotFound))
+}
+
+func TestClientCreateTasks(t *testing.T) {
+	repository := mocks.NewRepository(t)
+	repository.On("CreateTasks", mock.Anything, []service.Task{{Title: "first"}, {Title: "third", Description: "c"}}).
+		Return([]int{10, 11}, nil).Once()
+
+	var sleeps []time.Duration
+	c := newTestClient(t, &appDoer{app: newTestApp(t, repository, 0)}, &sleeps, WithToken(signToken(t, testSecret)))
+	ctx := context.Background()
+	tasks := []TaskRequest{{Title: "first"}, {Title: ""}, {Title: "third", Description: "c"}}
+
+	// Некорректная задача пропускается, остальные создаются
+	result, err := c.CreateTasks(ctx, BatchRequest{Mode: BatchBestEffort, Tasks: tasks})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Created)
+	assert.Equal(t, 1, result.Failed)
+	require.Len(t, result.Results, 3)
+	assert.Equal(t, BatchItem{Index: 0, TaskID: 10}, result.Results[0])
+	assert.Equal(t, 1, result.Results[1].Index)
+	require.NotNil(t, result.Results[1].Error)
+	assert.Equal(t, "FIELD_INCORRECT", result.Results[1].Error.Code)
+	assert.Equal(t, BatchItem{Index: 2, TaskID: 11}, result.Results[2])
+
+	// По умолчанию пакет с некорректной задачей отклоняется целиком, хранилище не вызывается
+	_, err = c.CreateTasks(ctx, BatchRequest{Tasks: tasks})
+	assert.True(t, errors.Is(err, ErrBadRequest))
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "1 of 3 tasks are invalid", apiErr.Desc)
+
+	_, err = c.CreateTasks(ctx, BatchRequest{Tasks: append(tasks, TaskRequest{Title: "fourth"})})
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "Batch has 4 tasks, at most 3 allowed", apiErr.Desc)
+
+	_, err = c.CreateTasks(ctx, BatchRequest{Mode: "partial", Tasks: tasks})
+	assert.True(t, errors.Is(err, ErrBadRequest))
+
+	_, err = c.CreateTasks(ctx, BatchRequest{})
+	assert.True(t, errors.Is(err, ErrBadRequest))
+}
+
+func TestClientBulk(t *testing.T) {
+	done := StatusDone
+	repository := mocks.NewRepository(t)
+	repository.On("WithinTx", mock.Anything, service.TxOptions{}, mock.Anything).
+		Return(func(ctx context.Context, _ service.TxOptions, fn func(context.Context) error) error { return fn(ctx) })
+	repository.On("FindTaskIDs", mock.Anything, service.TaskSelector{Filter: service.TaskFilter{Status: "in_progress"}}).
+		Return([]int{1, 2, 3, 4}, nil)
+	repository.On("FindTaskIDs", mock.Anything, service.TaskSelector{IDs: []int{5, 6}}).Return([]int{5}, nil)
+	repository.On("UpdateTasks", mock.Anything, []int{1, 2, 3, 4}, service.TaskUpdate{Status: &done}).Return(4, nil).Once()
+	repository.On("DeleteTasks", mock.Anything, []int{5}).Return(1, nil).Once()
+
+	var sleeps []time.Duration
+	app := newTestApp(t, repository, 0)
+	user := newTestClient(t, &appDoer{app: app}, &sleeps, WithToken(signToken(t, testSecret)))
+	admin := newTestClient(t, &appDoer{app: app}, &sleeps, WithToken(signScopedToken(t, testSecret, "tasks:read tasks:admin")))
+	ctx := context.Background()
+	update := BulkUpdateRequest{Filter: &TaskFilter{Status: StatusInProgress}, Patch: UpdateTaskRequest{Status: &done}}
+
+	// Пробный запуск ничего не меняет и сообщает о превышении ограничения
+	dryRun := update
+	dryRun.DryRun = true
+	result, err := user.UpdateTasks(ctx, dryRun)
+	require.NoError(t, err)
+	assert.Equal(t, &BulkResult{Affected: 4, IDs: []int{1, 2, 3, 4}, DryRun: true, LimitExceeded: true}, result)
+
+	_, err = user.UpdateTasks(ctx, update)
+	assert.True(t, errors.Is(err, ErrUnprocessable))
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "LIMIT_EXCEEDED", apiErr.Code)
+
+	// Снять ограничение может только tasks:admin
+	update.OverrideLimit = true
+	_, err = user.UpdateTasks(ctx, update)
+	assert.True(t, errors.Is(err, ErrForbidden))
+
+	result, err = admin.UpdateTasks(ctx, update)
+	require.NoError(t, err)
+	assert.Equal(t, &BulkResult{Affected: 4, IDs: []int{1, 2, 3, 4}}, result)
+
+	// Отсутствующие ID пропускаются
+	result, err = user.DeleteTasks(ctx, BulkDeleteRequest{IDs: []int{5, 6}})
+	require.NoError(t, err)
+	assert.Equal(t, &BulkResult{Affected: 1, IDs: []int{5}}, result)
+
+	invalid := []BulkDeleteRequest{
+		{},
+		{IDs: []int{1}, Filter: &TaskFilter{Status: StatusNew}},
+		{Filter: &TaskFilter{}},
+		{Filter: &TaskFilter{Status: "closed"}},
+		{IDs: []int{0}},
+	}
+	for _, req := range invalid {
+		_, err = user.DeleteTasks(ctx, req)
+		assert.True(t, errors.Is(err, ErrBadRequest), "%+v", req)
+	}
+	_, err = user.UpdateTasks(ctx, BulkUpdateRequest{IDs: []int{1}})
+	assert.True(t, errors.Is(err, ErrBadRequest), "пустой patch")
+}
+
+func TestClientTokenRefresh(t *testing.T) {
+	repository := mocks.NewRepository(t)
+	repository.On("GetTask", mock.Anything, 1).Return(&service.TaskResponse{ID: 1}, nil).Once()
+
+	// Первый токен подписан чужим секретом, после 401 клиент запрашивает новый
+	tokens := []string{signToken(t, "stale"), signToken(t, testSecret)}
+	calls := 0
+	tokenFunc := func(context.Context) (string, error) {
+		token := tokens[calls]
+		calls++
+		return token, nil
+	}
+
+	var sleeps []time.Duration
+	doer := &appDoer{app: newTestApp(t, repository, 0)}
+	c := newTestClient(t, doer, &sleeps, WithTokenFunc(tokenFunc))
+
+	task, err := c.GetTask(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, task.ID)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, 2, doer.requests)
+
+	// Без действующего токена - ErrUnauthorized после одной попытки обновления
+	c = newTestClient(t, &appDoer{app: newTestApp(t, repository, 0)}, &sleeps, WithToken("broken"))
+	_, err = c.GetTask(context.Background(), 1)
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+}
+
+func TestClientRetries(t *testing.T) {
+	unavailable := func(retryAfter string) *http.Response {
+		return response(http.StatusServiceUnavailable, http.Header{"Retry-After": {retryAfter}},
+			`{"status":"error","error":{"code":"SERVICE_UNAVAILABLE","desc":"down"}}`)
+	}
+
+	t.Run("Повтор после 503 с Retry-After", func(t *testing.T) {
+		repository := mocks.NewRepository(t)
+		repository.On("GetTask", mock.Anything, 1).Return(&service.TaskResponse{ID: 1}, nil).Once()
+
+		doer := &appDoer{app: newTestApp(t, repository, 0), fail: func(n int) *http.Response {
+			if n <= 2 {
+				return unavailable("2")
+			}
+			return nil
+		}}
+		var sleeps []time.Duration
+		c := newTestClient(t, doer, &sleeps, WithToken(signToken(t, testSecret)))
+
+		task, err := c.GetTask(context.Background(), 1)
+		require.NoError(t, err)
+		assert.Equal(t, 1, task.ID)
+		assert.Equal(t, []time.Duration{2 * time.Second, 2 * time.Second}, sleeps)
+	})
+
+	t.Run("Попытки закончились", func(t *testing.T) {
+		doer := &appDoer{fail: func(int) *http.Response { return unavailable("") }}
+		var sleeps []time.Duration
+		c := newTestClient(t, doer, &sleeps, WithRetryPolicy(RetryPolicy{MaxAttempts: 4, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}))
+
+		_, err := c.GetTask(context.Background(), 1)
+		assert.True(t, errors.Is(err, ErrServer))
+		assert.Equal(t, 4, doer.requests)
+		require.Len(t, sleeps, 3)
+		for i, d := range sleeps {
+			base := 100 * time.Millisecond << i
+			assert.GreaterOrEqual(t, d, base/2)
+			assert.LessOrEqual(t, d, base)
+		}
+	})
+
+	t.Run("Обрыв соединения при создании - 500 без повтора", func(t *testing.T) {
+		repository := mocks.NewRepository(t)
+		repository.On("CreateTask", mock.Anything, mock.Anything).
+			Return(0, fmt.Errorf("%w: connection reset by peer", service.ErrOutcomeUnknown)).Once()
+
+		doer := &appDoer{app: newTestApp(t, repository, 0)}
+		var sleeps []time.Duration
+		c := newTestClient(t, doer, &sleeps, WithToken(signToken(t, testSecret)))
+
+		_, err := c.CreateTask(context.Background(), TaskRequest{Title: "once"})
+		assert.True(t, errors.Is(err, ErrServer))
+		assert.True(t, errors.Is(err, ErrOutcomeUnknown))
+		var apiErr *APIError
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusInternalServerError, apiErr.StatusCode)
+		assert.Equal(t, "OUTCOME_UNKNOWN", apiErr.Code)
+		assert.Zero(t, apiErr.RetryAfter)
+		assert.Equal(t, 1, doer.requests)
+		assert.Empty(t, sleeps)
+	})
+
+	t.Run("Обрыв соединения при создании не повторяется и с ключом", func(t *testing.T) {
+		repository := mocks.NewRepository(t)
+		repository.On("CreateTask", mock.Anything, mock.Anything).
+			Return(0, fmt.Errorf("%w: connection reset by peer", service.ErrOutcomeUnknown)).Once()
+
+		doer := &appDoer{app: newTestApp(t, repository, 0)}
+		var sleeps []time.Duration
+		c := newTestClient(t, doer, &sleeps, WithToken(signToken(t, testSecret)), WithIdempotencyKeys())
+
+		_, err := c.CreateTask(context.Background(), TaskRequest{Title: "once"})
+		assert.True(t, errors.Is(err, ErrOutcomeUnknown))
+		assert.Equal(t, 1, doer.requests)
+		assert.Empty(t, sleeps)
+	})
+
+	t.Run("POST без ключа не повторяется после 503", func(t *testing.T) {
+		repository := mocks.NewRepository(t)
+		repository.On("CreateTask", mock.Anything, mock.Anything).
+			Return(0, &service.UnavailableError{Err: errors.New("breaker is open")}).Once()
+
+		doer := &appDoer{app: newTestApp(t, repository, 0)}
+		var sleeps []time.Duration
+		c := newTestClient(t, doer, &sleeps, WithToken(signToken(t, testSecret)))
+
+		_, err := c.CreateTask(context.Background(), TaskRequest{Title: "once"})
+		assert.True(t, errors.Is(err, ErrServer))
+		assert.Equal(t, 1, doer.requests)
+		assert.Empty(t, sleeps)
+	})
+
+	t.Run("Недоступное хранилище - 503 с Retry-After", func(t *testing.T) {
+		repository := mocks.NewRepository(t)
+		repository.On("GetTask", mock.Anything, 1).
+			Return(nil, &service.UnavailableError{RetryAfter: 1500 * time.Millisecond, Err: errors.New("breaker is open")}).Twice()
+
+		doer := &appDoer{app: newTestApp(t, repository, 0)}
+		var sleeps []time.Duration
+		c := newTestClient(t, doer, &sleeps, WithToken(signToken(t, testSecret)),
+			WithRetryPolicy(RetryPolicy{MaxAttempts: 2, BaseDelay: 100 * time.Millisecond, MaxDelay: 5 * time.Second}))
+
+		_, err := c.GetTask(context.Background(), 1)
+		assert.True(t, errors.Is(err, ErrServer))
+		var apiErr *APIError
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+		assert.Equal(t, "SERVICE_UNAVAILABLE", apiErr.Code)
+		assert.Equal(t, 2*time.Second, apiErr.RetryAfter)
+		assert.Equal(t, []time.Duration{2 * time.Second}, sleeps)
+	})
+
+	t.Run("Бюджет запроса исчерпан - 504", func(t *testing.T) {
+		withDeadline := func(budget time.Duration) any {
+			return mock.MatchedBy(func(ctx context.Context) bool {
+				deadline, ok := ctx.Deadline()
+				return ok && time.Until(deadline) > budget-10*time.Second && time.Until(deadline) <= budget
+			})
+		}
+
+		repository := mocks.NewRepository(t)
+		repository.On("GetTask", withDeadline(time.Minute), 1).Return(nil, context.DeadlineExceeded).Once()
+		repository.On("ListTasks", withDeadline(2*time.Minute), mock.Anything).
+			Return(nil, fmt.Errorf("%w: canceling statement due to statement timeout", service.ErrTimeout)).Once()
+
+		doer := &appDoer{app: newTestApp(t, repository, 0)}
+		var sleeps []time.Duration
+		c := newTestClient(t, doer, &sleeps, WithToken(signToken(t, testSecret)), WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+
+		_, err := c.GetTask(context.Background(), 1)
+		var apiErr *APIError
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusGatewayTimeout, apiErr.StatusCode)
+		assert.Equal(t, "DEADLINE_EXCEEDED", apiErr.Code)
+
+		_, err = c.ListTasks(context.Background(), ListOptions{})
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, "DEADLINE_EXCEEDED", apiErr.Code)
+	})
+
+	t.Run("Retry-After 429 не укладывается в дедлайн", func(t *testing.T) {
+		repository := mocks.NewRepository(t)
+		repository.On("GetTask", mock.Anything, 1).Return(&service.TaskResponse{ID: 1}, nil).Once()
+
+		doer := &appDoer{app: newTestApp(t, repository, 1)}
+		var sleeps []time.Duration
+		c := newTestClient(t, doer, &sleeps, WithToken(signToken(t, testSecret)))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_, err := c.GetTask(ctx, 1)
+		require.NoError(t, err)
+
+		// Лимит 1 запрос в минуту: второй получает 429, ждать минуту до дедлайна бессмысленно
+		_, err = c.GetTask(ctx, 1)
+		assert.True(t, errors.Is(err, ErrRateLimited))
+		var apiErr *APIError
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, "TOO_MANY_REQUESTS", apiErr.Code)
+		assert.Greater(t, apiErr.RetryAfter, 50*time.Second)
+		assert.Equal(t, 2, doer.requests)
+		assert.Empty(t, sleeps)
+	})
+}
+
+// lostDoer - транспорт, теряющий ответы первых lost запросов после их выполнения, как при обрыве соединения
+type lostDoer struct {
+	appDoer
+	lost int
+	keys []string
+}
+
+func (d *lostDoer) Do(req *http.Request) (*http.Response, error) {
+	d.keys = append(d.keys, req.Header.Get("Idempotency-Key"))
+	resp, err := d.appDoer.Do(req)
+	if err == nil && d.requests <= d.lost {
+		resp.Body.Close()
+		return nil, errors.New("connection reset by peer")
+	}
+	return resp, err
+}
+
+func TestClientIdempotencyKeys(t *testing.T) {
+	t.Run("Повтор после потерянного ответа не создаёт задачу дважды", func(t *testing.T) {
+		repository := mocks.NewRepository(t)
+		repository.On("CreateTask", mock.Anything, mock.Anything).Return(7, nil).Once()
+
+		doer := &lostDoer{appDoer: appDoer{app: newTestApp(t, repository, 0)}, lost: 1}
+		var sleeps []time.Duration
+		c := newTestClient(t, doer, &sleeps, WithToken(signToken(t, testSecret)), WithIdempotencyKeys())
+
+		id, err := c.CreateTask(context.Background(), TaskRequest{Title: "once"})
+		require.NoError(t, err)
+		assert.Equal(t, 7, id)
+		require.Len(t, doer.keys, 2)
+		assert.NotEmpty(t, doer.keys[0])
+		assert.Equal(t, doer.keys[0], doer.keys[1], "все попытки с одним ключом")
+		assert.Len(t, sleeps, 1)
+
+		// Новый вызов - новый ключ
+		repository.On("CreateTask", mock.Anything, mock.Anything).Return(8, nil).Once()
+		id, err = c.CreateTask(context.Background(), TaskRequest{Title: "once"})
+		require.NoError(t, err)
+		assert.Equal(t, 8, id)
+		assert.NotEqual(t, doer.keys[0], doer.keys[2])
+	})
+
+	t.Run("Без ключей POST не повторяется после сетевой ошибки", func(t *testing.T) {
+		repository := mocks.NewRepository(t)
+		repository.On("CreateTask", mock.Anything, mock.Anything).Return(7, nil).Once()
+
+		doer := &lostDoer{appDoer: appDoer{app: newTestApp(t, repository, 0)}, lost: 1}
+		var sleeps []time.Duration
+		c := newTestClient(t, doer, &sleeps, WithToken(signToken(t, testSecret)))
+
+		_, err := c.CreateTask(context.Background(), TaskRequest{Title: "once"})
+		assert.Error(t, err)
+		assert.Equal(t, []string{""}, doer.keys)
+		assert.Empty(t, sleeps)
+	})
+
+	t.Run("Повтор после 503 пакетного создания", func(t *testing.T) {
+		repository := mocks.NewRepository(t)
+		repository.On("CreateTasks", mock.Anything, mock.Anything).
+			Return(nil, &service.UnavailableError{Err: errors.New("breaker is open")}).Once()
+		repository.On("CreateTasks", mock.Anything, mock.Anything).Return([]int{1}, nil).Once()
+
+		doer := &appDoer{app: newTestApp(t, repository, 0)}
+		var sleeps []time.Duration
+		c := newTestClient(t, doer, &sleeps, WithToken(signToken(t, testSecret)), WithIdempotencyKeys())
+
+		result, err := c.CreateTasks(context.Background(), BatchRequest{Tasks: []TaskRequest{{Title: "a"}}})
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Created)
+		assert.Equal(t, 2, doer.requests)
+	})
+
+	t.Run("Повтор после 500 получает сохранённый ответ", func(t *testing.T) {
+		repository := mocks.NewRepository(t)
+		repository.On("CreateTasks", mock.Anything, mock.Anything).Return(nil, errors.New("connection reset")).Once()
+
+		doer := &appDoer{app: newTestApp(t, repository, 0)}
+		var sleeps []time.Duration
+		c := newTestClient(t, doer, &sleeps, WithToken(signToken(t, testSecret)), WithIdempotencyKeys())
+
+		// Запись могла выполниться: сервер не выполняет запрос повторно, а отдаёт тот же ответ
+		_, err := c.CreateTasks(context.Background(), BatchRequest{Tasks: []TaskRequest{{Title: "a"}}})
+		assert.True(t, errors.Is(err, ErrServer))
+		assert.Equal(t, 3, doer.requests)
+	})
+
+	t.Run("С ключом POST повторяется после 503", func(t *testing.T) {
+		repository := mocks.NewRepository(t)
+		repository.On("CreateTask", mock.Anything, mock.Anything).
+			Return(0, &service.UnavailableError{Err: errors.New("breaker is open")}).Once()
+		repository.On("CreateTask", mock.Anything, mock.Anything).Return(7, nil).Once()
+
+		doer := &appDoer{app: newTestApp(t, repository, 0)}
+		var sleeps []time.Duration
+		c := newTestClient(t, doer, &sleeps, WithToken(signToken(t, testSecret)), WithIdempotencyKeys())
+
+		id, err := c.CreateTask(context.Background(), TaskRequest{Title: "once"})
+		require.NoError(t, err)
+		assert.Equal(t, 7, id)
+		assert.Equal(t, 2, doer.requests)
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+
+	assert.Equal(t, 3*time.Second, parseRetryAfter("3", now))
+	assert.Equal(t, 90*time.Second, parseRetryAfter(now.Add(90*time.Second).Format(http.TimeFormat), now))
+	assert.Zero(t, parseRetryAfter("", now))
+	assert.Zero(t, parseRetryAfter("soon", now))
+	assert.Zero(t, parseRetryAfter(now.Add(-time.Minute).Format(http.TimeFormat), now))
+}
+
+func TestNewValidatesBaseURL(t *testing.T) {
+	_, err := New("localhost:8080")
+	assert.EqualError(t, err, `invalid base URL "localhost:8080": scheme must be http or https`)
+}
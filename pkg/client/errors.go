@@ -0,0 +1,70 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Ошибки для проверки через errors.Is, соответствуют HTTP статусу ответа
+var (
+	ErrBadRequest           = errors.New("bad request")
+	ErrUnauthorized         = errors.New("unauthorized")
+	ErrForbidden            = errors.New("forbidden")
+	ErrNotFound             = errors.New("not found")
+	ErrPayloadTooLarge      = errors.New("payload too large")
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	ErrUnprocessable        = errors.New("unprocessable")
+	ErrRateLimited          = errors.New("rate limited")
+	ErrServer               = errors.New("server error")
+	// ErrOutcomeUnknown - сервер потерял соединение с хранилищем после записи, запрос мог быть выполнен.
+	// Совпадает и с ErrServer. Клиент такой запрос не повторяет: проверьте результат перед повтором
+	ErrOutcomeUnknown = errors.New("outcome unknown")
+)
+
+// codeOutcomeUnknown - код ошибки API для ErrOutcomeUnknown
+const codeOutcomeUnknown = "OUTCOME_UNKNOWN"
+
+// APIError - ошибка из конверта apitypes.ErrorResponse
+type APIError struct {
+	StatusCode int
+	// Code - код ошибки API, например FIELD_INCORRECT или NOT_FOUND
+	Code string
+	Desc string
+	// RetryAfter - значение заголовка Retry-After, если сервер его прислал
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("simple-service: HTTP %d", e.StatusCode)
+	}
+	return fmt.Sprintf("simple-service: HTTP %d %s: %s", e.StatusCode, e.Code, e.Desc)
+}
+
+// Is - сопоставление с ErrNotFound и другими ошибками по HTTP статусу
+func (e *APIError) Is(target error) bool {
+	switch e.StatusCode {
+	case http.StatusBadRequest:
+		return target == ErrBadRequest
+	case http.StatusUnauthorized:
+		return target == ErrUnauthorized
+	case http.StatusForbidden:
+		return target == ErrForbidden
+	case http.StatusNotFound:
+		return target == ErrNotFound
+	case http.StatusRequestEntityTooLarge:
+		return target == ErrPayloadTooLarge
+	case http.StatusUnsupportedMediaType:
+		return target == ErrUnsupportedMediaType
+	case http.StatusUnprocessableEntity:
+		return target == ErrUnprocessable
+	case http.StatusTooManyRequests:
+		return target == ErrRateLimited
+	}
+	if target == ErrOutcomeUnknown {
+		return e.Code == codeOutcomeUnknown
+	}
+	return e.StatusCode >= 500 && target == ErrServer
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy - повторы запросов при 5xx, 429 и сетевых ошибках.
+// Задержка растёт экспоненциально от BaseDelay до MaxDelay со случайным разбросом,
+// Retry-After от сервера имеет приоритет
+type RetryPolicy struct {
+	// MaxAttempts - всего попыток, включая первую; 1 отключает повторы
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy - политика повторов по умолчанию
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   100 * time.Millisecond,
+	MaxDelay:    5 * time.Second,
+}
+
+// retryable - стоит ли повторять запрос с таким статусом. Запросы, меняющие данные, без ключа
+// идемпотентности повторяются только после 429: сервер отклонил их до обработки. 503 не гарантирует,
+// что запрос не выполнен - соединение с БД могло оборваться после записи, и задача создалась бы дважды.
+// С ключом повторяется и 409: предыдущая попытка ещё выполняется
+func retryable(method string, idempotent bool, status int) bool {
+	switch status {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return idempotent || method == http.MethodGet || method == http.MethodHead
+	case http.StatusConflict:
+		return idempotent
+	}
+	return false
+}
+
+// parseRetryAfter - Retry-After в секундах или в виде HTTP даты
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// sleep - ожидание с учётом отмены контекста
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
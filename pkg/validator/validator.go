@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"regexp"
+	"strings"
 
 	"github.com/go-playground/validator"
 )
@@ -19,6 +20,7 @@ const (
 	ErrFieldBelowMinLen   = "Field is below minimum length"
 	ErrFieldExceedsMaxVal = "Field exceeds maximum value"
 	ErrFieldBelowMinVal   = "Field is below minimum value"
+	ErrFieldNotOneOf      = "Field must be one of"
 	ErrUnknownValidation  = "Unknown validation error"
 )
 
@@ -91,6 +93,8 @@ func parseValidationErrors(err error) error {
 		validationErrorDescription = ErrFieldExceedsMaxVal
 	case "gt", "gte":
 		validationErrorDescription = ErrFieldBelowMinVal
+	case "oneof":
+		validationErrorDescription = ErrFieldNotOneOf + " (" + strings.ReplaceAll(validationError.Param(), " ", ", ") + ")"
 	default:
 		validationErrorDescription = ErrUnknownValidation
 	}
@@ -14,6 +14,7 @@ type TestStruct struct {
 	MinField      string `validate:"min=3"`
 	LtField       int    `validate:"lt=10"`
 	GteField      int    `validate:"gte=5"`
+	OneOfField    string `validate:"omitempty,oneof=new done"`
 }
 
 func TestValidate(t *testing.T) {
@@ -65,6 +66,12 @@ func TestValidate(t *testing.T) {
 			wantErr:    true,
 			wantErrMsg: ErrFieldBelowMinVal + " for field: GteField",
 		},
+		{
+			name:       "Field not one of allowed values",
+			input:      TestStruct{RequiredField: "value", TagField: "#tag", MaxField: "value", MinField: "val", LtField: 5, GteField: 5, OneOfField: "closed"},
+			wantErr:    true,
+			wantErrMsg: ErrFieldNotOneOf + " (new, done) for field: OneOfField",
+		},
 	}
 
 	for _, tt := range tests {